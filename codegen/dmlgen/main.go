@@ -0,0 +1,107 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command dmlgen generates Go and Protocol Buffer source code for database
+// tables. The configuration gets read from a YAML or TOML file. The table
+// schemas get loaded either from the database via the DSN or from an offline
+// CSV dump of information_schema.COLUMNS.
+//
+//	$ dmlgen -config dmlgen.yaml
+//
+// Example YAML configuration:
+//
+//	package: catalog
+//	schema_csv: testdata/INFORMATION_SCHEMA.COLUMNS.csv
+//	tables:
+//	  - catalog_product_*
+//	  - core_config_data
+//	table_options:
+//	  catalog_product_*:
+//	    encoders: [text, protobuf]
+//	    struct_tags: [json]
+//	  core_config_data:
+//	    repository: true
+//	    column_aliases:
+//	      path: [storage_location]
+//	output_go: catalog_gen.go
+//	output_proto: catalog_gen.proto
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmlgen"
+)
+
+var (
+	flagConfig = flag.String("config", "dmlgen.yaml", "path to the YAML or TOML configuration file")
+	flagDSN    = flag.String("dsn", "", "overwrites the DSN of the configuration file")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(context.Background()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %+v\n", err)
+		os.Exit(1)
+	}
+}
+
+// loadConfig loads the configuration file, applies the flag overrides and
+// validates the result.
+func loadConfig(file, dsn string) (*dmlgen.Config, error) {
+	cfg, err := dmlgen.LoadConfig(file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if dsn != "" {
+		cfg.DSN = dsn
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return cfg, nil
+}
+
+func run(ctx context.Context) error {
+	cfg, err := loadConfig(*flagConfig, *flagDSN)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var db dml.Querier
+	if cfg.SchemaCSV == "" {
+		dbc, err := dml.NewConnPool(dml.WithDSN(cfg.DSN))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer dbc.Close()
+		db = dbc.DB
+	}
+
+	ts, err := cfg.NewTables(ctx, db)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(cfg.WriteFiles(ts))
+}
@@ -0,0 +1,46 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dmlgen")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "dmlgen.yaml")
+	require.NoError(t, ioutil.WriteFile(file, []byte("package: catalog\noutput_go: catalog_gen.go\n"), 0644))
+
+	t.Run("flag dsn", func(t *testing.T) {
+		const dsn = "magento:magento@tcp(localhost:3306)/magento?parseTime=true"
+		cfg, err := loadConfig(file, dsn)
+		require.NoError(t, err)
+		assert.Exactly(t, dsn, cfg.DSN)
+	})
+	t.Run("no dsn", func(t *testing.T) {
+		cfg, err := loadConfig(file, "")
+		assert.Nil(t, cfg)
+		assert.True(t, errors.Empty.Match(err), "%+v", err)
+	})
+}
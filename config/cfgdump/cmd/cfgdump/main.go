@@ -0,0 +1,339 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command cfgdump exports configuration values from a storage file into
+// Magento's config.php layout, YAML or JSON and imports such files.
+//
+// The storage file gets opened by its extension: .db and .bolt with package
+// config/storage/boltdb, .yaml, .yml, .toml and .json with package
+// config/storage/cfgfile. The format of the dump file gets detected by its
+// extension: .php, .yaml, .yml or .json. Instead of a storage file the flag
+// -dsn opens the table core_config_data of a MySQL database with package
+// config/storage/ccd. The flag -table sets a custom table name. The DSN must
+// contain the parameter parseTime=true.
+//
+//	$ cfgdump dump -storage staging.db -sections web,general -scopes default,websites/1 \
+//		-codes websites/1=base -sensitive payment/braintree/private_key -o config.php
+//	$ cfgdump import -storage production.db -file config.php -codes websites/1=base -dry-run
+//	$ cfgdump import -storage production.db -file config.php -codes websites/1=base
+//	$ cfgdump import -dsn 'magento:secret@tcp(localhost:3306)/magento?parseTime=true' \
+//		-table mage_core_config_data -file config.php -codes websites/1=base
+//
+// Import prints the changes as a diff. With -dry-run nothing gets written.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgdump"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/boltdb"
+	"github.com/corestoreio/pkg/config/storage/ccd"
+	"github.com/corestoreio/pkg/config/storage/cfgfile"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s dump -storage FILE|-dsn DSN [options]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s import -storage FILE|-dsn DSN -file FILE [-dry-run] [options]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Run %s dump -h or %s import -h for all options.\n", os.Args[0], os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "dump":
+		err = runDump(os.Args[2:])
+	case "import":
+		err = runImport(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %+v\n", err)
+		os.Exit(1)
+	}
+}
+
+// filterFlags contains the flags shared by both sub commands.
+type filterFlags struct {
+	storage   string
+	dsn       string
+	table     string
+	format    string
+	sections  string
+	scopes    string
+	sensitive string
+	codes     string
+}
+
+func (ff *filterFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&ff.storage, "storage", "", "storage file: .db, .bolt, .yaml, .yml, .toml or .json")
+	fs.StringVar(&ff.dsn, "dsn", "", "MySQL data source name with parseTime=true, used instead of -storage")
+	fs.StringVar(&ff.table, "table", "", "name of the table core_config_data, used with -dsn. Default "+ccd.DefaultTable)
+	fs.StringVar(&ff.format, "format", "", "format of the dump file: php, yaml or json. Default detected by the file extension or php")
+	fs.StringVar(&ff.sections, "sections", "", "comma separated list of sections, e.g. web,general")
+	fs.StringVar(&ff.scopes, "scopes", "", "comma separated list of scopes, e.g. default,websites/1,stores/2")
+	fs.StringVar(&ff.sensitive, "sensitive", "", "comma separated list of routes to exclude, e.g. payment/paypal/api_key")
+	fs.StringVar(&ff.codes, "codes", "", "comma separated list of website and store codes, e.g. websites/1=base,stores/1=default")
+}
+
+func (ff *filterFlags) options() ([]cfgdump.Option, error) {
+	var opts []cfgdump.Option
+	if ff.sections != "" {
+		opts = append(opts, cfgdump.WithSections(split(ff.sections)...))
+	}
+	if ff.scopes != "" {
+		var ids []scope.TypeID
+		for _, s := range split(ff.scopes) {
+			id, err := parseScope(s)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			ids = append(ids, id)
+		}
+		opts = append(opts, cfgdump.WithScopes(ids...))
+	}
+	if ff.sensitive != "" {
+		var routes []cfgpath.Route
+		for _, r := range split(ff.sensitive) {
+			routes = append(routes, cfgpath.MakeRoute(r))
+		}
+		opts = append(opts, cfgdump.WithSensitive(routes...))
+	}
+	if ff.codes != "" {
+		codes := make(map[scope.TypeID]string)
+		for _, c := range split(ff.codes) {
+			pos := strings.IndexByte(c, '=')
+			if pos < 0 {
+				return nil, errors.NewNotValidf("[cfgdump] Code %q must have the format scope/id=code", c)
+			}
+			id, err := parseScope(c[:pos])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			codes[id] = c[pos+1:]
+		}
+		opts = append(opts, cfgdump.WithScopeCodes(codes))
+	}
+	return opts, nil
+}
+
+// formatOf returns the format flag or detects the format by the file
+// extension.
+func (ff *filterFlags) formatOf(filename string) string {
+	if ff.format != "" {
+		return ff.format
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return cfgdump.FormatYAML
+	case ".json":
+		return cfgdump.FormatJSON
+	}
+	return cfgdump.FormatPHP
+}
+
+func split(s string) []string {
+	var ret []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// parseScope parses default, websites/ID or stores/ID.
+func parseScope(s string) (scope.TypeID, error) {
+	if s == scope.StrDefault.String() {
+		return scope.DefaultTypeID, nil
+	}
+	pos := strings.IndexByte(s, '/')
+	if pos < 0 || !scope.Valid(s[:pos]) {
+		return 0, errors.NewNotValidf("[cfgdump] Scope %q must be default, websites/ID or stores/ID", s)
+	}
+	id, err := strconv.ParseInt(s[pos+1:], 10, 64)
+	if err != nil {
+		return 0, errors.NewNotValidf("[cfgdump] Scope %q contains an invalid ID: %s", s, err)
+	}
+	return scope.FromString(s[:pos]).Pack(id), nil
+}
+
+type storager interface {
+	config.Storager
+	io.Closer
+}
+
+// openStorage opens the database if flag -dsn has been set, otherwise the
+// storage file.
+func (ff *filterFlags) openStorage() (storager, error) {
+	if ff.dsn == "" {
+		if ff.table != "" {
+			return nil, errors.NewNotValidf("[cfgdump] Flag -table requires flag -dsn")
+		}
+		return openStorage(ff.storage)
+	}
+	if ff.storage != "" {
+		return nil, errors.NewNotValidf("[cfgdump] Flags -storage and -dsn cannot be used together")
+	}
+	return openDBStorage(ff.dsn, ff.table)
+}
+
+// dbStorage closes the connection pool of a ccd.DBStorage.
+type dbStorage struct {
+	*ccd.DBStorage
+	db *dml.ConnPool
+}
+
+func (s dbStorage) Close() error {
+	return s.db.Close()
+}
+
+// openDBStorage loads the table core_config_data, or tableName if not empty.
+// All changes get written to the database.
+func openDBStorage(dsn, tableName string) (storager, error) {
+	db, err := dml.NewConnPool(dml.WithDSN(dsn))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var opts []ccd.Option
+	if tableName != "" {
+		opts = append(opts, ccd.WithTable(tableName))
+	}
+	s, err := ccd.NewDBStorage(context.Background(), db, opts...)
+	if err != nil {
+		db.Close()
+		return nil, errors.WithStack(err)
+	}
+	return dbStorage{DBStorage: s, db: db}, nil
+}
+
+// openStorage opens the storage file by its extension. Cfgfile storage writes
+// all changes back to the file.
+func openStorage(filename string) (storager, error) {
+	if filename == "" {
+		return nil, errors.NewEmptyf("[cfgdump] Flag -storage or -dsn cannot be empty")
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".db", ".bolt":
+		s, err := boltdb.OpenFile(filename, 0600)
+		return s, errors.WithStack(err)
+	}
+	s, err := cfgfile.New(filename, cfgfile.WithWriteBack())
+	return s, errors.WithStack(err)
+}
+
+func runDump(args []string) (err error) {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	var ff filterFlags
+	ff.register(fs)
+	output := fs.String("o", "", "output file. Default stdout")
+	fs.Parse(args)
+
+	opts, err := ff.options()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	s, err := ff.openStorage()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		if cErr := s.Close(); err == nil {
+			err = errors.WithStack(cErr)
+		}
+	}()
+
+	kvs, err := cfgdump.Dump(s, opts...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if *output == "" {
+		return cfgdump.Encode(os.Stdout, ff.formatOf(""), kvs, opts...)
+	}
+	f, err := os.Create(*output)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := cfgdump.Encode(f, ff.formatOf(*output), kvs, opts...); err != nil {
+		f.Close()
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(f.Close())
+}
+
+func runImport(args []string) (err error) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	var ff filterFlags
+	ff.register(fs)
+	file := fs.String("file", "", "dump file to import")
+	dryRun := fs.Bool("dry-run", false, "prints the changes without writing them")
+	fs.Parse(args)
+
+	opts, err := ff.options()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := os.Open(*file)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	kvs, err := cfgdump.Decode(f, ff.formatOf(*file), opts...)
+	f.Close()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	s, err := ff.openStorage()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		if cErr := s.Close(); err == nil {
+			err = errors.WithStack(cErr)
+		}
+	}()
+
+	var cs cfgdump.Changes
+	if *dryRun {
+		cs, err = cfgdump.Diff(s, kvs)
+	} else {
+		cs, err = cfgdump.Import(s, kvs)
+	}
+	fmt.Print(cs.String())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fmt.Fprintf(os.Stderr, "%d changes\n", len(cs))
+	return nil
+}
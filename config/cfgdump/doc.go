@@ -0,0 +1,48 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfgdump exports configuration values from a config.Storager into a
+// file and imports such a file into a config.Storager.
+//
+// Three formats are supported. FormatPHP writes the `system` array of
+// Magento's `app/etc/config.php` as created by `bin/magento app:config:dump`.
+// FormatYAML and FormatJSON write the scope organized document known from
+// package config/storage/cfgfile. Website and store IDs get written as keys
+// unless WithScopeCodes maps them to codes, like Magento does.
+//
+//		<?php
+//		return [
+//		    'system' => [
+//		        'default' => [
+//		            'web' => [
+//		                'secure' => [
+//		                    'base_url' => 'https://www.example.com/',
+//		                ],
+//		            ],
+//		        ],
+//		        'websites' => [
+//		            'base' => [
+//		                ...
+//
+// Dump and Decode filter the values by section and scope. Sensitive paths, for
+// example all paths of a cfgmodel.Obscure type, get excluded. Such values must
+// be promoted via a different channel.
+//
+// Diff compares decoded values with a config.Storager and acts as a dry-run.
+// Import writes only the changed values and is therefore idempotent. Paths
+// missing in the file do not get deleted. A null value deletes a path.
+//
+// Command cfgdump in the sub directory cmd wraps the functions for file based
+// storage engines.
+package cfgdump
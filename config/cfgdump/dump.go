@@ -0,0 +1,299 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgdump
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/corestoreio/pkg/util/conv"
+)
+
+// Option applies options to the Dump, Encode and Decode functions.
+type Option func(*options) error
+
+type options struct {
+	sections  map[string]bool
+	scopes    map[scope.TypeID]bool
+	sensitive map[string]bool
+	// codes maps a website or store to its code and ids the other way round.
+	// The key of ids is the scope string followed by the code, for example
+	// "websites/base".
+	codes map[scope.TypeID]string
+	ids   map[string]scope.TypeID
+}
+
+func newOptions(opts []Option) (*options, error) {
+	o := &options{
+		sections:  make(map[string]bool),
+		scopes:    make(map[scope.TypeID]bool),
+		sensitive: make(map[string]bool),
+		codes:     make(map[scope.TypeID]string),
+		ids:       make(map[string]scope.TypeID),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			if err := opt(o); err != nil {
+				return nil, errors.Wrap(err, "[cfgdump] Option")
+			}
+		}
+	}
+	return o, nil
+}
+
+// skip reports whether a path gets filtered out.
+func (o *options) skip(p cfgpath.Path) bool {
+	if o.sensitive[p.Route.Data] {
+		return true
+	}
+	if len(o.scopes) > 0 && !o.scopes[p.ScopeID] {
+		return true
+	}
+	if len(o.sections) > 0 {
+		sec, err := p.Part(1)
+		if err != nil || !o.sections[sec.Data] {
+			return true
+		}
+	}
+	return false
+}
+
+// WithSections limits the values to the provided sections, the first part of
+// a route, for example `web` or `general`.
+func WithSections(sections ...string) Option {
+	return func(o *options) error {
+		for _, s := range sections {
+			o.sections[s] = true
+		}
+		return nil
+	}
+}
+
+// WithScopes limits the values to the provided scopes, for example
+// scope.DefaultTypeID or scope.Store.Pack(2).
+func WithScopes(scopes ...scope.TypeID) Option {
+	return func(o *options) error {
+		for _, s := range scopes {
+			o.scopes[s] = true
+		}
+		return nil
+	}
+}
+
+// WithSensitive excludes the routes, for example `payment/paypal/api_key`,
+// in all scopes.
+func WithSensitive(routes ...cfgpath.Route) Option {
+	return func(o *options) error {
+		for _, r := range routes {
+			o.sensitive[r.Data] = true
+		}
+		return nil
+	}
+}
+
+// WithObscure excludes the routes of the encrypted configuration values.
+func WithObscure(obs ...cfgmodel.Obscure) Option {
+	return func(o *options) error {
+		for _, ob := range obs {
+			o.sensitive[ob.Route().Data] = true
+		}
+		return nil
+	}
+}
+
+// WithSensitiveFields excludes the routes of all fields with type
+// element.TypeObscure.
+func WithSensitiveFields(ss element.SectionSlice) Option {
+	return func(o *options) error {
+		for _, s := range ss {
+			for _, g := range s.Groups {
+				for _, f := range g.Fields {
+					if f.Type != element.TypeObscure {
+						continue
+					}
+					r, err := f.Route(s.ID, g.ID)
+					if err != nil {
+						return errors.Wrapf(err, "[cfgdump] Field.Route. Section %q Group %q", s.ID, g.ID)
+					}
+					o.sensitive[r.Data] = true
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// WithScopeCodes maps website and store IDs to their codes, for example
+// scope.Website.Pack(1) to `base`. Encode writes the codes instead of the IDs
+// and Decode resolves the codes to the IDs. Codes must be unique per scope.
+func WithScopeCodes(codes map[scope.TypeID]string) Option {
+	return func(o *options) error {
+		for id, code := range codes {
+			scp, _ := id.Unpack()
+			if scp != scope.Website && scp != scope.Store {
+				return errors.NewNotSupportedf("[cfgdump] Scope %s cannot have a code", scp)
+			}
+			if code == "" {
+				return errors.NewEmptyf("[cfgdump] Code of %s cannot be empty", id)
+			}
+			key := scp.StrType() + "/" + code
+			if prev, ok := o.ids[key]; ok && prev != id {
+				return errors.NewAlreadyExistsf("[cfgdump] Code %q already used by %s", code, prev)
+			}
+			o.codes[id] = code
+			o.ids[key] = id
+		}
+		return nil
+	}
+}
+
+// KeyValue contains a configuration path and its value.
+type KeyValue struct {
+	Path  cfgpath.Path
+	Value interface{}
+}
+
+// KeyValues a list of KeyValue.
+type KeyValues []KeyValue
+
+// Sort sorts the values by route and scope.
+func (kvs KeyValues) Sort() {
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i].Path.Less(kvs[j].Path) })
+}
+
+// Dump loads all values from the Storager which match the filter options.
+// The returned values are sorted.
+func Dump(s config.Storager, opts ...Option) (KeyValues, error) {
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	keys, err := s.AllKeys()
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgdump] Storager.AllKeys")
+	}
+	kvs := make(KeyValues, 0, len(keys))
+	for _, p := range keys {
+		if o.skip(p) {
+			continue
+		}
+		v, err := s.Get(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgdump] Storager.Get %q", p)
+		}
+		kvs = append(kvs, KeyValue{Path: p, Value: v})
+	}
+	kvs.Sort()
+	return kvs, nil
+}
+
+// Change describes the difference between a value in the Storager and a value
+// to import.
+type Change struct {
+	Path cfgpath.Path
+	// Exists is false if the path does not exist in the Storager.
+	Exists bool
+	Old    interface{}
+	// New contains the value to import. Nil deletes the path.
+	New interface{}
+}
+
+// Changes a list of Change.
+type Changes []Change
+
+// String returns a diff with one line for each old and new value.
+func (cs Changes) String() string {
+	var buf bytes.Buffer
+	for _, c := range cs {
+		if c.Exists {
+			fmt.Fprintf(&buf, "- %s: %s\n", c.Path, formatValue(c.Old))
+		}
+		if c.New != nil {
+			fmt.Fprintf(&buf, "+ %s: %s\n", c.Path, formatValue(c.New))
+		}
+	}
+	return buf.String()
+}
+
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+	s, err := conv.ToStringE(v)
+	if err != nil {
+		return fmt.Sprintf("%#v", v)
+	}
+	return fmt.Sprintf("%q", s)
+}
+
+// Diff compares the values with the Storager and returns the values which
+// would be changed by Import. Values are equal if their string
+// representations are equal.
+func Diff(s config.Storager, kvs KeyValues) (Changes, error) {
+	var cs Changes
+	for _, kv := range kvs {
+		old, err := s.Get(kv.Path)
+		exists := err == nil && old != nil
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "[cfgdump] Storager.Get %q", kv.Path)
+		}
+		equal, err := isEqual(exists, old, kv.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgdump] Path %q", kv.Path)
+		}
+		if !equal {
+			cs = append(cs, Change{Path: kv.Path, Exists: exists, Old: old, New: kv.Value})
+		}
+	}
+	return cs, nil
+}
+
+func isEqual(exists bool, old, new interface{}) (bool, error) {
+	if !exists || new == nil {
+		return !exists && new == nil, nil
+	}
+	o, err := conv.ToStringE(old)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	n, err := conv.ToStringE(new)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return o == n, nil
+}
+
+// Import writes all changed values into the Storager and returns the applied
+// changes. Running Import twice with the same values writes nothing the second
+// time.
+func Import(s config.Storager, kvs KeyValues) (Changes, error) {
+	cs, err := Diff(s, kvs)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for i, c := range cs {
+		if err := s.Set(c.Path, c.New); err != nil {
+			return cs[:i], errors.Wrapf(err, "[cfgdump] Storager.Set %q", c.Path)
+		}
+	}
+	return cs, nil
+}
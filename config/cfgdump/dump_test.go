@@ -0,0 +1,165 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgdump_test
+
+import (
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgdump"
+	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStorage(t *testing.T) config.Storager {
+	s := config.NewInMemoryStore()
+	for fq, v := range map[string]interface{}{
+		"default/0/web/secure/base_url":            "https://www.example.com/",
+		"websites/1/web/secure/base_url":           "https://shop.example.com/",
+		"stores/2/web/secure/base_url":             "https://shop.example.ch/",
+		"default/0/general/locale/code":            "en_US",
+		"stores/2/general/locale/code":             "de_CH",
+		"default/0/payment/braintree/private_key":  "0:3:secret",
+		"websites/1/payment/braintree/private_key": "0:3:secret2",
+		"default/0/catalog/frontend/list_per_page": 12,
+	} {
+		p, err := cfgpath.SplitFQ(fq)
+		require.NoError(t, err)
+		require.NoError(t, s.Set(p, v))
+	}
+	return s
+}
+
+func fqs(kvs cfgdump.KeyValues) []string {
+	ret := make([]string, len(kvs))
+	for i, kv := range kvs {
+		ret[i] = kv.Path.String()
+	}
+	return ret
+}
+
+func TestDump(t *testing.T) {
+	t.Run("all", func(t *testing.T) {
+		kvs, err := cfgdump.Dump(newStorage(t))
+		require.NoError(t, err)
+		assert.Exactly(t, []string{
+			"default/0/catalog/frontend/list_per_page",
+			"default/0/general/locale/code",
+			"stores/2/general/locale/code",
+			"default/0/payment/braintree/private_key",
+			"websites/1/payment/braintree/private_key",
+			"default/0/web/secure/base_url",
+			"websites/1/web/secure/base_url",
+			"stores/2/web/secure/base_url",
+		}, fqs(kvs))
+		assert.Exactly(t, 12, kvs[0].Value)
+	})
+
+	t.Run("sections, scopes and sensitive", func(t *testing.T) {
+		kvs, err := cfgdump.Dump(newStorage(t),
+			cfgdump.WithSections("web", "payment"),
+			cfgdump.WithScopes(scope.DefaultTypeID, scope.Website.Pack(1)),
+			cfgdump.WithObscure(cfgmodel.NewObscure("payment/braintree/private_key")),
+		)
+		require.NoError(t, err)
+		assert.Exactly(t, []string{
+			"default/0/web/secure/base_url",
+			"websites/1/web/secure/base_url",
+		}, fqs(kvs))
+	})
+
+	t.Run("sensitive fields", func(t *testing.T) {
+		ss := element.MustNewConfiguration(
+			element.Section{
+				ID: cfgpath.NewRoute("payment"),
+				Groups: element.NewGroupSlice(
+					element.Group{
+						ID: cfgpath.NewRoute("braintree"),
+						Fields: element.NewFieldSlice(
+							element.Field{ID: cfgpath.NewRoute("private_key"), Type: element.TypeObscure},
+							element.Field{ID: cfgpath.NewRoute("title"), Type: element.TypeText},
+						),
+					},
+				),
+			},
+		)
+		kvs, err := cfgdump.Dump(newStorage(t), cfgdump.WithSections("payment"), cfgdump.WithSensitiveFields(ss))
+		require.NoError(t, err)
+		assert.Empty(t, kvs)
+	})
+}
+
+func TestWithScopeCodes(t *testing.T) {
+	_, err := cfgdump.Dump(newStorage(t), cfgdump.WithScopeCodes(map[scope.TypeID]string{
+		scope.DefaultTypeID: "admin",
+	}))
+	assert.True(t, errors.IsNotSupported(err), "%+v", err)
+
+	_, err = cfgdump.Dump(newStorage(t), cfgdump.WithScopeCodes(map[scope.TypeID]string{
+		scope.Store.Pack(1): "",
+	}))
+	assert.True(t, errors.IsEmpty(err), "%+v", err)
+
+	_, err = cfgdump.Dump(newStorage(t),
+		cfgdump.WithScopeCodes(map[scope.TypeID]string{scope.Store.Pack(1): "default"}),
+		cfgdump.WithScopeCodes(map[scope.TypeID]string{scope.Store.Pack(2): "default"}),
+	)
+	assert.True(t, errors.IsAlreadyExists(err), "%+v", err)
+}
+
+func TestDiff_Import(t *testing.T) {
+	dst := config.NewInMemoryStore()
+	pURL := cfgpath.MustMakeByString("web/secure/base_url").BindStore(2)
+	pCode := cfgpath.MustMakeByString("general/locale/code")
+	pPage := cfgpath.MustMakeByString("catalog/frontend/list_per_page")
+	require.NoError(t, dst.Set(pURL, "https://old.example.ch/"))
+	require.NoError(t, dst.Set(pCode, "en_US"))
+	require.NoError(t, dst.Set(pPage, "12"))
+
+	kvs := cfgdump.KeyValues{
+		{Path: pURL, Value: "https://shop.example.ch/"},
+		{Path: pCode, Value: nil},
+		{Path: pPage, Value: 12},
+		{Path: cfgpath.MustMakeByString("general/locale/timezone"), Value: "Europe/Zurich"},
+		{Path: cfgpath.MustMakeByString("general/locale/weight_unit"), Value: nil},
+	}
+
+	cs, err := cfgdump.Diff(dst, kvs)
+	require.NoError(t, err)
+	assert.Exactly(t, "- stores/2/web/secure/base_url: \"https://old.example.ch/\"\n"+
+		"+ stores/2/web/secure/base_url: \"https://shop.example.ch/\"\n"+
+		"- default/0/general/locale/code: \"en_US\"\n"+
+		"+ default/0/general/locale/timezone: \"Europe/Zurich\"\n", cs.String())
+
+	v, err := dst.Get(pURL)
+	require.NoError(t, err)
+	assert.Exactly(t, "https://old.example.ch/", v, "Diff must not write")
+
+	cs, err = cfgdump.Import(dst, kvs)
+	require.NoError(t, err)
+	assert.Len(t, cs, 3)
+	v, err = dst.Get(pURL)
+	require.NoError(t, err)
+	assert.Exactly(t, "https://shop.example.ch/", v)
+
+	cs, err = cfgdump.Import(dst, kvs)
+	require.NoError(t, err)
+	assert.Empty(t, cs, "Import must be idempotent")
+}
@@ -0,0 +1,245 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgdump
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/store/scope"
+	"gopkg.in/yaml.v2"
+)
+
+// Supported file formats.
+const (
+	FormatPHP  = "php"
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// phpSystemKey defines the key in Magento's config.php which contains the
+// configuration values.
+const phpSystemKey = "system"
+
+// Encode writes the values in the format to w. Sensitive paths and paths not
+// matching the filter options get skipped.
+func Encode(w io.Writer, format string, kvs KeyValues, opts ...Option) error {
+	o, err := newOptions(opts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	doc := make(map[string]interface{}, 3)
+	for _, kv := range kvs {
+		if o.skip(kv.Path) {
+			continue
+		}
+		if err := o.insert(doc, kv); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	switch format {
+	case FormatPHP:
+		err = encodePHP(w, map[string]interface{}{phpSystemKey: doc})
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(doc)
+	case FormatYAML:
+		var data []byte
+		if data, err = yaml.Marshal(doc); err == nil {
+			_, err = w.Write(data)
+		}
+	default:
+		err = errors.NewNotSupportedf("[cfgdump] Format %q not supported", format)
+	}
+	return errors.Wrapf(err, "[cfgdump] Encode %q", format)
+}
+
+// insert adds the value to the nested document.
+func (o *options) insert(doc map[string]interface{}, kv KeyValue) error {
+	node := doc
+	scp, id := kv.Path.ScopeID.Unpack()
+	switch scp {
+	case scope.Website, scope.Store:
+		code, ok := o.codes[kv.Path.ScopeID]
+		if !ok {
+			code = strconv.FormatInt(id, 10)
+		}
+		node = child(child(node, scp.StrType()), code)
+	default:
+		node = child(node, scope.StrDefault.String())
+	}
+
+	parts := strings.Split(kv.Path.Route.Data, "/")
+	for _, part := range parts[:len(parts)-1] {
+		if node = child(node, part); node == nil {
+			return errors.NewNotValidf("[cfgdump] Path %q collides with a value", kv.Path)
+		}
+	}
+	last := parts[len(parts)-1]
+	if _, ok := node[last]; ok {
+		return errors.NewNotValidf("[cfgdump] Path %q collides with another path", kv.Path)
+	}
+	v := kv.Value
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	node[last] = v
+	return nil
+}
+
+// child returns the nested map of key and creates it if it does not exists.
+// Returns nil if key contains already a value.
+func child(m map[string]interface{}, key string) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	v, ok := m[key]
+	if !ok {
+		c := make(map[string]interface{})
+		m[key] = c
+		return c
+	}
+	c, _ := v.(map[string]interface{})
+	return c
+}
+
+// Decode reads the values in the format from r. The returned values are
+// sorted. Sensitive paths and paths not matching the filter options get
+// skipped.
+func Decode(r io.Reader, format string, opts ...Option) (KeyValues, error) {
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgdump] Decode.ReadAll")
+	}
+
+	var doc interface{}
+	switch format {
+	case FormatPHP:
+		var php interface{}
+		if php, err = decodePHP(data); err == nil {
+			m, ok := php.(map[string]interface{})
+			if !ok {
+				return nil, errors.NewNotValidf("[cfgdump] PHP file must return an array but got %T", php)
+			}
+			doc = m[phpSystemKey]
+		}
+	case FormatJSON:
+		err = json.Unmarshal(data, &doc)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &doc)
+	default:
+		err = errors.NewNotSupportedf("[cfgdump] Format %q not supported", format)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "[cfgdump] Decode %q", format)
+	}
+
+	var kvs KeyValues
+	for scp, v := range toMap(doc) {
+		switch scp {
+		case scope.StrDefault.String():
+			if kvs, err = o.flatten(kvs, scope.DefaultTypeID, "", v); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		case scope.StrWebsites.String(), scope.StrStores.String():
+			for code, v := range toMap(v) {
+				id, err := o.scopeID(scp, code)
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				if kvs, err = o.flatten(kvs, id, "", v); err != nil {
+					return nil, errors.WithStack(err)
+				}
+			}
+		default:
+			return nil, errors.NewNotSupportedf("[cfgdump] Unknown scope %q", scp)
+		}
+	}
+	kvs.Sort()
+	return kvs, nil
+}
+
+// scopeID resolves the code or the ID of a website or store.
+func (o *options) scopeID(scp, code string) (scope.TypeID, error) {
+	if id, ok := o.ids[scp+"/"+code]; ok {
+		return id, nil
+	}
+	id, err := strconv.ParseInt(code, 10, 64)
+	if err != nil {
+		return 0, errors.NewNotFoundf("[cfgdump] Unknown code %q in scope %q", code, scp)
+	}
+	return scope.FromString(scp).Pack(id), nil
+}
+
+// flatten joins the keys of the nested maps to routes and appends the values.
+func (o *options) flatten(kvs KeyValues, id scope.TypeID, route string, v interface{}) (KeyValues, error) {
+	if m := toMap(v); m != nil {
+		var err error
+		for k, v := range m {
+			if kvs, err = o.flatten(kvs, id, joinRoute(route, k), v); err != nil {
+				return nil, err
+			}
+		}
+		return kvs, nil
+	}
+	p, err := cfgpath.MakeByString(route)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[cfgdump] Invalid route %q", route)
+	}
+	p = p.Bind(id)
+	if err := p.IsValid(); err != nil {
+		return nil, errors.Wrapf(err, "[cfgdump] Invalid path %q", p)
+	}
+	if !o.skip(p) {
+		kvs = append(kvs, KeyValue{Path: p, Value: v})
+	}
+	return kvs, nil
+}
+
+func joinRoute(route, key string) string {
+	key = strings.Trim(key, "/")
+	if route == "" {
+		return key
+	}
+	return route + "/" + key
+}
+
+// toMap converts the maps of the different decoders. Returns nil if v is not a
+// map.
+func toMap(v interface{}) map[string]interface{} {
+	switch vt := v.(type) {
+	case map[string]interface{}:
+		return vt
+	case map[interface{}]interface{}: // YAML
+		m := make(map[string]interface{}, len(vt))
+		for k, v := range vt {
+			m[fmt.Sprint(k)] = v
+		}
+		return m
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgdump_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgdump"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var codes = cfgdump.WithScopeCodes(map[scope.TypeID]string{
+	scope.Website.Pack(1): "base",
+	scope.Store.Pack(2):   "swiss",
+})
+
+func TestEncode_PHP(t *testing.T) {
+	kvs, err := cfgdump.Dump(newStorage(t))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, cfgdump.Encode(&buf, cfgdump.FormatPHP, kvs, codes,
+		cfgdump.WithSensitive(cfgpath.MakeRoute("payment/braintree/private_key"))))
+
+	want, err := ioutil.ReadFile("testdata/config.php")
+	require.NoError(t, err)
+	assert.Exactly(t, string(want), buf.String())
+}
+
+func TestEncode_Decode(t *testing.T) {
+	kvs, err := cfgdump.Dump(newStorage(t))
+	require.NoError(t, err)
+
+	for _, format := range []string{cfgdump.FormatPHP, cfgdump.FormatYAML, cfgdump.FormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, cfgdump.Encode(&buf, format, kvs, codes))
+
+			have, err := cfgdump.Decode(&buf, format, codes)
+			require.NoError(t, err)
+			assert.Exactly(t, fqs(kvs), fqs(have))
+			for i, kv := range have {
+				assert.EqualValues(t, kvs[i].Value, kv.Value, "%s", kv.Path)
+			}
+		})
+	}
+
+	t.Run("unsupported format", func(t *testing.T) {
+		err := cfgdump.Encode(ioutil.Discard, "xml", kvs)
+		assert.True(t, errors.IsNotSupported(err), "%+v", err)
+		_, err = cfgdump.Decode(strings.NewReader(""), "xml")
+		assert.True(t, errors.IsNotSupported(err), "%+v", err)
+	})
+}
+
+func TestDecode_PHP(t *testing.T) {
+	t.Run("Magento config.php", func(t *testing.T) {
+		const php = `<?php
+// generated by app:config:dump
+return array (
+  'modules' => [
+    'Magento_Store' => 1,
+  ],
+  'scopes' => array(),
+  'system' => array (
+    'default' => array (
+      'dev' => ['js' => ['merge_files' => '1', "minify_files" => TRUE]],
+      'general' => [
+        'locale' => [
+          'code' => 'it\'s \\ fine', # comment
+          /* multi
+             line */
+          'timezone' => "Europe/Berlin\t",
+          'weight_unit' => NULL,
+        ],
+      ],
+    ),
+    'stores' => [
+      'default' => ['general' => ['locale' => ['code' => 'de_DE']]],
+      '3' => ['catalog' => ['frontend' => ['list_per_page' => -12, 'ratio' => 1.5e2]]],
+    ],
+  ),
+);
+`
+		kvs, err := cfgdump.Decode(strings.NewReader(php), cfgdump.FormatPHP,
+			cfgdump.WithScopeCodes(map[scope.TypeID]string{scope.Store.Pack(1): "default"}))
+		require.NoError(t, err)
+		assert.Exactly(t, []string{
+			"stores/3/catalog/frontend/list_per_page",
+			"stores/3/catalog/frontend/ratio",
+			"default/0/dev/js/merge_files",
+			"default/0/dev/js/minify_files",
+			"default/0/general/locale/code",
+			"stores/1/general/locale/code",
+			"default/0/general/locale/timezone",
+			"default/0/general/locale/weight_unit",
+		}, fqs(kvs))
+		assert.Exactly(t, -12, kvs[0].Value)
+		assert.Exactly(t, 150.0, kvs[1].Value)
+		assert.Exactly(t, "1", kvs[2].Value)
+		assert.Exactly(t, true, kvs[3].Value)
+		assert.Exactly(t, `it's \ fine`, kvs[4].Value)
+		assert.Exactly(t, "de_DE", kvs[5].Value)
+		assert.Exactly(t, "Europe/Berlin\t", kvs[6].Value)
+		assert.Nil(t, kvs[7].Value)
+	})
+
+	t.Run("filter", func(t *testing.T) {
+		kvs, err := cfgdump.Decode(strings.NewReader(`<?php return ['system' => ['default' => [
+			'web' => ['secure' => ['base_url' => 'x']],
+			'general' => ['locale' => ['code' => 'y']],
+		], 'websites' => ['2' => ['web' => ['secure' => ['base_url' => 'z']]]]]];`),
+			cfgdump.FormatPHP, cfgdump.WithSections("web"), cfgdump.WithScopes(scope.Website.Pack(2)))
+		require.NoError(t, err)
+		assert.Exactly(t, []string{"websites/2/web/secure/base_url"}, fqs(kvs))
+	})
+
+	tests := []struct {
+		php    string
+		errBhf errors.BehaviourFunc
+	}{
+		{`return [];`, errors.IsNotValid},
+		{`<?php return ['system' => ['default' => ['a' => 'b']]]`, errors.IsNotValid},
+		{`<?php return ['system' => ['default' => ['a' => "$b"]]];`, errors.IsNotValid},
+		{`<?php return ['system' => ['default' => ['a' => 'b]]];`, errors.IsNotValid},
+		{`<?php return ['system' => ['default' => ['a' => FOO]]];`, errors.IsNotValid},
+		{`<?php return ['system' => ['default' => ['a' => 1 2]]];`, errors.IsNotValid},
+		{`<?php return ['system' => ['default' => ['a' => 'b']]]; echo 1;`, errors.IsNotValid},
+		{`<?php return 'system';`, errors.IsNotValid},
+		{`<?php return ['system' => ['global' => ['a' => ['b' => ['c' => 1]]]]];`, errors.IsNotSupported},
+		{`<?php return ['system' => ['stores' => ['admin' => ['a' => ['b' => ['c' => 1]]]]]];`, errors.IsNotFound},
+	}
+	for _, test := range tests {
+		_, err := cfgdump.Decode(strings.NewReader(test.php), cfgdump.FormatPHP)
+		assert.True(t, test.errBhf(err), "%s\n%+v", test.php, err)
+	}
+}
@@ -0,0 +1,360 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgdump
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/util/conv"
+)
+
+const phpIndent = "    "
+
+// encodePHP writes the document as PHP file which returns a short syntax
+// array, formatted like the files of Magento's app:config:dump.
+func encodePHP(w io.Writer, doc map[string]interface{}) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("<?php\nreturn ")
+	if err := writePHPValue(bw, doc, 0); err != nil {
+		return errors.WithStack(err)
+	}
+	bw.WriteString(";\n")
+	return errors.WithStack(bw.Flush())
+}
+
+func writePHPValue(w *bufio.Writer, v interface{}, depth int) error {
+	switch vt := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(vt))
+		for k := range vt {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		w.WriteString("[\n")
+		for _, k := range keys {
+			w.WriteString(strings.Repeat(phpIndent, depth+1))
+			w.WriteString(quotePHP(k))
+			w.WriteString(" => ")
+			if err := writePHPValue(w, vt[k], depth+1); err != nil {
+				return errors.WithStack(err)
+			}
+			w.WriteString(",\n")
+		}
+		w.WriteString(strings.Repeat(phpIndent, depth))
+		w.WriteByte(']')
+	case nil:
+		w.WriteString("null")
+	case bool:
+		w.WriteString(strconv.FormatBool(vt))
+	case int:
+		w.WriteString(strconv.Itoa(vt))
+	case int64:
+		w.WriteString(strconv.FormatInt(vt, 10))
+	case uint64:
+		w.WriteString(strconv.FormatUint(vt, 10))
+	case float64:
+		if math.IsInf(vt, 0) || math.IsNaN(vt) {
+			return errors.NewNotSupportedf("[cfgdump] Float %f not supported", vt)
+		}
+		s := strconv.FormatFloat(vt, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			s += ".0"
+		}
+		w.WriteString(s)
+	case string:
+		w.WriteString(quotePHP(vt))
+	default:
+		s, err := conv.ToStringE(v)
+		if err != nil {
+			return errors.NewNotSupportedf("[cfgdump] Type %T not supported: %s", v, err)
+		}
+		w.WriteString(quotePHP(s))
+	}
+	return nil
+}
+
+// quotePHP returns a single quoted PHP string.
+func quotePHP(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// decodePHP parses a PHP file which returns an array. Only literals are
+// supported: arrays in short and long syntax, strings, numbers, booleans and
+// null. Arrays with keys become a map[string]interface{} and lists a
+// []interface{}.
+func decodePHP(data []byte) (interface{}, error) {
+	p := &phpParser{data: data}
+	p.skipSpace()
+	if !p.consume("<?php") {
+		return nil, p.errorf("expected <?php")
+	}
+	p.skipSpace()
+	if !p.consumeWord("return") {
+		return nil, p.errorf("expected return")
+	}
+	v, err := p.value()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	p.skipSpace()
+	if !p.consume(";") {
+		return nil, p.errorf("expected ;")
+	}
+	p.skipSpace()
+	p.consume("?>")
+	p.skipSpace()
+	if p.pos < len(p.data) {
+		return nil, p.errorf("unexpected content after return statement")
+	}
+	return v, nil
+}
+
+type phpParser struct {
+	data []byte
+	pos  int
+}
+
+func (p *phpParser) errorf(msg string) error {
+	return errors.NewNotValidf("[cfgdump] PHP syntax error at offset %d: %s", p.pos, msg)
+}
+
+func (p *phpParser) skipSpace() {
+	for p.pos < len(p.data) {
+		switch rest := p.data[p.pos:]; {
+		case rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\n' || rest[0] == '\r':
+			p.pos++
+		case rest[0] == '#' || bytes.HasPrefix(rest, []byte("//")):
+			if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+				p.pos += i + 1
+			} else {
+				p.pos = len(p.data)
+			}
+		case bytes.HasPrefix(rest, []byte("/*")):
+			if i := bytes.Index(rest[2:], []byte("*/")); i >= 0 {
+				p.pos += i + 4
+			} else {
+				p.pos = len(p.data)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *phpParser) consume(s string) bool {
+	if bytes.HasPrefix(p.data[p.pos:], []byte(s)) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// consumeWord consumes a case insensitive keyword.
+func (p *phpParser) consumeWord(w string) bool {
+	end := p.pos + len(w)
+	if end > len(p.data) || !strings.EqualFold(string(p.data[p.pos:end]), w) {
+		return false
+	}
+	if end < len(p.data) && isWordChar(p.data[end]) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *phpParser) value() (interface{}, error) {
+	p.skipSpace()
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of file")
+	}
+	switch c := p.data[p.pos]; {
+	case c == '[':
+		p.pos++
+		return p.array(']')
+	case c == '\'':
+		return p.singleQuoted()
+	case c == '"':
+		return p.doubleQuoted()
+	case c == '-' || c == '+' || c == '.' || c >= '0' && c <= '9':
+		return p.number()
+	case p.consumeWord("array"):
+		p.skipSpace()
+		if !p.consume("(") {
+			return nil, p.errorf("expected ( after array")
+		}
+		return p.array(')')
+	case p.consumeWord("true"):
+		return true, nil
+	case p.consumeWord("false"):
+		return false, nil
+	case p.consumeWord("null"):
+		return nil, nil
+	}
+	return nil, p.errorf("unsupported expression")
+}
+
+func (p *phpParser) array(end byte) (interface{}, error) {
+	var list []interface{}
+	m := make(map[string]interface{})
+	hasKeys := false
+	nextIndex := 0
+	for {
+		p.skipSpace()
+		if p.consume(string(end)) {
+			break
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		p.skipSpace()
+		if p.consume("=>") {
+			key, err := phpKey(v)
+			if err != nil {
+				return nil, p.errorf(err.Error())
+			}
+			if v, err = p.value(); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if i, err := strconv.Atoi(key); err == nil && i >= nextIndex {
+				nextIndex = i + 1
+			}
+			m[key] = v
+			hasKeys = true
+		} else {
+			m[strconv.Itoa(nextIndex)] = v
+			nextIndex++
+			list = append(list, v)
+		}
+		p.skipSpace()
+		if !p.consume(",") {
+			p.skipSpace()
+			if !p.consume(string(end)) {
+				return nil, p.errorf("expected , or " + string(end))
+			}
+			break
+		}
+	}
+	if !hasKeys && list != nil {
+		return list, nil
+	}
+	return m, nil
+}
+
+// phpKey converts an array key into a string.
+func phpKey(v interface{}) (string, error) {
+	switch vt := v.(type) {
+	case string:
+		return vt, nil
+	case int:
+		return strconv.Itoa(vt), nil
+	case bool:
+		if vt {
+			return "1", nil
+		}
+		return "0", nil
+	case nil:
+		return "", nil
+	}
+	return "", errors.NewNotSupportedf("array key of type %T", v)
+}
+
+func (p *phpParser) singleQuoted() (interface{}, error) {
+	p.pos++ // opening quote
+	var buf bytes.Buffer
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		switch {
+		case c == '\'':
+			p.pos++
+			return buf.String(), nil
+		case c == '\\' && p.pos+1 < len(p.data) && (p.data[p.pos+1] == '\\' || p.data[p.pos+1] == '\''):
+			buf.WriteByte(p.data[p.pos+1])
+			p.pos += 2
+		default:
+			buf.WriteByte(c)
+			p.pos++
+		}
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+var phpEscapes = map[byte]byte{
+	'n': '\n', 't': '\t', 'r': '\r', 'v': '\v', 'f': '\f', 'e': 0x1b, '\\': '\\', '$': '$', '"': '"',
+}
+
+func (p *phpParser) doubleQuoted() (interface{}, error) {
+	p.pos++ // opening quote
+	var buf bytes.Buffer
+	for p.pos < len(p.data) {
+		c := p.data[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			return buf.String(), nil
+		case c == '$':
+			return nil, p.errorf("variables in strings are not supported")
+		case c == '\\' && p.pos+1 < len(p.data):
+			if e, ok := phpEscapes[p.data[p.pos+1]]; ok {
+				buf.WriteByte(e)
+			} else {
+				buf.Write(p.data[p.pos : p.pos+2])
+			}
+			p.pos += 2
+		default:
+			buf.WriteByte(c)
+			p.pos++
+		}
+	}
+	return nil, p.errorf("unterminated string")
+}
+
+func (p *phpParser) number() (interface{}, error) {
+	start := p.pos
+	isFloat := false
+loop:
+	for ; p.pos < len(p.data); p.pos++ {
+		switch c := p.data[p.pos]; {
+		case c >= '0' && c <= '9', c == '-', c == '+':
+		case c == '.', c == 'e', c == 'E':
+			isFloat = true
+		default:
+			break loop
+		}
+	}
+	s := string(p.data[start:p.pos])
+	if !isFloat {
+		if i, err := strconv.Atoi(s); err == nil {
+			return i, nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		p.pos = start
+		return nil, p.errorf("invalid number " + strconv.Quote(s))
+	}
+	return f, nil
+}
@@ -0,0 +1,45 @@
+<?php
+return [
+    'system' => [
+        'default' => [
+            'catalog' => [
+                'frontend' => [
+                    'list_per_page' => 12,
+                ],
+            ],
+            'general' => [
+                'locale' => [
+                    'code' => 'en_US',
+                ],
+            ],
+            'web' => [
+                'secure' => [
+                    'base_url' => 'https://www.example.com/',
+                ],
+            ],
+        ],
+        'stores' => [
+            'swiss' => [
+                'general' => [
+                    'locale' => [
+                        'code' => 'de_CH',
+                    ],
+                ],
+                'web' => [
+                    'secure' => [
+                        'base_url' => 'https://shop.example.ch/',
+                    ],
+                ],
+            ],
+        ],
+        'websites' => [
+            'base' => [
+                'web' => [
+                    'secure' => [
+                        'base_url' => 'https://shop.example.com/',
+                    ],
+                ],
+            ],
+        ],
+    ],
+];
@@ -0,0 +1,273 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgmodel
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/util/conv"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Cipher versions written as second part of the ciphertext prefix. Magento
+// uses the versions 0 to 2 for its mcrypt based ciphers, which are not
+// supported, and version 3 for ChaCha20-Poly1305. AES-256-GCM is not known to
+// Magento and gets its own version to avoid collisions with future Magento
+// versions. Values encrypted with AES-256-GCM cannot be read by Magento.
+const (
+	CipherChaCha20Poly1305 = 3
+	CipherAES256GCM        = 100
+)
+
+// cryptKeyLength defines the length in bytes of a key for both ciphers.
+const cryptKeyLength = 32
+
+// cryptKeyBase64Prefix marks a base64 encoded key, as written by newer
+// Magento versions.
+const cryptKeyBase64Prefix = "base64"
+
+// ParseCryptKey parses the value of `crypt/key` in Magento's `app/etc/env.php`.
+// Multiple keys get separated by white space. The index of a key is its
+// version, the last key is the current one. Each key must have 32 bytes, a key
+// prefixed with `base64` gets decoded first.
+// Error behaviour: Empty or NotValid.
+func ParseCryptKey(cryptKey string) ([][]byte, error) {
+	fields := strings.Fields(cryptKey)
+	if len(fields) == 0 {
+		return nil, errors.NewEmptyf("[cfgmodel] ParseCryptKey: crypt/key cannot be empty")
+	}
+	keys := make([][]byte, len(fields))
+	for i, f := range fields {
+		k := []byte(f)
+		if strings.HasPrefix(f, cryptKeyBase64Prefix) {
+			var err error
+			if k, err = base64.StdEncoding.DecodeString(f[len(cryptKeyBase64Prefix):]); err != nil {
+				return nil, errors.NewNotValidf("[cfgmodel] ParseCryptKey: Key version %d contains invalid base64: %s", i, err)
+			}
+		}
+		if len(k) != cryptKeyLength {
+			return nil, errors.NewNotValidf("[cfgmodel] ParseCryptKey: Key version %d must have %d bytes but has %d", i, cryptKeyLength, len(k))
+		}
+		keys[i] = k
+	}
+	return keys, nil
+}
+
+// Crypter encrypts and decrypts values compatible to Magento 2. A ciphertext
+// has the format `keyVersion:cipherVersion:base64(nonce + sealed data)`, for
+// example `0:3:ABC...`. The nonce serves as additional data. Crypter
+// implements the Encrypter and Decrypter interfaces. Thread safe.
+type Crypter struct {
+	// Cipher used for encryption, defaults to CipherChaCha20Poly1305 which
+	// Magento can read. Set it to CipherAES256GCM only if Magento never reads
+	// the values.
+	Cipher int
+	// Rand provides the nonces, defaults to crypto/rand.Reader.
+	Rand io.Reader
+	keys [][]byte
+}
+
+// NewCrypter creates a new Crypter from the value of `crypt/key`. Encrypt uses
+// the last key, Decrypt all keys.
+func NewCrypter(cryptKey string) (*Crypter, error) {
+	keys, err := ParseCryptKey(cryptKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgmodel] NewCrypter")
+	}
+	return &Crypter{
+		Cipher: CipherChaCha20Poly1305,
+		Rand:   rand.Reader,
+		keys:   keys,
+	}, nil
+}
+
+// MustNewCrypter same as NewCrypter but panics on error.
+func MustNewCrypter(cryptKey string) *Crypter {
+	c, err := NewCrypter(cryptKey)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// KeyVersion returns the version of the key used for encryption.
+func (c *Crypter) KeyVersion() int {
+	return len(c.keys) - 1
+}
+
+func (c *Crypter) aead(keyVersion, cipherVersion int) (cipher.AEAD, error) {
+	if keyVersion < 0 || keyVersion >= len(c.keys) {
+		return nil, errors.NewNotFoundf("[cfgmodel] Crypter: Key version %d not found", keyVersion)
+	}
+	key := c.keys[keyVersion]
+	switch cipherVersion {
+	case CipherChaCha20Poly1305:
+		a, err := chacha20poly1305.New(key)
+		return a, errors.Wrap(err, "[cfgmodel] Crypter: chacha20poly1305.New")
+	case CipherAES256GCM:
+		b, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "[cfgmodel] Crypter: aes.NewCipher")
+		}
+		a, err := cipher.NewGCM(b)
+		return a, errors.Wrap(err, "[cfgmodel] Crypter: cipher.NewGCM")
+	}
+	return nil, errors.NewNotSupportedf("[cfgmodel] Crypter: Cipher version %d not supported", cipherVersion)
+}
+
+// prefix returns the ciphertext prefix of the current key and cipher.
+func (c *Crypter) prefix() string {
+	return strconv.Itoa(c.KeyVersion()) + ":" + strconv.Itoa(c.Cipher) + ":"
+}
+
+// Encrypt encrypts the plaintext with the current key.
+func (c *Crypter) Encrypt(plaintext []byte) ([]byte, error) {
+	a, err := c.aead(c.KeyVersion(), c.Cipher)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	nonce := make([]byte, a.NonceSize(), a.NonceSize()+len(plaintext)+a.Overhead())
+	if _, err := io.ReadFull(c.Rand, nonce); err != nil {
+		return nil, errors.Wrap(err, "[cfgmodel] Crypter.Encrypt: Failed to read nonce")
+	}
+	sealed := a.Seal(nonce, nonce, plaintext, nonce)
+
+	p := c.prefix()
+	ret := make([]byte, len(p)+base64.StdEncoding.EncodedLen(len(sealed)))
+	copy(ret, p)
+	base64.StdEncoding.Encode(ret[len(p):], sealed)
+	return ret, nil
+}
+
+// Decrypt decrypts a ciphertext of any known key. An empty ciphertext returns
+// an empty plaintext. A ciphertext without key version uses the first key.
+// Error behaviour: NotSupported, NotFound or NotValid.
+func (c *Crypter) Decrypt(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) == 0 {
+		return []byte{}, nil
+	}
+	var keyVersion, cipherVersion int
+	var payload []byte
+	var err error
+	switch parts := bytes.SplitN(ciphertext, []byte(":"), 4); len(parts) {
+	case 3:
+		if keyVersion, err = strconv.Atoi(string(parts[0])); err != nil {
+			return nil, errors.NewNotValidf("[cfgmodel] Crypter.Decrypt: Invalid key version %q", parts[0])
+		}
+		cipherVersion, err = strconv.Atoi(string(parts[1]))
+		payload = parts[2]
+	case 2:
+		cipherVersion, err = strconv.Atoi(string(parts[0]))
+		payload = parts[1]
+	default:
+		return nil, errors.NewNotSupportedf("[cfgmodel] Crypter.Decrypt: Ciphertext format with %d parts not supported", len(parts))
+	}
+	if err != nil {
+		return nil, errors.NewNotValidf("[cfgmodel] Crypter.Decrypt: Invalid cipher version: %s", err)
+	}
+
+	a, err := c.aead(keyVersion, cipherVersion)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(payload)))
+	n, err := base64.StdEncoding.Decode(sealed, payload)
+	if err != nil {
+		return nil, errors.NewNotValidf("[cfgmodel] Crypter.Decrypt: Invalid base64: %s", err)
+	}
+	sealed = sealed[:n]
+	if len(sealed) < a.NonceSize() {
+		return nil, errors.NewNotValidf("[cfgmodel] Crypter.Decrypt: Ciphertext too short")
+	}
+	nonce := sealed[:a.NonceSize()]
+	plaintext, err := a.Open(nil, nonce, sealed[a.NonceSize():], nonce)
+	if err != nil {
+		return nil, errors.NewNotValidf("[cfgmodel] Crypter.Decrypt: %s", err)
+	}
+	return plaintext, nil
+}
+
+// IsCurrent reports whether the ciphertext has been encrypted with the
+// current key and cipher.
+func (c *Crypter) IsCurrent(ciphertext []byte) bool {
+	return bytes.HasPrefix(ciphertext, []byte(c.prefix()))
+}
+
+// WithCrypter sets the Crypter as Encrypter and Decrypter.
+func WithCrypter(c *Crypter) Option {
+	return func(b *optionBox) error {
+		if b.Obscure == nil {
+			return nil
+		}
+		b.Obscure.Encrypter = c
+		b.Obscure.Decrypter = c
+		return nil
+	}
+}
+
+// RotateObscure re-encrypts the values of all obscured paths in all scopes
+// with the current key and cipher of the Crypter. The Crypter must contain the
+// old keys to decrypt the values. Values already encrypted with the current
+// key and cipher get skipped, so the rotation can be repeated after a failure.
+// Returns the number of re-encrypted values.
+func RotateObscure(s config.Storager, c *Crypter, obs ...Obscure) (int, error) {
+	routes := make(map[string]bool, len(obs))
+	for _, ob := range obs {
+		routes[ob.route.Data] = true
+	}
+	keys, err := s.AllKeys()
+	if err != nil {
+		return 0, errors.Wrap(err, "[cfgmodel] RotateObscure.AllKeys")
+	}
+	count := 0
+	for _, p := range keys {
+		if !routes[p.Route.Data] {
+			continue
+		}
+		v, err := s.Get(p)
+		if err != nil {
+			return count, errors.Wrapf(err, "[cfgmodel] RotateObscure.Get %q", p)
+		}
+		old, err := conv.ToByteE(v)
+		if err != nil {
+			return count, errors.Wrapf(err, "[cfgmodel] RotateObscure %q", p)
+		}
+		if len(old) == 0 || c.IsCurrent(old) {
+			continue
+		}
+		plain, err := c.Decrypt(old)
+		if err != nil {
+			return count, errors.Wrapf(err, "[cfgmodel] RotateObscure.Decrypt %q", p)
+		}
+		enc, err := c.Encrypt(plain)
+		if err != nil {
+			return count, errors.Wrapf(err, "[cfgmodel] RotateObscure.Encrypt %q", p)
+		}
+		if err := s.Set(p, enc); err != nil {
+			return count, errors.Wrapf(err, "[cfgmodel] RotateObscure.Set %q", p)
+		}
+		count++
+	}
+	return count, nil
+}
@@ -20,8 +20,6 @@ import (
 	"github.com/corestoreio/errors"
 )
 
-// todo implement compatible type for both interfaces like in the example ExampleNewGCM_encrypt() and ExampleNewGCM_decrypt of the std lib cipher package.
-
 // Encrypter defines a function which encrypts the plaintext input data and
 // returns the encrypted data. Or may return an error.
 type Encrypter interface {
@@ -70,7 +68,8 @@ func WithDecrypter(d Decrypter) Option {
 	}
 }
 
-// Obscure backend model for handling sensible values
+// Obscure backend model for handling sensible values. Option WithCrypter sets
+// an encryption compatible to Magento 2.
 type Obscure struct {
 	Byte
 	Encrypter
@@ -113,6 +113,15 @@ func (p Path) BindStore(id int64) Path {
 	return p
 }
 
+// Less reports whether p sorts before o. Paths get compared by their route and
+// then by their scope.
+func (p Path) Less(o Path) bool {
+	if p.Route.Data != o.Route.Data {
+		return p.Route.Data < o.Route.Data
+	}
+	return p.ScopeID < o.ScopeID
+}
+
 // String returns a fully qualified path. Errors get logged if debug mode
 // is enabled. String starts with `[cfgpath] Error:` on error.
 // Error behaviour: NotValid, Empty or WriteFailed
@@ -36,9 +36,9 @@ func (ps PathSlice) Contains(p Path) bool {
 
 func (ps PathSlice) Len() int { return len(ps) }
 func (ps PathSlice) Less(i, j int) bool {
-	return ps[i].Route.Data < ps[j].Route.Data
+	return ps[i].Less(ps[j])
 }
 func (ps PathSlice) Swap(i, j int) { ps[i], ps[j] = ps[j], ps[i] }
 
-// Sort is a convenience method to sort stable.
+// Sort is a convenience method to sort stable by route and scope.
 func (ps PathSlice) Sort() { sort.Stable(ps) }
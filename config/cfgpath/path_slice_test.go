@@ -69,6 +69,21 @@ func TestPathSlice_Sort(t *testing.T) {
 		cfgpath.Path{Route: cfgpath.MakeRoute(`xx/yy/zz`), ScopeID: scope.DefaultTypeID},
 	}
 	assert.Exactly(t, want, ps)
+
+	ps = cfgpath.PathSlice{
+		cfgpath.MustMakeByString("bb/cc/dd").BindStore(2),
+		cfgpath.MustMakeByString("aa/bb/cc").BindWebsite(1),
+		cfgpath.MustMakeByString("bb/cc/dd"),
+		cfgpath.MustMakeByString("aa/bb/cc"),
+	}
+	ps.Sort()
+	want = cfgpath.PathSlice{
+		cfgpath.MustMakeByString("aa/bb/cc"),
+		cfgpath.MustMakeByString("aa/bb/cc").BindWebsite(1),
+		cfgpath.MustMakeByString("bb/cc/dd"),
+		cfgpath.MustMakeByString("bb/cc/dd").BindStore(2),
+	}
+	assert.Exactly(t, want, ps, "sorted by route and then by scope")
 }
 
 // BenchmarkPathSlice_Sort-4	 1000000	      1987 ns/op	     480 B/op	       8 allocs/op
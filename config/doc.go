@@ -39,6 +39,10 @@ To get a value from the configuration Service via any type method you have to pr
 path.Path. If you use the ScopedGetter via function NewScoped() you can only provide a
 path.Route to the type methods String(), Int(), Float64(), etc.
 
+Function Scoped.Explain returns the resolved value together with the tried
+scope paths, the storage which answered and whether the default value of an
+element.Field applied. ExplainHandler exposes it as a debug HTTP handler.
+
 The examples show the overall best practices.
 */
 package config
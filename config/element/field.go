@@ -18,6 +18,7 @@ import (
 	"sort"
 
 	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/cfgsource"
 	"github.com/corestoreio/pkg/storage/text"
 	"github.com/corestoreio/pkg/store/scope"
 	"github.com/corestoreio/errors"
@@ -59,6 +60,10 @@ type Field struct {
 	CanBeEmpty bool `json:",omitempty"`
 	// Default can contain any default config value: float64, int64, string, bool
 	Default interface{} `json:",omitempty"`
+	// Source contains all allowed values aka SourceModel in Mage slang. Used
+	// by the ValidatingWriter to reject values which are not part of the
+	// options.
+	Source cfgsource.Slice `json:",omitempty"`
 }
 
 // NewFieldSlice wrapper to create a new FieldSlice
@@ -158,6 +163,9 @@ func (f Field) Update(new Field) Field {
 	if new.Default != nil {
 		f.Default = new.Default
 	}
+	if new.Source != nil {
+		f.Source = new.Source
+	}
 	return f
 }
 
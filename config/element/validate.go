@@ -0,0 +1,217 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package element
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/cfgsource"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/corestoreio/pkg/util/conv"
+)
+
+// ValidatingWriter checks each value against the Field definitions of a
+// SectionSlice before passing it to the underlying ConfigurationWriter. It
+// rejects unknown paths, scopes not allowed by Field.Scopes and values which
+// are not part of Field.Source. Values get converted depending on the
+// FieldType:
+//		TypeText, TypeTextarea, TypeHidden, TypeObscure, TypeLabel,
+//		TypeImage, TypeSelect        => string
+//		TypeMultiselect              => comma separated string
+//		TypeTime                     => time.Time
+//		TypeDuration                 => time.Duration
+// If a Field has a Source, the value gets converted to the type of the Source
+// values. A nil value gets passed through without validation. Other types
+// like TypeButton or TypeCustom do not get converted. Thread safe for
+// concurrent writing.
+type ValidatingWriter struct {
+	w      ConfigurationWriter
+	fields map[string]Field
+}
+
+// NewValidatingWriter creates a new opt-in validating writer which wraps w,
+// for example the config.Service. SectionSlice ss must contain all Fields
+// which can be written.
+func NewValidatingWriter(w ConfigurationWriter, ss SectionSlice) (*ValidatingWriter, error) {
+	vw := &ValidatingWriter{
+		w:      w,
+		fields: make(map[string]Field, ss.TotalFields()),
+	}
+	for _, s := range ss {
+		for _, g := range s.Groups {
+			for _, f := range g.Fields {
+				r, err := f.Route(s.ID, g.ID)
+				if err != nil {
+					return nil, errors.Wrapf(err, "[element] NewValidatingWriter.Field.Route. Section %q Group %q", s.ID, g.ID)
+				}
+				vw.fields[r.String()] = f
+			}
+		}
+	}
+	return vw, nil
+}
+
+// MustNewValidatingWriter same as NewValidatingWriter but panics on error.
+func MustNewValidatingWriter(w ConfigurationWriter, ss SectionSlice) *ValidatingWriter {
+	vw, err := NewValidatingWriter(w, ss)
+	if err != nil {
+		panic(err)
+	}
+	return vw
+}
+
+// Write validates and converts the value and writes it to the underlying
+// ConfigurationWriter. Error behaviour: NotFound, Unauthorized or NotValid.
+func (vw *ValidatingWriter) Write(p cfgpath.Path, v interface{}) error {
+	f, ok := vw.fields[p.Route.String()]
+	if !ok {
+		return errors.NewNotFoundf("[element] ValidatingWriter: Unknown path %q", p.Route)
+	}
+	v, err := f.Validate(p.ScopeID, v)
+	if err != nil {
+		return errors.Wrapf(err, "[element] ValidatingWriter: Path %q", p.Route)
+	}
+	return vw.w.Write(p, v)
+}
+
+// Validate checks if the value is allowed for scope h and returns the
+// converted value. See type ValidatingWriter for the conversion rules. A Field
+// without Scopes can only be written in default scope. Error behaviour:
+// Unauthorized or NotValid.
+func (f Field) Validate(h scope.TypeID, v interface{}) (interface{}, error) {
+	perm := f.Scopes
+	if perm == 0 {
+		perm = scope.PermDefault
+	}
+	if scp, _ := h.Unpack(); !perm.Has(scp) {
+		return nil, errors.NewUnauthorizedf("[element] Scope permission insufficient: Have %q; Want %q", h, perm)
+	}
+	if v == nil {
+		return nil, nil
+	}
+
+	var ft FieldType
+	if f.Type != nil {
+		ft = f.Type.Type()
+	}
+
+	if ft == TypeMultiselect {
+		vals, err := toStrings(v)
+		if err != nil {
+			return nil, errors.Wrap(err, "[element] Field.Validate.toStrings")
+		}
+		if len(vals) == 0 && !f.CanBeEmpty {
+			return nil, errors.NewNotValidf("[element] Multiselect Field %q cannot be empty", f.ID)
+		}
+		for _, val := range vals {
+			if _, err := validateSource(f.Source, val); err != nil {
+				return nil, errors.Wrap(err, "[element] Field.Validate.Multiselect")
+			}
+		}
+		return strings.Join(vals, ","), nil
+	}
+
+	if len(f.Source) > 0 {
+		return validateSource(f.Source, v)
+	}
+
+	cv, err := v, error(nil)
+	switch ft {
+	case TypeText, TypeTextarea, TypeHidden, TypeObscure, TypeLabel, TypeImage, TypeSelect:
+		cv, err = conv.ToStringE(v)
+	case TypeTime:
+		cv, err = conv.ToTimeE(v)
+	case TypeDuration:
+		cv, err = conv.ToDurationE(v)
+	}
+	if err != nil {
+		return nil, errors.NewNotValidf("[element] Field %q cannot convert value %#v to %s: %s", f.ID, v, ft, err)
+	}
+	return cv, nil
+}
+
+// validateSource converts v to the type of the source values and checks if
+// the value is contained in the source. An empty source allows all values.
+func validateSource(src cfgsource.Slice, v interface{}) (interface{}, error) {
+	if len(src) == 0 {
+		return v, nil
+	}
+
+	var ok bool
+	var err error
+	switch src[0].NotNull {
+	case cfgsource.NotNullInt:
+		var i int
+		if i, err = conv.ToIntE(v); err == nil {
+			ok, v = src.ContainsValInt(i), i
+		}
+	case cfgsource.NotNullFloat64:
+		var f float64
+		if f, err = conv.ToFloat64E(v); err == nil {
+			ok, v = src.ContainsValFloat64(f), f
+		}
+	case cfgsource.NotNullBool:
+		var b bool
+		if b, err = conv.ToBoolE(v); err == nil {
+			ok, v = src.ContainsValBool(b), b
+		}
+	default:
+		var s string
+		if s, err = conv.ToStringE(v); err == nil {
+			ok, v = src.ContainsValString(s), s
+		}
+	}
+	if err != nil || !ok {
+		return nil, errors.NewNotValidf("[element] The value %#v cannot be found within the allowed options: %s", v, src)
+	}
+	return v, nil
+}
+
+// toStrings converts a comma separated string or a slice into a string slice.
+func toStrings(v interface{}) ([]string, error) {
+	switch vt := v.(type) {
+	case string:
+		if vt == "" {
+			return nil, nil
+		}
+		return strings.Split(vt, ","), nil
+	case []string:
+		return vt, nil
+	case []int:
+		ret := make([]string, len(vt))
+		for i, val := range vt {
+			ret[i] = fmt.Sprint(val)
+		}
+		return ret, nil
+	case []interface{}:
+		ret := make([]string, len(vt))
+		for i, val := range vt {
+			s, err := conv.ToStringE(val)
+			if err != nil {
+				return nil, errors.NewNotValidf("[element] Cannot convert %#v to string: %s", val, err)
+			}
+			ret[i] = s
+		}
+		return ret, nil
+	}
+	s, err := conv.ToStringE(v)
+	if err != nil {
+		return nil, errors.NewNotValidf("[element] Cannot convert %#v to string: %s", v, err)
+	}
+	return toStrings(s)
+}
@@ -0,0 +1,160 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package element_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/cfgsource"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ element.ConfigurationWriter = (*element.ValidatingWriter)(nil)
+
+func newValidatingSections() element.SectionSlice {
+	return element.MustNewConfiguration(
+		element.Section{
+			ID: cfgpath.NewRoute("web"),
+			Groups: element.NewGroupSlice(
+				element.Group{
+					ID: cfgpath.NewRoute("secure"),
+					Fields: element.NewFieldSlice(
+						element.Field{
+							// Path: `web/secure/base_url`
+							ID:     cfgpath.NewRoute("base_url"),
+							Type:   element.TypeText,
+							Scopes: scope.PermStore,
+						},
+						element.Field{
+							// Path: `web/secure/use_in_frontend`
+							ID:     cfgpath.NewRoute("use_in_frontend"),
+							Type:   element.TypeSelect,
+							Scopes: scope.PermWebsite,
+							Source: cfgsource.NewByIntValue(0, 1),
+						},
+						element.Field{
+							// Path: `web/secure/offloader_header`
+							ID:     cfgpath.NewRoute("offloader_header"),
+							Type:   element.TypeSelect,
+							Source: cfgsource.NewByStringValue("SSL_OFFLOADED", "X_FORWARDED_PROTO"),
+						},
+					),
+				},
+				element.Group{
+					ID: cfgpath.NewRoute("cookie"),
+					Fields: element.NewFieldSlice(
+						element.Field{
+							// Path: `web/cookie/cookie_lifetime`
+							ID:     cfgpath.NewRoute("cookie_lifetime"),
+							Type:   element.TypeDuration,
+							Scopes: scope.PermStore,
+						},
+						element.Field{
+							// Path: `web/cookie/expires_at`
+							ID:   cfgpath.NewRoute("expires_at"),
+							Type: element.TypeTime,
+						},
+						element.Field{
+							// Path: `web/cookie/allowed_domains`
+							ID:     cfgpath.NewRoute("allowed_domains"),
+							Type:   element.TypeMultiselect,
+							Scopes: scope.PermStore,
+							Source: cfgsource.NewByStringValue("a.io", "b.io", "c.io"),
+						},
+						element.Field{
+							// Path: `web/cookie/secure`
+							ID:         cfgpath.NewRoute("secure"),
+							ConfigPath: cfgpath.NewRoute("web/session/secure"),
+							Type:       element.TypeButton,
+						},
+					),
+				},
+			),
+		},
+	)
+}
+
+func TestValidatingWriter(t *testing.T) {
+
+	expiresAt := time.Date(2018, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		path       cfgpath.Path
+		value      interface{}
+		want       interface{}
+		wantErrBhf errors.BehaviourFunc
+	}{
+		{cfgpath.MustNewByParts("web/secure/base_url").Bind(scope.Store.Pack(2)), "https://corestore.io/", "https://corestore.io/", nil},
+		{cfgpath.MustNewByParts("web/secure/base_url"), []byte("https://corestore.io/"), "https://corestore.io/", nil},
+		{cfgpath.MustNewByParts("web/secure/base_url"), nil, nil, nil},
+		{cfgpath.MustNewByParts("web/secure/unknown"), "https://corestore.io/", nil, errors.IsNotFound},
+		{cfgpath.MustNewByParts("web/secure/use_in_frontend").Bind(scope.Website.Pack(1)), "1", 1, nil},
+		{cfgpath.MustNewByParts("web/secure/use_in_frontend"), true, 1, nil},
+		{cfgpath.MustNewByParts("web/secure/use_in_frontend").Bind(scope.Store.Pack(2)), 1, nil, errors.IsUnauthorized},
+		{cfgpath.MustNewByParts("web/secure/use_in_frontend"), 2, nil, errors.IsNotValid},
+		{cfgpath.MustNewByParts("web/secure/use_in_frontend"), "yes", nil, errors.IsNotValid},
+		{cfgpath.MustNewByParts("web/secure/offloader_header"), "X_FORWARDED_PROTO", "X_FORWARDED_PROTO", nil},
+		{cfgpath.MustNewByParts("web/secure/offloader_header"), "garbage", nil, errors.IsNotValid},
+		{cfgpath.MustNewByParts("web/secure/offloader_header").Bind(scope.Website.Pack(1)), "SSL_OFFLOADED", nil, errors.IsUnauthorized},
+		{cfgpath.MustNewByParts("web/cookie/cookie_lifetime").Bind(scope.Store.Pack(3)), "1h", time.Hour, nil},
+		{cfgpath.MustNewByParts("web/cookie/cookie_lifetime"), "forever", nil, errors.IsNotValid},
+		{cfgpath.MustNewByParts("web/cookie/expires_at"), expiresAt, expiresAt, nil},
+		{cfgpath.MustNewByParts("web/cookie/expires_at"), "2018-08-01T12:00:00Z", expiresAt, nil},
+		{cfgpath.MustNewByParts("web/cookie/expires_at"), "tomorrow", nil, errors.IsNotValid},
+		{cfgpath.MustNewByParts("web/cookie/allowed_domains"), "a.io,c.io", "a.io,c.io", nil},
+		{cfgpath.MustNewByParts("web/cookie/allowed_domains"), []string{"b.io", "c.io"}, "b.io,c.io", nil},
+		{cfgpath.MustNewByParts("web/cookie/allowed_domains"), []interface{}{"a.io"}, "a.io", nil},
+		{cfgpath.MustNewByParts("web/cookie/allowed_domains"), "a.io,d.io", nil, errors.IsNotValid},
+		{cfgpath.MustNewByParts("web/cookie/allowed_domains"), "", nil, errors.IsNotValid},
+		{cfgpath.MustNewByParts("web/cookie/secure"), true, nil, errors.IsNotFound},
+		{cfgpath.MustNewByParts("web/session/secure"), true, true, nil},
+	}
+	for i, test := range tests {
+		im := config.NewInMemoryStore()
+		vw, err := element.NewValidatingWriter(config.MustNewService(im), newValidatingSections())
+		require.NoError(t, err)
+
+		haveErr := vw.Write(test.path, test.value)
+		if test.wantErrBhf != nil {
+			assert.True(t, test.wantErrBhf(haveErr), "Index %d => %+v", i, haveErr)
+			_, err := im.Get(test.path)
+			assert.True(t, errors.IsNotFound(err), "Index %d => %+v", i, err)
+			continue
+		}
+		require.NoError(t, haveErr, "Index %d", i)
+		have, err := im.Get(test.path)
+		require.NoError(t, err, "Index %d", i)
+		assert.Exactly(t, test.want, have, "Index %d", i)
+	}
+}
+
+func TestField_Validate_CanBeEmpty(t *testing.T) {
+	f := element.Field{
+		ID:         cfgpath.NewRoute("allowed_domains"),
+		Type:       element.TypeMultiselect,
+		CanBeEmpty: true,
+		Source:     cfgsource.NewByStringValue("a.io", "b.io"),
+	}
+	v, err := f.Validate(scope.DefaultTypeID, "")
+	require.NoError(t, err)
+	assert.Exactly(t, "", v)
+}
@@ -0,0 +1,160 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// SourceDefault names the source of a value taken from element.Field.Default.
+const SourceDefault = "element.Field.Default"
+
+// Sourcer gets implemented by a Storager which combines several storages, for
+// example an overlay, and can tell which of them holds a key.
+type Sourcer interface {
+	// Source returns the name of the storage which holds the key.
+	Source(key cfgpath.Path) string
+}
+
+// SourceOf returns the name of the storage which holds the key. If s
+// implements interface Sourcer, its Source function gets called, otherwise
+// the name gets derived from the type of s, for example "*ccd.DBStorage".
+func SourceOf(s Storager, key cfgpath.Path) string {
+	if sr, ok := s.(Sourcer); ok {
+		return sr.Source(key)
+	}
+	return fmt.Sprintf("%T", s)
+}
+
+// Explainer gets implemented by a Getter which can tell where the raw value of
+// a fully qualified path comes from. Used by Scoped.Explain.
+type Explainer interface {
+	// Explain returns the raw value and the name of the storage which holds
+	// it. A missing path must return an error with behaviour NotFound.
+	Explain(p cfgpath.Path) (v interface{}, source string, err error)
+}
+
+// Explain implements interface Explainer.
+func (s *Service) Explain(p cfgpath.Path) (interface{}, string, error) {
+	v, err := s.get(p)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "[config] Service.Explain Path %q", p)
+	}
+	return v, SourceOf(s.backend, p), nil
+}
+
+// Lookup describes a single step of the scope fallback in Scoped.Explain.
+type Lookup struct {
+	// Path contains the fully qualified path which has been tried.
+	Path   string
+	Found  bool
+	Source string `json:",omitempty"`
+	// Error contains an error other than NotFound.
+	Error string `json:",omitempty"`
+}
+
+// Explanation contains the value resolved by Scoped.Explain and the lookup
+// chain which led to it.
+type Explanation struct {
+	Route     string
+	WebsiteID int64
+	StoreID   int64
+	// Value contains the raw value found in a storage or the default value of
+	// the field. Nil if nothing has been found.
+	Value interface{}
+	// Found reports whether a storage or a default value provided the value.
+	Found bool
+	// Path contains the fully qualified path which answered. Empty if the
+	// default value applied or nothing has been found.
+	Path string `json:",omitempty"`
+	// Source names the storage which answered, see function SourceOf, or
+	// SourceDefault.
+	Source         string `json:",omitempty"`
+	DefaultApplied bool
+	// Lookups lists the tried paths in the order store, website and default.
+	Lookups []Lookup
+}
+
+// Explain resolves the route like the typed functions, for example String,
+// and returns the raw value together with the lookup chain: which scoped paths
+// have been tried and which storage answered. The optional field restricts the
+// scopes like in package cfgmodel and its Default value applies if no storage
+// contains the route. The Root Getter must implement interface Explainer. A
+// value which cannot be found returns an Explanation with Found false and no
+// error.
+func (ss Scoped) Explain(r cfgpath.Route, f *element.Field) (Explanation, error) {
+	ex, ok := ss.Root.(Explainer)
+	if !ok {
+		return Explanation{}, errors.NewNotSupportedf("[config] Root %T does not implement interface Explainer", ss.Root)
+	}
+	p, err := cfgpath.Make(r)
+	if err != nil {
+		return Explanation{}, errors.Wrapf(err, "[config] Explain. Route %q", r)
+	}
+
+	var scp scope.Type
+	if f != nil {
+		scp = f.Scopes.Top()
+	}
+	var ps = make([]cfgpath.Path, 0, 3)
+	if ss.isAllowedStore(scp) {
+		ps = append(ps, p.BindStore(ss.StoreID))
+	}
+	if ss.isAllowedWebsite(scp) {
+		ps = append(ps, p.BindWebsite(ss.WebsiteID))
+	}
+	p.ScopeID = scope.DefaultTypeID
+	ps = append(ps, p)
+
+	e := Explanation{
+		Route:     r.Data,
+		WebsiteID: ss.WebsiteID,
+		StoreID:   ss.StoreID,
+		Lookups:   make([]Lookup, 0, len(ps)),
+	}
+	for _, p := range ps {
+		v, src, err := ex.Explain(p)
+		l := Lookup{Path: p.String()}
+		switch {
+		case err == nil:
+			l.Found = true
+			l.Source = src
+			e.Lookups = append(e.Lookups, l)
+			e.Value = v
+			e.Found = true
+			e.Path = l.Path
+			e.Source = src
+			return e, nil
+		case !errors.IsNotFound(err):
+			l.Error = err.Error()
+			e.Lookups = append(e.Lookups, l)
+			return e, errors.Wrapf(err, "[config] Explain. Path %q", p)
+		}
+		e.Lookups = append(e.Lookups, l)
+	}
+
+	if f != nil && f.Default != nil {
+		e.Value = f.Default
+		e.Found = true
+		e.Source = SourceDefault
+		e.DefaultApplied = true
+	}
+	return e, nil
+}
@@ -0,0 +1,85 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+)
+
+// ExplainHandler returns a debug HTTP handler which responds with the JSON
+// encoded Explanation of a route. Query parameters: "route" (required),
+// "website" and "store" (optional IDs). The route gets searched in the
+// optional sections to apply the scope restriction and the default value of
+// its element.Field. Example:
+//
+//	GET /debug/config/explain?route=web/unsecure/base_url&website=1&store=2
+//
+// Mount the handler only on an internal debug address because it reveals
+// configuration values.
+func ExplainHandler(root Getter, sections element.SectionSlice) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		route := cfgpath.MakeRoute(q.Get("route"))
+		if route.Data == "" {
+			http.Error(w, "[config] Missing query parameter route", http.StatusBadRequest)
+			return
+		}
+		var ids [2]int64
+		for i, key := range [...]string{"website", "store"} {
+			v := q.Get(key)
+			if v == "" {
+				continue
+			}
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || id < 0 {
+				http.Error(w, "[config] Invalid query parameter "+key+": "+strconv.Quote(v), http.StatusBadRequest)
+				return
+			}
+			ids[i] = id
+		}
+
+		ss := NewScoped(root, ids[0], ids[1])
+		if !ss.IsValid() {
+			http.Error(w, "[config] Invalid combination of website and store ID", http.StatusBadRequest)
+			return
+		}
+
+		var f *element.Field
+		if len(sections) > 0 {
+			if fld, _, err := sections.FindField(route); err == nil {
+				f = &fld
+			}
+		}
+
+		e, err := ss.Explain(route, f)
+		if err != nil && e.Route == "" {
+			// Root does not support Explain or the route is invalid.
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		if err := json.NewEncoder(w).Encode(e); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,215 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Explainer = (*config.Service)(nil)
+
+type namedStorage struct {
+	config.Storager
+}
+
+func (namedStorage) Source(key cfgpath.Path) string { return "named:" + key.String() }
+
+type errStorage struct {
+	config.Storager
+}
+
+func (errStorage) Get(key cfgpath.Path) (interface{}, error) {
+	return nil, errors.NewFatalf("storage broken")
+}
+
+func newExplainService(t *testing.T) *config.Service {
+	im := config.NewInMemoryStore()
+	p := cfgpath.MustMakeByString("web/unsecure/base_url")
+	require.NoError(t, im.Set(p, "http://default.io/"))
+	require.NoError(t, im.Set(p.BindWebsite(1), "http://website1.io/"))
+	require.NoError(t, im.Set(p.BindStore(2), "http://store2.io/"))
+	return config.MustNewService(namedStorage{im})
+}
+
+var fieldBaseURL = element.Field{
+	ID:      cfgpath.MakeRoute("base_url"),
+	Scopes:  scope.PermStore,
+	Default: "http://field.io/",
+}
+
+func TestSourceOf(t *testing.T) {
+	p := cfgpath.MustMakeByString("aa/bb/cc")
+	assert.Exactly(t, "*config.kvmap", config.SourceOf(config.NewInMemoryStore(), p))
+	assert.Exactly(t, "named:default/0/aa/bb/cc", config.SourceOf(namedStorage{}, p))
+}
+
+func TestScoped_Explain(t *testing.T) {
+	srv := newExplainService(t)
+	defer func() { assert.NoError(t, srv.Close()) }()
+	r := cfgpath.MakeRoute("web/unsecure/base_url")
+
+	t.Run("store", func(t *testing.T) {
+		e, err := srv.NewScoped(1, 2).Explain(r, &fieldBaseURL)
+		require.NoError(t, err)
+		assert.Exactly(t, "http://store2.io/", e.Value)
+		assert.True(t, e.Found)
+		assert.False(t, e.DefaultApplied)
+		assert.Exactly(t, "stores/2/web/unsecure/base_url", e.Path)
+		assert.Exactly(t, "named:stores/2/web/unsecure/base_url", e.Source)
+		assert.Exactly(t, []config.Lookup{
+			{Path: "stores/2/web/unsecure/base_url", Found: true, Source: "named:stores/2/web/unsecure/base_url"},
+		}, e.Lookups)
+	})
+
+	t.Run("store falls back to website", func(t *testing.T) {
+		e, err := srv.NewScoped(1, 3).Explain(r, &fieldBaseURL)
+		require.NoError(t, err)
+		assert.Exactly(t, "http://website1.io/", e.Value)
+		assert.Exactly(t, []config.Lookup{
+			{Path: "stores/3/web/unsecure/base_url"},
+			{Path: "websites/1/web/unsecure/base_url", Found: true, Source: "named:websites/1/web/unsecure/base_url"},
+		}, e.Lookups)
+	})
+
+	t.Run("field restricted to default scope", func(t *testing.T) {
+		f := fieldBaseURL
+		f.Scopes = scope.PermDefault
+		e, err := srv.NewScoped(1, 2).Explain(r, &f)
+		require.NoError(t, err)
+		assert.Exactly(t, "http://default.io/", e.Value)
+		assert.Exactly(t, []config.Lookup{
+			{Path: "default/0/web/unsecure/base_url", Found: true, Source: "named:default/0/web/unsecure/base_url"},
+		}, e.Lookups)
+	})
+
+	t.Run("without field", func(t *testing.T) {
+		e, err := srv.NewScoped(2, 4).Explain(r, nil)
+		require.NoError(t, err)
+		assert.Exactly(t, "http://default.io/", e.Value)
+		assert.Len(t, e.Lookups, 3)
+	})
+
+	t.Run("default of field applies", func(t *testing.T) {
+		e, err := srv.NewScoped(1, 2).Explain(cfgpath.MakeRoute("web/secure/base_url"), &fieldBaseURL)
+		require.NoError(t, err)
+		assert.Exactly(t, "http://field.io/", e.Value)
+		assert.True(t, e.Found)
+		assert.True(t, e.DefaultApplied)
+		assert.Exactly(t, config.SourceDefault, e.Source)
+		assert.Empty(t, e.Path)
+		assert.Len(t, e.Lookups, 3)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		e, err := srv.NewScoped(1, 2).Explain(cfgpath.MakeRoute("web/secure/base_url"), nil)
+		require.NoError(t, err)
+		assert.Nil(t, e.Value)
+		assert.False(t, e.Found)
+		assert.Len(t, e.Lookups, 3)
+	})
+
+	t.Run("invalid route", func(t *testing.T) {
+		_, err := srv.NewScoped(1, 2).Explain(cfgpath.MakeRoute("web"), nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("storage error", func(t *testing.T) {
+		srv := config.MustNewService(errStorage{config.NewInMemoryStore()})
+		defer func() { assert.NoError(t, srv.Close()) }()
+		e, err := srv.NewScoped(1, 2).Explain(r, nil)
+		assert.True(t, errors.IsFatal(err), "%+v", err)
+		require.Len(t, e.Lookups, 1)
+		assert.Contains(t, e.Lookups[0].Error, "storage broken")
+	})
+
+	t.Run("root not an Explainer", func(t *testing.T) {
+		_, err := config.NewScoped(struct{ config.Getter }{srv}, 1, 2).Explain(r, nil)
+		assert.True(t, errors.IsNotSupported(err), "%+v", err)
+	})
+}
+
+func TestExplainHandler(t *testing.T) {
+	srv := newExplainService(t)
+	defer func() { assert.NoError(t, srv.Close()) }()
+
+	sections := element.NewSectionSlice(element.Section{
+		ID: cfgpath.MakeRoute("web"),
+		Groups: element.NewGroupSlice(element.Group{
+			ID:     cfgpath.MakeRoute("secure"),
+			Fields: element.NewFieldSlice(fieldBaseURL),
+		}),
+	})
+	h := config.ExplainHandler(srv, sections)
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		return rec
+	}
+
+	t.Run("store value", func(t *testing.T) {
+		rec := serve("/?route=web/unsecure/base_url&website=1&store=2")
+		require.Exactly(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Exactly(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+		var e config.Explanation
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e))
+		assert.Exactly(t, "http://store2.io/", e.Value)
+		assert.Exactly(t, "stores/2/web/unsecure/base_url", e.Path)
+		assert.Exactly(t, int64(2), e.StoreID)
+	})
+
+	t.Run("field default", func(t *testing.T) {
+		rec := serve("/?route=web/secure/base_url&website=1&store=2")
+		require.Exactly(t, http.StatusOK, rec.Code, rec.Body.String())
+		var e config.Explanation
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e))
+		assert.Exactly(t, "http://field.io/", e.Value)
+		assert.True(t, e.DefaultApplied)
+		assert.Len(t, e.Lookups, 3)
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		for _, target := range []string{
+			"/",
+			"/?route=web/unsecure/base_url&store=x",
+			"/?route=web/unsecure/base_url&website=-1",
+			"/?route=web/unsecure/base_url&store=2",
+			"/?route=web",
+		} {
+			rec := serve(target)
+			assert.Exactly(t, http.StatusBadRequest, rec.Code, target)
+		}
+	})
+
+	t.Run("storage error", func(t *testing.T) {
+		srv := config.MustNewService(errStorage{config.NewInMemoryStore()})
+		defer func() { assert.NoError(t, srv.Close()) }()
+		rec := httptest.NewRecorder()
+		config.ExplainHandler(srv, nil).ServeHTTP(rec, httptest.NewRequest("GET", "/?route=web/unsecure/base_url", nil))
+		assert.Exactly(t, http.StatusInternalServerError, rec.Code)
+		assert.Contains(t, rec.Body.String(), "storage broken")
+	})
+}
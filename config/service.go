@@ -15,6 +15,7 @@
 package config
 
 import (
+	"context"
 	"time"
 
 	"github.com/corestoreio/pkg/config/cfgpath"
@@ -80,6 +81,14 @@ type Storager interface {
 	AllKeys() (cfgpath.PathSlice, error)
 }
 
+// ContextSetter gets implemented by a Storager which needs the context of a
+// write, for example to record the actor of a change. Service.WriteContext
+// prefers SetContext over Set.
+type ContextSetter interface {
+	// SetContext same as Storager.Set but with a context.
+	SetContext(ctx context.Context, key cfgpath.Path, value interface{}) error
+}
+
 // Service main configuration provider. Please use the NewService() function
 type Service struct {
 	// backend is the underlying data holding provider. Only access it if you
@@ -168,11 +177,24 @@ func (s *Service) NewScoped(websiteID, storeID int64) Scoped {
 //		// 6 for example comes from core_store/store database table
 //		err := Write(p.Bind(scope.StoreID, 6), "CHF")
 func (s *Service) Write(p cfgpath.Path, v interface{}) error {
+	return s.WriteContext(context.Background(), p, v)
+}
+
+// WriteContext same as Write but passes the context to the backend if the
+// backend implements interface ContextSetter. For example
+// config/storage/cfghistory takes the actor of the change from the context.
+func (s *Service) WriteContext(ctx context.Context, p cfgpath.Path, v interface{}) error {
 	if s.Log.IsDebug() {
 		s.Log.Debug("config.Service.Write", log.Stringer("path", p), log.Object("val", v))
 	}
 
-	if err := s.backend.Set(p, v); err != nil {
+	var err error
+	if cs, ok := s.backend.(ContextSetter); ok {
+		err = cs.SetContext(ctx, p, v)
+	} else {
+		err = s.backend.Set(p, v)
+	}
+	if err != nil {
 		return errors.Wrap(err, "[config] sStorage.Set")
 	}
 	if s.pubSub != nil {
@@ -181,6 +203,19 @@ func (s *Service) Write(p cfgpath.Path, v interface{}) error {
 	return nil
 }
 
+// Publish notifies the subscribers about a changed path without writing to the
+// backend. Use it if the value has already been applied to the backend by other
+// means. Does nothing if the pub/sub service has not been started, see
+// WithPubSub. Implements interface Publisher.
+func (s *Service) Publish(p cfgpath.Path) {
+	if s.Log.IsDebug() {
+		s.Log.Debug("config.Service.Publish", log.Stringer("path", p))
+	}
+	if s.pubSub != nil {
+		s.sendMsg(p)
+	}
+}
+
 // get generic getter ... not sure if this should be public ...
 func (s *Service) get(p cfgpath.Path) (interface{}, error) {
 	if s.Log.IsDebug() {
@@ -51,6 +51,15 @@ type Subscriber interface {
 	Subscribe(cfgpath.Route, MessageReceiver) (subscriptionID int, err error)
 }
 
+// Publisher notifies the subscribers about a changed path without writing a
+// value. Implemented by the config.Service. Used by packages which apply
+// changes made by other processes directly to a Storager, see package
+// config/storage/ccd.
+type Publisher interface {
+	// Publish sends the path to all subscribers of its route.
+	Publish(cfgpath.Path)
+}
+
 // pubSub embedded pointer struct into the Service
 type pubSub struct {
 	// subMap, subscribed writers are getting called when a write event
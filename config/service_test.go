@@ -15,6 +15,7 @@
 package config_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -87,6 +88,33 @@ func TestService_Write(t *testing.T) {
 	assert.True(t, errors.IsNotValid(err), "Error: %s", err)
 }
 
+type ctxKey struct{}
+
+// contextStorage records the context value of each SetContext call.
+type contextStorage struct {
+	config.Storager
+	values []interface{}
+}
+
+func (cs *contextStorage) SetContext(ctx context.Context, key cfgpath.Path, value interface{}) error {
+	cs.values = append(cs.values, ctx.Value(ctxKey{}))
+	return cs.Storager.Set(key, value)
+}
+
+func TestService_WriteContext(t *testing.T) {
+	cs := &contextStorage{Storager: config.NewInMemoryStore()}
+	srv := config.MustNewService(cs)
+
+	p := cfgpath.MustMakeByString("aa/bb/cc")
+	assert.NoError(t, srv.WriteContext(context.WithValue(context.Background(), ctxKey{}, "jdoe"), p, 1))
+	assert.NoError(t, srv.Write(p, 2))
+	assert.Exactly(t, []interface{}{"jdoe", nil}, cs.values)
+
+	v, err := srv.Int(p)
+	assert.NoError(t, err)
+	assert.Exactly(t, 2, v)
+}
+
 func TestService_Types(t *testing.T) {
 
 	basePath := cfgpath.MustNewByParts("aa/bb/cc")
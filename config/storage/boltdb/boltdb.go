@@ -0,0 +1,213 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltdb
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/boltdb/bolt"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// BucketName defines the root bucket for all configuration values. Each scope
+// gets its own nested bucket, for example `default/0`, `websites/1` or
+// `stores/2`. The keys in a scope bucket are the routes.
+var BucketName = []byte("config")
+
+var errKeyNotFound = errors.NewNotFoundf(`[boltdb] Key not found`)
+
+// Storage implements the config.Storager interface on a bolt database. Values
+// get encoded with a type tag, so Get returns the same type which has been
+// passed to Set. Supported types are string, []byte, bool, all int, uint and
+// float types, time.Time and time.Duration. Other types implementing
+// fmt.Stringer get stored as string.
+type Storage struct {
+	DB *bolt.DB
+}
+
+// OpenFile creates and opens a bolt database at the given path. If the file
+// does not exist then it will be created automatically. If the third argument
+// Options doesn't get applied bolt.DefaultOptions will be used.
+func OpenFile(path string, mode os.FileMode, options ...*bolt.Options) (*Storage, error) {
+	var opt = bolt.DefaultOptions
+	if len(options) == 1 {
+		opt = options[0]
+	}
+	db, err := bolt.Open(path, mode, opt)
+	if err != nil {
+		return nil, errors.NewFatalf("[boltdb] bolt.Open: %s", err)
+	}
+	s, err := New(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, errors.Wrap(err, "[boltdb] OpenFile.New")
+	}
+	return s, nil
+}
+
+// New uses an existing DB and creates the bucket from variable name BucketName
+// if that bucket does not exists.
+func New(db *bolt.DB) (*Storage, error) {
+	err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(BucketName); err != nil {
+			return errors.NewFatalf("[boltdb] bolt.CreateBucketIfNotExists: %s", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "[boltdb] db.Update")
+	}
+	return &Storage{DB: db}, nil
+}
+
+// Close closes the bolt database.
+func (s *Storage) Close() error {
+	return errors.Wrap(s.DB.Close(), "[boltdb] DB.Close")
+}
+
+// Set implements config.Storager interface. A nil value deletes the key.
+func (s *Storage) Set(key cfgpath.Path, value interface{}) error {
+	return s.Batch(func(w config.Writer) error {
+		return w.Write(key, value)
+	})
+}
+
+// Batch runs function fn within one read-write transaction. Either all values
+// written to the config.Writer get stored or none of them if an error occurs.
+// The config.Writer must not be used after fn returns. Values written via Batch
+// do not get published to the subscribers of the config.Service.
+func (s *Storage) Batch(fn func(config.Writer) error) error {
+	err := s.DB.Update(func(tx *bolt.Tx) error {
+		return fn(txWriter{tx})
+	})
+	return errors.Wrap(err, "[boltdb] Batch.Update")
+}
+
+// Get implements config.Storager interface.
+// Error behaviour: NotFound.
+func (s *Storage) Get(key cfgpath.Path) (interface{}, error) {
+	bucket, route, err := bucketRoute(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "[boltdb] Get.bucketRoute")
+	}
+	var v interface{}
+	var found bool
+	if err := s.DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(BucketName).Bucket(bucket)
+		if b == nil {
+			return nil
+		}
+		data := b.Get(route)
+		if data == nil {
+			return nil
+		}
+		found = true
+		v, err = decodeValue(data)
+		return err
+	}); err != nil {
+		return nil, errors.Wrapf(err, "[boltdb] Get.View %q", key)
+	}
+	if !found {
+		return nil, errKeyNotFound
+	}
+	return v, nil
+}
+
+// AllKeys implements config.Storager interface. The keys are sorted by route
+// and scope. Plain keys in the root bucket get ignored.
+func (s *Storage) AllKeys() (cfgpath.PathSlice, error) {
+	var ret cfgpath.PathSlice
+	err := s.DB.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket(BucketName)
+		return root.ForEach(func(bucket, v []byte) error {
+			b := root.Bucket(bucket)
+			if v != nil || b == nil {
+				return nil // plain key and not a scope bucket
+			}
+			scopeID, err := parseBucket(bucket)
+			if err != nil {
+				return errors.Wrapf(err, "[boltdb] Bucket %q", bucket)
+			}
+			return b.ForEach(func(route, _ []byte) error {
+				ret = append(ret, cfgpath.Path{
+					Route:   cfgpath.MakeRoute(string(route)),
+					ScopeID: scopeID,
+				})
+				return nil
+			})
+		})
+	})
+	ret.Sort()
+	return ret, errors.Wrap(err, "[boltdb] AllKeys.View")
+}
+
+// txWriter writes into an open read-write transaction.
+type txWriter struct {
+	tx *bolt.Tx
+}
+
+func (w txWriter) Write(key cfgpath.Path, value interface{}) error {
+	bucket, route, err := bucketRoute(key)
+	if err != nil {
+		return errors.Wrap(err, "[boltdb] Write.bucketRoute")
+	}
+	if value == nil {
+		if b := w.tx.Bucket(BucketName).Bucket(bucket); b != nil {
+			return errors.Wrapf(b.Delete(route), "[boltdb] Delete %q", key)
+		}
+		return nil
+	}
+	data, err := encodeValue(value)
+	if err != nil {
+		return errors.Wrapf(err, "[boltdb] Write.encodeValue %q", key)
+	}
+	b, err := w.tx.Bucket(BucketName).CreateBucketIfNotExists(bucket)
+	if err != nil {
+		return errors.NewFatalf("[boltdb] bolt.CreateBucketIfNotExists %q: %s", bucket, err)
+	}
+	return errors.Wrapf(b.Put(route, data), "[boltdb] Put %q", key)
+}
+
+// bucketRoute validates the path and returns the name of the scope bucket
+// and the route.
+func bucketRoute(key cfgpath.Path) (bucket, route []byte, err error) {
+	fq, err := key.FQ()
+	if err != nil {
+		return nil, nil, err
+	}
+	// fq: scope/ID/route
+	pos := strings.IndexByte(fq.Data, cfgpath.Separator)
+	pos += 1 + strings.IndexByte(fq.Data[pos+1:], cfgpath.Separator)
+	return []byte(fq.Data[:pos]), []byte(fq.Data[pos+1:]), nil
+}
+
+// parseBucket returns the scope of a bucket name like `websites/1`.
+func parseBucket(bucket []byte) (scope.TypeID, error) {
+	name := string(bucket)
+	pos := strings.IndexByte(name, cfgpath.Separator)
+	if pos < 0 || !scope.Valid(name[:pos]) {
+		return 0, errors.NewNotSupportedf("[boltdb] Unknown scope in bucket %q", name)
+	}
+	id, err := strconv.ParseInt(name[pos+1:], 10, 64)
+	if err != nil {
+		return 0, errors.NewNotValidf("[boltdb] Invalid scope ID in bucket %q: %s", name, err)
+	}
+	return scope.MakeTypeID(scope.FromString(name[:pos]), id), nil
+}
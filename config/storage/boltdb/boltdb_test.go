@@ -0,0 +1,238 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltdb_test
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/boltdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Storager = (*boltdb.Storage)(nil)
+
+func openTemp(t *testing.T) (*boltdb.Storage, func()) {
+	dir, err := ioutil.TempDir("", "boltdb_")
+	require.NoError(t, err)
+	s, err := boltdb.OpenFile(filepath.Join(dir, "config.db"), 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return s, func() {
+		assert.NoError(t, s.Close())
+		os.RemoveAll(dir)
+	}
+}
+
+type stringer struct{}
+
+func (stringer) String() string { return "I'm a Stringer" }
+
+func TestStorage_SetGet(t *testing.T) {
+	t.Parallel()
+	s, cleanup := openTemp(t)
+	defer cleanup()
+
+	now := time.Date(2018, 7, 29, 13, 14, 15, 16, time.FixedZone("CEST", 7200))
+	p := cfgpath.MustMakeByString("aa/bb/cc")
+
+	tests := []struct {
+		val  interface{}
+		want interface{}
+	}{
+		{"Gopher", "Gopher"},
+		{"", ""},
+		{[]byte("Bytes"), []byte("Bytes")},
+		{true, true},
+		{false, false},
+		{-1, -1},
+		{int8(math.MinInt8), int8(math.MinInt8)},
+		{int16(math.MinInt16), int16(math.MinInt16)},
+		{int32(math.MinInt32), int32(math.MinInt32)},
+		{int64(math.MinInt64), int64(math.MinInt64)},
+		{uint(33), uint(33)},
+		{uint8(math.MaxUint8), uint8(math.MaxUint8)},
+		{uint16(math.MaxUint16), uint16(math.MaxUint16)},
+		{uint32(math.MaxUint32), uint32(math.MaxUint32)},
+		{uint64(math.MaxUint64), uint64(math.MaxUint64)},
+		{float32(math.E), float32(math.E)},
+		{math.Pi, math.Pi},
+		{time.Minute * 3, time.Minute * 3},
+		{stringer{}, "I'm a Stringer"},
+	}
+	for i, test := range tests {
+		require.NoError(t, s.Set(p.BindStore(int64(i)), test.val), "Index %d", i)
+		have, err := s.Get(p.BindStore(int64(i)))
+		require.NoError(t, err, "Index %d", i)
+		assert.Exactly(t, test.want, have, "Index %d", i)
+	}
+
+	require.NoError(t, s.Set(p, now))
+	have, err := s.Get(p)
+	require.NoError(t, err)
+	assert.True(t, now.Equal(have.(time.Time)), "%s", have)
+
+	err = s.Set(p, struct{}{})
+	assert.True(t, errors.IsNotSupported(err), "%+v", err)
+
+	err = s.Set(cfgpath.Path{Route: cfgpath.MakeRoute("aa")}, 1)
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+}
+
+func TestStorage_Get_NotFound(t *testing.T) {
+	t.Parallel()
+	s, cleanup := openTemp(t)
+	defer cleanup()
+
+	p := cfgpath.MustMakeByString("aa/bb/cc")
+	v, err := s.Get(p)
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+	assert.Nil(t, v)
+
+	require.NoError(t, s.Set(p, 1))
+	v, err = s.Get(p.BindWebsite(1))
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+	assert.Nil(t, v)
+
+	// nil deletes the key
+	require.NoError(t, s.Set(p, nil))
+	v, err = s.Get(p)
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+	assert.Nil(t, v)
+	require.NoError(t, s.Set(p.BindStore(3), nil))
+}
+
+func TestStorage_Batch(t *testing.T) {
+	t.Parallel()
+	s, cleanup := openTemp(t)
+	defer cleanup()
+
+	p := cfgpath.MustMakeByString("aa/bb/cc")
+	require.NoError(t, s.Batch(func(w config.Writer) error {
+		if err := w.Write(p, "default"); err != nil {
+			return err
+		}
+		if err := w.Write(p.BindWebsite(1), "website"); err != nil {
+			return err
+		}
+		return w.Write(cfgpath.MustMakeByString("xx/yy/zz").BindStore(2), "store")
+	}))
+
+	errRollback := errors.NewFatalf("rollback")
+	err := s.Batch(func(w config.Writer) error {
+		if err := w.Write(p, "changed"); err != nil {
+			return err
+		}
+		if err := w.Write(p.BindStore(5), "changed"); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	assert.True(t, errors.IsFatal(err), "%+v", err)
+
+	v, err := s.Get(p)
+	require.NoError(t, err)
+	assert.Exactly(t, "default", v)
+	_, err = s.Get(p.BindStore(5))
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+
+	keys, err := s.AllKeys()
+	require.NoError(t, err)
+	var have []string
+	for _, k := range keys {
+		have = append(have, k.String())
+	}
+	assert.Exactly(t, []string{
+		"default/0/aa/bb/cc",
+		"websites/1/aa/bb/cc",
+		"stores/2/xx/yy/zz",
+	}, have)
+}
+
+func TestStorage_AllKeys_PlainRootKey(t *testing.T) {
+	t.Parallel()
+	s, cleanup := openTemp(t)
+	defer cleanup()
+
+	require.NoError(t, s.Set(cfgpath.MustMakeByString("xx/yy/zz").BindStore(3), 1))
+	require.NoError(t, s.Set(cfgpath.MustMakeByString("aa/bb/cc"), 2))
+	require.NoError(t, s.DB.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltdb.BucketName).Put([]byte("websites/7"), []byte("plain"))
+	}))
+
+	keys, err := s.AllKeys()
+	require.NoError(t, err)
+	var have []string
+	for _, k := range keys {
+		have = append(have, k.String())
+	}
+	assert.Exactly(t, []string{"default/0/aa/bb/cc", "stores/3/xx/yy/zz"}, have)
+}
+
+func TestStorage_Service(t *testing.T) {
+	t.Parallel()
+	s, cleanup := openTemp(t)
+	defer cleanup()
+
+	srv := config.MustNewService(s)
+	defer func() { assert.NoError(t, srv.Close()) }()
+
+	now := time.Now()
+	pBool := cfgpath.MustMakeByString("aa/bb/bool").BindWebsite(2)
+	pInt := cfgpath.MustMakeByString("aa/bb/int").BindStore(3)
+	pFloat := cfgpath.MustMakeByString("aa/bb/float")
+	pTime := cfgpath.MustMakeByString("aa/bb/time")
+	pDuration := cfgpath.MustMakeByString("aa/bb/duration")
+
+	require.NoError(t, srv.Write(pBool, true))
+	require.NoError(t, srv.Write(pInt, 4711))
+	require.NoError(t, srv.Write(pFloat, 2.7182))
+	require.NoError(t, srv.Write(pTime, now))
+	require.NoError(t, srv.Write(pDuration, time.Hour))
+
+	b, err := srv.Bool(pBool)
+	require.NoError(t, err)
+	assert.True(t, b)
+	i, err := srv.Int(pInt)
+	require.NoError(t, err)
+	assert.Exactly(t, 4711, i)
+	f, err := srv.Float64(pFloat)
+	require.NoError(t, err)
+	assert.Exactly(t, 2.7182, f)
+	tm, err := srv.Time(pTime)
+	require.NoError(t, err)
+	assert.True(t, now.Equal(tm), "%s", tm)
+	d, err := srv.Duration(pDuration)
+	require.NoError(t, err)
+	assert.Exactly(t, time.Hour, d)
+}
+
+func TestOpenFile_Error(t *testing.T) {
+	t.Parallel()
+	s, err := boltdb.OpenFile(filepath.Join("non", "existent"), 0400)
+	assert.Nil(t, s)
+	assert.True(t, errors.IsFatal(err), "Error: %s", err)
+}
@@ -15,6 +15,11 @@
 // Package boltdb uses the bolt database for reading and writing
 // configuration paths.
 //
-// Maybe implements synchronization with MySQL core_config_data table.
-// Converts all values to byte slices.
+// Each scope gets its own bucket below the root bucket BucketName. Values get
+// encoded with a type tag, so the getters of the config.Service return the
+// same values as with the in-memory storage. Multiple values can be written
+// within one transaction with function Storage.Batch.
+//
+// The storage suits for embedded persistent configurations on nodes without a
+// MySQL server.
 package boltdb
@@ -0,0 +1,165 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/corestoreio/errors"
+)
+
+// Type tags prepended to each encoded value. Do not change the order because
+// the tags get persisted.
+const (
+	typeString byte = iota + 1
+	typeBytes
+	typeBool
+	typeInt
+	typeInt8
+	typeInt16
+	typeInt32
+	typeInt64
+	typeUint
+	typeUint8
+	typeUint16
+	typeUint32
+	typeUint64
+	typeFloat32
+	typeFloat64
+	typeTime
+	typeDuration
+)
+
+// encodeValue converts a value into a byte slice. The first byte contains the
+// type tag, the remaining bytes the value. Types which are not supported but
+// implement fmt.Stringer get stored as string.
+func encodeValue(v interface{}) ([]byte, error) {
+	switch vt := v.(type) {
+	case string:
+		return append([]byte{typeString}, vt...), nil
+	case []byte:
+		return append([]byte{typeBytes}, vt...), nil
+	case bool:
+		if vt {
+			return []byte{typeBool, 1}, nil
+		}
+		return []byte{typeBool, 0}, nil
+	case int:
+		return encodeUint64(typeInt, uint64(vt)), nil
+	case int8:
+		return encodeUint64(typeInt8, uint64(vt)), nil
+	case int16:
+		return encodeUint64(typeInt16, uint64(vt)), nil
+	case int32:
+		return encodeUint64(typeInt32, uint64(vt)), nil
+	case int64:
+		return encodeUint64(typeInt64, uint64(vt)), nil
+	case uint:
+		return encodeUint64(typeUint, uint64(vt)), nil
+	case uint8:
+		return encodeUint64(typeUint8, uint64(vt)), nil
+	case uint16:
+		return encodeUint64(typeUint16, uint64(vt)), nil
+	case uint32:
+		return encodeUint64(typeUint32, uint64(vt)), nil
+	case uint64:
+		return encodeUint64(typeUint64, vt), nil
+	case float32:
+		return encodeUint64(typeFloat32, math.Float64bits(float64(vt))), nil
+	case float64:
+		return encodeUint64(typeFloat64, math.Float64bits(vt)), nil
+	case time.Duration:
+		return encodeUint64(typeDuration, uint64(vt)), nil
+	case time.Time:
+		b, err := vt.MarshalBinary()
+		if err != nil {
+			return nil, errors.NewNotValidf("[boltdb] Time.MarshalBinary: %s", err)
+		}
+		return append([]byte{typeTime}, b...), nil
+	case fmt.Stringer:
+		return append([]byte{typeString}, vt.String()...), nil
+	}
+	return nil, errors.NewNotSupportedf("[boltdb] Type %T not supported", v)
+}
+
+func encodeUint64(typ byte, v uint64) []byte {
+	var buf [9]byte
+	buf[0] = typ
+	binary.BigEndian.PutUint64(buf[1:], v)
+	return buf[:]
+}
+
+// decodeValue converts an encoded byte slice back into its type. The returned
+// value does not reference b.
+func decodeValue(b []byte) (interface{}, error) {
+	if len(b) == 0 {
+		return nil, errors.NewNotValidf("[boltdb] Empty value")
+	}
+	typ, data := b[0], b[1:]
+	switch typ {
+	case typeString:
+		return string(data), nil
+	case typeBytes:
+		return append([]byte{}, data...), nil
+	case typeBool:
+		if len(data) != 1 {
+			return nil, errors.NewNotValidf("[boltdb] Invalid bool length %d", len(data))
+		}
+		return data[0] == 1, nil
+	case typeTime:
+		var t time.Time
+		if err := t.UnmarshalBinary(data); err != nil {
+			return nil, errors.NewNotValidf("[boltdb] Time.UnmarshalBinary: %s", err)
+		}
+		return t, nil
+	}
+
+	if len(data) != 8 {
+		return nil, errors.NewNotValidf("[boltdb] Invalid length %d for type %d", len(data), typ)
+	}
+	u := binary.BigEndian.Uint64(data)
+	switch typ {
+	case typeInt:
+		return int(int64(u)), nil
+	case typeInt8:
+		return int8(int64(u)), nil
+	case typeInt16:
+		return int16(int64(u)), nil
+	case typeInt32:
+		return int32(int64(u)), nil
+	case typeInt64:
+		return int64(u), nil
+	case typeUint:
+		return uint(u), nil
+	case typeUint8:
+		return uint8(u), nil
+	case typeUint16:
+		return uint16(u), nil
+	case typeUint32:
+		return uint32(u), nil
+	case typeUint64:
+		return u, nil
+	case typeFloat32:
+		return float32(math.Float64frombits(u)), nil
+	case typeFloat64:
+		return math.Float64frombits(u), nil
+	case typeDuration:
+		return time.Duration(int64(u)), nil
+	}
+	return nil, errors.NewNotSupportedf("[boltdb] Unknown type %d", typ)
+}
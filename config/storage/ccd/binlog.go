@@ -0,0 +1,230 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ccd
+
+import (
+	"context"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/corestoreio/pkg/util/conv"
+)
+
+// BinlogHandler republishes the row changes of the table core_config_data,
+// received via the binary log of MySQL, so that the subscribers of a
+// config.Service get notified about changes made by other processes, for
+// example the Magento backend. Inserted and updated rows apply the new value,
+// deleted rows remove the path. If an update changes the path or the scope, the
+// old path gets removed. Implements interface binlogsync.RowsEventHandler.
+//
+// If the backend of the config.Service is a DBStorage, set the fields Storage
+// and Publisher. The changes get applied to the in-memory index of the
+// DBStorage and published without writing back to the database. Otherwise set
+// the field Writer, for example to a config.Service with an in-memory backend,
+// which stores and publishes the values, deleted paths get written as nil.
+type BinlogHandler struct {
+	// Storage receives the changes in its in-memory index. The database does
+	// not get written.
+	Storage *DBStorage
+	// Publisher gets notified about each path applied to Storage. Usually the
+	// config.Service whose backend is Storage. Optional.
+	Publisher config.Publisher
+	// Writer receives the changed values if Storage is nil.
+	Writer config.Writer
+	// TableName of core_config_data including a prefix. Default the table of
+	// Storage or DefaultTable.
+	TableName string
+	// Log optional logger. Default log.BlackHole.
+	Log log.Logger
+}
+
+// ccdRow contains the relevant columns of a binlog row.
+type ccdRow struct {
+	path  cfgpath.Path
+	value interface{}
+}
+
+func (bh *BinlogHandler) tableName() string {
+	switch {
+	case bh.TableName != "":
+		return bh.TableName
+	case bh.Storage != nil:
+		return bh.Storage.table
+	}
+	return DefaultTable
+}
+
+// Do writes the changed rows of the table core_config_data into the Writer.
+// Rows of other tables get ignored. The SnapshotAction gets treated like an
+// insert.
+func (bh *BinlogHandler) Do(_ context.Context, action string, t ddl.Table, rows [][]interface{}) error {
+	if t.Name != bh.tableName() {
+		return nil
+	}
+
+	var idx [4]int // scope, scope_id, path, value
+	for i, name := range [...]string{"scope", "scope_id", "path", "value"} {
+		idx[i] = -1
+		for j, c := range t.Columns {
+			if c.Field == name {
+				idx[i] = j
+			}
+		}
+		if idx[i] < 0 {
+			return errors.NewNotFoundf("[ccd] BinlogHandler: Column %q not found in table %q", name, t.Name)
+		}
+	}
+
+	switch action {
+	case binlogsync.InsertAction, binlogsync.SnapshotAction:
+		for _, row := range rows {
+			r, err := newCCDRow(idx, row)
+			if err != nil {
+				return errors.Wrapf(err, "[ccd] BinlogHandler.Do action %q", action)
+			}
+			if err := bh.write(action, r.path, r.value, false); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case binlogsync.DeleteAction:
+		for _, row := range rows {
+			r, err := newCCDRow(idx, row)
+			if err != nil {
+				return errors.Wrapf(err, "[ccd] BinlogHandler.Do action %q", action)
+			}
+			if err := bh.write(action, r.path, nil, true); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case binlogsync.UpdateAction:
+		if len(rows)%2 != 0 {
+			return errors.NewNotValidf("[ccd] BinlogHandler.Do: Update requires an even number of rows, got %d", len(rows))
+		}
+		for i := 0; i < len(rows); i += 2 {
+			before, err := newCCDRow(idx, rows[i])
+			if err != nil {
+				return errors.Wrapf(err, "[ccd] BinlogHandler.Do action %q before image", action)
+			}
+			after, err := newCCDRow(idx, rows[i+1])
+			if err != nil {
+				return errors.Wrapf(err, "[ccd] BinlogHandler.Do action %q after image", action)
+			}
+			if before.path.String() != after.path.String() {
+				if err := bh.write(action, before.path, nil, true); err != nil {
+					return errors.WithStack(err)
+				}
+			}
+			if err := bh.write(action, after.path, after.value, false); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+	return nil
+}
+
+func (bh *BinlogHandler) write(action string, p cfgpath.Path, v interface{}, deleted bool) error {
+	if bh.Log != nil && bh.Log.IsDebug() {
+		bh.Log.Debug("ccd.BinlogHandler.Write", log.String("action", action), log.Stringer("path", p), log.Object("val", v), log.Bool("deleted", deleted))
+	}
+	switch {
+	case bh.Storage != nil:
+		bh.Storage.apply(p, v, deleted)
+		if bh.Publisher != nil {
+			bh.Publisher.Publish(p)
+		}
+	case bh.Writer != nil:
+		if err := bh.Writer.Write(p, v); err != nil {
+			return errors.Wrapf(err, "[ccd] BinlogHandler.Writer.Write Path %q", p)
+		}
+	default:
+		return errors.NewEmptyf("[ccd] BinlogHandler requires a Storage or a Writer")
+	}
+	return nil
+}
+
+// newCCDRow extracts scope, scope ID, path and value from a binlog row. The
+// value gets converted from []byte to string, NULL stays nil.
+func newCCDRow(idx [4]int, row []interface{}) (ccdRow, error) {
+	for _, i := range idx {
+		if i >= len(row) {
+			return ccdRow{}, errors.NewNotValidf("[ccd] Row has %d columns but column index is %d", len(row), i)
+		}
+	}
+	scp, err := conv.ToStringE(row[idx[0]])
+	if err != nil {
+		return ccdRow{}, errors.Wrapf(err, "[ccd] Column scope: %#v", row[idx[0]])
+	}
+	id, err := conv.ToInt64E(row[idx[1]])
+	if err != nil {
+		return ccdRow{}, errors.Wrapf(err, "[ccd] Column scope_id: %#v", row[idx[1]])
+	}
+	route, err := conv.ToStringE(row[idx[2]])
+	if err != nil {
+		return ccdRow{}, errors.Wrapf(err, "[ccd] Column path: %#v", row[idx[2]])
+	}
+	p, err := cfgpath.MakeByString(route)
+	if err != nil {
+		return ccdRow{}, errors.Wrapf(err, "[ccd] cfgpath.MakeByString Path %q", route)
+	}
+
+	val := row[idx[3]]
+	if b, ok := val.([]byte); ok {
+		val = string(b)
+	}
+	return ccdRow{
+		path:  p.Bind(scope.FromString(scp).Pack(id)),
+		value: val,
+	}, nil
+}
+
+// Complete does nothing.
+func (bh *BinlogHandler) Complete(_ context.Context) error { return nil }
+
+// String returns the name of the handler.
+func (bh *BinlogHandler) String() string { return "ccd.BinlogHandler" }
+
+// WithBinlogSync registers a BinlogHandler at the Canal which applies all
+// changes of the table core_config_data to the Service. Subscribers of the
+// Service, see config.WithPubSub, receive the changed paths with the correct
+// scope. If the backend of the Service is a DBStorage, pass it as argument dbs,
+// so that the changes get applied to its in-memory index instead of being
+// written back to the database. With a nil dbs the changes get written into the
+// Service. Argument tableName can be empty to use the table of dbs or
+// DefaultTable. The Canal must be started by the caller.
+func WithBinlogSync(c *binlogsync.Canal, dbs *DBStorage, tableName string) config.Option {
+	return func(s *config.Service) error {
+		bh := &BinlogHandler{
+			TableName: tableName,
+			Log:       s.Log,
+		}
+		if dbs != nil {
+			bh.Storage = dbs
+			bh.Publisher = s
+		} else {
+			bh.Writer = s
+		}
+		f, err := binlogsync.NewFilter(binlogsync.IncludeTables(bh.tableName()))
+		if err != nil {
+			return errors.Wrapf(err, "[ccd] WithBinlogSync Table %q", bh.tableName())
+		}
+		c.RegisterFilteredRowsEventHandler(bh, f)
+		return nil
+	}
+}
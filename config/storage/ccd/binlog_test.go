@@ -0,0 +1,287 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ccd_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/ccd"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ binlogsync.RowsEventHandler = (*ccd.BinlogHandler)(nil)
+
+type writeRecorder struct {
+	paths  []string
+	values []interface{}
+	err    error
+}
+
+func (wr *writeRecorder) Write(p cfgpath.Path, v interface{}) error {
+	wr.paths = append(wr.paths, p.String())
+	wr.values = append(wr.values, v)
+	return wr.err
+}
+
+func tableCoreConfigData(name string) ddl.Table {
+	return ddl.Table{
+		Name: name,
+		Columns: ddl.Columns{
+			&ddl.Column{Field: "config_id"},
+			&ddl.Column{Field: "scope"},
+			&ddl.Column{Field: "scope_id"},
+			&ddl.Column{Field: "path"},
+			&ddl.Column{Field: "value"},
+		},
+	}
+}
+
+func TestBinlogHandler_Do(t *testing.T) {
+	ctx := context.Background()
+	tbl := tableCoreConfigData(ccd.DefaultTable)
+
+	t.Run("insert and snapshot", func(t *testing.T) {
+		wr := new(writeRecorder)
+		bh := &ccd.BinlogHandler{Writer: wr}
+		require.NoError(t, bh.Do(ctx, binlogsync.InsertAction, tbl, [][]interface{}{
+			{int64(1), []byte("default"), int64(0), []byte("web/cookie/cookie_lifetime"), []byte("3600")},
+			{int64(2), []byte("stores"), int64(2), []byte("general/locale/code"), nil},
+		}))
+		require.NoError(t, bh.Do(ctx, binlogsync.SnapshotAction, tbl, [][]interface{}{
+			{int64(3), "websites", int32(1), "carriers/flatrate/price", "5.00"},
+		}))
+		assert.Exactly(t, []string{
+			"default/0/web/cookie/cookie_lifetime",
+			"stores/2/general/locale/code",
+			"websites/1/carriers/flatrate/price",
+		}, wr.paths)
+		assert.Exactly(t, []interface{}{"3600", nil, "5.00"}, wr.values)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		wr := new(writeRecorder)
+		bh := &ccd.BinlogHandler{Writer: wr}
+		require.NoError(t, bh.Do(ctx, binlogsync.DeleteAction, tbl, [][]interface{}{
+			{int64(1), []byte("websites"), int64(3), []byte("web/cookie/cookie_lifetime"), []byte("3600")},
+		}))
+		assert.Exactly(t, []string{"websites/3/web/cookie/cookie_lifetime"}, wr.paths)
+		assert.Exactly(t, []interface{}{nil}, wr.values)
+	})
+
+	t.Run("update value", func(t *testing.T) {
+		wr := new(writeRecorder)
+		bh := &ccd.BinlogHandler{Writer: wr}
+		require.NoError(t, bh.Do(ctx, binlogsync.UpdateAction, tbl, [][]interface{}{
+			{int64(1), []byte("default"), int64(0), []byte("web/cookie/cookie_lifetime"), []byte("3600")},
+			{int64(1), []byte("default"), int64(0), []byte("web/cookie/cookie_lifetime"), []byte("7200")},
+		}))
+		assert.Exactly(t, []string{"default/0/web/cookie/cookie_lifetime"}, wr.paths)
+		assert.Exactly(t, []interface{}{"7200"}, wr.values)
+	})
+
+	t.Run("update scope removes old path", func(t *testing.T) {
+		wr := new(writeRecorder)
+		bh := &ccd.BinlogHandler{Writer: wr}
+		require.NoError(t, bh.Do(ctx, binlogsync.UpdateAction, tbl, [][]interface{}{
+			{int64(1), []byte("default"), int64(0), []byte("web/cookie/cookie_lifetime"), []byte("3600")},
+			{int64(1), []byte("stores"), int64(4), []byte("web/cookie/cookie_lifetime"), []byte("3600")},
+		}))
+		assert.Exactly(t, []string{
+			"default/0/web/cookie/cookie_lifetime",
+			"stores/4/web/cookie/cookie_lifetime",
+		}, wr.paths)
+		assert.Exactly(t, []interface{}{nil, "3600"}, wr.values)
+	})
+
+	t.Run("update odd rows", func(t *testing.T) {
+		bh := &ccd.BinlogHandler{Writer: new(writeRecorder)}
+		err := bh.Do(ctx, binlogsync.UpdateAction, tbl, [][]interface{}{
+			{int64(1), []byte("default"), int64(0), []byte("web/cookie/cookie_lifetime"), []byte("3600")},
+		})
+		assert.True(t, errors.IsNotValid(err), "%+v", err)
+	})
+
+	t.Run("other table ignored", func(t *testing.T) {
+		wr := new(writeRecorder)
+		bh := &ccd.BinlogHandler{Writer: wr}
+		require.NoError(t, bh.Do(ctx, binlogsync.InsertAction, tableCoreConfigData("catalog_product_entity"), [][]interface{}{
+			{int64(1), []byte("default"), int64(0), []byte("web/cookie/cookie_lifetime"), []byte("3600")},
+		}))
+		assert.Empty(t, wr.paths)
+	})
+
+	t.Run("table prefix", func(t *testing.T) {
+		wr := new(writeRecorder)
+		bh := &ccd.BinlogHandler{Writer: wr, TableName: "mage_core_config_data"}
+		require.NoError(t, bh.Do(ctx, binlogsync.InsertAction, tableCoreConfigData("mage_core_config_data"), [][]interface{}{
+			{int64(1), []byte("default"), int64(0), []byte("web/cookie/cookie_lifetime"), []byte("3600")},
+		}))
+		assert.Exactly(t, []string{"default/0/web/cookie/cookie_lifetime"}, wr.paths)
+	})
+
+	t.Run("column missing", func(t *testing.T) {
+		tbl := tableCoreConfigData(ccd.DefaultTable)
+		tbl.Columns = tbl.Columns[:4]
+		bh := &ccd.BinlogHandler{Writer: new(writeRecorder)}
+		err := bh.Do(ctx, binlogsync.InsertAction, tbl, [][]interface{}{{int64(1), "default", int64(0), "a/b/c"}})
+		assert.True(t, errors.IsNotFound(err), "%+v", err)
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		bh := &ccd.BinlogHandler{Writer: new(writeRecorder)}
+		err := bh.Do(ctx, binlogsync.InsertAction, tbl, [][]interface{}{
+			{int64(1), []byte("default"), int64(0), []byte("web"), []byte("3600")},
+		})
+		assert.Error(t, err)
+	})
+
+	t.Run("writer error", func(t *testing.T) {
+		bh := &ccd.BinlogHandler{Writer: &writeRecorder{err: errors.NewNotSupportedf("read only")}}
+		err := bh.Do(ctx, binlogsync.InsertAction, tbl, [][]interface{}{
+			{int64(1), []byte("default"), int64(0), []byte("web/cookie/cookie_lifetime"), []byte("3600")},
+		})
+		assert.True(t, errors.IsNotSupported(err), "%+v", err)
+	})
+}
+
+type pathReceiver struct {
+	mu    sync.Mutex
+	paths []string
+	done  chan struct{}
+}
+
+func (pr *pathReceiver) MessageConfig(p cfgpath.Path) error {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	pr.paths = append(pr.paths, p.String())
+	if len(pr.paths) == 2 {
+		close(pr.done)
+	}
+	return nil
+}
+
+func TestBinlogHandler_PubSub(t *testing.T) {
+	srv := config.MustNewService(config.NewInMemoryStore(), config.WithPubSub())
+	defer func() { assert.NoError(t, srv.Close()) }()
+
+	pr := &pathReceiver{done: make(chan struct{})}
+	_, err := srv.Subscribe(cfgpath.MakeRoute("web/cookie"), pr)
+	require.NoError(t, err)
+
+	bh := &ccd.BinlogHandler{Writer: srv}
+	require.NoError(t, bh.Do(context.Background(), binlogsync.UpdateAction, tableCoreConfigData(ccd.DefaultTable), [][]interface{}{
+		{int64(1), []byte("default"), int64(0), []byte("web/cookie/cookie_lifetime"), []byte("3600")},
+		{int64(1), []byte("stores"), int64(4), []byte("web/cookie/cookie_lifetime"), []byte("7200")},
+	}))
+
+	select {
+	case <-pr.done:
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the subscriber")
+	}
+	pr.mu.Lock()
+	assert.Exactly(t, []string{
+		"default/0/web/cookie/cookie_lifetime",
+		"stores/4/web/cookie/cookie_lifetime",
+	}, pr.paths)
+	pr.mu.Unlock()
+
+	v, err := srv.String(cfgpath.MustMakeByString("web/cookie/cookie_lifetime").Bind(scope.Store.Pack(4)))
+	require.NoError(t, err)
+	assert.Exactly(t, "7200", v)
+}
+
+type publishRecorder struct {
+	paths []string
+}
+
+func (pr *publishRecorder) Publish(p cfgpath.Path) {
+	pr.paths = append(pr.paths, p.String())
+}
+
+func TestBinlogHandler_DBStorage(t *testing.T) {
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selectSQL)).WillReturnRows(
+		sqlmock.NewRows(ccdColumns).
+			AddRow(1, "default", 0, "web/cookie/cookie_lifetime", "3600").
+			AddRow(2, "default", 0, "web/cookie/cookie_path", "/"),
+	)
+	sdb := ccd.MustNewDBStorage(context.Background(), dbc)
+
+	// The mock expects no further statement because the changes must not be
+	// written back to the database.
+	pr := new(publishRecorder)
+	bh := &ccd.BinlogHandler{Storage: sdb, Publisher: pr}
+	ctx := context.Background()
+	tbl := tableCoreConfigData(ccd.DefaultTable)
+
+	require.NoError(t, bh.Do(ctx, binlogsync.DeleteAction, tbl, [][]interface{}{
+		{int64(2), []byte("default"), int64(0), []byte("web/cookie/cookie_path"), []byte("/")},
+	}))
+	require.NoError(t, bh.Do(ctx, binlogsync.UpdateAction, tbl, [][]interface{}{
+		{int64(1), []byte("default"), int64(0), []byte("web/cookie/cookie_lifetime"), []byte("3600")},
+		{int64(1), []byte("default"), int64(0), []byte("web/cookie/cookie_lifetime"), []byte("900")},
+	}))
+	require.NoError(t, bh.Do(ctx, binlogsync.InsertAction, tbl, [][]interface{}{
+		{int64(3), []byte("stores"), int64(2), []byte("web/cookie/cookie_domain"), nil},
+	}))
+
+	assert.Exactly(t, []string{
+		"default/0/web/cookie/cookie_path",
+		"default/0/web/cookie/cookie_lifetime",
+		"stores/2/web/cookie/cookie_domain",
+	}, pr.paths)
+
+	_, err := sdb.Get(cfgpath.MustMakeByString("web/cookie/cookie_path"))
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+
+	v, err := sdb.Get(cfgpath.MustMakeByString("web/cookie/cookie_lifetime"))
+	require.NoError(t, err)
+	assert.Exactly(t, "900", v)
+
+	v, err = sdb.Get(cfgpath.MustMakeByString("web/cookie/cookie_domain").BindStore(2))
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func TestBinlogHandler_NoTarget(t *testing.T) {
+	bh := new(ccd.BinlogHandler)
+	err := bh.Do(context.Background(), binlogsync.InsertAction, tableCoreConfigData(ccd.DefaultTable), [][]interface{}{
+		{int64(1), []byte("default"), int64(0), []byte("web/cookie/cookie_lifetime"), []byte("3600")},
+	})
+	assert.True(t, errors.IsEmpty(err), "%+v", err)
+}
+
+func TestWithBinlogSync(t *testing.T) {
+	srv := config.MustNewService(config.NewInMemoryStore(), ccd.WithBinlogSync(new(binlogsync.Canal), nil, ""))
+	assert.NoError(t, srv.Close())
+
+	_, err := config.NewService(config.NewInMemoryStore(), ccd.WithBinlogSync(new(binlogsync.Canal), nil, "core_[config"))
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+}
@@ -16,236 +16,389 @@ package ccd
 
 import (
 	"context"
-	"fmt"
+	"sync"
 	"time"
 
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
 	"github.com/corestoreio/pkg/config/cfgpath"
-	"github.com/corestoreio/pkg/storage/csdb"
+	"github.com/corestoreio/pkg/sql/dml"
 	"github.com/corestoreio/pkg/store/scope"
 	"github.com/corestoreio/pkg/util/conv"
-	"github.com/corestoreio/pkg/util/null"
-	"github.com/corestoreio/errors"
-	"github.com/corestoreio/log"
 )
 
-// TableCollection handles all tables and its columns. init() in generated Go
-// file will set the value.
-var TableCollection *csdb.Tables
+// Option applies options to the NewDBStorage function.
+type Option func(*DBStorage) error
 
-// DBStorage connects the MySQL DB with the config.Service type. Implements
-// interface config.Storager.
+// WithTable sets a custom name for the table core_config_data, for example
+// with a table prefix. Default name is the constant DefaultTable.
+func WithTable(tableName string) Option {
+	return func(dbs *DBStorage) error {
+		if err := dml.IsValidIdentifier(tableName); err != nil {
+			return errors.Wrapf(err, "[ccd] Invalid table name %q", tableName)
+		}
+		dbs.table = tableName
+		return nil
+	}
+}
+
+// WithLogger sets a custom logger. Default log.BlackHole.
+func WithLogger(l log.Logger) Option {
+	return func(dbs *DBStorage) error {
+		dbs.log = l
+		return nil
+	}
+}
+
+// WithRefreshInterval sets the interval of the background refresh started
+// with function Start. The optional function onChange gets called after each
+// refresh with the changed paths, if there are any. A zero or negative
+// interval disables the background refresh.
+func WithRefreshInterval(interval time.Duration, onChange func(cfgpath.PathSlice)) Option {
+	return func(dbs *DBStorage) error {
+		dbs.interval = interval
+		dbs.onChange = onChange
+		return nil
+	}
+}
+
+// indexKey identifies a row of core_config_data in the in-memory index.
+type indexKey struct {
+	scopeID scope.TypeID
+	route   string
+}
+
+// indexWrite contains a change of the in-memory index made during a Refresh.
+type indexWrite struct {
+	value   dml.NullString
+	deleted bool
+}
+
+// DBStorage connects the MySQL DB with the config.Service type. The whole
+// table core_config_data gets loaded with one query into an in-memory index
+// and all reads are served from that index. Writes go directly to the database
+// and update the index. Changes made by other processes can be loaded with
+// function Refresh, either on demand or periodically via Start. Implements
+// interface config.Storager. Thread safe.
 type DBStorage struct {
-	log log.Logger
-	// All is a SQL statement for the all keys query
-	All *csdb.ResurrectStmt
-	// Read is a SQL statement for selecting a value from a path/key
-	Read *csdb.ResurrectStmt
-	// Write statement inserts or updates a value
-	Write *csdb.ResurrectStmt
-}
-
-// NewDBStorage creates a new pointer with resurrecting prepared SQL statements.
-// Default logger for the three underlying ResurrectStmt type sports to black
-// hole.
-//
-// All has an idle time of 15s. Read an idle time of 10s. Write an idle time of
-// 30s. Implements interface config.Storager.
-func NewDBStorage(p csdb.Preparer) (*DBStorage, error) {
-	// todo: instead of logging the error we may write it into an
-	// error channel and the gopher who calls NewDBStorage is responsible
-	// for continuously reading from the error channel. or we accept an error channel
-	// as argument here and then writing to it ...
+	db       *dml.ConnPool
+	table    string
+	log      log.Logger
+	interval time.Duration
+	onChange func(cfgpath.PathSlice)
+
+	mu    sync.RWMutex
+	index map[indexKey]dml.NullString
+	// loads counts the running Refresh calls. While the table gets loaded,
+	// writes records the keys changed by Set and the BinlogHandler, because
+	// those values are newer than the loaded rows and must survive the
+	// replacement of the index.
+	loads  int
+	writes map[indexKey]indexWrite
 
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewDBStorage creates a new DBStorage and preloads the table
+// core_config_data. Implements interface config.Storager.
+func NewDBStorage(ctx context.Context, db *dml.ConnPool, opts ...Option) (*DBStorage, error) {
 	dbs := &DBStorage{
-		log: log.BlackHole{}, // skip debug and info level via init with empty fields
-		All: csdb.NewResurrectStmt(p, fmt.Sprintf(
-			"SELECT scope,scope_id,path FROM `%s` ORDER BY scope,scope_id,path",
-			TableCollection.Name(TableIndexCoreConfigData),
-		)),
-		Read: csdb.NewResurrectStmt(p, fmt.Sprintf(
-			"SELECT `value` FROM `%s` WHERE `scope`=? AND `scope_id`=? AND `path`=?",
-			TableCollection.Name(TableIndexCoreConfigData),
-		)),
-
-		Write: csdb.NewResurrectStmt(p, fmt.Sprintf(
-			"INSERT INTO `%s` (`scope`,`scope_id`,`path`,`value`) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE `value`=?",
-			TableCollection.Name(TableIndexCoreConfigData),
-		)),
-	}
-	dbs.All.Idle = time.Second * 15
-	dbs.All.Log = dbs.log
-	dbs.Read.Idle = time.Second * 10
-	dbs.Read.Log = dbs.log
-	dbs.Write.Idle = time.Second * 30
-	dbs.Write.Log = dbs.log
-	// in the future we may add errors ... just to have for now the func signature
+		db:    db,
+		table: DefaultTable,
+		log:   log.BlackHole{}, // skip debug and info level via init with empty fields
+		index: make(map[indexKey]dml.NullString),
+	}
+	for _, o := range opts {
+		if err := o(dbs); err != nil {
+			return nil, errors.Wrap(err, "[ccd] NewDBStorage applied option error")
+		}
+	}
+	if _, err := dbs.Refresh(ctx); err != nil {
+		return nil, errors.WithStack(err)
+	}
 	return dbs, nil
 }
 
 // MustNewDBStorage same as NewDBStorage but panics on error. Implements
 // interface config.Storager.
-func MustNewDBStorage(p csdb.Preparer) *DBStorage {
-	s, err := NewDBStorage(p)
+func MustNewDBStorage(ctx context.Context, db *dml.ConnPool, opts ...Option) *DBStorage {
+	s, err := NewDBStorage(ctx, db, opts...)
 	if err != nil {
 		panic(err)
 	}
 	return s
 }
 
-// SetLogger applies your custom logger
-func (dbs *DBStorage) SetLogger(l log.Logger) *DBStorage {
-	dbs.log = l
-	dbs.All.Log = l
-	dbs.Read.Log = l
-	dbs.Write.Log = l
+// Start starts the background refresh, if an interval has been set via option
+// WithRefreshInterval. Refresh errors get logged as Info message. Calling Start
+// twice has no effect.
+func (dbs *DBStorage) Start() *DBStorage {
+	dbs.mu.Lock()
+	defer dbs.mu.Unlock()
+	if dbs.interval <= 0 || dbs.stop != nil {
+		return dbs
+	}
+	dbs.stop = make(chan struct{})
+	dbs.wg.Add(1)
+	go dbs.refreshLoop(dbs.stop)
 	return dbs
 }
 
-// Start starts the internal idle time checker for the resurrecting SQL statements.
-func (dbs *DBStorage) Start() *DBStorage {
-	dbs.All.StartIdleChecker()
-	dbs.Read.StartIdleChecker()
-	dbs.Write.StartIdleChecker()
-	return dbs
+func (dbs *DBStorage) refreshLoop(stop <-chan struct{}) {
+	defer dbs.wg.Done()
+	ticker := time.NewTicker(dbs.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			changed, err := dbs.Refresh(context.Background())
+			if err != nil {
+				if dbs.log.IsInfo() {
+					dbs.log.Info("ccd.DBStorage.refreshLoop.Refresh", log.Err(err), log.String("table", dbs.table))
+				}
+				continue
+			}
+			if dbs.onChange != nil && len(changed) > 0 {
+				dbs.onChange(changed)
+			}
+		}
+	}
 }
 
-// Stop stops the internal goroutines for idle time checking. Returns the
-// first occurring sql.Stmt.Close() error.
+// Stop stops the background refresh and waits until a running refresh has
+// been finished.
 func (dbs *DBStorage) Stop() error {
-	if err := dbs.All.StopIdleChecker(); err != nil {
-		return errors.Wrap(err, "[ccd] All.StopIdleChecker")
-	}
-	if err := dbs.Read.StopIdleChecker(); err != nil {
-		return errors.Wrap(err, "[ccd] Read.StopIdleChecker")
-	}
-	if err := dbs.Write.StopIdleChecker(); err != nil {
-		return errors.Wrap(err, "[ccd] Write.StopIdleChecker")
+	dbs.mu.Lock()
+	stop := dbs.stop
+	dbs.stop = nil
+	dbs.mu.Unlock()
+	if stop != nil {
+		close(stop)
+		dbs.wg.Wait()
 	}
 	return nil
 }
 
-// Set sets a value with its key. Database errors get logged as Info message.
-// Enabled debug level logs the insert ID or rows affected.
-func (dbs *DBStorage) Set(key cfgpath.Path, value interface{}) error {
-	// update lastUsed at the end because there might be the slight chance that
-	// a statement gets closed despite we're waiting for the result from the
-	// server.
-	dbs.Write.StartStmtUse()
-	defer dbs.Write.StopStmtUse()
+// Refresh loads the whole table core_config_data and replaces the in-memory
+// index. It returns the paths whose values have been added, changed or
+// removed since the last load, sorted by route and scope. Rows with an invalid path get skipped
+// and logged as Info message. Values written via Set or the BinlogHandler
+// while the table gets loaded are newer than the loaded rows and take
+// precedence.
+func (dbs *DBStorage) Refresh(ctx context.Context) (cfgpath.PathSlice, error) {
+	dbs.beginLoad()
+	defer dbs.endLoad()
 
-	valStr, err := conv.ToStringE(value)
+	rows, err := loadAll(ctx, dbs.db, dbs.table)
 	if err != nil {
-		return errors.Wrapf(err, "[ccd] Set.conv.ToStringE. SQL: %q Key: %q Value: %v", dbs.Write.sqlRaw, key, value)
+		return nil, errors.WithStack(err)
 	}
 
-	stmt, err := dbs.Write.Stmt(context.TODO())
-	if err != nil {
-		return errors.Wrapf(err, "[ccd] Set.Write.Stmt. SQL: %q Key: %q", dbs.Write.sqlRaw, key)
+	index := make(map[indexKey]dml.NullString, len(rows))
+	for _, r := range rows {
+		p, err := r.ConfigPath()
+		if err != nil {
+			if dbs.log.IsInfo() {
+				dbs.log.Info("ccd.DBStorage.Refresh.ConfigPath", log.Err(err), log.String("table", dbs.table))
+			}
+			continue
+		}
+		index[indexKey{scopeID: p.ScopeID, route: p.Route.Data}] = r.Value
 	}
 
-	pathLeveled, err := key.Level(-1)
+	dbs.mu.Lock()
+	for k, w := range dbs.writes {
+		if w.deleted {
+			delete(index, k)
+			continue
+		}
+		index[k] = w.value
+	}
+	old := dbs.index
+	dbs.index = index
+
+	var changed cfgpath.PathSlice
+	for k, v := range index {
+		if ov, ok := old[k]; !ok || ov != v {
+			changed = append(changed, k.path())
+		}
+	}
+	for k := range old {
+		if _, ok := index[k]; !ok {
+			changed = append(changed, k.path())
+		}
+	}
+	dbs.mu.Unlock()
+	changed.Sort()
+
+	if dbs.log.IsDebug() {
+		dbs.log.Debug("ccd.DBStorage.Refresh", log.Int("rows", len(rows)), log.Int("changed", len(changed)), log.String("table", dbs.table))
+	}
+	return changed, nil
+}
+
+// beginLoad starts recording the writes to the index.
+func (dbs *DBStorage) beginLoad() {
+	dbs.mu.Lock()
+	defer dbs.mu.Unlock()
+	if dbs.loads == 0 {
+		dbs.writes = make(map[indexKey]indexWrite)
+	}
+	dbs.loads++
+}
+
+// endLoad stops recording the writes to the index once the last Refresh has
+// finished.
+func (dbs *DBStorage) endLoad() {
+	dbs.mu.Lock()
+	defer dbs.mu.Unlock()
+	dbs.loads--
+	if dbs.loads == 0 {
+		dbs.writes = nil
+	}
+}
+
+// setIndex changes a key of the in-memory index and records the change for a
+// running Refresh. The caller must hold the write lock.
+func (dbs *DBStorage) setIndex(k indexKey, nv dml.NullString, deleted bool) {
+	if deleted {
+		delete(dbs.index, k)
+	} else {
+		dbs.index[k] = nv
+	}
+	if dbs.loads > 0 {
+		dbs.writes[k] = indexWrite{value: nv, deleted: deleted}
+	}
+}
+
+func (k indexKey) path() cfgpath.Path {
+	return cfgpath.Path{Route: cfgpath.MakeRoute(k.route), ScopeID: k.scopeID}
+}
+
+// Set writes a value with its key into the database with an INSERT ... ON
+// DUPLICATE KEY UPDATE statement and updates the in-memory index. A nil value
+// deletes the row.
+func (dbs *DBStorage) Set(key cfgpath.Path, value interface{}) error {
+	return dbs.SetContext(context.Background(), key, value)
+}
+
+// SetContext same as Set but with a context.
+func (dbs *DBStorage) SetContext(ctx context.Context, key cfgpath.Path, value interface{}) error {
+	if err := key.IsValid(); err != nil {
+		return errors.Wrapf(err, "[ccd] Set invalid path %q", key)
+	}
+	if value == nil {
+		return errors.WithStack(dbs.delete(ctx, key))
+	}
+	str, err := conv.ToStringE(value)
 	if err != nil {
-		return errors.Wrapf(err, "[ccd] Set.key.Level. SQL: %q Key: %q", dbs.Write.sqlRaw, key)
+		return errors.Wrapf(err, "[ccd] Set.conv.ToStringE Key: %q Value: %#v", key, value)
 	}
+	nv := dml.MakeNullString(str)
 
 	scp, id := key.ScopeID.Unpack()
-	result, err := stmt.Exec(scp.StrType(), id, pathLeveled, valStr, valStr)
+	e := &TableCoreConfigData{
+		Scope:   scp.StrType(),
+		ScopeID: id,
+		Path:    key.Route.Data,
+		Value:   nv,
+	}
+	result, err := dml.NewInsert(dbs.table).AddColumns(insertColumns...).
+		AddOnDuplicateKey(dml.Column("value").Values()).
+		WithDB(dbs.db.DB).WithArgs().Record("", e).ExecContext(ctx)
 	if err != nil {
-		return errors.Wrapf(err, "[ccd] Set.stmt.Exec. SQL: %q KeyID: %d Scope: %q Path: %q Value: %q", dbs.Write.sqlRaw, id, scp, pathLeveled, valStr)
+		return errors.Wrapf(err, "[ccd] Set Key: %q", key)
 	}
 	if dbs.log.IsDebug() {
 		li, err1 := result.LastInsertId()
 		ra, err2 := result.RowsAffected()
 		dbs.log.Debug(
-			"config.DBStorage.Set.Write.Result",
+			"ccd.DBStorage.Set.Result",
 			log.Int64("lastInsertID", li),
 			log.ErrWithKey("lastInsertIDErr", err1),
 			log.Int64("rowsAffected", ra),
 			log.ErrWithKey("rowsAffectedErr", err2),
-			log.String("SQL", dbs.Write.sqlRaw),
 			log.Stringer("key", key),
 			log.Object("value", value),
 		)
 	}
+
+	dbs.mu.Lock()
+	dbs.setIndex(indexKey{scopeID: key.ScopeID, route: key.Route.Data}, nv, false)
+	dbs.mu.Unlock()
 	return nil
 }
 
-// Get returns a value from the database by its key. It is guaranteed that the
-// type in the empty interface is a string. It returns nil on error but errors
-// get logged as info message. Error behaviour: NotFound
-func (dbs *DBStorage) Get(key cfgpath.Path) (interface{}, error) {
-	// update lastUsed at the end because there might be the slight chance that
-	// a statement gets closed despite we're waiting for the result from the
-	// server.
-	dbs.Read.StartStmtUse()
-	defer dbs.Read.StopStmtUse()
-
-	stmt, err := dbs.Read.Stmt(context.TODO())
-	if err != nil {
-		return nil, errors.Wrapf(err, "[ccd] Get.Read.Stmt. SQL: %q Key: %q", dbs.Read.sqlRaw, key)
-	}
-
-	pl, err := key.Level(-1)
-	if err != nil {
-		return nil, errors.Wrapf(err, "[ccd] Get.key.Level. SQL: %q Key: %q", dbs.Read.sqlRaw, key)
-	}
-
-	var data null.String
+// delete removes the row of the key from the database and from the in-memory
+// index.
+func (dbs *DBStorage) delete(ctx context.Context, key cfgpath.Path) error {
 	scp, id := key.ScopeID.Unpack()
-	err = stmt.QueryRow(scp.StrType(), id, pl).Scan(&data)
+	result, err := dml.NewDelete(dbs.table).Where(
+		dml.Column("scope").Str(scp.StrType()),
+		dml.Column("scope_id").Int64(id),
+		dml.Column("path").Str(key.Route.Data),
+	).WithDB(dbs.db.DB).WithArgs().ExecContext(ctx)
 	if err != nil {
-		return nil, errors.Wrapf(err, "[ccd] Get.QueryRow. SQL: %q Key: %q PathLevel: %q", dbs.Read.sqlRaw, key, pl)
+		return errors.Wrapf(err, "[ccd] Delete Key: %q", key)
 	}
-	if data.Valid {
-		return data.String, nil
+	if dbs.log.IsDebug() {
+		ra, err2 := result.RowsAffected()
+		dbs.log.Debug(
+			"ccd.DBStorage.Delete.Result",
+			log.Int64("rowsAffected", ra),
+			log.ErrWithKey("rowsAffectedErr", err2),
+			log.Stringer("key", key),
+		)
 	}
-	return nil, errKeyNotFound
-}
 
-var errKeyNotFound = errors.NewNotFoundf(`[ccd] Key not found`) // todo add test
-
-// AllKeys returns all available keys. Database errors get logged as info message.
-func (dbs *DBStorage) AllKeys() (cfgpath.PathSlice, error) {
-	// update lastUsed at the end because there might be the slight chance
-	// that a statement gets closed despite we're waiting for the result
-	// from the server.
-	dbs.All.StartStmtUse()
-	defer dbs.All.StopStmtUse()
+	dbs.mu.Lock()
+	dbs.setIndex(indexKey{scopeID: key.ScopeID, route: key.Route.Data}, dml.NullString{}, true)
+	dbs.mu.Unlock()
+	return nil
+}
 
-	stmt, err := dbs.All.Stmt(context.TODO())
-	if err != nil {
-		return nil, errors.Wrapf(err, "[ccd] AllKeys.All.Stmt. SQL: %q", dbs.All.sqlRaw)
+// apply changes the in-memory index without writing to the database. Used for
+// changes which other processes have already written, see BinlogHandler. A
+// value gets converted to a string, nil stands for NULL.
+func (dbs *DBStorage) apply(key cfgpath.Path, value interface{}, deleted bool) {
+	k := indexKey{scopeID: key.ScopeID, route: key.Route.Data}
+	var nv dml.NullString
+	if value != nil {
+		nv = dml.MakeNullString(conv.ToString(value))
 	}
+	dbs.mu.Lock()
+	dbs.setIndex(k, nv, deleted)
+	dbs.mu.Unlock()
+}
 
-	rows, err := stmt.Query()
-	if err != nil {
-		return nil, errors.Wrapf(err, "[ccd] AllKeys.All.Query. SQL: %q", dbs.All.sqlRaw)
+// Get returns a value from the in-memory index by its key. The type in the
+// empty interface is either a string or nil for a row whose value is NULL, like
+// in Magento. A missing key returns an error with behaviour NotFound.
+func (dbs *DBStorage) Get(key cfgpath.Path) (interface{}, error) {
+	dbs.mu.RLock()
+	v, ok := dbs.index[indexKey{scopeID: key.ScopeID, route: key.Route.Data}]
+	dbs.mu.RUnlock()
+	if !ok {
+		return nil, errors.NewNotFoundf("[ccd] Key %q not found", key)
 	}
-	defer rows.Close()
-
-	const maxCap = 750 // Just a guess the 750
-	var ret = make(cfgpath.PathSlice, 0, maxCap)
-	var sqlScope null.String
-	var sqlScopeID null.Int64
-	var sqlPath null.String
+	if !v.Valid {
+		return nil, nil
+	}
+	return v.String, nil
+}
 
-	for rows.Next() {
-		if err := rows.Scan(&sqlScope, &sqlScopeID, &sqlPath); err != nil {
-			return nil, errors.Wrapf(err, "[ccd] AllKeys.rows.Scan. SQL: %q", dbs.All.sqlRaw)
-		}
-		if sqlPath.Valid {
-			p, err := cfgpath.NewByParts(sqlPath.String)
-			if err != nil {
-				return ret, errors.Wrapf(err, "[ccd] AllKeys.rows.cfgpath.NewByParts. SQL: %q: Path: %q", dbs.All.sqlRaw, sqlPath.String)
-			}
-			ret = append(ret, p.Bind(scope.FromString(sqlScope.String).Pack(sqlScopeID.Int64)))
-		}
-		sqlScope.String = ""
-		sqlScope.Valid = false
-		sqlScopeID.Int64 = 0
-		sqlScopeID.Valid = false
-		sqlPath.String = ""
-		sqlPath.Valid = false
+// AllKeys returns all available keys of the in-memory index including the
+// rows whose value is NULL, sorted by route and scope.
+func (dbs *DBStorage) AllKeys() (cfgpath.PathSlice, error) {
+	dbs.mu.RLock()
+	ret := make(cfgpath.PathSlice, 0, len(dbs.index))
+	for k := range dbs.index {
+		ret = append(ret, k.path())
 	}
+	dbs.mu.RUnlock()
+	ret.Sort()
 	return ret, nil
 }
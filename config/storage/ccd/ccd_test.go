@@ -15,258 +15,294 @@
 package ccd_test
 
 import (
-	"database/sql/driver"
-	"fmt"
+	"context"
 	"testing"
 	"time"
 
-	"github.com/DATA-DOG/go-sqlmock"
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
 	"github.com/corestoreio/pkg/config"
 	"github.com/corestoreio/pkg/config/cfgpath"
 	"github.com/corestoreio/pkg/config/storage/ccd"
+	"github.com/corestoreio/pkg/sql/dmltest"
 	"github.com/corestoreio/pkg/store/scope"
-	"github.com/corestoreio/pkg/util/cstesting"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var _ config.Storager = (*ccd.DBStorage)(nil)
 
-func TestDBStorageOneStmt(t *testing.T) {
-	t.Parallel()
+const (
+	selectSQL = "SELECT `config_id`, `scope`, `scope_id`, `path`, `value` FROM `core_config_data` ORDER BY `config_id`"
+	insertSQL = "INSERT INTO `core_config_data` (`scope`,`scope_id`,`path`,`value`) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE `value`=VALUES(`value`)"
+)
 
-	dbc, dbMock := cstesting.MockDB(t)
-	defer func() {
-		dbMock.ExpectClose()
+var ccdColumns = []string{"config_id", "scope", "scope_id", "path", "value"}
+
+func TestNewDBStorage(t *testing.T) {
+	t.Run("invalid table name", func(t *testing.T) {
+		s, err := ccd.NewDBStorage(context.Background(), nil, ccd.WithTable("core config"))
+		assert.Nil(t, s)
+		assert.Error(t, err)
+	})
+	t.Run("load error", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selectSQL)).WillReturnError(errors.NewAlreadyClosedf("DB closed"))
+
+		s, err := ccd.NewDBStorage(context.Background(), dbc)
+		assert.Nil(t, s)
+		assert.True(t, errors.IsAlreadyClosed(err), "%+v", err)
+	})
+	t.Run("MustNewDBStorage panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			ccd.MustNewDBStorage(context.Background(), nil, ccd.WithTable(""))
+		})
+	})
+}
 
-		assert.NoError(t, dbc.Close())
+func TestDBStorage_Preload(t *testing.T) {
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
 
-		if err := dbMock.ExpectationsWereMet(); err != nil {
-			t.Error("there were unfulfilled expections", err)
-		}
-	}()
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selectSQL)).WillReturnRows(
+		dmltest.MustMockRows(dmltest.WithFile("testdata", "core_config_data.csv")),
+	)
+	sdb := ccd.MustNewDBStorage(context.Background(), dbc)
 
-	sdb := ccd.MustNewDBStorage(dbc.DB).Start()
+	// All reads are served from the index, the mock expects no further query.
+	v, err := sdb.Get(cfgpath.MustMakeByString("general/region/state_required").BindStore(2))
+	require.NoError(t, err)
+	assert.Exactly(t, "AT", v)
 
-	// Stop() would only be called under rare circumstances on a production system
-	defer func() { assert.NoError(t, sdb.Stop()) }()
+	v, err = sdb.Get(cfgpath.MustMakeByString("web/unsecure/base_url").BindWebsite(1))
+	require.NoError(t, err)
+	assert.Exactly(t, "http://magento-1-8a.dev/", v)
 
-	tests := []struct {
-		key       cfgpath.Path
-		value     interface{}
-		wantNil   bool
-		wantValue string
-	}{
-		{cfgpath.MustNewByParts("testDBStorage/secure/base_url").BindStore(1), "http://corestore.io", false, "http://corestore.io"},
-		{cfgpath.MustNewByParts("testDBStorage/log/active").BindStore(2), 1, false, "1"},
-		{cfgpath.MustNewByParts("testDBStorage/log/clean").BindStore(99999), 19.999, false, "19.999"},
-		{cfgpath.MustNewByParts("testDBStorage/log/clean").BindStore(99999), 29.999, false, "29.999"},
-		{cfgpath.MustNewByParts("testDBStorage/catalog/purge").Bind(scope.DefaultTypeID), true, false, "true"},
-		{cfgpath.MustNewByParts("testDBStorage/catalog/clean").Bind(scope.DefaultTypeID), 0, false, "0"},
-	}
+	v, err = sdb.Get(cfgpath.MustMakeByString("web/unsecure/base_url").BindWebsite(2))
+	assert.Nil(t, v)
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
 
-	prepIns := dbMock.ExpectPrepare("INSERT INTO `[^`]+` \\(.+\\) VALUES \\(\\?,\\?,\\?,\\?\\) ON DUPLICATE KEY UPDATE `value`=\\?")
-	for i, test := range tests {
+	v, err = sdb.Get(cfgpath.MustMakeByString("web/cookie/cookie_domain").BindStore(2))
+	require.NoError(t, err, "NULL row must be found")
+	assert.Nil(t, v)
 
-		prepIns.ExpectExec().WithArgs(
-			driver.Value(test.key.ScopeID.Type().StrType()),
-			driver.Value(test.key.ScopeID.ID()),
-			driver.Value(test.key.Bytes()),
-			driver.Value(test.wantValue), // value
-			driver.Value(test.wantValue), // value fall back on duplicate key
-		).WillReturnResult(sqlmock.NewResult(0, 1))
-
-		if err := sdb.Set(test.key, test.value); err != nil {
-			t.Fatal("Index", i, " => ", err)
-		}
+	allKeys, err := sdb.AllKeys()
+	require.NoError(t, err)
+	assert.Len(t, allKeys, 21)
+	for _, p := range allKeys {
+		_, err := sdb.Get(p)
+		assert.NoError(t, err, "Key %q of AllKeys must be readable", p)
 	}
+	assert.Exactly(t, "default/0/cms/wysiwyg/enabled", allKeys[0].String())
+	assert.Exactly(t, "default/0/general/region/state_required", allKeys[2].String())
+	assert.Exactly(t, "stores/2/general/region/state_required", allKeys[3].String())
+}
 
-	// both prepared statements cannot run within one for loop :-(
+func TestDBStorage_Set(t *testing.T) {
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
 
-	prepSel := dbMock.ExpectPrepare("SELECT `value` FROM `[^`]+` WHERE `scope`=\\? AND `scope_id`=\\? AND `path`=\\?")
-	for i, test := range tests {
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selectSQL)).WillReturnRows(sqlmock.NewRows(ccdColumns))
+	sdb := ccd.MustNewDBStorage(context.Background(), dbc, ccd.WithTable("core_config_data"))
 
-		prepSel.ExpectQuery().WithArgs(
-			driver.Value(test.key.ScopeID.Type().StrType()),
-			driver.Value(test.key.ScopeID.ID()),
-			driver.Value(test.key.Bytes()),
-		).WillReturnRows(sqlmock.NewRows([]string{"value"}).FromCSVString(test.wantValue))
-
-		if test.wantNil {
-			g, err := sdb.Get(test.key)
-			assert.NoError(t, err, "Index %d", i)
-			assert.Nil(t, g, "Index %d", i)
-		} else {
-			g, err := sdb.Get(test.key)
-			assert.NoError(t, err, "Index %d", i)
-			assert.Exactly(t, test.wantValue, g, "Index %d", i)
-		}
+	tests := []struct {
+		key       cfgpath.Path
+		value     interface{}
+		wantValue string
+	}{
+		{cfgpath.MustMakeByString("testDBStorage/secure/base_url").BindStore(1), "http://corestore.io", "http://corestore.io"},
+		{cfgpath.MustMakeByString("testDBStorage/log/active").BindStore(2), 1, "1"},
+		{cfgpath.MustMakeByString("testDBStorage/log/clean").BindStore(99999), 19.999, "19.999"},
+		{cfgpath.MustMakeByString("testDBStorage/log/clean").BindStore(99999), 29.999, "29.999"},
+		{cfgpath.MustMakeByString("testDBStorage/catalog/purge").Bind(scope.DefaultTypeID), true, "true"},
+		{cfgpath.MustMakeByString("testDBStorage/catalog/clean").BindWebsite(3), []byte("0"), "0"},
 	}
+	for i, test := range tests {
+		scp, id := test.key.ScopeID.Unpack()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(insertSQL)).
+			WithArgs(scp.StrType(), id, test.key.Route.Data, test.wantValue).
+			WillReturnResult(sqlmock.NewResult(int64(i+1), 1))
 
-	//assert.Exactly(t, 1, strings.Count(debugLogBuf.String(), `csdb.ResurrectStmt.stmt.Prepare SQL: "INSERT INTO`))
-	//assert.Exactly(t, 1, strings.Count(debugLogBuf.String(), "csdb.ResurrectStmt.stmt.Prepare SQL: \"SELECT `value` FROM"))
+		require.NoError(t, sdb.Set(test.key, test.value), "Index %d", i)
 
-	mockRows := sqlmock.NewRows([]string{"scope", "scope_id", "path"})
-	for _, test := range tests {
-		mockRows.FromCSVString(fmt.Sprintf("%s,%d,%s", test.key.ScopeID.Type().StrType(), test.key.ScopeID.ID(), test.key.Chars))
+		v, err := sdb.Get(test.key)
+		require.NoError(t, err, "Index %d", i)
+		assert.Exactly(t, test.wantValue, v, "Index %d", i)
 	}
-	prepAll := dbMock.ExpectPrepare("SELECT scope,scope_id,path FROM `[^`]+` ORDER BY scope,scope_id,path")
-	prepAll.ExpectQuery().WillReturnRows(mockRows)
 
 	allKeys, err := sdb.AllKeys()
-	assert.NoError(t, err)
-
+	require.NoError(t, err)
+	assert.Len(t, allKeys, 5)
 	for i, test := range tests {
 		assert.True(t, allKeys.Contains(test.key), "Missing Key: %s\nIndex %d", test.key, i)
 	}
-	//assert.Exactly(t, 1, strings.Count(debugLogBuf.String(), `SELECT scope,scope_id,path FROM `))
-}
 
-var dbStorageMultiTests = []struct {
-	key       cfgpath.Path
-	value     interface{}
-	wantValue string
-}{
-	{cfgpath.MustNewByParts("testDBStorage/secure/base_url").BindWebsite(10), "http://corestore.io", "http://corestore.io"},
-	{cfgpath.MustNewByParts("testDBStorage/log/active").BindWebsite(10), 1, "1"},
-	{cfgpath.MustNewByParts("testDBStorage/log/clean").BindWebsite(20), 19.999, "19.999"},
-	{cfgpath.MustNewByParts("testDBStorage/product/shipping").BindWebsite(20), 29.999, "29.999"},
-	{cfgpath.MustNewByParts("testDBStorage/checkout/multishipping"), false, "false"},
-	{cfgpath.MustNewByParts("testDBStorage/shipping/rate").BindStore(321), 3.14159, "3.14159"},
-}
+	t.Run("nil value deletes", func(t *testing.T) {
+		p := cfgpath.MustMakeByString("testDBStorage/log/active").BindStore(2)
+		dbMock.ExpectExec("DELETE FROM `core_config_data` WHERE (.+)`scope`(.+)'stores'(.+)`scope_id`(.+)2(.+)`path`(.+)'testDBStorage/log/active'").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		require.NoError(t, sdb.Set(p, nil))
 
-func TestDBStorageMultipleStmt_Set(t *testing.T) {
-	t.Parallel()
-	dbc, dbMock := cstesting.MockDB(t)
-	defer func() {
-		dbMock.ExpectClose()
+		v, err := sdb.Get(p)
+		assert.Nil(t, v)
+		assert.True(t, errors.IsNotFound(err), "%+v", err)
 
-		assert.NoError(t, dbc.Close())
+		allKeys, err := sdb.AllKeys()
+		require.NoError(t, err)
+		assert.False(t, allKeys.Contains(p))
+		assert.Len(t, allKeys, 4)
+	})
+
+	t.Run("DB error keeps index", func(t *testing.T) {
+		p := cfgpath.MustMakeByString("testDBStorage/secure/base_url").BindStore(1)
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(insertSQL)).
+			WillReturnError(errors.NewAlreadyClosedf("DB closed"))
+		err := sdb.Set(p, "https://corestore.io")
+		assert.True(t, errors.IsAlreadyClosed(err), "%+v", err)
+
+		v, err := sdb.Get(p)
+		require.NoError(t, err)
+		assert.Exactly(t, "http://corestore.io", v)
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		err := sdb.Set(cfgpath.Path{Route: cfgpath.MakeRoute("a/b")}, "x")
+		assert.Error(t, err)
+	})
+}
 
-		if err := dbMock.ExpectationsWereMet(); err != nil {
-			t.Error("there were unfulfilled expections", err)
-		}
-	}()
+func TestDBStorage_Refresh(t *testing.T) {
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
 
-	sdb := ccd.MustNewDBStorage(dbc.DB)
-	sdb.Write.Idle = time.Second * 1
-
-	sdb.Start()
-
-	var prepIns *sqlmock.ExpectedPrepare
-	for i, test := range dbStorageMultiTests {
-		if i < 3 {
-			prepIns = dbMock.ExpectPrepare("INSERT INTO `[^`]+` \\(.+\\) VALUES \\(\\?,\\?,\\?,\\?\\) ON DUPLICATE KEY UPDATE `value`=\\?")
-		}
-
-		prepIns.ExpectExec().WithArgs(
-			driver.Value(test.key.ScopeID.Type().StrType()),
-			driver.Value(test.key.ScopeID.ID()),
-			driver.Value(test.key.Bytes()),
-			driver.Value(test.wantValue), // value
-			driver.Value(test.wantValue), // value fall back on duplicate key
-		).WillReturnResult(sqlmock.NewResult(0, 1))
-
-		if err := sdb.Set(test.key, test.value); err != nil {
-			t.Fatal("Index", i, "with error:", err)
-		}
-
-		if i < 2 {
-			// last two iterations reopen a new statement, not closing it and reusing it
-			time.Sleep(time.Millisecond * 1500) // trigger ticker to close statements
-		}
-	}
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selectSQL)).WillReturnRows(
+		sqlmock.NewRows(ccdColumns).
+			AddRow(1, "default", 0, "web/cookie/cookie_lifetime", "3600").
+			AddRow(2, "websites", 1, "web/cookie/cookie_lifetime", "7200").
+			AddRow(3, "default", 0, "web/cookie/cookie_path", "/").
+			AddRow(4, "default", 0, "web/cookie/cookie_domain", nil).
+			AddRow(5, "default", 0, "web", "invalid"),
+	)
+	sdb := ccd.MustNewDBStorage(context.Background(), dbc)
 
-	assert.NoError(t, sdb.Stop())
+	allKeys, err := sdb.AllKeys()
+	require.NoError(t, err)
+	assert.Len(t, allKeys, 4, "Invalid path must be skipped")
+
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selectSQL)).WillReturnRows(
+		sqlmock.NewRows(ccdColumns).
+			AddRow(1, "default", 0, "web/cookie/cookie_lifetime", "3600").
+			AddRow(2, "websites", 1, "web/cookie/cookie_lifetime", "1800").
+			AddRow(4, "default", 0, "web/cookie/cookie_domain", "example.com").
+			AddRow(6, "stores", 3, "web/cookie/cookie_httponly", "1"),
+	)
+	changed, err := sdb.Refresh(context.Background())
+	require.NoError(t, err)
+	assert.Exactly(t, []string{
+		"default/0/web/cookie/cookie_domain",
+		"stores/3/web/cookie/cookie_httponly",
+		"websites/1/web/cookie/cookie_lifetime",
+		"default/0/web/cookie/cookie_path",
+	}, pathStrings(changed))
+
+	v, err := sdb.Get(cfgpath.MustMakeByString("web/cookie/cookie_lifetime").BindWebsite(1))
+	require.NoError(t, err)
+	assert.Exactly(t, "1800", v)
+	_, err = sdb.Get(cfgpath.MustMakeByString("web/cookie/cookie_path"))
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selectSQL)).WillReturnError(errors.NewAlreadyClosedf("DB closed"))
+	changed, err = sdb.Refresh(context.Background())
+	assert.Nil(t, changed)
+	assert.True(t, errors.IsAlreadyClosed(err), "%+v", err)
+
+	v, err = sdb.Get(cfgpath.MustMakeByString("web/cookie/cookie_domain"))
+	require.NoError(t, err, "Failed refresh must keep the index")
+	assert.Exactly(t, "example.com", v)
 }
 
-func TestDBStorageMultipleStmt_Get(t *testing.T) {
-	t.Parallel()
-	dbc, dbMock := cstesting.MockDB(t)
-	defer func() {
-		dbMock.ExpectClose()
-
-		assert.NoError(t, dbc.Close())
-
-		if err := dbMock.ExpectationsWereMet(); err != nil {
-			t.Error("there were unfulfilled expections", err)
-		}
+func TestDBStorage_Refresh_ConcurrentSet(t *testing.T) {
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selectSQL)).WillReturnRows(sqlmock.NewRows(ccdColumns))
+	sdb := ccd.MustNewDBStorage(context.Background(), dbc)
+
+	// the rows of the slow load do not yet contain the Set value
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selectSQL)).WillDelayFor(200 * time.Millisecond).WillReturnRows(
+		sqlmock.NewRows(ccdColumns).
+			AddRow(1, "default", 0, "web/cookie/cookie_lifetime", "3600").
+			AddRow(2, "default", 0, "web/cookie/cookie_path", "/"),
+	)
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(insertSQL)).
+		WithArgs("default", int64(0), "web/cookie/cookie_lifetime", "900").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	type result struct {
+		changed cfgpath.PathSlice
+		err     error
+	}
+	resC := make(chan result)
+	go func() {
+		changed, err := sdb.Refresh(context.Background())
+		resC <- result{changed: changed, err: err}
 	}()
+	time.Sleep(50 * time.Millisecond)
+	p := cfgpath.MustMakeByString("web/cookie/cookie_lifetime")
+	require.NoError(t, sdb.Set(p, 900))
 
-	sdb := ccd.MustNewDBStorage(dbc.DB)
-	sdb.Read.Idle = time.Second * 1
+	res := <-resC
+	require.NoError(t, res.err)
+	assert.Exactly(t, []string{"default/0/web/cookie/cookie_path"}, pathStrings(res.changed))
 
-	sdb.Start()
+	v, err := sdb.Get(p)
+	require.NoError(t, err)
+	assert.Exactly(t, "900", v, "Set during the load must not be overwritten")
 
-	// both prepared statements cannot run within one for loop :-(
-
-	var prepSel *sqlmock.ExpectedPrepare
-	for i, test := range dbStorageMultiTests {
-		if i < 3 {
-			prepSel = dbMock.ExpectPrepare("SELECT `value` FROM `[^`]+` WHERE `scope`=\\? AND `scope_id`=\\? AND `path`=\\?")
-		}
-
-		prepSel.ExpectQuery().WithArgs(
-			driver.Value(test.key.ScopeID.Type().StrType()),
-			driver.Value(test.key.ScopeID.ID()),
-			driver.Value(test.key.Bytes()),
-		).WillReturnRows(sqlmock.NewRows([]string{"value"}).FromCSVString(test.wantValue))
-
-		g, err := sdb.Get(test.key)
-		assert.NoError(t, err, "Index %d", i)
-		assert.Exactly(t, test.wantValue, g, "Index %d", i)
+	dbMock.ExpectClose() // second connection of the concurrent Set
+}
 
-		if i < 2 {
-			// last two iterations reopen a new statement, not closing it and reusing it
-			time.Sleep(time.Millisecond * 1500) // trigger ticker to close statements
-		}
+func TestDBStorage_Start(t *testing.T) {
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selectSQL)).WillReturnRows(
+		sqlmock.NewRows(ccdColumns).AddRow(1, "default", 0, "web/cookie/cookie_lifetime", "3600"),
+	)
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selectSQL)).WillReturnRows(
+		sqlmock.NewRows(ccdColumns).AddRow(1, "default", 0, "web/cookie/cookie_lifetime", "900"),
+	)
+
+	changedC := make(chan cfgpath.PathSlice, 1)
+	sdb := ccd.MustNewDBStorage(context.Background(), dbc,
+		ccd.WithRefreshInterval(time.Millisecond*10, func(ps cfgpath.PathSlice) {
+			select {
+			case changedC <- ps:
+			default:
+			}
+		}),
+	).Start().Start()
+
+	select {
+	case ps := <-changedC:
+		assert.Exactly(t, []string{"default/0/web/cookie/cookie_lifetime"}, pathStrings(ps))
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the refresh")
 	}
+	require.NoError(t, sdb.Stop())
+	require.NoError(t, sdb.Stop())
 
-	assert.NoError(t, sdb.Stop())
+	v, err := sdb.Get(cfgpath.MustMakeByString("web/cookie/cookie_lifetime"))
+	require.NoError(t, err)
+	assert.Exactly(t, "900", v)
 }
 
-func TestDBStorageMultipleStmt_All(t *testing.T) {
-	t.Parallel()
-
-	dbc, dbMock := cstesting.MockDB(t)
-	defer func() {
-		dbMock.ExpectClose()
-
-		assert.NoError(t, dbc.Close())
-
-		if err := dbMock.ExpectationsWereMet(); err != nil {
-			t.Error("there were unfulfilled expections", err)
-		}
-	}()
-
-	sdb := ccd.MustNewDBStorage(dbc.DB)
-	sdb.All.Idle = time.Second * 1
-
-	sdb.Start()
-
-	var prepAll *sqlmock.ExpectedPrepare
-	for i, test := range dbStorageMultiTests {
-
-		if i < 3 {
-			prepAll = dbMock.ExpectPrepare("SELECT scope,scope_id,path FROM `[^`]+` ORDER BY scope,scope_id,path")
-		}
-
-		mockRows := sqlmock.NewRows([]string{"scope", "scope_id", "path"})
-		for _, test := range dbStorageMultiTests {
-			mockRows.FromCSVString(fmt.Sprintf("%s,%d,%s", test.key.ScopeID.Type().StrType(), test.key.ScopeID.ID(), test.key.Chars))
-		}
-		prepAll.ExpectQuery().WillReturnRows(mockRows)
-
-		allKeys, err := sdb.AllKeys()
-		if err != nil {
-			t.Fatal("Index", i, "with error AllKeys:", err)
-		}
-
-		assert.True(t, allKeys.Contains(test.key), "Missing Key: %s", test.key)
-
-		if i < 2 {
-			time.Sleep(time.Millisecond * 1500) // trigger ticker to close statements
-		}
+func pathStrings(ps cfgpath.PathSlice) []string {
+	ret := make([]string, len(ps))
+	for i, p := range ps {
+		ret[i] = p.String()
 	}
-	assert.NoError(t, sdb.Stop())
-
+	return ret
 }
@@ -0,0 +1,106 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ccd
+
+import (
+	"context"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// DefaultTable defines the name of the Magento table which stores the
+// configuration values.
+const DefaultTable = "core_config_data"
+
+// TableCoreConfigData represents a row of the table core_config_data.
+type TableCoreConfigData struct {
+	ConfigID uint64         // config_id int(10) unsigned NOT NULL PRI  auto_increment
+	Scope    string         // scope varchar(8) NOT NULL MUL DEFAULT 'default'
+	ScopeID  int64          // scope_id int(11) NOT NULL  DEFAULT '0'
+	Path     string         // path varchar(255) NOT NULL  DEFAULT 'general'
+	Value    dml.NullString // value text NULL
+}
+
+// MapColumns implements interface dml.ColumnMapper.
+func (e *TableCoreConfigData) MapColumns(cm *dml.ColumnMap) error {
+	if cm.Mode() == dml.ColumnMapEntityReadAll {
+		return cm.Uint64(&e.ConfigID).String(&e.Scope).Int64(&e.ScopeID).String(&e.Path).NullString(&e.Value).Err()
+	}
+	for cm.Next() {
+		switch c := cm.Column(); c {
+		case "config_id":
+			cm.Uint64(&e.ConfigID)
+		case "scope":
+			cm.String(&e.Scope)
+		case "scope_id":
+			cm.Int64(&e.ScopeID)
+		case "path":
+			cm.String(&e.Path)
+		case "value":
+			cm.NullString(&e.Value)
+		default:
+			return errors.NewNotFoundf("[ccd] TableCoreConfigData Column %q not found", c)
+		}
+	}
+	return errors.WithStack(cm.Err())
+}
+
+// ConfigPath returns the scoped configuration path of the row.
+func (e *TableCoreConfigData) ConfigPath() (cfgpath.Path, error) {
+	p, err := cfgpath.MakeByString(e.Path)
+	if err != nil {
+		return cfgpath.Path{}, errors.Wrapf(err, "[ccd] Invalid path %q of config ID %d", e.Path, e.ConfigID)
+	}
+	return p.Bind(scope.FromString(e.Scope).Pack(e.ScopeID)), nil
+}
+
+// TableCoreConfigDataSlice represents a collection of core_config_data rows.
+type TableCoreConfigDataSlice []*TableCoreConfigData
+
+// MapColumns implements interface dml.ColumnMapper.
+func (cs *TableCoreConfigDataSlice) MapColumns(cm *dml.ColumnMap) error {
+	switch m := cm.Mode(); m {
+	case dml.ColumnMapScan:
+		if cm.Count == 0 {
+			*cs = (*cs)[:0]
+		}
+		e := new(TableCoreConfigData)
+		if err := e.MapColumns(cm); err != nil {
+			return errors.WithStack(err)
+		}
+		*cs = append(*cs, e)
+	default:
+		return errors.NewNotSupportedf("[ccd] Unknown Mode: %q", string(m))
+	}
+	return cm.Err()
+}
+
+// selectColumns lists all columns in the same order as
+// TableCoreConfigData.MapColumns reads them.
+var selectColumns = []string{"config_id", "scope", "scope_id", "path", "value"}
+
+// insertColumns lists the columns written by DBStorage.Set.
+var insertColumns = selectColumns[1:]
+
+// loadAll reads the whole table with one query.
+func loadAll(ctx context.Context, db *dml.ConnPool, table string) (TableCoreConfigDataSlice, error) {
+	var rows TableCoreConfigDataSlice
+	_, err := dml.NewSelect(selectColumns...).From(table).OrderBy("config_id").
+		WithDB(db.DB).WithArgs().Load(ctx, &rows)
+	return rows, errors.Wrapf(err, "[ccd] Failed to load table %q", table)
+}
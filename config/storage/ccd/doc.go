@@ -15,6 +15,13 @@
 // Package ccd = core_config_data uses the MySQL based table core_config_data
 // for reading and writing configuration paths, scopes and values.
 //
+// The DBStorage loads the whole table with one query into an in-memory index
+// and serves all reads from it. Writes use INSERT ... ON DUPLICATE KEY UPDATE.
+// Function Refresh, called on demand or periodically, reloads the table and
+// reports the changed paths.
+//
 // It also provides an option function to load data from core_config_data into
-// a storage service.
+// a storage service. The BinlogHandler, registered via WithBinlogSync,
+// republishes changes of core_config_data made by other processes, for example
+// the Magento backend, to the subscribers of a config.Service.
 package ccd
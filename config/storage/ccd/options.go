@@ -15,46 +15,46 @@
 package ccd
 
 import (
-	"github.com/corestoreio/pkg/config"
-	"github.com/corestoreio/pkg/config/cfgpath"
-	"github.com/corestoreio/pkg/storage/dbr"
-	"github.com/corestoreio/pkg/store/scope"
+	"context"
+
 	"github.com/corestoreio/errors"
 	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/sql/dml"
 )
 
-// WithCoreConfigData reads the table core_config_data into the Service and
-// overrides existing values. If the column `value` is NULL entry will be
-// ignored. Stops on errors.
-func WithCoreConfigData(dbrSess dbr.SessionRunner) config.Option {
+// WithCoreConfigData reads the table core_config_data with one query into the
+// Service and overrides existing values. If the column `value` is NULL entry
+// will be ignored. Argument tableName can be empty to use DefaultTable. Stops
+// on errors.
+func WithCoreConfigData(db *dml.ConnPool, tableName string) config.Option {
 	return func(s *config.Service) error {
-
-		var ccd TableCoreConfigDataSlice
-		loadedRows, err := TableCollection.MustTable(TableIndexCoreConfigData).Load(dbrSess, &ccd)
+		if tableName == "" {
+			tableName = DefaultTable
+		}
+		ccd, err := loadAll(context.Background(), db, tableName)
 		if s.Log.IsDebug() {
-			s.Log.Debug("ccd.WithCoreConfigData.Load", log.Int("rows", loadedRows), log.Err(err))
+			s.Log.Debug("ccd.WithCoreConfigData.Load", log.Int("rows", len(ccd)), log.Err(err))
 		}
 		if err != nil {
-			return errors.Wrap(err, "[ccd] WithCoreConfigData.csdb.Load")
+			return errors.Wrap(err, "[ccd] WithCoreConfigData.Load")
 		}
 
 		var writtenRows int
 		for _, cd := range ccd {
 			if cd.Value.Valid {
-				var p cfgpath.Path
-				p, err = cfgpath.NewByParts(cd.Path)
+				p, err := cd.ConfigPath()
 				if err != nil {
-					return errors.Wrapf(err, "[ccd] cfgpath.NewByParts Path %q", cd.Path)
+					return errors.WithStack(err)
 				}
-
-				if err = s.Write(p.Bind(scope.FromString(cd.Scope).Pack(cd.ScopeID)), cd.Value.String); err != nil {
-					return errors.Wrapf(err, "[ccd] cfgpath.NewByParts Path %q Scope: %q ID: %d Value: %q", cd.Path, cd.Scope, cd.ScopeID, cd.Value.String)
+				if err = s.Write(p, cd.Value.String); err != nil {
+					return errors.Wrapf(err, "[ccd] Service.Write Path %q Scope: %q ID: %d Value: %q", cd.Path, cd.Scope, cd.ScopeID, cd.Value.String)
 				}
 				writtenRows++
 			}
 		}
 		if s.Log.IsDebug() {
-			s.Log.Debug("ccd.WithCoreConfigData.Written", log.Int("loadedRows", loadedRows), log.Int("writtenRows", writtenRows))
+			s.Log.Debug("ccd.WithCoreConfigData.Written", log.Int("loadedRows", len(ccd)), log.Int("writtenRows", writtenRows))
 		}
 		return nil
 	}
@@ -0,0 +1,237 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgenv
+
+import (
+	"os"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// DefaultPrefix defines the prefix of the environment variables.
+const DefaultPrefix = "CS"
+
+// separator separates the prefix, the route parts, the scope and its ID.
+const separator = "__"
+
+// Option applies options to the New function.
+type Option func(*Storage) error
+
+// WithPrefix sets the prefix of the environment variable names. Default
+// prefix is the constant DefaultPrefix.
+func WithPrefix(prefix string) Option {
+	return func(s *Storage) error {
+		if prefix == "" {
+			return errors.NewEmptyf("[cfgenv] Prefix cannot be empty")
+		}
+		s.prefix = strings.ToUpper(prefix)
+		return nil
+	}
+}
+
+// WithEnviron sets the environment variables in the form "key=value" instead of
+// using os.Environ().
+func WithEnviron(environ []string) Option {
+	return func(s *Storage) error {
+		s.environ = environ
+		return nil
+	}
+}
+
+// WithLogger sets a custom logger. Default log.BlackHole.
+func WithLogger(l log.Logger) Option {
+	return func(s *Storage) error {
+		s.log = l
+		return nil
+	}
+}
+
+type keyVal struct {
+	k cfgpath.Path
+	v interface{}
+	// name of the environment variable
+	name string
+}
+
+// Storage decorates a config.Storager with the values of the environment
+// variables. The environment gets read once in New.
+type Storage struct {
+	backend config.Storager
+	prefix  string
+	environ []string
+	log     log.Logger
+	// kv contains the overridden values. It is read only after New.
+	kv map[uint32]keyVal
+}
+
+// New creates a new overlay for the backend. An environment variable which
+// has the prefix but an invalid path, returns an error with behaviour
+// NotValid or NotSupported. An environment variable with the prefix but less
+// than three route parts, like CS__X, belongs to a different application and
+// gets skipped.
+func New(backend config.Storager, opts ...Option) (*Storage, error) {
+	s := &Storage{
+		backend: backend,
+		prefix:  DefaultPrefix,
+		log:     log.BlackHole{},
+		kv:      make(map[uint32]keyVal),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			if err := opt(s); err != nil {
+				return nil, errors.Wrap(err, "[cfgenv] New.Option")
+			}
+		}
+	}
+	if s.environ == nil {
+		s.environ = os.Environ()
+	}
+
+	for _, env := range s.environ {
+		pos := strings.IndexByte(env, '=')
+		if pos < 0 {
+			continue
+		}
+		p, ok, err := ParseName(s.prefix, env[:pos])
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgenv] Environment variable %q", env[:pos])
+		}
+		if !ok {
+			if s.log.IsInfo() && strings.HasPrefix(strings.ToUpper(env[:pos]), s.prefix+separator) {
+				s.log.Info("cfgenv.New.Skip", log.String("name", env[:pos]), log.String("reason", "too few route parts"))
+			}
+			continue
+		}
+		h32, err := p.Hash(-1)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgenv] Environment variable %q", env[:pos])
+		}
+		s.kv[h32] = keyVal{p, env[pos+1:], env[:pos]}
+	}
+	return s, nil
+}
+
+// MustNew same as New but panics on error.
+func MustNew(backend config.Storager, opts ...Option) *Storage {
+	s, err := New(backend, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// ParseName parses the name of an environment variable into a path. It
+// returns false if the name does not start with the prefix followed by two
+// underscores or if the name has less than three route parts.
+func ParseName(prefix, name string) (_ cfgpath.Path, ok bool, err error) {
+	name = strings.ToUpper(name)
+	prefix = strings.ToUpper(prefix) + separator
+	if !strings.HasPrefix(name, prefix) {
+		return cfgpath.Path{}, false, nil
+	}
+	parts := strings.Split(strings.ToLower(name[len(prefix):]), separator)
+	for _, part := range parts {
+		if part == "" {
+			return cfgpath.Path{}, false, errors.NewNotValidf("[cfgenv] Name %q contains an empty part", name)
+		}
+	}
+	if len(parts) < cfgpath.Levels {
+		return cfgpath.Path{}, false, nil
+	}
+
+	scp, id := scope.StrDefault.String(), "0"
+	if l := len(parts); l > 2 && scope.Valid(parts[l-2]) {
+		scp, id = parts[l-2], parts[l-1]
+		parts = parts[:l-2]
+	}
+
+	p, err := cfgpath.SplitFQ(scp + "/" + id + "/" + strings.Join(parts, "/"))
+	if err != nil {
+		return cfgpath.Path{}, false, errors.Wrapf(err, "[cfgenv] Name %q", name)
+	}
+	if err := p.IsValid(); err != nil {
+		return cfgpath.Path{}, false, errors.Wrapf(err, "[cfgenv] Name %q", name)
+	}
+	return p, true, nil
+}
+
+// Set implements config.Storager interface. It writes the value to the
+// backend. An overridden key keeps returning the value of the environment
+// variable.
+func (s *Storage) Set(key cfgpath.Path, value interface{}) error {
+	return s.backend.Set(key, value)
+}
+
+// Get implements config.Storager interface. It returns the value of the
+// environment variable, if set, otherwise the value of the backend.
+func (s *Storage) Get(key cfgpath.Path) (interface{}, error) {
+	if len(s.kv) > 0 {
+		h32, err := key.Hash(-1)
+		if err != nil {
+			return nil, errors.Wrap(err, "[cfgenv] key.Hash")
+		}
+		if kv, ok := s.kv[h32]; ok {
+			return kv.v, nil
+		}
+	}
+	return s.backend.Get(key)
+}
+
+// Source implements config.Sourcer interface. It returns "env:" followed by
+// the name of the environment variable for an overridden key, otherwise the
+// source of the backend.
+func (s *Storage) Source(key cfgpath.Path) string {
+	if h32, err := key.Hash(-1); err == nil {
+		if kv, ok := s.kv[h32]; ok {
+			return "env:" + kv.name
+		}
+	}
+	return config.SourceOf(s.backend, key)
+}
+
+// AllKeys implements config.Storager interface. It returns the keys of the
+// backend and the overridden keys which do not exist in the backend sorted by
+// route and scope. The slice of the backend does not get modified.
+func (s *Storage) AllKeys() (cfgpath.PathSlice, error) {
+	bKeys, err := s.backend.AllKeys()
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgenv] backend.AllKeys")
+	}
+	keys := make(cfgpath.PathSlice, len(bKeys), len(bKeys)+len(s.kv))
+	copy(keys, bKeys)
+	for _, kv := range s.kv {
+		if !bKeys.Contains(kv.k) {
+			keys = append(keys, kv.k)
+		}
+	}
+	keys.Sort()
+	return keys, nil
+}
+
+// OverriddenKeys returns all keys set by environment variables sorted by route
+// and scope.
+func (s *Storage) OverriddenKeys() cfgpath.PathSlice {
+	keys := make(cfgpath.PathSlice, 0, len(s.kv))
+	for _, kv := range s.kv {
+		keys = append(keys, kv.k)
+	}
+	keys.Sort()
+	return keys
+}
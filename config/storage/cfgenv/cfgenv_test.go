@@ -0,0 +1,235 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgenv_test
+
+import (
+	"os"
+	"sort"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/config/storage/cfgenv"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Storager = (*cfgenv.Storage)(nil)
+
+func TestParseName(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		prefix  string
+		name    string
+		want    string
+		wantOK  bool
+		wantErr errors.BehaviourFunc
+	}{
+		{"CS", "CS__WEB__SECURE__BASE_URL", "default/0/web/secure/base_url", true, nil},
+		{"CS", "cs__web__secure__base_url", "default/0/web/secure/base_url", true, nil},
+		{"CS", "CS__WEB__SECURE__BASE_URL__DEFAULT__0", "default/0/web/secure/base_url", true, nil},
+		{"CS", "CS__WEB__SECURE__BASE_URL__WEBSITES__1", "websites/1/web/secure/base_url", true, nil},
+		{"CS", "CS__WEB__SECURE__BASE_URL__STORES__2", "stores/2/web/secure/base_url", true, nil},
+		{"CS", "CS__CATALOG__FRONTEND__LIST__MODE__STORES__2", "stores/2/catalog/frontend/list/mode", true, nil},
+		{"shop", "SHOP__WEB__SECURE__BASE_URL__STORES__2", "stores/2/web/secure/base_url", true, nil},
+		{"CS", "CSX__WEB__SECURE__BASE_URL", "", false, nil},
+		{"CS", "PATH", "", false, nil},
+		{"CS", "CS__WEB__SECURE", "", false, nil},
+		{"CS", "CS__X", "", false, nil},
+		{"CS", "CS__WEB__SECURE__STORES__2", "", false, errors.IsNotValid},
+		{"CS", "CS__WEB__SECURE__BASE_URL__STORES__X", "", false, errors.IsNotValid},
+		{"CS", "CS__WEB____BASE_URL", "", false, errors.IsNotValid},
+	}
+	for _, test := range tests {
+		p, ok, err := cfgenv.ParseName(test.prefix, test.name)
+		if test.wantErr != nil {
+			assert.True(t, test.wantErr(err), "%s: %+v", test.name, err)
+			continue
+		}
+		require.NoError(t, err, test.name)
+		assert.Exactly(t, test.wantOK, ok, test.name)
+		if ok {
+			assert.Exactly(t, test.want, p.String(), test.name)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	backend := config.NewInMemoryStore()
+	pURL := cfgpath.MustMakeByString("web/secure/base_url")
+	require.NoError(t, backend.Set(pURL, "https://backend.io/"))
+	require.NoError(t, backend.Set(pURL.BindStore(2), "https://store2.backend.io/"))
+	require.NoError(t, backend.Set(pURL.BindStore(3), "https://store3.backend.io/"))
+
+	s, err := cfgenv.New(backend, cfgenv.WithEnviron([]string{
+		"PATH=/usr/bin",
+		"CS__WEB__SECURE__BASE_URL__STORES__2=https://store2.env.io/",
+		"CS__WEB__SECURE__BASE_URL__WEBSITES__1=https://website1.env.io/",
+		"CS__WEB__COOKIE__COOKIE_LIFETIME=3600",
+	}))
+	require.NoError(t, err)
+
+	srv := config.MustNewService(s)
+	defer func() { assert.NoError(t, srv.Close()) }()
+
+	tests := []struct {
+		p    cfgpath.Path
+		want string
+	}{
+		{pURL, "https://backend.io/"},
+		{pURL.BindWebsite(1), "https://website1.env.io/"},
+		{pURL.BindStore(2), "https://store2.env.io/"},
+		{pURL.BindStore(3), "https://store3.backend.io/"},
+	}
+	for _, test := range tests {
+		have, err := srv.String(test.p)
+		require.NoError(t, err, test.p.String())
+		assert.Exactly(t, test.want, have, test.p.String())
+	}
+
+	lt, err := srv.Int(cfgpath.MustMakeByString("web/cookie/cookie_lifetime"))
+	require.NoError(t, err)
+	assert.Exactly(t, 3600, lt)
+
+	// the environment variable wins
+	require.NoError(t, srv.Write(pURL.BindStore(2), "https://store2.written.io/"))
+	have, err := srv.String(pURL.BindStore(2))
+	require.NoError(t, err)
+	assert.Exactly(t, "https://store2.env.io/", have)
+
+	v, err := s.Get(pURL.BindStore(4))
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+	assert.Nil(t, v)
+
+	var overridden []string
+	for _, p := range s.OverriddenKeys() {
+		overridden = append(overridden, p.String())
+	}
+	assert.Exactly(t, []string{
+		"default/0/web/cookie/cookie_lifetime",
+		"websites/1/web/secure/base_url",
+		"stores/2/web/secure/base_url",
+	}, overridden)
+
+	keys, err := s.AllKeys()
+	require.NoError(t, err)
+	for _, p := range []cfgpath.Path{
+		pURL, pURL.BindWebsite(1), pURL.BindStore(2), pURL.BindStore(3),
+		cfgpath.MustMakeByString("web/cookie/cookie_lifetime"),
+	} {
+		assert.True(t, keys.Contains(p), "Missing key %q", p)
+	}
+	var storeTwo int
+	for _, p := range keys {
+		if p.ScopeID == scope.MakeTypeID(scope.Store, 2) && p.Route.Data == pURL.Route.Data {
+			storeTwo++
+		}
+	}
+	assert.Exactly(t, 1, storeTwo, "Key stores/2/web/secure/base_url must be listed once")
+	assert.True(t, sort.IsSorted(keys), "AllKeys must be sorted: %v", keys)
+}
+
+// sharedKeysStore returns always the same slice from AllKeys.
+type sharedKeysStore struct {
+	config.Storager
+	keys cfgpath.PathSlice
+}
+
+func (s sharedKeysStore) AllKeys() (cfgpath.PathSlice, error) { return s.keys, nil }
+
+func TestStorage_AllKeys_CopiesBackend(t *testing.T) {
+	t.Parallel()
+
+	pURL := cfgpath.MustMakeByString("web/secure/base_url")
+	backendKeys := make(cfgpath.PathSlice, 1, 10) // spare capacity for an append
+	backendKeys[0] = pURL.BindStore(3)
+	backend := sharedKeysStore{Storager: config.NewInMemoryStore(), keys: backendKeys}
+
+	s := cfgenv.MustNew(backend, cfgenv.WithEnviron([]string{
+		"CS__WEB__SECURE__BASE_URL=https://env.io/",
+	}))
+	keys, err := s.AllKeys()
+	require.NoError(t, err)
+	assert.Exactly(t, cfgpath.PathSlice{pURL, pURL.BindStore(3)}, keys)
+	assert.Exactly(t, cfgpath.PathSlice{pURL.BindStore(3)}, backend.keys)
+	assert.Exactly(t, pURL.BindStore(3), backendKeys[:2][0], "Backend array must not be modified")
+	assert.Exactly(t, cfgpath.Path{}, backendKeys[:2][1], "Backend array must not be modified")
+}
+
+func TestNew_Errors(t *testing.T) {
+	t.Parallel()
+
+	s, err := cfgenv.New(config.NewInMemoryStore(), cfgenv.WithEnviron([]string{
+		"CS__X=unrelated",
+		"CS__WEB__SECURE__BASE_URL=https://env.io/",
+	}))
+	require.NoError(t, err, "Variable CS__X must be skipped")
+	assert.Len(t, s.OverriddenKeys(), 1)
+
+	s, err = cfgenv.New(config.NewInMemoryStore(), cfgenv.WithEnviron([]string{
+		"CS__WEB__SECURE__BASE_URL__STORES__X=https://store2.env.io/",
+	}))
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+	assert.Nil(t, s)
+
+	s, err = cfgenv.New(config.NewInMemoryStore(), cfgenv.WithPrefix(""))
+	assert.True(t, errors.IsEmpty(err), "%+v", err)
+	assert.Nil(t, s)
+}
+
+func TestNew_OSEnviron(t *testing.T) {
+	require.NoError(t, os.Setenv("CSTEST__WEB__SECURE__BASE_URL__STORES__5", "https://store5.env.io/"))
+	defer os.Unsetenv("CSTEST__WEB__SECURE__BASE_URL__STORES__5")
+
+	s := cfgenv.MustNew(config.NewInMemoryStore(), cfgenv.WithPrefix("CSTEST"))
+	v, err := s.Get(cfgpath.MustMakeByString("web/secure/base_url").BindStore(5))
+	require.NoError(t, err)
+	assert.Exactly(t, "https://store5.env.io/", v)
+}
+
+func TestStorage_Source(t *testing.T) {
+	t.Parallel()
+
+	backend := config.NewInMemoryStore()
+	pURL := cfgpath.MustMakeByString("web/secure/base_url")
+	require.NoError(t, backend.Set(pURL, "https://backend.io/"))
+
+	s := cfgenv.MustNew(backend, cfgenv.WithEnviron([]string{
+		"CS__WEB__SECURE__BASE_URL__STORES__2=https://store2.env.io/",
+	}))
+	assert.Exactly(t, "env:CS__WEB__SECURE__BASE_URL__STORES__2", s.Source(pURL.BindStore(2)))
+	assert.Exactly(t, config.SourceOf(backend, pURL), s.Source(pURL))
+
+	srv := config.MustNewService(s)
+	defer func() { assert.NoError(t, srv.Close()) }()
+
+	e, err := config.NewScoped(srv, 1, 2).Explain(pURL.Route, &element.Field{Scopes: scope.PermStore})
+	require.NoError(t, err)
+	assert.Exactly(t, "https://store2.env.io/", e.Value)
+	assert.Exactly(t, "stores/2/web/secure/base_url", e.Path)
+	assert.Exactly(t, "env:CS__WEB__SECURE__BASE_URL__STORES__2", e.Source)
+
+	e, err = config.NewScoped(srv, 1, 3).Explain(pURL.Route, &element.Field{Scopes: scope.PermStore})
+	require.NoError(t, err)
+	assert.Exactly(t, "https://backend.io/", e.Value)
+	assert.Exactly(t, "default/0/web/secure/base_url", e.Path)
+	assert.Len(t, e.Lookups, 3)
+}
@@ -0,0 +1,34 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfgenv overlays configuration values from environment variables onto
+// any config.Storager.
+//
+// The name of an environment variable consists of the prefix, the route parts
+// and optionally the scope and its ID, all separated by two underscores. The
+// name is case insensitive. A single underscore belongs to the route part.
+//
+//		CS__WEB__SECURE__BASE_URL                => default/0/web/secure/base_url
+//		CS__WEB__SECURE__BASE_URL__DEFAULT__0    => default/0/web/secure/base_url
+//		CS__WEB__SECURE__BASE_URL__WEBSITES__1   => websites/1/web/secure/base_url
+//		CS__WEB__SECURE__BASE_URL__STORES__2     => stores/2/web/secure/base_url
+//
+// A name with less than three route parts, like CS__X, gets skipped because it
+// belongs to a different application.
+//
+// The default prefix is CS and can be changed with option WithPrefix. Values
+// of environment variables take precedence over the values of the backend.
+// This allows Kubernetes deployments to override single paths without touching
+// the database table core_config_data.
+package cfgenv
@@ -0,0 +1,196 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/store/scope"
+	"gopkg.in/yaml.v2"
+)
+
+// decode parses the document and flattens the nested keys into paths.
+func decode(format string, data []byte) (map[uint32]keyVal, error) {
+	var doc map[string]interface{}
+	var err error
+	switch format {
+	case FormatJSON:
+		err = json.Unmarshal(data, &doc)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &doc)
+	case FormatTOML:
+		_, err = toml.Decode(string(data), &doc)
+	default:
+		err = errors.NewNotSupportedf("[cfgfile] Format %q not supported", format)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgfile] Unmarshal")
+	}
+
+	flat := make(map[string]interface{}, len(doc))
+	if err := flatten("", doc, flat); err != nil {
+		return nil, errors.Wrap(err, "[cfgfile] flatten")
+	}
+
+	kv := make(map[uint32]keyVal, len(flat))
+	for _, key := range sortKeys(flat) {
+		p, err := makePath(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgfile] Key %q", key)
+		}
+		h32, err := p.Hash(-1)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgfile] Key %q", key)
+		}
+		if _, ok := kv[h32]; ok {
+			return nil, errors.NewAlreadyExistsf("[cfgfile] Duplicate key %q", key)
+		}
+		kv[h32] = keyVal{p, flat[key]}
+	}
+	return kv, nil
+}
+
+// flatten joins the keys of nested maps with the cfgpath.Separator. Empty
+// values get ignored.
+func flatten(prefix string, v interface{}, ret map[string]interface{}) error {
+	switch vt := v.(type) {
+	case map[string]interface{}:
+		for k, v := range vt {
+			if err := flatten(joinKey(prefix, k), v, ret); err != nil {
+				return err
+			}
+		}
+	case map[interface{}]interface{}: // YAML
+		for k, v := range vt {
+			if err := flatten(joinKey(prefix, fmt.Sprint(k)), v, ret); err != nil {
+				return err
+			}
+		}
+	case nil:
+		// empty value
+	default:
+		if prefix == "" {
+			return errors.NewNotValidf("[cfgfile] Document must contain a map but got %T", v)
+		}
+		ret[prefix] = v
+	}
+	return nil
+}
+
+func joinKey(prefix, key string) string {
+	key = strings.Trim(key, "/")
+	if prefix == "" {
+		return key
+	}
+	return prefix + "/" + key
+}
+
+// makePath creates a path from a flattened key. The first part of the key must
+// be the scope. Websites and stores must be followed by the ID.
+//		default/web/secure/offloader_header
+//		websites/1/web/secure/offloader_header
+//		stores/2/web/secure/offloader_header
+func makePath(key string) (cfgpath.Path, error) {
+	pos := strings.IndexByte(key, cfgpath.Separator)
+	if pos < 0 {
+		return cfgpath.Path{}, errors.NewNotValidf("[cfgfile] Key %q contains no scope", key)
+	}
+	switch key[:pos] {
+	case scope.StrDefault.String():
+		return cfgpath.MakeByString(key[pos+1:])
+	case scope.StrWebsites.String(), scope.StrStores.String():
+		p, err := cfgpath.SplitFQ(key)
+		if err != nil {
+			return cfgpath.Path{}, err
+		}
+		return p, p.IsValid()
+	}
+	return cfgpath.Path{}, errors.NewNotSupportedf("[cfgfile] Unknown scope %q in key %q", key[:pos], key)
+}
+
+// encode creates the nested document from the paths.
+func encode(format string, kv map[uint32]keyVal) ([]byte, error) {
+	doc := make(map[string]interface{}, 3)
+	for _, kv := range kv {
+		node := doc
+		scp, id := kv.k.ScopeID.Unpack()
+		switch scp {
+		case scope.Website, scope.Store:
+			node = child(child(node, scope.FromType(scp).String()), strconv.FormatInt(id, 10))
+		default:
+			node = child(node, scope.StrDefault.String())
+		}
+
+		parts := strings.Split(kv.k.Route.Data, "/")
+		for _, part := range parts[:len(parts)-1] {
+			node = child(node, part)
+			if node == nil {
+				return nil, errors.NewNotValidf("[cfgfile] Path %q collides with a value", kv.k)
+			}
+		}
+		last := parts[len(parts)-1]
+		if _, ok := node[last]; ok {
+			return nil, errors.NewNotValidf("[cfgfile] Path %q collides with another path", kv.k)
+		}
+		node[last] = kv.v
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatJSON:
+		var data []byte
+		if data, err = json.MarshalIndent(doc, "", "  "); err == nil {
+			buf.Write(data)
+			buf.WriteByte('\n')
+		}
+	case FormatYAML:
+		var data []byte
+		if data, err = yaml.Marshal(doc); err == nil {
+			buf.Write(data)
+		}
+	case FormatTOML:
+		err = toml.NewEncoder(&buf).Encode(doc)
+	default:
+		err = errors.NewNotSupportedf("[cfgfile] Format %q not supported", format)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgfile] Marshal")
+	}
+	return buf.Bytes(), nil
+}
+
+// child returns the nested map of key and creates it if it does not exists.
+// Returns nil if key contains already a value.
+func child(m map[string]interface{}, key string) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	v, ok := m[key]
+	if !ok {
+		c := make(map[string]interface{})
+		m[key] = c
+		return c
+	}
+	c, _ := v.(map[string]interface{})
+	return c
+}
@@ -0,0 +1,45 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfgfile reads and writes configuration paths and their values from a
+// YAML, TOML or JSON file.
+//
+// The document must be organized by scope. The top level keys are `default`,
+// `websites` and `stores`. Websites and stores contain their IDs as keys. The
+// nested keys below a scope form the route of a cfgpath.Path.
+//
+//		default:
+//		  web:
+//		    secure:
+//		      offloader_header: SSL_OFFLOADED
+//		websites:
+//		  1:
+//		    general:
+//		      locale:
+//		        timezone: Europe/Berlin
+//		stores:
+//		  2:
+//		    general:
+//		      locale:
+//		        timezone: Europe/Zurich
+//
+// Keys can also contain the separator, for example `websites/1` or
+// `web/secure/offloader_header`.
+//
+// With option WithWriteBack all changes get written back to the file. The
+// option function WithHotReload for the config.Service watches the file and
+// publishes all changed paths via the pub/sub system of the config.Service.
+// This allows to keep the configuration in a version control system and mount
+// it into containers.
+package cfgfile
@@ -0,0 +1,317 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+)
+
+// Supported file formats. The format gets detected by the file extension or
+// can be set with the option WithFormat.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// Option applies options to the New function.
+type Option func(*Storage) error
+
+// WithFormat sets the format of the file and overrides the detection by the
+// file extension. Supported formats are the constants FormatJSON, FormatYAML
+// and FormatTOML.
+func WithFormat(format string) Option {
+	return func(s *Storage) error {
+		switch format {
+		case FormatJSON, FormatYAML, FormatTOML:
+			s.format = format
+			return nil
+		}
+		return errors.NewNotSupportedf("[cfgfile] Format %q not supported", format)
+	}
+}
+
+// WithWriteBack writes the whole document back to the file each time a value
+// gets changed via function Set. The file gets replaced atomically.
+func WithWriteBack() Option {
+	return func(s *Storage) error {
+		s.writeBack = true
+		return nil
+	}
+}
+
+type keyVal struct {
+	k cfgpath.Path
+	v interface{}
+}
+
+// Storage holds the configuration values of a file in memory. All methods are
+// thread safe.
+type Storage struct {
+	filename  string
+	format    string
+	writeBack bool
+
+	mu      sync.RWMutex
+	kv      map[uint32]keyVal
+	modTime time.Time
+	size    int64
+
+	closed bool
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New loads the configuration values from a file. The format gets detected by
+// the file extension: .json, .yaml, .yml or .toml.
+func New(filename string, opts ...Option) (*Storage, error) {
+	s := &Storage{
+		filename: filename,
+		stop:     make(chan struct{}),
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		s.format = FormatJSON
+	case ".yaml", ".yml":
+		s.format = FormatYAML
+	case ".toml":
+		s.format = FormatTOML
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			if err := opt(s); err != nil {
+				return nil, errors.Wrap(err, "[cfgfile] New.Option")
+			}
+		}
+	}
+	if s.format == "" {
+		return nil, errors.NewNotSupportedf("[cfgfile] Cannot detect format of file %q", filename)
+	}
+	if _, err := s.Reload(); err != nil {
+		return nil, errors.Wrap(err, "[cfgfile] New.Reload")
+	}
+	return s, nil
+}
+
+// MustNew same as New but panics on error. Use only in testing or during boot
+// process.
+func MustNew(filename string, opts ...Option) *Storage {
+	s, err := New(filename, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Filename returns the name of the loaded file.
+func (s *Storage) Filename() string {
+	return s.filename
+}
+
+// Set implements config.Storager interface. A nil value removes the key. If
+// the option WithWriteBack has been set and the value differs from the current
+// one, the file gets rewritten.
+func (s *Storage) Set(key cfgpath.Path, value interface{}) error {
+	h32, err := key.Hash(-1)
+	if err != nil {
+		return errors.Wrap(err, "[cfgfile] key.Hash")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, ok := s.kv[h32]
+	switch {
+	case value == nil && !ok:
+		return nil
+	case value == nil:
+		delete(s.kv, h32)
+	case ok && reflect.DeepEqual(old.v, value):
+		return nil
+	default:
+		s.kv[h32] = keyVal{key, value}
+	}
+
+	if !s.writeBack {
+		return nil
+	}
+	if err := s.write(); err != nil {
+		// restore the previous state to stay in sync with the file
+		if ok {
+			s.kv[h32] = old
+		} else {
+			delete(s.kv, h32)
+		}
+		return errors.Wrapf(err, "[cfgfile] Set.write %q", s.filename)
+	}
+	return nil
+}
+
+// Get implements config.Storager interface.
+// Error behaviour: NotFound.
+func (s *Storage) Get(key cfgpath.Path) (interface{}, error) {
+	h32, err := key.Hash(-1)
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgfile] key.Hash")
+	}
+	s.mu.RLock()
+	data, ok := s.kv[h32]
+	s.mu.RUnlock()
+	if ok {
+		return data.v, nil
+	}
+	return nil, errors.NewNotFoundf("[cfgfile] Key %q not found", key)
+}
+
+// AllKeys implements config.Storager interface. The returned slice is sorted
+// by route and scope.
+func (s *Storage) AllKeys() (cfgpath.PathSlice, error) {
+	s.mu.RLock()
+	ret := make(cfgpath.PathSlice, 0, len(s.kv))
+	for _, kv := range s.kv {
+		ret = append(ret, kv.k)
+	}
+	s.mu.RUnlock()
+	ret.Sort()
+	return ret, nil
+}
+
+// Reload reads the file again and replaces all values. It returns the sorted
+// paths whose values have been changed, added or removed. On error the current
+// values stay untouched.
+func (s *Storage) Reload() (cfgpath.PathSlice, error) {
+	fi, err := os.Stat(s.filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[cfgfile] Reload.Stat %q", s.filename)
+	}
+	data, err := ioutil.ReadFile(s.filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[cfgfile] Reload.ReadFile %q", s.filename)
+	}
+	kv, err := decode(s.format, data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[cfgfile] Reload.decode %q", s.filename)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var changed cfgpath.PathSlice
+	for h32, nkv := range kv {
+		if okv, ok := s.kv[h32]; !ok || !reflect.DeepEqual(okv.v, nkv.v) {
+			changed = append(changed, nkv.k)
+		}
+	}
+	for h32, okv := range s.kv {
+		if _, ok := kv[h32]; !ok {
+			changed = append(changed, okv.k)
+		}
+	}
+	s.kv = kv
+	s.modTime = fi.ModTime()
+	s.size = fi.Size()
+	changed.Sort()
+	return changed, nil
+}
+
+// modified reports whether the modification time or the size of the file
+// differs from the last read or write.
+func (s *Storage) modified() (bool, error) {
+	fi, err := os.Stat(s.filename)
+	if err != nil {
+		return false, errors.Wrapf(err, "[cfgfile] Stat %q", s.filename)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !fi.ModTime().Equal(s.modTime) || fi.Size() != s.size, nil
+}
+
+// write encodes all values and replaces the file atomically via a temporary
+// file in the same directory. Caller must hold the write lock.
+func (s *Storage) write() error {
+	data, err := encode(s.format, s.kv)
+	if err != nil {
+		return errors.Wrap(err, "[cfgfile] encode")
+	}
+
+	perm := os.FileMode(0644)
+	if fi, err := os.Stat(s.filename); err == nil {
+		perm = fi.Mode().Perm()
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(s.filename), "."+filepath.Base(s.filename))
+	if err != nil {
+		return errors.Wrap(err, "[cfgfile] TempFile")
+	}
+	tmpName := f.Name()
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Chmod(tmpName, perm)
+	}
+	if err == nil {
+		err = os.Rename(tmpName, s.filename)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return errors.Wrapf(err, "[cfgfile] Write %q", tmpName)
+	}
+
+	fi, err := os.Stat(s.filename)
+	if err != nil {
+		return errors.Wrapf(err, "[cfgfile] Stat %q", s.filename)
+	}
+	s.modTime = fi.ModTime()
+	s.size = fi.Size()
+	return nil
+}
+
+// Close terminates the goroutine started by the option function
+// WithHotReload. Close can be called multiple times.
+func (s *Storage) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.stop)
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// sortKeys returns the keys of a map in sorted order.
+func sortKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
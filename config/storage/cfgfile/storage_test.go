@@ -0,0 +1,276 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgfile_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/cfgfile"
+	"github.com/corestoreio/pkg/util/conv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Storager = (*cfgfile.Storage)(nil)
+
+var _ config.MessageReceiver = (messageReceiver)(nil)
+
+type messageReceiver chan cfgpath.Path
+
+func (mr messageReceiver) MessageConfig(p cfgpath.Path) error {
+	mr <- p
+	return nil
+}
+
+func pathStrings(ps cfgpath.PathSlice) []string {
+	ret := make([]string, len(ps))
+	for i, p := range ps {
+		ret[i] = p.String()
+	}
+	return ret
+}
+
+// copyFile copies a file from the testdata directory into a temporary
+// directory.
+func copyFile(t *testing.T, name string) (filename string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "cfgfile")
+	require.NoError(t, err)
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	filename = filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(filename, data, 0644))
+	return filename, func() { os.RemoveAll(dir) }
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	wantKeys := []string{
+		"websites/1/general/locale/timezone",
+		"stores/2/general/locale/timezone",
+		"default/0/web/cookie/cookie_lifetime",
+		"default/0/web/secure/offloader_header",
+	}
+
+	for _, file := range []string{"config.yaml", "config.toml", "config.json"} {
+		t.Run(file, func(t *testing.T) {
+			s, err := cfgfile.New(filepath.Join("testdata", file))
+			require.NoError(t, err)
+			defer func() { assert.NoError(t, s.Close()) }()
+
+			keys, err := s.AllKeys()
+			require.NoError(t, err)
+			assert.Exactly(t, wantKeys, pathStrings(keys))
+
+			v, err := s.Get(cfgpath.MustMakeByString("web/secure/offloader_header"))
+			require.NoError(t, err)
+			assert.Exactly(t, "SSL_OFFLOADED", v)
+
+			v, err = s.Get(cfgpath.MustMakeByString("web/cookie/cookie_lifetime"))
+			require.NoError(t, err)
+			assert.Exactly(t, 3600, conv.ToInt(v))
+
+			v, err = s.Get(cfgpath.MustMakeByString("general/locale/timezone").BindWebsite(1))
+			require.NoError(t, err)
+			assert.Exactly(t, "Europe/Berlin", v)
+
+			v, err = s.Get(cfgpath.MustMakeByString("general/locale/timezone").BindStore(2))
+			require.NoError(t, err)
+			assert.Exactly(t, "Europe/Zurich", v)
+
+			v, err = s.Get(cfgpath.MustMakeByString("general/locale/timezone"))
+			assert.True(t, errors.IsNotFound(err), "%+v", err)
+			assert.Nil(t, v)
+		})
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("unknown extension", func(t *testing.T) {
+		s, err := cfgfile.New("testdata/config.ini")
+		assert.True(t, errors.IsNotSupported(err), "%+v", err)
+		assert.Nil(t, s)
+	})
+	t.Run("unknown format", func(t *testing.T) {
+		s, err := cfgfile.New("testdata/config.json", cfgfile.WithFormat("ini"))
+		assert.True(t, errors.IsNotSupported(err), "%+v", err)
+		assert.Nil(t, s)
+	})
+	t.Run("file not found", func(t *testing.T) {
+		s, err := cfgfile.New("testdata/not_found.json")
+		assert.True(t, os.IsNotExist(errors.Cause(err)), "%+v", err)
+		assert.Nil(t, s)
+	})
+	t.Run("format mismatch", func(t *testing.T) {
+		s, err := cfgfile.New("testdata/config.toml", cfgfile.WithFormat(cfgfile.FormatJSON))
+		assert.Error(t, err)
+		assert.Nil(t, s)
+	})
+	t.Run("invalid scope", func(t *testing.T) {
+		s, err := cfgfile.New("testdata/invalid_scope.yaml")
+		assert.True(t, errors.IsNotSupported(err), "%+v", err)
+		assert.Nil(t, s)
+	})
+	t.Run("invalid route", func(t *testing.T) {
+		s, err := cfgfile.New("testdata/invalid_route.json")
+		assert.True(t, errors.IsNotValid(err), "%+v", err)
+		assert.Nil(t, s)
+	})
+}
+
+func TestStorage_Set(t *testing.T) {
+	t.Parallel()
+
+	s := cfgfile.MustNew("testdata/config.yaml")
+	p := cfgpath.MustMakeByString("web/secure/offloader_header")
+
+	require.NoError(t, s.Set(p.BindWebsite(3), "X_FORWARDED_PROTO"))
+	v, err := s.Get(p.BindWebsite(3))
+	require.NoError(t, err)
+	assert.Exactly(t, "X_FORWARDED_PROTO", v)
+
+	// nil removes the key
+	require.NoError(t, s.Set(p, nil))
+	_, err = s.Get(p)
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+
+	// file has not been touched
+	changed, err := s.Reload()
+	require.NoError(t, err)
+	assert.Exactly(t, []string{
+		"default/0/web/secure/offloader_header",
+		"websites/3/web/secure/offloader_header",
+	}, pathStrings(changed))
+}
+
+func TestWithWriteBack(t *testing.T) {
+	t.Parallel()
+
+	for _, file := range []string{"config.yaml", "config.toml", "config.json"} {
+		t.Run(file, func(t *testing.T) {
+			filename, cleanup := copyFile(t, file)
+			defer cleanup()
+
+			s := cfgfile.MustNew(filename, cfgfile.WithWriteBack())
+			p := cfgpath.MustMakeByString("web/secure/offloader_header")
+			require.NoError(t, s.Set(p.BindStore(4), "X_FORWARDED_PROTO"))
+			require.NoError(t, s.Set(p, nil))
+			require.NoError(t, s.Set(cfgpath.MustMakeByString("general/locale/timezone").BindWebsite(1), "Europe/Vienna"))
+
+			s2 := cfgfile.MustNew(filename)
+			keys, err := s2.AllKeys()
+			require.NoError(t, err)
+			assert.Exactly(t, []string{
+				"websites/1/general/locale/timezone",
+				"stores/2/general/locale/timezone",
+				"default/0/web/cookie/cookie_lifetime",
+				"stores/4/web/secure/offloader_header",
+			}, pathStrings(keys))
+
+			v, err := s2.Get(p.BindStore(4))
+			require.NoError(t, err)
+			assert.Exactly(t, "X_FORWARDED_PROTO", v)
+			v, err = s2.Get(cfgpath.MustMakeByString("general/locale/timezone").BindWebsite(1))
+			require.NoError(t, err)
+			assert.Exactly(t, "Europe/Vienna", v)
+			v, err = s2.Get(cfgpath.MustMakeByString("web/cookie/cookie_lifetime"))
+			require.NoError(t, err)
+			assert.Exactly(t, 3600, conv.ToInt(v))
+
+			// the file has been written by s itself, so nothing changed
+			changed, err := s.Reload()
+			require.NoError(t, err)
+			assert.Empty(t, changed)
+		})
+	}
+}
+
+func TestWithWriteBack_Collision(t *testing.T) {
+	t.Parallel()
+
+	filename, cleanup := copyFile(t, "config.json")
+	defer cleanup()
+
+	s := cfgfile.MustNew(filename, cfgfile.WithWriteBack())
+	err := s.Set(cfgpath.MustMakeByString("web/secure/offloader_header/name"), "X")
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+
+	// the failed value must not be visible
+	_, err = s.Get(cfgpath.MustMakeByString("web/secure/offloader_header/name"))
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+}
+
+func TestWithHotReload(t *testing.T) {
+	t.Parallel()
+
+	filename, cleanup := copyFile(t, "config.yaml")
+	defer cleanup()
+
+	fs := cfgfile.MustNew(filename)
+	srv := config.MustNewService(fs, config.WithPubSub(), cfgfile.WithHotReload(fs, 10*time.Millisecond))
+	defer func() {
+		assert.NoError(t, fs.Close())
+		assert.NoError(t, srv.Close())
+	}()
+
+	mr := make(messageReceiver, 10)
+	_, err := srv.Subscribe(cfgpath.MakeRoute("general/locale"), mr)
+	require.NoError(t, err)
+	_, err = srv.Subscribe(cfgpath.MakeRoute("web/secure"), mr)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`
+default:
+  web:
+    cookie:
+      cookie_lifetime: 3600
+websites:
+  1:
+    general:
+      locale:
+        timezone: Europe/Vienna
+stores:
+  2:
+    general/locale/timezone: Europe/Zurich
+`), 0644))
+
+	var have []string
+	for len(have) < 2 {
+		select {
+		case p := <-mr:
+			have = append(have, p.String())
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timeout waiting for messages. Have: %v", have)
+		}
+	}
+	assert.Exactly(t, []string{
+		"websites/1/general/locale/timezone",
+		"default/0/web/secure/offloader_header",
+	}, have)
+
+	tz, err := srv.String(cfgpath.MustMakeByString("general/locale/timezone").BindWebsite(1))
+	require.NoError(t, err)
+	assert.Exactly(t, "Europe/Vienna", tz)
+	assert.False(t, srv.IsSet(cfgpath.MustMakeByString("web/secure/offloader_header")))
+}
@@ -0,0 +1,90 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgfile
+
+import (
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/config"
+)
+
+// DefaultReloadInterval defines the interval to check the file for changes,
+// if the interval argument of WithHotReload is zero.
+const DefaultReloadInterval = 2 * time.Second
+
+// WithHotReload starts a goroutine which checks the file of Storage s in the
+// given interval for changes of the modification time or the size. Polling
+// has been chosen because it also works with files mounted into containers,
+// which get replaced via symlinks. Changed, added and removed paths get
+// written via config.Service.Write to trigger the publishing to all
+// subscribers. Removed paths get written with a nil value. If Storage s is the
+// backend of the config.Service, the values have already been set. If the
+// reload fails, for example because of a half written file, the error gets
+// logged and the current values stay active. Call Storage.Close to terminate
+// the goroutine.
+func WithHotReload(s *Storage, interval time.Duration) config.Option {
+	return func(srv *config.Service) error {
+		if interval <= 0 {
+			interval = DefaultReloadInterval
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.closed {
+			return errors.NewAlreadyClosedf("[cfgfile] Storage %q already closed", s.filename)
+		}
+		s.wg.Add(1)
+		go s.watch(srv, interval)
+		return nil
+	}
+}
+
+func (s *Storage) watch(srv *config.Service, interval time.Duration) {
+	defer s.wg.Done()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+			if err := s.reloadAndPublish(srv); err != nil && srv.Log.IsInfo() {
+				srv.Log.Info("cfgfile.Storage.watch.reloadAndPublish", log.Err(err), log.String("file", s.filename))
+			}
+		}
+	}
+}
+
+func (s *Storage) reloadAndPublish(w config.Writer) error {
+	ok, err := s.modified()
+	if err != nil || !ok {
+		return err
+	}
+	changed, err := s.Reload()
+	if err != nil {
+		return errors.Wrap(err, "[cfgfile] Reload")
+	}
+	for _, p := range changed {
+		v, err := s.Get(p)
+		if err != nil && !errors.IsNotFound(err) {
+			return errors.Wrapf(err, "[cfgfile] Get %q", p)
+		}
+		if err := w.Write(p, v); err != nil {
+			return errors.Wrapf(err, "[cfgfile] Write %q", p)
+		}
+	}
+	return nil
+}
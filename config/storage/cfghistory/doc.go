@@ -0,0 +1,32 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfghistory records each configuration change in a database table.
+//
+// The Storage type decorates a config.Storager. Each Set loads the old value
+// from the backend, inserts a row with the old value, the new value, the path,
+// the scope, a timestamp and the actor into the history table and then writes
+// the new value. A Set fails if the change cannot be recorded. The actor gets taken from the context, see WithContextActor.
+//
+// The recorded history allows to list all changes of a path, to compare the
+// configuration between two points in time and to roll back a path or all
+// changes within a time range. A roll back gets recorded like any other
+// change.
+//
+// Values get stored as strings, like in table core_config_data. A rolled back
+// value gets written as a string into the backend. A nil value indicates that
+// the path did not exist or has been deleted.
+//
+// The history table gets created with TableDDL or Storage.CreateTable.
+package cfghistory
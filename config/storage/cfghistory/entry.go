@@ -0,0 +1,137 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfghistory
+
+import (
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// DefaultTable defines the name of the history table.
+const DefaultTable = "core_config_history"
+
+// Entry represents a single row of the history table.
+type Entry struct {
+	HistoryID uint64         // history_id bigint(20) unsigned NOT NULL PRI auto_increment
+	Scope     string         // scope varchar(8) NOT NULL
+	ScopeID   int64          // scope_id int(11) NOT NULL
+	Path      string         // path varchar(255) NOT NULL
+	OldValue  dml.NullString // old_value text NULL
+	NewValue  dml.NullString // new_value text NULL
+	Actor     string         // actor varchar(255) NOT NULL DEFAULT ''
+	CreatedAt time.Time      // created_at datetime(6) NOT NULL
+}
+
+// MapColumns implements interface dml.ColumnMapper.
+func (e *Entry) MapColumns(cm *dml.ColumnMap) error {
+	if cm.Mode() == dml.ColumnMapEntityReadAll {
+		return cm.Uint64(&e.HistoryID).String(&e.Scope).Int64(&e.ScopeID).String(&e.Path).
+			NullString(&e.OldValue).NullString(&e.NewValue).String(&e.Actor).Time(&e.CreatedAt).Err()
+	}
+	for cm.Next() {
+		switch c := cm.Column(); c {
+		case "history_id":
+			cm.Uint64(&e.HistoryID)
+		case "scope":
+			cm.String(&e.Scope)
+		case "scope_id":
+			cm.Int64(&e.ScopeID)
+		case "path":
+			cm.String(&e.Path)
+		case "old_value":
+			cm.NullString(&e.OldValue)
+		case "new_value":
+			cm.NullString(&e.NewValue)
+		case "actor":
+			cm.String(&e.Actor)
+		case "created_at":
+			cm.Time(&e.CreatedAt)
+		default:
+			return errors.NewNotFoundf("[cfghistory] Entry Column %q not found", c)
+		}
+	}
+	return errors.WithStack(cm.Err())
+}
+
+// ConfigPath returns the scoped configuration path of the entry.
+func (e *Entry) ConfigPath() (cfgpath.Path, error) {
+	p, err := cfgpath.MakeByString(e.Path)
+	if err != nil {
+		return cfgpath.Path{}, errors.Wrapf(err, "[cfghistory] Invalid path %q of history ID %d", e.Path, e.HistoryID)
+	}
+	return p.Bind(scope.FromString(e.Scope).Pack(e.ScopeID)), nil
+}
+
+// Entries represents a collection of history rows.
+type Entries []*Entry
+
+// MapColumns implements interface dml.ColumnMapper.
+func (es *Entries) MapColumns(cm *dml.ColumnMap) error {
+	switch m := cm.Mode(); m {
+	case dml.ColumnMapScan:
+		if cm.Count == 0 {
+			*es = (*es)[:0]
+		}
+		e := new(Entry)
+		if err := e.MapColumns(cm); err != nil {
+			return errors.WithStack(err)
+		}
+		*es = append(*es, e)
+	default:
+		return errors.NewNotSupportedf("[cfghistory] Unknown Mode: %q", string(m))
+	}
+	return cm.Err()
+}
+
+// Change describes the difference of a path between two points in time.
+type Change struct {
+	Path cfgpath.Path
+	// From contains the value before the first change. Not valid if the path
+	// did not exist.
+	From dml.NullString
+	// To contains the value after the last change. Not valid if the path has
+	// been deleted.
+	To dml.NullString
+	// Actors lists the unique actors in the order of their changes.
+	Actors []string
+}
+
+// insertColumns lists the columns written when recording a change.
+var insertColumns = []string{"scope", "scope_id", "path", "old_value", "new_value", "actor", "created_at"}
+
+// entryColumns lists all columns of the history table in the same order as
+// Entry.MapColumns reads them.
+var entryColumns = append([]string{"history_id"}, insertColumns...)
+
+// TableDDL returns the CREATE TABLE statement for the history table.
+func TableDDL(tableName string) string {
+	return "CREATE TABLE IF NOT EXISTS " + dml.Quoter.Name(tableName) + ` (
+	` + "`history_id`" + ` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT 'History ID',
+	` + "`scope`" + ` VARCHAR(8) NOT NULL DEFAULT 'default' COMMENT 'Config Scope',
+	` + "`scope_id`" + ` INT NOT NULL DEFAULT 0 COMMENT 'Config Scope ID',
+	` + "`path`" + ` VARCHAR(255) NOT NULL COMMENT 'Config Path',
+	` + "`old_value`" + ` TEXT NULL COMMENT 'Value before the change',
+	` + "`new_value`" + ` TEXT NULL COMMENT 'Value after the change',
+	` + "`actor`" + ` VARCHAR(255) NOT NULL DEFAULT '' COMMENT 'Who changed the value',
+	` + "`created_at`" + ` DATETIME(6) NOT NULL COMMENT 'Time of the change',
+	PRIMARY KEY (` + "`history_id`" + `),
+	KEY ` + "`CORE_CONFIG_HISTORY_PATH`" + ` (` + "`scope`, `scope_id`, `path`, `created_at`" + `),
+	KEY ` + "`CORE_CONFIG_HISTORY_CREATED_AT`" + ` (` + "`created_at`" + `)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='Config Data History'`
+}
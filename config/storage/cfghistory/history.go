@@ -0,0 +1,341 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfghistory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/util/conv"
+)
+
+// Option applies options to the New function.
+type Option func(*Storage) error
+
+// WithTable sets a custom name for the history table. Default name is the
+// constant DefaultTable.
+func WithTable(tableName string) Option {
+	return func(s *Storage) error {
+		if err := dml.IsValidIdentifier(tableName); err != nil {
+			return errors.Wrapf(err, "[cfghistory] Invalid table name %q", tableName)
+		}
+		s.table = tableName
+		return nil
+	}
+}
+
+// WithContextActor adds the name of the actor, for example the admin user
+// name or the name of a deployment job, to the context. SetContext records the
+// actor with each change.
+func WithContextActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, ctxActorKey{}, actor)
+}
+
+// FromContextActor returns the actor from a context. Returns an empty string
+// if no actor has been set.
+func FromContextActor(ctx context.Context) string {
+	a, _ := ctx.Value(ctxActorKey{}).(string)
+	return a
+}
+
+type ctxActorKey struct{}
+
+// Storage wraps a config.Storager and records each change in the history
+// table. Writes get serialized. Thread safe.
+type Storage struct {
+	backend config.Storager
+	db      *dml.ConnPool
+	table   string
+	// mu serializes the read of the old value, the write and the recording.
+	mu sync.Mutex
+}
+
+// New creates a new history recording Storager. The history table must exist,
+// see CreateTable.
+func New(backend config.Storager, db *dml.ConnPool, opts ...Option) (*Storage, error) {
+	s := &Storage{
+		backend: backend,
+		db:      db,
+		table:   DefaultTable,
+	}
+	for _, o := range opts {
+		if err := o(s); err != nil {
+			return nil, errors.Wrap(err, "[cfghistory] New applied option error")
+		}
+	}
+	return s, nil
+}
+
+// MustNew same as New but panics on error.
+func MustNew(backend config.Storager, db *dml.ConnPool, opts ...Option) *Storage {
+	s, err := New(backend, db, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// CreateTable creates the history table if it does not yet exist.
+func (s *Storage) CreateTable(ctx context.Context) error {
+	_, err := s.db.DB.ExecContext(ctx, TableDDL(s.table))
+	return errors.Wrapf(err, "[cfghistory] Failed to create table %q", s.table)
+}
+
+// Set implements config.Storager interface. It records the change without an
+// actor. Use SetContext or config.Service.WriteContext to record the actor.
+func (s *Storage) Set(key cfgpath.Path, value interface{}) error {
+	return s.SetContext(context.Background(), key, value)
+}
+
+// SetContext writes the value to the backend and records the old and the new
+// value together with the actor from the context in the history table.
+// Implements interface config.ContextSetter. A
+// value equal to the current value gets written but not recorded. The history
+// row gets inserted within a transaction before the value gets written to the
+// backend. If the recording fails, the backend does not get touched, and if
+// the backend fails, the transaction gets rolled back.
+func (s *Storage) SetContext(ctx context.Context, key cfgpath.Path, value interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set(ctx, key, value)
+}
+
+func (s *Storage) set(ctx context.Context, key cfgpath.Path, value interface{}) error {
+	if err := key.IsValid(); err != nil {
+		return errors.Wrapf(err, "[cfghistory] Invalid path %q", key)
+	}
+	oldVal, err := s.current(key)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	newVal, err := toNullString(value)
+	if err != nil {
+		return errors.Wrapf(err, "[cfghistory] Failed to convert value of path %q", key)
+	}
+
+	if oldVal == newVal {
+		return errors.Wrapf(s.backend.Set(key, value), "[cfghistory] Backend failed to set path %q", key)
+	}
+
+	scp, scopeID := key.ScopeID.Unpack()
+	e := &Entry{
+		Scope:     scp.StrType(),
+		ScopeID:   scopeID,
+		Path:      key.Route.Data,
+		OldValue:  oldVal,
+		NewValue:  newVal,
+		Actor:     FromContextActor(ctx),
+		CreatedAt: time.Now(),
+	}
+	return s.db.Transaction(ctx, nil, func(tx *dml.Tx) error {
+		if _, err := tx.InsertInto(s.table).AddColumns(insertColumns...).
+			WithArgs().Record("", e).ExecContext(ctx); err != nil {
+			return errors.Wrapf(err, "[cfghistory] Failed to record the change of path %q", key)
+		}
+		return errors.Wrapf(s.backend.Set(key, value), "[cfghistory] Backend failed to set path %q", key)
+	})
+}
+
+// current returns the value of the key in the backend. A not found path
+// returns an invalid NullString.
+func (s *Storage) current(key cfgpath.Path) (dml.NullString, error) {
+	v, err := s.backend.Get(key)
+	if errors.IsNotFound(err) {
+		return dml.NullString{}, nil
+	}
+	if err != nil {
+		return dml.NullString{}, errors.Wrapf(err, "[cfghistory] Backend failed to get path %q", key)
+	}
+	ns, err := toNullString(v)
+	return ns, errors.Wrapf(err, "[cfghistory] Failed to convert current value of path %q", key)
+}
+
+// Get implements config.Storager interface.
+func (s *Storage) Get(key cfgpath.Path) (interface{}, error) {
+	return s.backend.Get(key)
+}
+
+// AllKeys implements config.Storager interface.
+func (s *Storage) AllKeys() (cfgpath.PathSlice, error) {
+	return s.backend.AllKeys()
+}
+
+// Source implements config.Sourcer interface and returns the source of the
+// backend.
+func (s *Storage) Source(key cfgpath.Path) string {
+	return config.SourceOf(s.backend, key)
+}
+
+// History returns all recorded changes of a path, oldest first.
+func (s *Storage) History(ctx context.Context, key cfgpath.Path) (Entries, error) {
+	scp, scopeID := key.ScopeID.Unpack()
+	var es Entries
+	_, err := dml.NewSelect(entryColumns...).From(s.table).
+		Where(
+			dml.Column("scope").Str(scp.StrType()),
+			dml.Column("scope_id").Int64(scopeID),
+			dml.Column("path").Str(key.Route.Data),
+		).OrderBy("created_at", "history_id").
+		WithDB(s.db.DB).WithArgs().Load(ctx, &es)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[cfghistory] Failed to load history of path %q", key)
+	}
+	return es, nil
+}
+
+// Diff returns the changed paths between the points in time from (exclusive)
+// and to (inclusive). A path changed and reverted within the range does not
+// get returned. The changes are sorted by route and scope.
+func (s *Storage) Diff(ctx context.Context, from, to time.Time) ([]Change, error) {
+	if to.Before(from) {
+		return nil, errors.NewNotValidf("[cfghistory] Time to %s is before time from %s", to, from)
+	}
+	var es Entries
+	_, err := dml.NewSelect(entryColumns...).From(s.table).
+		Where(
+			dml.Column("created_at").Greater().Time(from),
+			dml.Column("created_at").LessOrEqual().Time(to),
+		).OrderBy("created_at", "history_id").
+		WithDB(s.db.DB).WithArgs().Load(ctx, &es)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[cfghistory] Failed to load history between %s and %s", from, to)
+	}
+
+	changes := make([]Change, 0, len(es))
+	idx := make(map[uint32]int, len(es))
+	for _, e := range es {
+		p, err := e.ConfigPath()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		h32, err := p.Hash(-1)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfghistory] Failed to hash path %q", p)
+		}
+		i, ok := idx[h32]
+		if !ok {
+			i = len(changes)
+			idx[h32] = i
+			changes = append(changes, Change{Path: p, From: e.OldValue})
+		}
+		c := &changes[i]
+		c.To = e.NewValue
+		if !containsString(c.Actors, e.Actor) {
+			c.Actors = append(c.Actors, e.Actor)
+		}
+	}
+
+	ret := changes[:0]
+	for _, c := range changes {
+		if c.From != c.To {
+			ret = append(ret, c)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Path.Less(ret[j].Path) })
+	return ret, nil
+}
+
+// Rollback restores the value which the path had at the point in time to. The
+// roll back gets recorded with the actor from the context. Nothing happens if
+// the path has not been changed after to. The history stores strings, so the
+// restored value gets written as a string, for example "1" instead of int 1,
+// or nil if the path did not exist.
+func (s *Storage) Rollback(ctx context.Context, key cfgpath.Path, to time.Time) error {
+	// The history gets read under the lock, otherwise a concurrent Set could
+	// change the path between the read and the roll back.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scp, scopeID := key.ScopeID.Unpack()
+	var es Entries
+	_, err := dml.NewSelect(entryColumns...).From(s.table).
+		Where(
+			dml.Column("scope").Str(scp.StrType()),
+			dml.Column("scope_id").Int64(scopeID),
+			dml.Column("path").Str(key.Route.Data),
+			dml.Column("created_at").Greater().Time(to),
+		).OrderBy("created_at", "history_id").Limit(0, 1).
+		WithDB(s.db.DB).WithArgs().Load(ctx, &es)
+	if err != nil {
+		return errors.Wrapf(err, "[cfghistory] Failed to load history of path %q", key)
+	}
+	if len(es) == 0 {
+		return nil
+	}
+	return errors.Wrapf(s.set(ctx, key, fromNullString(es[0].OldValue)), "[cfghistory] Failed to roll back path %q", key)
+}
+
+// RollbackRange reverts all changes made between the points in time from
+// (exclusive) and to (inclusive) and returns the reverted changes. If a path
+// has been changed again after to, no path gets reverted and a NotValid error
+// gets returned. The roll backs get recorded with the actor from the context.
+// Like Rollback the values get restored as strings.
+func (s *Storage) RollbackRange(ctx context.Context, from, to time.Time) ([]Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changes, err := s.Diff(ctx, from, to)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, c := range changes {
+		cur, err := s.current(c.Path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if cur != c.To {
+			return nil, errors.NewNotValidf("[cfghistory] Path %q has been changed after %s. Current value %q, expected %q", c.Path, to, cur.String, c.To.String)
+		}
+	}
+	for _, c := range changes {
+		if err := s.set(ctx, c.Path, fromNullString(c.From)); err != nil {
+			return nil, errors.Wrapf(err, "[cfghistory] Failed to roll back path %q", c.Path)
+		}
+	}
+	return changes, nil
+}
+
+func toNullString(v interface{}) (dml.NullString, error) {
+	if v == nil {
+		return dml.NullString{}, nil
+	}
+	str, err := conv.ToStringE(v)
+	if err != nil {
+		return dml.NullString{}, errors.WithStack(err)
+	}
+	return dml.MakeNullString(str), nil
+}
+
+func fromNullString(ns dml.NullString) interface{} {
+	if !ns.Valid {
+		return nil
+	}
+	return ns.String
+}
+
+func containsString(sl []string, s string) bool {
+	for _, v := range sl {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,366 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfghistory_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/cfghistory"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ config.Storager      = (*cfghistory.Storage)(nil)
+	_ config.ContextSetter = (*cfghistory.Storage)(nil)
+)
+
+var historyColumns = []string{"history_id", "scope", "scope_id", "path", "old_value", "new_value", "actor", "created_at"}
+
+const insertSQL = "INSERT INTO `core_config_history` (`scope`,`scope_id`,`path`,`old_value`,`new_value`,`actor`,`created_at`) VALUES (?,?,?,?,?,?,?)"
+
+// readOnlyStorage fails on each Set.
+type readOnlyStorage struct {
+	config.Storager
+}
+
+func (readOnlyStorage) Set(key cfgpath.Path, _ interface{}) error {
+	return errors.NewNotSupportedf("read only storage: %s", key)
+}
+
+var (
+	t1 = time.Date(2018, 8, 1, 12, 0, 0, 0, time.UTC)
+	t2 = t1.Add(time.Hour)
+	t3 = t2.Add(time.Hour)
+)
+
+func TestNew(t *testing.T) {
+	t.Run("invalid table name", func(t *testing.T) {
+		s, err := cfghistory.New(config.NewInMemoryStore(), nil, cfghistory.WithTable("core config"))
+		assert.Nil(t, s)
+		assert.Error(t, err)
+	})
+	t.Run("MustNew panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			cfghistory.MustNew(config.NewInMemoryStore(), nil, cfghistory.WithTable(""))
+		})
+	})
+}
+
+func TestFromContextActor(t *testing.T) {
+	assert.Exactly(t, "", cfghistory.FromContextActor(context.Background()))
+	ctx := cfghistory.WithContextActor(context.Background(), "admin@example.com")
+	assert.Exactly(t, "admin@example.com", cfghistory.FromContextActor(ctx))
+}
+
+func TestTableDDL(t *testing.T) {
+	ddl := cfghistory.TableDDL("my_history")
+	assert.Contains(t, ddl, "CREATE TABLE IF NOT EXISTS `my_history`")
+	for _, c := range historyColumns {
+		assert.Contains(t, ddl, "`"+c+"`")
+	}
+}
+
+func TestStorage_CreateTable(t *testing.T) {
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(cfghistory.TableDDL("cfg_history"))).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	s := cfghistory.MustNew(config.NewInMemoryStore(), dbc, cfghistory.WithTable("cfg_history"))
+	require.NoError(t, s.CreateTable(context.Background()))
+}
+
+func TestStorage_SetContext(t *testing.T) {
+	p := cfgpath.MustMakeByString("web/secure/base_url").BindStore(2)
+
+	t.Run("records old and new value", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		backend := config.NewInMemoryStore()
+		require.NoError(t, backend.Set(p, "http://old.test/"))
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(insertSQL)).
+			WithArgs("stores", 2, "web/secure/base_url", "http://old.test/", "http://new.test/", "jdoe", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		dbMock.ExpectCommit()
+
+		s := cfghistory.MustNew(backend, dbc)
+		ctx := cfghistory.WithContextActor(context.Background(), "jdoe")
+		require.NoError(t, s.SetContext(ctx, p, "http://new.test/"))
+
+		v, err := s.Get(p)
+		require.NoError(t, err)
+		assert.Exactly(t, "http://new.test/", v)
+	})
+
+	t.Run("new path without actor", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(insertSQL)).
+			WithArgs("stores", 2, "web/secure/base_url", nil, "1", "", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		dbMock.ExpectCommit()
+
+		s := cfghistory.MustNew(config.NewInMemoryStore(), dbc)
+		require.NoError(t, s.Set(p, 1))
+	})
+
+	t.Run("actor via config.Service", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		// NewService writes the default base URL.
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(insertSQL)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		dbMock.ExpectCommit()
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(insertSQL)).
+			WithArgs("stores", 2, "web/secure/base_url", nil, "http://new.test/", "jdoe", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(2, 1))
+		dbMock.ExpectCommit()
+
+		srv := config.MustNewService(cfghistory.MustNew(config.NewInMemoryStore(), dbc))
+		ctx := cfghistory.WithContextActor(context.Background(), "jdoe")
+		require.NoError(t, srv.WriteContext(ctx, p, "http://new.test/"))
+	})
+
+	t.Run("unchanged value does not get recorded", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		backend := config.NewInMemoryStore()
+		require.NoError(t, backend.Set(p, "1"))
+
+		s := cfghistory.MustNew(backend, dbc)
+		require.NoError(t, s.Set(p, 1))
+	})
+
+	t.Run("recording fails", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(insertSQL)).
+			WillReturnError(errors.NewAlreadyClosedf("DB closed"))
+		dbMock.ExpectRollback()
+
+		backend := config.NewInMemoryStore()
+		s := cfghistory.MustNew(backend, dbc)
+		err := s.Set(p, "a")
+		assert.True(t, errors.IsAlreadyClosed(err), "%+v", err)
+
+		_, err = backend.Get(p)
+		assert.True(t, errors.IsNotFound(err), "Backend must not be written: %+v", err)
+	})
+
+	t.Run("backend fails", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(insertSQL)).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		dbMock.ExpectRollback()
+
+		s := cfghistory.MustNew(readOnlyStorage{Storager: config.NewInMemoryStore()}, dbc)
+		err := s.Set(p, "a")
+		assert.True(t, errors.IsNotSupported(err), "%+v", err)
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		s := cfghistory.MustNew(config.NewInMemoryStore(), nil)
+		err := s.Set(cfgpath.Path{}, "a")
+		assert.Error(t, err)
+	})
+}
+
+func TestStorage_History(t *testing.T) {
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT `history_id`, `scope`, `scope_id`, `path`, `old_value`, `new_value`, `actor`, `created_at` FROM `core_config_history` WHERE (`scope` = 'websites') AND (`scope_id` = 1) AND (`path` = 'carriers/flatrate/active') ORDER BY `created_at`, `history_id`")).
+		WillReturnRows(sqlmock.NewRows(historyColumns).
+			AddRow(3, "websites", 1, "carriers/flatrate/active", nil, "1", "jdoe", t1).
+			AddRow(7, "websites", 1, "carriers/flatrate/active", "1", "0", "deploy", t2))
+
+	s := cfghistory.MustNew(config.NewInMemoryStore(), dbc)
+	es, err := s.History(context.Background(), cfgpath.MustMakeByString("carriers/flatrate/active").BindWebsite(1))
+	require.NoError(t, err)
+	require.Len(t, es, 2)
+	assert.Exactly(t, uint64(3), es[0].HistoryID)
+	assert.False(t, es[0].OldValue.Valid)
+	assert.Exactly(t, dml.MakeNullString("0"), es[1].NewValue)
+	assert.Exactly(t, "deploy", es[1].Actor)
+	assert.Exactly(t, t2, es[1].CreatedAt)
+
+	p, err := es[1].ConfigPath()
+	require.NoError(t, err)
+	assert.Exactly(t, "websites/1/carriers/flatrate/active", p.String())
+}
+
+func expectDiffQuery(dbMock sqlmock.Sqlmock) {
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT `history_id`, `scope`, `scope_id`, `path`, `old_value`, `new_value`, `actor`, `created_at` FROM `core_config_history` WHERE (`created_at` > '2018-08-01 12:00:00') AND (`created_at` <= '2018-08-01 14:00:00') ORDER BY `created_at`, `history_id`")).
+		WillReturnRows(sqlmock.NewRows(historyColumns).
+			AddRow(1, "stores", 2, "web/secure/base_url", "http://old.test/", "http://typo.test/", "jdoe", t2).
+			AddRow(2, "default", 0, "carriers/flatrate/active", nil, "1", "jdoe", t2).
+			AddRow(3, "default", 0, "general/locale/code", "de_CH", "fr_CH", "jdoe", t2).
+			AddRow(4, "stores", 2, "web/secure/base_url", "http://typo.test/", "http://new.test/", "deploy", t3).
+			AddRow(5, "default", 0, "general/locale/code", "fr_CH", "de_CH", "jdoe", t3))
+}
+
+func TestStorage_Diff(t *testing.T) {
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	expectDiffQuery(dbMock)
+
+	s := cfghistory.MustNew(config.NewInMemoryStore(), dbc)
+	changes, err := s.Diff(context.Background(), t1, t3)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	assert.Exactly(t, "default/0/carriers/flatrate/active", changes[0].Path.String())
+	assert.False(t, changes[0].From.Valid)
+	assert.Exactly(t, dml.MakeNullString("1"), changes[0].To)
+	assert.Exactly(t, []string{"jdoe"}, changes[0].Actors)
+
+	assert.Exactly(t, "stores/2/web/secure/base_url", changes[1].Path.String())
+	assert.Exactly(t, dml.MakeNullString("http://old.test/"), changes[1].From)
+	assert.Exactly(t, dml.MakeNullString("http://new.test/"), changes[1].To)
+	assert.Exactly(t, []string{"jdoe", "deploy"}, changes[1].Actors)
+
+	_, err = s.Diff(context.Background(), t3, t1)
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+}
+
+func TestStorage_Rollback(t *testing.T) {
+	p := cfgpath.MustMakeByString("web/secure/base_url").BindStore(2)
+
+	t.Run("restores old value", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT `history_id`, `scope`, `scope_id`, `path`, `old_value`, `new_value`, `actor`, `created_at` FROM `core_config_history` WHERE (`scope` = 'stores') AND (`scope_id` = 2) AND (`path` = 'web/secure/base_url') AND (`created_at` > '2018-08-01 12:00:00') ORDER BY `created_at`, `history_id` LIMIT 0,1")).
+			WillReturnRows(sqlmock.NewRows(historyColumns).
+				AddRow(1, "stores", 2, "web/secure/base_url", "http://old.test/", "http://typo.test/", "jdoe", t2))
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(insertSQL)).
+			WithArgs("stores", 2, "web/secure/base_url", "http://new.test/", "http://old.test/", "oncall", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(9, 1))
+		dbMock.ExpectCommit()
+
+		backend := config.NewInMemoryStore()
+		require.NoError(t, backend.Set(p, "http://new.test/"))
+
+		s := cfghistory.MustNew(backend, dbc)
+		ctx := cfghistory.WithContextActor(context.Background(), "oncall")
+		require.NoError(t, s.Rollback(ctx, p, t1))
+
+		v, err := backend.Get(p)
+		require.NoError(t, err)
+		assert.Exactly(t, "http://old.test/", v)
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery("SELECT").WillReturnRows(sqlmock.NewRows(historyColumns))
+
+		backend := config.NewInMemoryStore()
+		require.NoError(t, backend.Set(p, "http://new.test/"))
+
+		s := cfghistory.MustNew(backend, dbc)
+		require.NoError(t, s.Rollback(context.Background(), p, t3))
+
+		v, err := backend.Get(p)
+		require.NoError(t, err)
+		assert.Exactly(t, "http://new.test/", v)
+	})
+}
+
+func TestStorage_RollbackRange(t *testing.T) {
+	pURL := cfgpath.MustMakeByString("web/secure/base_url").BindStore(2)
+	pActive := cfgpath.MustMakeByString("carriers/flatrate/active")
+
+	t.Run("reverts all changes", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		expectDiffQuery(dbMock)
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(insertSQL)).
+			WithArgs("default", 0, "carriers/flatrate/active", "1", nil, "oncall", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(6, 1))
+		dbMock.ExpectCommit()
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(insertSQL)).
+			WithArgs("stores", 2, "web/secure/base_url", "http://new.test/", "http://old.test/", "oncall", sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(7, 1))
+		dbMock.ExpectCommit()
+
+		backend := config.NewInMemoryStore()
+		require.NoError(t, backend.Set(pURL, "http://new.test/"))
+		require.NoError(t, backend.Set(pActive, 1))
+
+		s := cfghistory.MustNew(backend, dbc)
+		ctx := cfghistory.WithContextActor(context.Background(), "oncall")
+		changes, err := s.RollbackRange(ctx, t1, t3)
+		require.NoError(t, err)
+		assert.Len(t, changes, 2)
+
+		v, err := backend.Get(pURL)
+		require.NoError(t, err)
+		assert.Exactly(t, "http://old.test/", v)
+		v, err = backend.Get(pActive)
+		require.NoError(t, err)
+		assert.Nil(t, v)
+	})
+
+	t.Run("conflict with a later change", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		expectDiffQuery(dbMock)
+
+		backend := config.NewInMemoryStore()
+		require.NoError(t, backend.Set(pURL, "http://newer.test/"))
+		require.NoError(t, backend.Set(pActive, "1"))
+
+		s := cfghistory.MustNew(backend, dbc)
+		changes, err := s.RollbackRange(context.Background(), t1, t3)
+		assert.Nil(t, changes)
+		assert.True(t, errors.IsNotValid(err), "%+v", err)
+
+		v, err := backend.Get(pActive)
+		require.NoError(t, err)
+		assert.Exactly(t, "1", v)
+	})
+}
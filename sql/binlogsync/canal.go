@@ -4,7 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"net"
-	"regexp"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -14,12 +13,12 @@ import (
 	"github.com/corestoreio/pkg/sql/ddl"
 	"github.com/corestoreio/pkg/sql/dml"
 	"github.com/corestoreio/pkg/sql/myreplicator"
-	"github.com/corestoreio/pkg/store/scope"
 	"github.com/corestoreio/pkg/sync/singleflight"
 	"github.com/corestoreio/pkg/util/conv"
 	"github.com/corestoreio/errors"
 	"github.com/corestoreio/log"
 	"github.com/go-sql-driver/mysql"
+	gomysql "github.com/siddontang/go-mysql/mysql"
 )
 
 // Use flavor for different MySQL versions,
@@ -30,7 +29,8 @@ const (
 
 // Canal can sync your MySQL data. MySQL must use the binlog format ROW.
 type Canal struct {
-	// BackendPosition initial idea. writing supported but not loading
+	// BackendPosition defines the configuration path used by
+	// WithConfigurationWriter and WithConfigurationCheckpoint.
 	BackendPosition cfgmodel.Str
 
 	// mclose acts only during the call to Close().
@@ -39,19 +39,39 @@ type Canal struct {
 	DSN         *mysql.Config
 	canalParams map[string]string
 
-	cfgw config.Writer
+	// checkpoint loads the start position and saves the position of the
+	// last successfully handled transaction. Can be nil.
+	checkpoint Checkpointer
+	// gset contains the executed GTID set if the sync has been started with
+	// a GTID set. Only accessed by the sync goroutine.
+	gset gomysql.GTIDSet
+	// snapshot, if set, dispatches the existing rows before the sync starts.
+	snapshot *Snapshot
+	// resumed gets set if the start position has been loaded from the
+	// checkpoint.
+	resumed bool
 
 	masterMu           sync.RWMutex
 	masterStatus       ddl.MasterStatus
 	masterLastSaveTime time.Time
 
-	// expAlterTable defines the regex to be used to detect ALTER TABLE
-	// statements to reinitialize the internal table structure cache.
-	expAlterTable *regexp.Regexp
-	syncer        *myreplicator.BinlogSyncer
+	syncer *myreplicator.BinlogSyncer
 
 	rsMu       sync.RWMutex
-	rsHandlers []RowsEventHandler
+	rsHandlers []filteredHandler
+	// skipHandlerErrors logs the handler errors without behaviour
+	// Interrupted instead of stopping the sync.
+	skipHandlerErrors bool
+
+	// txn buffers the rows events of the current transaction until COMMIT.
+	// Nil if no transaction has been started. Only accessed by the sync
+	// goroutine.
+	txn *Transaction
+	// txnGTID contains the GTID of the next transaction.
+	txnGTID string
+	// schemas contains the column lists of the synced tables versioned by the
+	// binlog position. Only accessed by the sync goroutine.
+	schemas schemaHistory
 
 	db *sql.DB
 
@@ -67,6 +87,12 @@ type Canal struct {
 	closed *int32
 	Log    log.Logger
 	wg     sync.WaitGroup
+
+	// done gets closed when the sync goroutine has terminated. runErr
+	// contains the error which has stopped it.
+	done   chan struct{}
+	errMu  sync.Mutex
+	runErr error
 }
 
 // Option applies multiple options to the Canal type.
@@ -98,11 +124,41 @@ func WithDB(db *sql.DB) Option {
 	}
 }
 
-// WithConfigurationWriter used to persists the current binlog position.
+// WithConfigurationWriter used to persists the current binlog position. The
+// position does not get loaded, use WithConfigurationCheckpoint to resume the
+// sync.
 func WithConfigurationWriter(w config.Writer) Option {
-	//Write(p cfgpath.Path, value interface{}) error
+	return WithConfigurationCheckpoint(nil, w)
+}
+
+// WithConfigurationCheckpoint loads and persists the binlog position in the
+// configuration, see CheckpointConfig.
+func WithConfigurationCheckpoint(g config.Getter, w config.Writer) Option {
+	return func(c *Canal) error {
+		c.checkpoint = CheckpointConfig{Path: c.BackendPosition, Getter: g, Writer: w}
+		return nil
+	}
+}
+
+// WithCheckpoint sets a custom store for the binlog position. On start the
+// Canal resumes at the loaded checkpoint, with a GTID set if available. The
+// checkpoint gets only saved between transactions and after all handlers have
+// returned without an error. A failed handler stops the Canal without saving
+// the position of the current transaction.
+func WithCheckpoint(cp Checkpointer) Option {
 	return func(c *Canal) error {
-		c.cfgw = w
+		c.checkpoint = cp
+		return nil
+	}
+}
+
+// WithSkipHandlerErrors logs the errors of the handlers and continues the
+// sync. The position gets saved even if a handler has failed to process the
+// transaction, so the failed events will not be delivered again. Errors with
+// behaviour Interrupted still stop the Canal.
+func WithSkipHandlerErrors() Option {
+	return func(c *Canal) error {
+		c.skipHandlerErrors = true
 		return nil
 	}
 }
@@ -121,12 +177,29 @@ func withUpdateBinlogStart(c *Canal) error {
 
 	if v, ok := c.canalParams["BinlogStartFile"]; ok && v != "" {
 		c.masterStatus.File = v
+		c.masterStatus.ExecutedGTIDSet = "" // explicit position wins
 	}
 	if v, ok := c.canalParams["BinlogStartPosition"]; ok && v != "" {
 		if hasPos := conv.ToUint(v); hasPos >= 4 {
 			c.masterStatus.Position = hasPos
 		}
 	}
+
+	if c.checkpoint == nil {
+		return nil
+	}
+	cp, err := c.checkpoint.LoadCheckpoint(ctx)
+	switch {
+	case errors.IsNotFound(err):
+		if c.Log.IsInfo() {
+			c.Log.Info("[binlogsync] No checkpoint found, starting at master status", log.Stringer("master_status", c.masterStatus))
+		}
+	case err != nil:
+		return errors.Wrap(err, "[binlogsync] LoadCheckpoint")
+	default:
+		c.masterStatus = cp
+		c.resumed = true
+	}
 	return nil
 }
 
@@ -177,7 +250,6 @@ func NewCanal(dsn *mysql.Config, db Option, opts ...Option) (*Canal, error) {
 	c.DSN = dsn
 	c.closed = new(int32)
 	atomic.StoreInt32(c.closed, 0)
-	c.expAlterTable = regexp.MustCompile("(?i)^ALTER\\sTABLE\\s.*?`{0,1}(.*?)`{0,1}\\.{0,1}`{0,1}([^`\\.]+?)`{0,1}\\s.*")
 
 	c.BackendPosition = cfgmodel.NewStr("sql/binlogsync/position")
 
@@ -212,26 +284,30 @@ func NewCanal(dsn *mysql.Config, db Option, opts ...Option) (*Canal, error) {
 	return c, nil
 }
 
-func (c *Canal) masterSave() error {
+// masterSave persists the master status with the Checkpointer. Saves at most
+// once per second, unless force has been set.
+func (c *Canal) masterSave(ctx context.Context, force bool) error {
 
 	n := time.Now()
-	if n.Sub(c.masterLastSaveTime) < time.Second {
+	if !force && n.Sub(c.masterLastSaveTime) < time.Second {
 		return nil
 	}
 	c.masterMu.Lock()
 	defer c.masterMu.Unlock()
 
-	if c.cfgw == nil {
+	if c.checkpoint == nil {
 		if c.Log.IsDebug() {
-			c.Log.Debug("[binlogsync] Master Status cannot be saved because config.Writer is nil",
+			c.Log.Debug("[binlogsync] Master Status cannot be saved because Checkpointer is nil",
 				log.String("database", c.DSN.DBName), log.Stringer("master_status", c.masterStatus))
 		}
 		return nil
 	}
+	if c.masterStatus.File == "" {
+		return nil // nothing synced yet
+	}
 
-	// todo refactor to find a different way by not importing package config and scope
-	if err := c.BackendPosition.Write(c.cfgw, c.masterStatus.String(), scope.DefaultTypeID); err != nil {
-		return errors.Wrap(err, "[binlogsync] failed to write into config")
+	if err := c.checkpoint.SaveCheckpoint(ctx, c.masterStatus); err != nil {
+		return errors.Wrap(err, "[binlogsync] failed to save the checkpoint")
 	}
 
 	c.masterLastSaveTime = n
@@ -239,11 +315,12 @@ func (c *Canal) masterSave() error {
 	return nil
 }
 
-func (c *Canal) masterUpdate(fileName string, pos uint) {
+func (c *Canal) masterUpdate(pos ddl.MasterStatus) {
 	c.masterMu.Lock()
 	defer c.masterMu.Unlock()
-	c.masterStatus.File = fileName
-	c.masterStatus.Position = pos
+	c.masterStatus.File = pos.File
+	c.masterStatus.Position = pos.Position
+	c.masterStatus.ExecutedGTIDSet = pos.ExecutedGTIDSet
 }
 
 // SyncedPosition returns the current synced position as retrieved from the SQl
@@ -255,18 +332,51 @@ func (c *Canal) SyncedPosition() ddl.MasterStatus {
 }
 
 // Start starts the sync process in the background as a goroutine. You can stop
-// the goroutine via the context.
+// the goroutine via the context. The error which terminates the goroutine can
+// be retrieved with Err after Done has been closed.
 func (c *Canal) Start(ctx context.Context) error {
+	c.done = make(chan struct{})
 	c.wg.Add(1)
-	go c.run(ctx)
+	go func() {
+		defer c.wg.Done()
+		err := c.run(ctx)
+		if c.isClosed() {
+			err = nil // stopped by Close
+		}
+		c.errMu.Lock()
+		c.runErr = err
+		c.errMu.Unlock()
+		close(c.done)
+	}()
 
 	return nil
 }
 
+// Done returns a channel which gets closed when the sync goroutine, started
+// with Start, has terminated. Returns nil if Start has not been called.
+func (c *Canal) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err returns the error which has stopped the sync goroutine. Returns nil as
+// long as the goroutine runs or if it has terminated without an error, for
+// example after Close.
+func (c *Canal) Err() error {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	return c.runErr
+}
+
 // run gets executed in its own goroutine
 func (c *Canal) run(ctx context.Context) error {
-	// refactor for better error handling
-	defer c.wg.Done()
+	if c.snapshot != nil && !c.resumed {
+		if err := c.runSnapshot(ctx); err != nil {
+			if !c.isClosed() {
+				c.Log.Info("[binlogsync] Canal start has encountered a snapshot error", log.Err(err))
+			}
+			return errors.Wrap(err, "[binlogsync] run.runSnapshot")
+		}
+	}
 
 	if err := c.startSyncBinlog(ctx); err != nil {
 		if !c.isClosed() {
@@ -297,10 +407,14 @@ func (c *Canal) Close() error {
 		c.syncer = nil
 	}
 
+	c.wg.Wait()
+
+	if err := c.masterSave(context.Background(), true); err != nil {
+		c.Log.Info("[binlogsync] Close: Failed to save master position", log.Err(err), log.Stringer("position", c.SyncedPosition()))
+	}
 	if err := c.db.Close(); err != nil {
 		return errors.Wrap(err, "[binlogsync] DB close error")
 	}
-	c.wg.Wait()
 	return nil
 }
 
@@ -338,14 +452,14 @@ func (c *Canal) FindTable(ctx context.Context, tableName string) (ddl.Table, err
 	return val.(ddl.Table), nil
 }
 
-// ClearTableCache clear table cache
+// ClearTableCache removes a table of the synced database from the cache. The
+// next rows event of the table loads the structure again. Tables of other
+// databases get ignored.
 func (c *Canal) ClearTableCache(db string, table string) {
-	// TODO implement
-	// c.tables.DeleteAllFromCache()
-	//key := fmt.Sprintf("%s.%s", db, table)
-	//c.tableLock.Lock()
-	//delete(c.tables, key)
-	//c.tableLock.Unlock()
+	if db != c.DSN.DBName {
+		return
+	}
+	c.tables.DeleteFromCache(table)
 }
 
 // CheckBinlogRowImage checks MySQL binlog row image, must be in FULL, MINIMAL, NOBLOB
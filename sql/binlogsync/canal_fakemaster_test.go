@@ -0,0 +1,228 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/myreplicator/fakemaster"
+	"github.com/go-sql-driver/mysql"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeSID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+// newFakeMaster creates a master which answers the column query of table
+// shop.customer.
+func newFakeMaster(t *testing.T) *fakemaster.Master {
+	m := fakemaster.NewMaster()
+	m.QueryHandler = func(query string) (*fakemaster.Result, error) {
+		if !strings.Contains(query, "information_schema.COLUMNS") {
+			return nil, fmt.Errorf("unknown query %q", query)
+		}
+		return &fakemaster.Result{
+			Columns: []string{
+				"TABLE_NAME", "COLUMN_NAME", "ORDINAL_POSITION", "COLUMN_DEFAULT", "IS_NULLABLE",
+				"DATA_TYPE", "CHARACTER_MAXIMUM_LENGTH", "NUMERIC_PRECISION", "NUMERIC_SCALE",
+				"COLUMN_TYPE", "COLUMN_KEY", "EXTRA", "COLUMN_COMMENT",
+			},
+			Rows: [][]interface{}{
+				{"customer", "entity_id", 1, nil, "NO", "int", nil, 10, 0, "int(10) unsigned", "PRI", "auto_increment", ""},
+				{"customer", "name", 2, nil, "YES", "varchar", 255, nil, nil, "varchar(255)", "", "", ""},
+			},
+		}, nil
+	}
+	require.NoError(t, m.Start())
+	return m
+}
+
+func writeCustomer(m *fakemaster.Master, xid uint64, id int) ddl.MasterStatus {
+	m.Begin("shop")
+	m.TableMap(7, "shop", "customer",
+		fakemaster.Column{Type: gomysql.MYSQL_TYPE_LONG},
+		fakemaster.Column{Type: gomysql.MYSQL_TYPE_VARCHAR, Meta: 255, Nullable: true},
+	)
+	m.WriteRows(7, []interface{}{id, "Gopher"})
+	return m.XID(xid)
+}
+
+type fakeTxHandler struct {
+	txC chan binlogsync.Transaction
+	err error
+}
+
+func (h fakeTxHandler) Do(_ context.Context, _ string, _ ddl.Table, _ [][]interface{}) error {
+	return nil
+}
+
+func (h fakeTxHandler) DoTransaction(_ context.Context, tx binlogsync.Transaction) error {
+	if h.err != nil {
+		return h.err
+	}
+	h.txC <- tx
+	return nil
+}
+
+func (h fakeTxHandler) Complete(_ context.Context) error { return nil }
+func (h fakeTxHandler) String() string                   { return "fakeTxHandler" }
+
+func newFakeCanal(m *fakemaster.Master, cp binlogsync.Checkpointer) (*binlogsync.Canal, error) {
+	dsn := mysql.NewConfig()
+	dsn.User = "root"
+	dsn.Net = "tcp"
+	dsn.Addr = m.Addr()
+	dsn.DBName = "shop"
+	return binlogsync.NewCanal(dsn, binlogsync.WithMySQL(), binlogsync.WithCheckpoint(cp))
+}
+
+func waitTransaction(t *testing.T, c *binlogsync.Canal, txC <-chan binlogsync.Transaction) binlogsync.Transaction {
+	select {
+	case tx := <-txC:
+		return tx
+	case <-c.Done():
+		t.Fatalf("Canal stopped: %+v", c.Err())
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for a transaction")
+	}
+	return binlogsync.Transaction{}
+}
+
+func tempCheckpointFile(t *testing.T) (binlogsync.CheckpointFile, func()) {
+	dir, err := ioutil.TempDir("", "binlogsync")
+	require.NoError(t, err)
+	return binlogsync.CheckpointFile(filepath.Join(dir, "checkpoint")), func() { os.RemoveAll(dir) }
+}
+
+func TestCanal_FakeMaster_ResumePosition(t *testing.T) {
+	m := newFakeMaster(t)
+	defer m.Close()
+	cp, cleanup := tempCheckpointFile(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	synced := writeCustomer(m, 11, 1)
+	writeCustomer(m, 12, 2)
+	require.NoError(t, cp.SaveCheckpoint(ctx, synced))
+
+	c, err := newFakeCanal(m, cp)
+	require.NoError(t, err)
+	txC := make(chan binlogsync.Transaction, 10)
+	c.RegisterRowsEventHandler(fakeTxHandler{txC: txC})
+	require.NoError(t, c.Start(ctx))
+
+	tx := waitTransaction(t, c, txC)
+	assert.Exactly(t, uint64(12), tx.XID)
+	require.Len(t, tx.Events, 1)
+	assert.Exactly(t, "customer", tx.Events[0].Table.Name)
+	assert.Exactly(t, "[[2 Gopher]]", fmt.Sprint(tx.Events[0].Rows))
+
+	// events written after the start get synced immediately
+	last := writeCustomer(m, 13, 3)
+	tx = waitTransaction(t, c, txC)
+	assert.Exactly(t, uint64(13), tx.XID)
+	assert.Exactly(t, "[[3 Gopher]]", fmt.Sprint(tx.Events[0].Rows))
+
+	require.NoError(t, c.Close())
+	<-c.Done()
+	assert.NoError(t, c.Err())
+
+	require.Len(t, m.Dumps(), 1)
+	assert.Exactly(t, synced, m.Dumps()[0].Position, "Dump must start at the checkpoint")
+	have, err := cp.LoadCheckpoint(ctx)
+	require.NoError(t, err)
+	assert.Exactly(t, last, have)
+}
+
+func TestCanal_FakeMaster_ResumeGTID(t *testing.T) {
+	m := newFakeMaster(t)
+	defer m.Close()
+	cp, cleanup := tempCheckpointFile(t)
+	defer cleanup()
+
+	for i := int64(1); i <= 3; i++ {
+		m.GTID(fakeSID, i)
+		writeCustomer(m, uint64(i+10), int(i))
+	}
+
+	ctx := context.Background()
+	require.NoError(t, cp.SaveCheckpoint(ctx, ddl.MasterStatus{
+		File: "mysql-bin.000001", Position: 4, ExecutedGTIDSet: fakeSID + ":1-2",
+	}))
+
+	c, err := newFakeCanal(m, cp)
+	require.NoError(t, err)
+	txC := make(chan binlogsync.Transaction, 10)
+	c.RegisterRowsEventHandler(fakeTxHandler{txC: txC})
+	require.NoError(t, c.Start(ctx))
+
+	tx := waitTransaction(t, c, txC)
+	assert.Exactly(t, fakeSID+":3", tx.GTID)
+	assert.Exactly(t, uint64(13), tx.XID)
+	assert.Exactly(t, "[[3 Gopher]]", fmt.Sprint(tx.Events[0].Rows))
+
+	require.NoError(t, c.Close())
+	require.Len(t, m.Dumps(), 1)
+	assert.Exactly(t, fakeSID+":1-2", m.Dumps()[0].GTIDSet, "Dump must start at the GTID checkpoint")
+	have, err := cp.LoadCheckpoint(ctx)
+	require.NoError(t, err)
+	assert.Exactly(t, fakeSID+":1-3", have.ExecutedGTIDSet)
+}
+
+func TestCanal_FakeMaster_CorruptCheckpoint(t *testing.T) {
+	m := newFakeMaster(t)
+	defer m.Close()
+	cp, cleanup := tempCheckpointFile(t)
+	defer cleanup()
+
+	require.NoError(t, ioutil.WriteFile(string(cp), []byte("mysql-bin.000001"), 0600))
+	c, err := newFakeCanal(m, cp)
+	assert.Nil(t, c)
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+	assert.Empty(t, m.Dumps())
+}
+
+func TestCanal_FakeMaster_Err(t *testing.T) {
+	m := newFakeMaster(t)
+	defer m.Close()
+	cp, cleanup := tempCheckpointFile(t)
+	defer cleanup()
+
+	c, err := newFakeCanal(m, cp)
+	require.NoError(t, err)
+	defer c.Close()
+	c.RegisterRowsEventHandler(fakeTxHandler{err: errors.NewAlreadyClosedf("index gone")})
+	require.NoError(t, c.Start(context.Background()))
+	writeCustomer(m, 11, 1)
+
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for the Canal to stop")
+	}
+	assert.True(t, errors.IsAlreadyClosed(c.Err()), "%+v", c.Err())
+	assert.Contains(t, fmt.Sprint(c.Err()), "index gone")
+}
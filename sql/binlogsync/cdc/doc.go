@@ -0,0 +1,28 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cdc implements change data capture on top of package binlogsync.
+//
+// The Handler converts the rows events of committed transactions into an
+// Envelope per row change, containing the before and after images, the
+// primary key, the operation and the binlog position. An Encoder serializes the
+// envelopes as JSON or protocol buffers, see file cdc.proto, and a Sink
+// delivers them, e.g. into a file, an HTTP webhook or memory.
+//
+// Delivery is at-least-once: If a Sink fails after all retries, the Handler
+// returns an error with behaviour Interrupted which stops the binlogsync.Canal
+// without saving the checkpoint. After a restart the transaction gets
+// delivered again, so consumers must handle duplicates, e.g. via the field
+// Position.
+package cdc
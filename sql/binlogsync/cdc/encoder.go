@@ -0,0 +1,164 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Encoder serializes an Envelope.
+type Encoder interface {
+	Encode(*Envelope) ([]byte, error)
+	// ContentType returns the MIME type of the encoded data.
+	ContentType() string
+}
+
+// Content types returned by the encoders.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// JSONEncoder encodes an Envelope with package encoding/json.
+type JSONEncoder struct{}
+
+// Encode implements Encoder.
+func (JSONEncoder) Encode(e *Envelope) ([]byte, error) { return json.Marshal(e) }
+
+// ContentType implements Encoder.
+func (JSONEncoder) ContentType() string { return ContentTypeJSON }
+
+// ProtobufEncoder encodes an Envelope into the protocol buffers wire format of
+// the message Envelope defined in file cdc.proto. The columns of an image get
+// sorted by name and all values get transferred as their string
+// representation.
+type ProtobufEncoder struct{}
+
+// ContentType implements Encoder.
+func (ProtobufEncoder) ContentType() string { return ContentTypeProtobuf }
+
+// Encode implements Encoder.
+func (ProtobufEncoder) Encode(e *Envelope) ([]byte, error) {
+	buf := make([]byte, 0, 256)
+	buf = appendString(buf, 1, e.Op)
+	buf = appendString(buf, 2, e.Schema)
+	buf = appendString(buf, 3, e.Table)
+	buf = appendColumns(buf, 4, e.PK)
+	buf = appendColumns(buf, 5, e.Before)
+	buf = appendColumns(buf, 6, e.After)
+	buf = appendString(buf, 7, e.GTID)
+	buf = appendString(buf, 8, e.Position)
+	if !e.Timestamp.IsZero() {
+		buf = appendTag(buf, 9, 0)
+		buf = appendUvarint(buf, uint64(e.Timestamp.UnixNano()))
+	}
+	return buf, nil
+}
+
+func appendTag(buf []byte, field, wireType uint64) []byte {
+	return appendUvarint(buf, field<<3|wireType)
+}
+
+func appendBytes(buf []byte, field uint64, b []byte) []byte {
+	buf = appendTag(buf, field, 2)
+	buf = appendUvarint(buf, uint64(len(b)))
+	return append(buf, b...)
+}
+
+func appendString(buf []byte, field uint64, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	buf = appendTag(buf, field, 2)
+	buf = appendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendColumns(buf []byte, field uint64, img map[string]interface{}) []byte {
+	names := make([]string, 0, len(img))
+	for n := range img {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var col []byte
+	for _, n := range names {
+		col = appendString(col[:0], 1, n)
+		if v := img[n]; v == nil {
+			col = appendTag(col, 3, 0)
+			col = append(col, 1)
+		} else {
+			col = appendBytes(col, 2, appendValue(nil, v))
+		}
+		buf = appendBytes(buf, field, col)
+	}
+	return buf
+}
+
+// appendValue appends the string representation of v.
+func appendValue(buf []byte, v interface{}) []byte {
+	switch vt := v.(type) {
+	case []byte:
+		return append(buf, vt...)
+	case string:
+		return append(buf, vt...)
+	case int8:
+		return strconv.AppendInt(buf, int64(vt), 10)
+	case int16:
+		return strconv.AppendInt(buf, int64(vt), 10)
+	case int32:
+		return strconv.AppendInt(buf, int64(vt), 10)
+	case int64:
+		return strconv.AppendInt(buf, vt, 10)
+	case int:
+		return strconv.AppendInt(buf, int64(vt), 10)
+	case uint8:
+		return strconv.AppendUint(buf, uint64(vt), 10)
+	case uint16:
+		return strconv.AppendUint(buf, uint64(vt), 10)
+	case uint32:
+		return strconv.AppendUint(buf, uint64(vt), 10)
+	case uint64:
+		return strconv.AppendUint(buf, vt, 10)
+	case uint:
+		return strconv.AppendUint(buf, uint64(vt), 10)
+	case float32:
+		return strconv.AppendFloat(buf, float64(vt), 'g', -1, 32)
+	case float64:
+		if math.IsInf(vt, 0) || math.IsNaN(vt) {
+			return append(buf, fmt.Sprint(vt)...)
+		}
+		return strconv.AppendFloat(buf, vt, 'g', -1, 64)
+	case bool:
+		return strconv.AppendBool(buf, vt)
+	case time.Time:
+		return vt.AppendFormat(buf, time.RFC3339Nano)
+	case fmt.Stringer:
+		return append(buf, vt.String()...)
+	}
+	return append(buf, fmt.Sprint(v)...)
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], v)
+	return append(buf, scratch[:n]...)
+}
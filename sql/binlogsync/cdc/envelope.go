@@ -0,0 +1,125 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"time"
+
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+)
+
+// Envelope describes a single row change. The images map the column name to
+// its value.
+type Envelope struct {
+	// Op contains the operation, one of the binlogsync action constants. A
+	// snapshot row contains only the after image.
+	Op     string `json:"op"`
+	Schema string `json:"schema"`
+	Table  string `json:"table"`
+	// PK contains the primary key columns of the after image, or of the
+	// before image for a delete.
+	PK map[string]interface{} `json:"pk"`
+	// Before contains the row before an update or delete. Nil for an insert.
+	Before map[string]interface{} `json:"before,omitempty"`
+	// After contains the row after an insert or update. Nil for a delete.
+	After map[string]interface{} `json:"after,omitempty"`
+	// GTID of the transaction, if enabled on the server.
+	GTID string `json:"gtid,omitempty"`
+	// Position points to the end of the transaction in the binlog in the
+	// format file;position. All envelopes of a transaction share the same
+	// position.
+	Position string `json:"position"`
+	// Timestamp when the statement has been executed on the master.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// textDataTypes contains the data types whose values get decoded as []byte
+// but are strings.
+var textDataTypes = map[string]bool{
+	"char":       true,
+	"varchar":    true,
+	"tinytext":   true,
+	"text":       true,
+	"mediumtext": true,
+	"longtext":   true,
+	"enum":       true,
+	"set":        true,
+	"json":       true,
+}
+
+// image maps the row values to the column names. Additional values, for
+// example from a column added after the table has been cached, get ignored.
+func image(cols ddl.Columns, row []interface{}) map[string]interface{} {
+	m := make(map[string]interface{}, len(row))
+	for i, v := range row {
+		if i >= len(cols) {
+			break
+		}
+		c := cols[i]
+		if b, ok := v.([]byte); ok && textDataTypes[c.DataType] {
+			v = string(b)
+		}
+		m[c.Field] = v
+	}
+	return m
+}
+
+func primaryKey(cols ddl.Columns, img map[string]interface{}) map[string]interface{} {
+	pk := make(map[string]interface{}, 1)
+	for _, c := range cols {
+		if c.IsPK() {
+			pk[c.Field] = img[c.Field]
+		}
+	}
+	return pk
+}
+
+// NewEnvelopes converts the rows events of a transaction into envelopes. An
+// update rows event contains pairs of before and after images.
+func NewEnvelopes(tx binlogsync.Transaction) []Envelope {
+	var envs []Envelope
+	for _, ev := range tx.Events {
+		cols := ev.Table.Columns
+		step := 1
+		if ev.Action == binlogsync.UpdateAction {
+			step = 2
+		}
+		for i := 0; i+step <= len(ev.Rows); i += step {
+			e := Envelope{
+				Op:        ev.Action,
+				Schema:    ev.Table.Schema,
+				Table:     ev.Table.Name,
+				GTID:      tx.GTID,
+				Position:  tx.Position.String(),
+				Timestamp: ev.Timestamp,
+			}
+			switch ev.Action {
+			case binlogsync.InsertAction, binlogsync.SnapshotAction:
+				e.After = image(cols, ev.Rows[i])
+				e.PK = primaryKey(cols, e.After)
+			case binlogsync.DeleteAction:
+				e.Before = image(cols, ev.Rows[i])
+				e.PK = primaryKey(cols, e.Before)
+			case binlogsync.UpdateAction:
+				e.Before = image(cols, ev.Rows[i])
+				e.After = image(cols, ev.Rows[i+1])
+				e.PK = primaryKey(cols, e.After)
+			}
+			envs = append(envs, e)
+		}
+	}
+	return envs
+}
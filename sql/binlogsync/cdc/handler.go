@@ -0,0 +1,107 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"context"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+)
+
+// Handler converts the rows events of committed transactions into envelopes,
+// encodes them and writes them to a Sink. Handler implements the interface
+// binlogsync.TransactionHandler.
+type Handler struct {
+	Sink    Sink
+	Encoder Encoder
+	// MaxRetries defines how often a failed write gets retried. Default 5.
+	MaxRetries int
+	// RetryBackoff defines the wait before the first retry, doubled for each
+	// further retry. Default 100ms.
+	RetryBackoff time.Duration
+	Log          log.Logger
+}
+
+// NewHandler creates a new CDC handler. If enc is nil, JSON gets used.
+func NewHandler(s Sink, enc Encoder) *Handler {
+	if enc == nil {
+		enc = JSONEncoder{}
+	}
+	return &Handler{
+		Sink:         s,
+		Encoder:      enc,
+		MaxRetries:   5,
+		RetryBackoff: 100 * time.Millisecond,
+		Log:          log.BlackHole{},
+	}
+}
+
+// Do implements binlogsync.RowsEventHandler for events outside of a
+// transaction. The timestamp of the envelopes gets taken from the binlog
+// event, see binlogsync.EventTimestamp.
+func (h *Handler) Do(ctx context.Context, action string, t ddl.Table, rows [][]interface{}) error {
+	ts, _ := binlogsync.EventTimestamp(ctx)
+	return h.DoTransaction(ctx, binlogsync.Transaction{
+		Events: []binlogsync.RowsEvent{{Action: action, Table: t, Rows: rows, Timestamp: ts}},
+	})
+}
+
+// DoTransaction implements binlogsync.TransactionHandler. If the sink fails
+// permanently or after all retries, an error with behaviour Interrupted gets
+// returned which stops the Canal before the position gets saved.
+func (h *Handler) DoTransaction(ctx context.Context, tx binlogsync.Transaction) error {
+	envs := NewEnvelopes(tx)
+	if len(envs) == 0 {
+		return nil
+	}
+	msgs := make([]Message, len(envs))
+	for i := range envs {
+		data, err := h.Encoder.Encode(&envs[i])
+		if err != nil {
+			return errors.NewInterruptedf("[cdc] Handler.Encode %s.%s at %q: %s", envs[i].Schema, envs[i].Table, envs[i].Position, err)
+		}
+		msgs[i] = Message{Envelope: &envs[i], Data: data, ContentType: h.Encoder.ContentType()}
+	}
+
+	backoff := h.RetryBackoff
+	for retry := 0; ; retry++ {
+		err := h.Sink.Write(ctx, msgs)
+		if err == nil {
+			return nil
+		}
+		if errors.IsNotValid(err) || retry >= h.MaxRetries {
+			return errors.NewInterruptedf("[cdc] Handler.Sink.Write at %q after %d retries: %s", tx.Position, retry, err)
+		}
+		if h.Log.IsInfo() {
+			h.Log.Info("cdc.Handler.Sink.Write.retry", log.Err(err), log.Int("retry", retry+1), log.Stringer("position", tx.Position), log.Duration("backoff", backoff))
+		}
+		select {
+		case <-ctx.Done():
+			return errors.NewInterruptedf("[cdc] Handler.Sink.Write at %q: %s", tx.Position, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// Complete implements binlogsync.RowsEventHandler.
+func (h *Handler) Complete(_ context.Context) error { return nil }
+
+// String implements binlogsync.RowsEventHandler.
+func (h *Handler) String() string { return "cdc.Handler" }
@@ -0,0 +1,183 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/binlogsync/cdc"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ binlogsync.TransactionHandler = (*cdc.Handler)(nil)
+
+func newTestTransaction() binlogsync.Transaction {
+	tbl := ddl.Table{
+		Schema: "shop",
+		Name:   "customer",
+		Columns: ddl.Columns{
+			&ddl.Column{Field: "id", DataType: "int", Key: "PRI"},
+			&ddl.Column{Field: "email", DataType: "varchar"},
+			&ddl.Column{Field: "avatar", DataType: "blob"},
+		},
+	}
+	ts := time.Unix(1500000000, 0).UTC()
+	return binlogsync.Transaction{
+		GTID:     "3e11fa47-71ca-11e1-9e33-c80aa9429562:23",
+		Position: ddl.MasterStatus{File: "mysql-bin.000003", Position: 4711},
+		Events: []binlogsync.RowsEvent{
+			{Action: binlogsync.InsertAction, Table: tbl, Timestamp: ts, Rows: [][]interface{}{
+				{int32(1), []byte("a@b.c"), []byte{0x01}},
+			}},
+			{Action: binlogsync.UpdateAction, Table: tbl, Timestamp: ts, Rows: [][]interface{}{
+				{int32(1), []byte("a@b.c"), nil},
+				{int32(1), []byte("x@y.z"), nil},
+			}},
+			{Action: binlogsync.DeleteAction, Table: tbl, Timestamp: ts, Rows: [][]interface{}{
+				{int32(1), []byte("x@y.z"), nil},
+			}},
+		},
+	}
+}
+
+func TestNewEnvelopes(t *testing.T) {
+	envs := cdc.NewEnvelopes(newTestTransaction())
+	require.Len(t, envs, 3)
+
+	assert.Exactly(t, "insert", envs[0].Op)
+	assert.Exactly(t, "shop", envs[0].Schema)
+	assert.Exactly(t, "customer", envs[0].Table)
+	assert.Exactly(t, "mysql-bin.000003;4711", envs[0].Position)
+	assert.Exactly(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:23", envs[0].GTID)
+	assert.Exactly(t, map[string]interface{}{"id": int32(1)}, envs[0].PK)
+	assert.Nil(t, envs[0].Before)
+	assert.Exactly(t, map[string]interface{}{"id": int32(1), "email": "a@b.c", "avatar": []byte{0x01}}, envs[0].After)
+
+	assert.Exactly(t, "update", envs[1].Op)
+	assert.Exactly(t, "a@b.c", envs[1].Before["email"])
+	assert.Exactly(t, "x@y.z", envs[1].After["email"])
+	assert.Nil(t, envs[1].After["avatar"])
+
+	assert.Exactly(t, "delete", envs[2].Op)
+	assert.Nil(t, envs[2].After)
+	assert.Exactly(t, map[string]interface{}{"id": int32(1)}, envs[2].PK)
+}
+
+func TestJSONEncoder(t *testing.T) {
+	envs := cdc.NewEnvelopes(newTestTransaction())
+	data, err := cdc.JSONEncoder{}.Encode(&envs[1])
+	require.NoError(t, err)
+	assert.Exactly(t,
+		`{"op":"update","schema":"shop","table":"customer","pk":{"id":1},"before":{"avatar":null,"email":"a@b.c","id":1},"after":{"avatar":null,"email":"x@y.z","id":1},"gtid":"3e11fa47-71ca-11e1-9e33-c80aa9429562:23","position":"mysql-bin.000003;4711","timestamp":"2017-07-14T02:40:00Z"}`,
+		string(data))
+	assert.Exactly(t, cdc.ContentTypeJSON, cdc.JSONEncoder{}.ContentType())
+}
+
+func TestProtobufEncoder(t *testing.T) {
+	e := &cdc.Envelope{
+		Op:     "delete",
+		Table:  "t",
+		PK:     map[string]interface{}{"id": int64(5)},
+		Before: map[string]interface{}{"id": int64(5), "n": nil},
+	}
+	data, err := cdc.ProtobufEncoder{}.Encode(e)
+	require.NoError(t, err)
+	assert.Exactly(t, []byte{
+		0x0a, 6, 'd', 'e', 'l', 'e', 't', 'e', // op
+		0x1a, 1, 't', // table
+		0x22, 7, 0x0a, 2, 'i', 'd', 0x12, 1, '5', // pk
+		0x2a, 7, 0x0a, 2, 'i', 'd', 0x12, 1, '5', // before id
+		0x2a, 5, 0x0a, 1, 'n', 0x18, 1, // before n NULL
+	}, data)
+}
+
+func TestHandler_Do(t *testing.T) {
+	tx := newTestTransaction()
+	ev := tx.Events[0]
+
+	t.Run("event timestamp", func(t *testing.T) {
+		ms := new(cdc.MemorySink)
+		h := cdc.NewHandler(ms, nil)
+		ts := time.Unix(1600000000, 0).UTC()
+		require.NoError(t, h.Do(binlogsync.WithEventTimestamp(context.Background(), ts), ev.Action, ev.Table, ev.Rows))
+		envs := ms.Envelopes()
+		require.Len(t, envs, 1)
+		assert.Exactly(t, ts, envs[0].Timestamp)
+	})
+
+	t.Run("no event timestamp", func(t *testing.T) {
+		ms := new(cdc.MemorySink)
+		h := cdc.NewHandler(ms, nil)
+		require.NoError(t, h.Do(context.Background(), ev.Action, ev.Table, ev.Rows))
+		envs := ms.Envelopes()
+		require.Len(t, envs, 1)
+		assert.True(t, envs[0].Timestamp.IsZero(), "%s", envs[0].Timestamp)
+	})
+}
+
+func TestHandler_DoTransaction(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("delivered", func(t *testing.T) {
+		ms := new(cdc.MemorySink)
+		h := cdc.NewHandler(ms, nil)
+		require.NoError(t, h.DoTransaction(ctx, newTestTransaction()))
+		msgs := ms.Messages()
+		require.Len(t, msgs, 3)
+		var e cdc.Envelope
+		require.NoError(t, json.Unmarshal(msgs[2].Data, &e))
+		assert.Exactly(t, "delete", e.Op)
+		assert.Exactly(t, cdc.ContentTypeJSON, msgs[2].ContentType)
+		assert.Len(t, ms.Envelopes(), 3)
+	})
+
+	t.Run("retried", func(t *testing.T) {
+		ms := new(cdc.MemorySink)
+		ms.FailNext(2, errors.NewTemporaryf("sink unavailable"))
+		h := cdc.NewHandler(ms, cdc.ProtobufEncoder{})
+		h.RetryBackoff = time.Millisecond
+		require.NoError(t, h.DoTransaction(ctx, newTestTransaction()))
+		assert.Len(t, ms.Messages(), 3)
+	})
+
+	t.Run("retries exhausted", func(t *testing.T) {
+		ms := new(cdc.MemorySink)
+		ms.FailNext(3, errors.NewTemporaryf("sink unavailable"))
+		h := cdc.NewHandler(ms, nil)
+		h.MaxRetries = 2
+		h.RetryBackoff = time.Millisecond
+		err := h.DoTransaction(ctx, newTestTransaction())
+		assert.True(t, errors.IsInterrupted(err), "%+v", err)
+		assert.Len(t, ms.Messages(), 0)
+	})
+
+	t.Run("permanent failure", func(t *testing.T) {
+		ms := new(cdc.MemorySink)
+		ms.FailNext(1, errors.NewNotValidf("rejected"))
+		h := cdc.NewHandler(ms, nil)
+		err := h.DoTransaction(ctx, newTestTransaction())
+		assert.True(t, errors.IsInterrupted(err), "%+v", err)
+		// no retry happened, so the next write succeeds
+		require.NoError(t, h.DoTransaction(ctx, newTestTransaction()))
+		assert.Len(t, ms.Messages(), 3)
+	})
+}
@@ -0,0 +1,222 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/corestoreio/errors"
+)
+
+// Message contains an encoded Envelope.
+type Message struct {
+	Envelope    *Envelope
+	Data        []byte
+	ContentType string
+}
+
+// Sink delivers messages to a destination. Write must only return nil once all
+// messages have been persisted or acknowledged by the receiver, because the
+// binlog position gets saved afterwards. Returning an error with behaviour
+// NotValid signals a permanent failure which won't be retried. Write gets
+// called sequentially.
+type Sink interface {
+	Write(ctx context.Context, msgs []Message) error
+	Close() error
+}
+
+// writeFramed writes the messages either as new line delimited JSON or, for
+// all other content types, each message prefixed with its length as uvarint.
+func writeFramed(w *bytes.Buffer, msgs []Message) {
+	for _, m := range msgs {
+		if m.ContentType == ContentTypeJSON {
+			w.Write(m.Data)
+			w.WriteByte('\n')
+			continue
+		}
+		w.Write(appendUvarint(nil, uint64(len(m.Data))))
+		w.Write(m.Data)
+	}
+}
+
+// FileSink appends the messages to a file. JSON messages get written one per
+// line, all other messages length prefixed with an uvarint. The file gets
+// synced after each write.
+type FileSink struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	f   *os.File
+}
+
+// NewFileSink opens or creates the file in append mode.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[cdc] NewFileSink.OpenFile %q", path)
+	}
+	return &FileSink{f: f}, nil
+}
+
+// Write implements Sink.
+func (fs *FileSink) Write(_ context.Context, msgs []Message) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.buf.Reset()
+	writeFramed(&fs.buf, msgs)
+	if _, err := fs.f.Write(fs.buf.Bytes()); err != nil {
+		return errors.Wrapf(err, "[cdc] FileSink.Write %q", fs.f.Name())
+	}
+	return errors.Wrapf(fs.f.Sync(), "[cdc] FileSink.Sync %q", fs.f.Name())
+}
+
+// Close implements Sink.
+func (fs *FileSink) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return errors.Wrapf(fs.f.Close(), "[cdc] FileSink.Close %q", fs.f.Name())
+}
+
+// HTTPSink posts the messages of a transaction with a single request to a
+// webhook. JSON messages get sent as a JSON array, all other messages length
+// prefixed with an uvarint. A response with status 2xx acknowledges the
+// messages. Status codes 4xx, except 408 and 429, are permanent failures.
+type HTTPSink struct {
+	URL    string
+	Client *http.Client
+	// Header gets added to each request.
+	Header http.Header
+}
+
+// NewHTTPSink creates a new HTTP sink with a request timeout of 30s.
+func NewHTTPSink(url string) *HTTPSink {
+	return &HTTPSink{
+		URL:    url,
+		Client: &http.Client{Timeout: 30 * time.Second},
+		Header: make(http.Header),
+	}
+}
+
+// Write implements Sink.
+func (hs *HTTPSink) Write(ctx context.Context, msgs []Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	contentType := msgs[0].ContentType
+	if contentType == ContentTypeJSON {
+		buf.WriteByte('[')
+		for i, m := range msgs {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(m.Data)
+		}
+		buf.WriteByte(']')
+	} else {
+		writeFramed(&buf, msgs)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hs.URL, &buf)
+	if err != nil {
+		return errors.NewNotValidf("[cdc] HTTPSink.NewRequest %q: %s", hs.URL, err)
+	}
+	req = req.WithContext(ctx)
+	for k, v := range hs.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := hs.Client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "[cdc] HTTPSink.Do %q", hs.URL)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests:
+		return errors.NewNotValidf("[cdc] HTTPSink %q rejected the messages with status %q", hs.URL, resp.Status)
+	}
+	return errors.NewTemporaryf("[cdc] HTTPSink %q failed with status %q", hs.URL, resp.Status)
+}
+
+// Close implements Sink.
+func (hs *HTTPSink) Close() error { return nil }
+
+// MemorySink stores the messages in memory. Mostly used in tests.
+type MemorySink struct {
+	mu       sync.Mutex
+	msgs     []Message
+	failErr  error
+	failNext int
+}
+
+// FailNext lets the next n calls to Write return err.
+func (ms *MemorySink) FailNext(n int, err error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.failNext = n
+	ms.failErr = err
+}
+
+// Write implements Sink.
+func (ms *MemorySink) Write(_ context.Context, msgs []Message) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.failNext > 0 {
+		ms.failNext--
+		return ms.failErr
+	}
+	ms.msgs = append(ms.msgs, msgs...)
+	return nil
+}
+
+// Messages returns a copy of all written messages.
+func (ms *MemorySink) Messages() []Message {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return append([]Message(nil), ms.msgs...)
+}
+
+// Envelopes returns the envelopes of all written messages.
+func (ms *MemorySink) Envelopes() []Envelope {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	envs := make([]Envelope, 0, len(ms.msgs))
+	for _, m := range ms.msgs {
+		envs = append(envs, *m.Envelope)
+	}
+	return envs
+}
+
+// Reset removes all messages.
+func (ms *MemorySink) Reset() {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.msgs = nil
+}
+
+// Close implements Sink.
+func (ms *MemorySink) Close() error { return nil }
@@ -0,0 +1,97 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cdc_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/binlogsync/cdc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cdc_file_sink")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "changes.jsonl")
+
+	fs, err := cdc.NewFileSink(path)
+	require.NoError(t, err)
+	h := cdc.NewHandler(fs, nil)
+	require.NoError(t, h.DoTransaction(context.Background(), newTestTransaction()))
+	require.NoError(t, fs.Close())
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	var ops []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var e cdc.Envelope
+		require.NoError(t, json.Unmarshal(s.Bytes(), &e))
+		ops = append(ops, e.Op)
+	}
+	require.NoError(t, s.Err())
+	assert.Exactly(t, []string{"insert", "update", "delete"}, ops)
+}
+
+func TestHTTPSink(t *testing.T) {
+	var calls int32
+	var received []cdc.Envelope
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Exactly(t, cdc.ContentTypeJSON, r.Header.Get("Content-Type"))
+		assert.Exactly(t, "secret", r.Header.Get("X-Token"))
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	hs := cdc.NewHTTPSink(srv.URL)
+	hs.Header.Set("X-Token", "secret")
+	h := cdc.NewHandler(hs, nil)
+	h.RetryBackoff = 0
+	require.NoError(t, h.DoTransaction(context.Background(), newTestTransaction()))
+	assert.Exactly(t, int32(2), atomic.LoadInt32(&calls))
+	require.Len(t, received, 3)
+	assert.Exactly(t, "update", received[1].Op)
+	assert.Exactly(t, "x@y.z", received[1].After["email"])
+}
+
+func TestHTTPSink_Rejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	defer srv.Close()
+
+	err := cdc.NewHTTPSink(srv.URL).Write(context.Background(), []cdc.Message{{Data: []byte(`{}`), ContentType: cdc.ContentTypeJSON}})
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+}
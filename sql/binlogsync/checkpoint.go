@@ -0,0 +1,185 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// Checkpointer loads and saves the binlog position up to which all
+// transactions have been handled successfully. The field ExecutedGTIDSet of
+// ddl.MasterStatus contains the GTID set, if GTIDs are enabled.
+type Checkpointer interface {
+	// LoadCheckpoint returns the last saved checkpoint. It must return an
+	// error with behaviour NotFound if no checkpoint has been saved yet.
+	LoadCheckpoint(ctx context.Context) (ddl.MasterStatus, error)
+	// SaveCheckpoint persists the checkpoint.
+	SaveCheckpoint(ctx context.Context, ms ddl.MasterStatus) error
+}
+
+// marshalCheckpoint converts the checkpoint into the format
+// file;position;gtid_set. The GTID set is optional.
+func marshalCheckpoint(ms ddl.MasterStatus) string {
+	if ms.ExecutedGTIDSet == "" {
+		return ms.String()
+	}
+	return ms.String() + ";" + ms.ExecutedGTIDSet
+}
+
+// unmarshalCheckpoint parses the format written by marshalCheckpoint. A
+// malformed checkpoint returns an error with behaviour NotValid, never NotFound,
+// so the Canal does not silently start at the current master status.
+func unmarshalCheckpoint(str string) (ddl.MasterStatus, error) {
+	var ms ddl.MasterStatus
+	if parts := strings.SplitN(str, ";", 3); len(parts) == 3 {
+		ms.ExecutedGTIDSet = parts[2]
+		str = parts[0] + ";" + parts[1]
+	}
+	if err := ms.FromString(str); err != nil {
+		return ddl.MasterStatus{}, errors.NewNotValidf("[binlogsync] Invalid checkpoint %q: %s", str, err)
+	}
+	return ms, nil
+}
+
+// CheckpointConfig stores the checkpoint in the configuration under the path
+// of Canal.BackendPosition in the default scope.
+type CheckpointConfig struct {
+	Path cfgmodel.Str
+	// Getter loads the checkpoint. Can be nil, then the Canal always starts at
+	// the current master status.
+	Getter config.Getter
+	Writer config.Writer
+}
+
+// LoadCheckpoint implements Checkpointer.
+func (cc CheckpointConfig) LoadCheckpoint(_ context.Context) (ddl.MasterStatus, error) {
+	if cc.Getter == nil {
+		return ddl.MasterStatus{}, errors.NewNotFoundf("[binlogsync] CheckpointConfig: Getter is nil")
+	}
+	v, err := cc.Path.Get(cc.Getter.NewScoped(0, 0))
+	if err != nil {
+		return ddl.MasterStatus{}, errors.Wrap(err, "[binlogsync] CheckpointConfig.Path.Get")
+	}
+	if v == "" {
+		return ddl.MasterStatus{}, errors.NewNotFoundf("[binlogsync] CheckpointConfig: No checkpoint found in %q", cc.Path.String())
+	}
+	return unmarshalCheckpoint(v)
+}
+
+// SaveCheckpoint implements Checkpointer.
+func (cc CheckpointConfig) SaveCheckpoint(_ context.Context, ms ddl.MasterStatus) error {
+	return errors.Wrap(cc.Path.Write(cc.Writer, marshalCheckpoint(ms), scope.DefaultTypeID), "[binlogsync] CheckpointConfig.Path.Write")
+}
+
+// CheckpointFile stores the checkpoint in a file. The file gets replaced
+// atomically, so a crash cannot leave a half written checkpoint.
+type CheckpointFile string
+
+// LoadCheckpoint implements Checkpointer.
+func (cf CheckpointFile) LoadCheckpoint(_ context.Context) (ddl.MasterStatus, error) {
+	data, err := ioutil.ReadFile(string(cf))
+	if os.IsNotExist(err) {
+		return ddl.MasterStatus{}, errors.NewNotFoundf("[binlogsync] CheckpointFile %q not found", string(cf))
+	}
+	if err != nil {
+		return ddl.MasterStatus{}, errors.Wrapf(err, "[binlogsync] CheckpointFile %q", string(cf))
+	}
+	return unmarshalCheckpoint(strings.TrimSpace(string(data)))
+}
+
+// SaveCheckpoint implements Checkpointer.
+func (cf CheckpointFile) SaveCheckpoint(_ context.Context, ms ddl.MasterStatus) error {
+	f, err := ioutil.TempFile(filepath.Dir(string(cf)), filepath.Base(string(cf)))
+	if err != nil {
+		return errors.Wrapf(err, "[binlogsync] CheckpointFile %q TempFile", string(cf))
+	}
+	_, err = f.WriteString(marshalCheckpoint(ms) + "\n")
+	if err == nil {
+		err = f.Sync()
+	}
+	if errC := f.Close(); err == nil {
+		err = errC
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), string(cf))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return errors.Wrapf(err, "[binlogsync] CheckpointFile %q write", string(cf))
+	}
+	return nil
+}
+
+// CheckpointDBTable defines the default table name for CheckpointDB. Use
+// CheckpointDBTableSQL to create it.
+const CheckpointDBTable = "binlogsync_checkpoint"
+
+// CheckpointDBTableSQL creates the table of CheckpointDB.
+const CheckpointDBTableSQL = "CREATE TABLE IF NOT EXISTS `" + CheckpointDBTable + "` (\n" +
+	"  `name` varchar(64) NOT NULL,\n" +
+	"  `position` text NOT NULL,\n" +
+	"  `updated_at` timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,\n" +
+	"  PRIMARY KEY (`name`)\n" +
+	") ENGINE=InnoDB DEFAULT CHARSET=utf8"
+
+// CheckpointDB stores the checkpoint in a database table. Name identifies the
+// Canal, so multiple Canals can share the table. Table defaults to
+// CheckpointDBTable.
+type CheckpointDB struct {
+	DB    *sql.DB
+	Table string
+	Name  string
+}
+
+func (cd CheckpointDB) table() string {
+	if cd.Table == "" {
+		return CheckpointDBTable
+	}
+	return cd.Table
+}
+
+// LoadCheckpoint implements Checkpointer.
+func (cd CheckpointDB) LoadCheckpoint(ctx context.Context) (ddl.MasterStatus, error) {
+	nv, found, err := dml.NewSelect("position").From(cd.table()).
+		Where(dml.Column("name").PlaceHolder()).
+		WithDB(cd.DB).WithArgs().LoadNullString(ctx, cd.Name)
+	if err != nil {
+		return ddl.MasterStatus{}, errors.Wrapf(err, "[binlogsync] CheckpointDB %q.%q", cd.table(), cd.Name)
+	}
+	if !found || nv.String == "" {
+		return ddl.MasterStatus{}, errors.NewNotFoundf("[binlogsync] CheckpointDB %q.%q not found", cd.table(), cd.Name)
+	}
+	return unmarshalCheckpoint(nv.String)
+}
+
+// SaveCheckpoint implements Checkpointer.
+func (cd CheckpointDB) SaveCheckpoint(ctx context.Context, ms ddl.MasterStatus) error {
+	_, err := dml.NewInsert(cd.table()).AddColumns("name", "position").BuildValues().
+		AddOnDuplicateKeyExclude("name").
+		WithDB(cd.DB).WithArgs().ExecContext(ctx, cd.Name, marshalCheckpoint(ms))
+	return errors.Wrapf(err, "[binlogsync] CheckpointDB %q.%q", cd.table(), cd.Name)
+}
@@ -0,0 +1,109 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/util/cstesting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ binlogsync.Checkpointer = (*binlogsync.CheckpointFile)(nil)
+var _ binlogsync.Checkpointer = (*binlogsync.CheckpointDB)(nil)
+var _ binlogsync.Checkpointer = (*binlogsync.CheckpointConfig)(nil)
+
+func TestCheckpointFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlogsync")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	cf := binlogsync.CheckpointFile(filepath.Join(dir, "checkpoint"))
+
+	_, err = cf.LoadCheckpoint(ctx)
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+
+	want := ddl.MasterStatus{File: "mysql-bin.000002", Position: 4711}
+	require.NoError(t, cf.SaveCheckpoint(ctx, want))
+	have, err := cf.LoadCheckpoint(ctx)
+	require.NoError(t, err)
+	assert.Exactly(t, want, have)
+
+	want.ExecutedGTIDSet = "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,4e11fa47-71ca-11e1-9e33-c80aa9429562:1-3"
+	require.NoError(t, cf.SaveCheckpoint(ctx, want))
+	have, err = cf.LoadCheckpoint(ctx)
+	require.NoError(t, err)
+	assert.Exactly(t, want, have)
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "temporary files must be removed")
+}
+
+func TestCheckpointFile_Corrupt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "binlogsync")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cf := binlogsync.CheckpointFile(filepath.Join(dir, "checkpoint"))
+	for _, data := range []string{"mysql-bin.000002", "mysql-bin.000002;xx", ";4711"} {
+		require.NoError(t, ioutil.WriteFile(string(cf), []byte(data), 0600))
+		_, err = cf.LoadCheckpoint(context.Background())
+		assert.True(t, errors.IsNotValid(err), "%q: %+v", data, err)
+		assert.False(t, errors.IsNotFound(err), "%q: %+v", data, err)
+	}
+}
+
+func TestCheckpointDB(t *testing.T) {
+	dbc, dbMock := cstesting.MockDB(t)
+	defer func() {
+		dbMock.ExpectClose()
+		assert.NoError(t, dbc.Close())
+		if err := dbMock.ExpectationsWereMet(); err != nil {
+			t.Error("there were unfulfilled expections", err)
+		}
+	}()
+
+	ctx := context.Background()
+	cd := binlogsync.CheckpointDB{DB: dbc.DB, Name: "search_index"}
+
+	dbMock.ExpectExec(cstesting.SQLMockQuoteMeta("INSERT INTO `binlogsync_checkpoint` (`name`,`position`) VALUES (?,?) ON DUPLICATE KEY UPDATE `position`=VALUES(`position`)")).
+		WithArgs("search_index", "mysql-bin.000002;4711;3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, cd.SaveCheckpoint(ctx, ddl.MasterStatus{File: "mysql-bin.000002", Position: 4711, ExecutedGTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}))
+
+	dbMock.ExpectQuery(cstesting.SQLMockQuoteMeta("SELECT `position` FROM `binlogsync_checkpoint` WHERE (`name` = ?)")).
+		WithArgs("search_index").
+		WillReturnRows(sqlmock.NewRows([]string{"position"}).AddRow("mysql-bin.000002;4711;3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"))
+	have, err := cd.LoadCheckpoint(ctx)
+	require.NoError(t, err)
+	assert.Exactly(t, ddl.MasterStatus{File: "mysql-bin.000002", Position: 4711, ExecutedGTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}, have)
+
+	dbMock.ExpectQuery(cstesting.SQLMockQuoteMeta("SELECT `position` FROM `binlogsync_checkpoint` WHERE (`name` = ?)")).
+		WithArgs("search_index").
+		WillReturnRows(sqlmock.NewRows([]string{"position"}))
+	_, err = cd.LoadCheckpoint(ctx)
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+}
@@ -0,0 +1,361 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/ddl"
+)
+
+// MaskFunc transforms the value of a sensitive column before a handler
+// receives it. The value can be nil for a NULL column. A MaskFunc must not
+// modify the value in place, e.g. a []byte, because the rows get shared
+// between all handlers.
+type MaskFunc func(v interface{}) interface{}
+
+// MaskNull replaces the value with NULL.
+func MaskNull(interface{}) interface{} { return nil }
+
+// MaskReplace replaces all non-NULL values with a constant.
+func MaskReplace(with interface{}) MaskFunc {
+	return func(v interface{}) interface{} {
+		if v == nil {
+			return nil
+		}
+		return with
+	}
+}
+
+// MaskHMACSHA256 replaces all non-NULL values with the hex encoded HMAC-SHA256
+// of their string representation. Equal values result in equal hashes, so the
+// column can still be used for joins or counting. The key must be kept secret
+// to prevent dictionary attacks.
+func MaskHMACSHA256(key []byte) MaskFunc {
+	return func(v interface{}) interface{} {
+		if v == nil {
+			return nil
+		}
+		mac := hmac.New(sha256.New, key)
+		switch vt := v.(type) {
+		case []byte:
+			mac.Write(vt)
+		case string:
+			mac.Write([]byte(vt))
+		default:
+			fmt.Fprint(mac, vt)
+		}
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+}
+
+// MaskEmail keeps the first character of the local part and the domain of an
+// email address, e.g. j***@example.com. Values without an @ get fully masked.
+func MaskEmail(v interface{}) interface{} {
+	var s string
+	switch vt := v.(type) {
+	case nil:
+		return nil
+	case []byte:
+		s = string(vt)
+	case string:
+		s = vt
+	default:
+		return "***"
+	}
+	at := strings.LastIndexByte(s, '@')
+	if at < 1 {
+		return "***"
+	}
+	_, size := utf8.DecodeRuneInString(s)
+	return s[:size] + "***" + s[at:]
+}
+
+// FilterOption applies an option to a Filter.
+type FilterOption func(*Filter) error
+
+type tableMatcher struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+func (tm tableMatcher) match(table string) bool {
+	if tm.re != nil {
+		return tm.re.MatchString(table)
+	}
+	ok, _ := path.Match(tm.glob, table) // pattern already validated
+	return ok
+}
+
+func newGlobMatchers(globs []string) ([]tableMatcher, error) {
+	tms := make([]tableMatcher, 0, len(globs))
+	for _, g := range globs {
+		if _, err := path.Match(g, ""); err != nil {
+			return nil, errors.NewNotValidf("[binlogsync] Invalid table pattern %q: %s", g, err)
+		}
+		tms = append(tms, tableMatcher{glob: g})
+	}
+	return tms, nil
+}
+
+func newRegexpMatchers(exprs []string) ([]tableMatcher, error) {
+	tms := make([]tableMatcher, 0, len(exprs))
+	for _, e := range exprs {
+		re, err := regexp.Compile(e)
+		if err != nil {
+			return nil, errors.NewNotValidf("[binlogsync] Invalid table regular expression %q: %s", e, err)
+		}
+		tms = append(tms, tableMatcher{re: re})
+	}
+	return tms, nil
+}
+
+// IncludeTables restricts the handler to the tables matching one of the glob
+// patterns, see path.Match for the syntax.
+func IncludeTables(globs ...string) FilterOption {
+	return func(f *Filter) error {
+		tms, err := newGlobMatchers(globs)
+		f.include = append(f.include, tms...)
+		return err
+	}
+}
+
+// ExcludeTables hides the tables matching one of the glob patterns from the
+// handler. Excludes take precedence over includes.
+func ExcludeTables(globs ...string) FilterOption {
+	return func(f *Filter) error {
+		tms, err := newGlobMatchers(globs)
+		f.exclude = append(f.exclude, tms...)
+		return err
+	}
+}
+
+// IncludeTablesRegexp same as IncludeTables but with regular expressions.
+func IncludeTablesRegexp(exprs ...string) FilterOption {
+	return func(f *Filter) error {
+		tms, err := newRegexpMatchers(exprs)
+		f.include = append(f.include, tms...)
+		return err
+	}
+}
+
+// ExcludeTablesRegexp same as ExcludeTables but with regular expressions.
+func ExcludeTablesRegexp(exprs ...string) FilterOption {
+	return func(f *Filter) error {
+		tms, err := newRegexpMatchers(exprs)
+		f.exclude = append(f.exclude, tms...)
+		return err
+	}
+}
+
+// AllowColumns restricts the columns of the tables matching the glob pattern
+// to the provided column names. The handler receives a table with only those
+// columns and rows with only their values. Unknown column names get ignored.
+// Column names are case-insensitive.
+func AllowColumns(tableGlob string, columns ...string) FilterOption {
+	return func(f *Filter) error {
+		tms, err := newGlobMatchers([]string{tableGlob})
+		if err != nil {
+			return err
+		}
+		f.allow = append(f.allow, columnRule{table: tms[0], columns: columns})
+		return nil
+	}
+}
+
+// MaskColumns applies the MaskFunc to the columns of the tables matching the
+// glob pattern. Masking happens before AllowColumns, and the first matching
+// mask of a column wins.
+func MaskColumns(tableGlob string, fn MaskFunc, columns ...string) FilterOption {
+	return func(f *Filter) error {
+		tms, err := newGlobMatchers([]string{tableGlob})
+		if err != nil {
+			return err
+		}
+		f.masks = append(f.masks, columnRule{table: tms[0], columns: columns, mask: fn})
+		return nil
+	}
+}
+
+type columnRule struct {
+	table   tableMatcher
+	columns []string
+	mask    MaskFunc
+}
+
+// hasColumn compares case-insensitive because MySQL column names are
+// case-insensitive.
+func (cr columnRule) hasColumn(name string) bool {
+	for _, c := range cr.columns {
+		if strings.EqualFold(c, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter restricts the tables and columns a handler receives and masks
+// sensitive column values. A Filter gets attached to a handler with
+// Canal.RegisterFilteredRowsEventHandler. A Filter is safe for concurrent use
+// once created.
+type Filter struct {
+	include []tableMatcher
+	exclude []tableMatcher
+	allow   []columnRule
+	masks   []columnRule
+}
+
+// NewFilter creates a new Filter. Without options all events pass unchanged.
+func NewFilter(opts ...FilterOption) (*Filter, error) {
+	f := new(Filter)
+	for _, o := range opts {
+		if err := o(f); err != nil {
+			return nil, errors.Wrap(err, "[binlogsync] NewFilter")
+		}
+	}
+	return f, nil
+}
+
+// MustNewFilter same as NewFilter but panics on error.
+func MustNewFilter(opts ...FilterOption) *Filter {
+	f, err := NewFilter(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// IncludesTable reports whether the events of a table pass the filter.
+func (f *Filter) IncludesTable(table string) bool {
+	if f == nil {
+		return true
+	}
+	for _, tm := range f.exclude {
+		if tm.match(table) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, tm := range f.include {
+		if tm.match(table) {
+			return true
+		}
+	}
+	return false
+}
+
+// event applies the column rules to a rows event. The returned event contains
+// new rows, the rows of ev stay untouched.
+func (f *Filter) event(ev RowsEvent) RowsEvent {
+	var masks map[int]MaskFunc
+	for i, c := range ev.Table.Columns {
+		for _, mr := range f.masks {
+			if mr.table.match(ev.Table.Name) && mr.hasColumn(c.Field) {
+				if masks == nil {
+					masks = make(map[int]MaskFunc, 2)
+				}
+				masks[i] = mr.mask
+				break
+			}
+		}
+	}
+
+	var keep []int // nil keeps all columns
+	for _, ar := range f.allow {
+		if !ar.table.match(ev.Table.Name) {
+			continue
+		}
+		if keep == nil {
+			keep = make([]int, 0, len(ar.columns))
+		}
+		for i, c := range ev.Table.Columns {
+			if ar.hasColumn(c.Field) {
+				keep = append(keep, i)
+			}
+		}
+		break
+	}
+
+	if masks == nil && keep == nil {
+		return ev
+	}
+
+	if keep != nil {
+		cols := make(ddl.Columns, 0, len(keep))
+		for _, idx := range keep {
+			cols = append(cols, ev.Table.Columns[idx])
+		}
+		t := ddl.NewTable(ev.Table.Name, cols...)
+		t.DB = ev.Table.DB
+		t.Schema = ev.Table.Schema
+		t.IsView = ev.Table.IsView
+		ev.Table = *t
+	}
+
+	rows := make([][]interface{}, len(ev.Rows))
+	for r, row := range ev.Rows {
+		if keep == nil {
+			nr := make([]interface{}, len(row))
+			for i, v := range row {
+				if fn, ok := masks[i]; ok {
+					v = fn(v)
+				}
+				nr[i] = v
+			}
+			rows[r] = nr
+			continue
+		}
+		nr := make([]interface{}, len(keep))
+		for j, i := range keep {
+			if i >= len(row) {
+				continue
+			}
+			v := row[i]
+			if fn, ok := masks[i]; ok {
+				v = fn(v)
+			}
+			nr[j] = v
+		}
+		rows[r] = nr
+	}
+	ev.Rows = rows
+	return ev
+}
+
+// transaction returns a copy of the transaction containing only the filtered
+// events. Returns false if no event passes the filter.
+func (f *Filter) transaction(tx Transaction) (Transaction, bool) {
+	if f == nil {
+		return tx, true
+	}
+	evs := make([]RowsEvent, 0, len(tx.Events))
+	for _, ev := range tx.Events {
+		if f.IncludesTable(ev.Table.Name) {
+			evs = append(evs, f.event(ev))
+		}
+	}
+	tx.Events = evs
+	return tx, len(evs) > 0
+}
@@ -0,0 +1,128 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFilterTestTransaction() Transaction {
+	customer := ddl.NewTable("customer_entity",
+		&ddl.Column{Field: "entity_id"},
+		&ddl.Column{Field: "email"},
+		&ddl.Column{Field: "password_hash"},
+		&ddl.Column{Field: "group_id"},
+	)
+	return Transaction{Events: []RowsEvent{
+		{Action: InsertAction, Table: *ddl.NewTable("catalog_product_entity", &ddl.Column{Field: "entity_id"}), Rows: [][]interface{}{{int64(7)}}},
+		{Action: InsertAction, Table: *customer, Rows: [][]interface{}{
+			{int64(1), []byte("jane@example.com"), []byte("$2y$10$secret"), int64(4)},
+		}},
+		{Action: DeleteAction, Table: *ddl.NewTable("sales_order_tmp", &ddl.Column{Field: "entity_id"}), Rows: [][]interface{}{{int64(3)}}},
+	}}
+}
+
+func TestFilter_IncludesTable(t *testing.T) {
+	f := MustNewFilter(
+		IncludeTables("customer_*", "catalog_product_entity"),
+		IncludeTablesRegexp("^sales_order(_item)?$"),
+		ExcludeTables("*_tmp", "customer_grid_flat"),
+	)
+	for _, test := range []struct {
+		table string
+		want  bool
+	}{
+		{"customer_entity", true},
+		{"customer_grid_flat", false},
+		{"catalog_product_entity", true},
+		{"catalog_category_entity", false},
+		{"sales_order", true},
+		{"sales_order_item", true},
+		{"sales_order_tmp", false},
+	} {
+		assert.Exactly(t, test.want, f.IncludesTable(test.table), "Table %q", test.table)
+	}
+
+	var nilFilter *Filter
+	assert.True(t, nilFilter.IncludesTable("any"))
+	assert.True(t, MustNewFilter().IncludesTable("any"))
+}
+
+func TestNewFilter_Invalid(t *testing.T) {
+	_, err := NewFilter(IncludeTables("[a-"))
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+	_, err = NewFilter(ExcludeTablesRegexp("(a"))
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+	_, err = NewFilter(AllowColumns("[", "a"))
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+}
+
+func TestMaskFuncs(t *testing.T) {
+	assert.Nil(t, MaskNull("x"))
+	assert.Exactly(t, "***", MaskReplace("***")([]byte("x")))
+	assert.Nil(t, MaskReplace("***")(nil))
+	assert.Exactly(t, "j***@example.com", MaskEmail([]byte("jane@example.com")))
+	assert.Exactly(t, "***", MaskEmail("no-mail"))
+	assert.Exactly(t, "ü***@example.com", MaskEmail("übel@example.com"))
+	assert.Exactly(t, "山***@example.jp", MaskEmail([]byte("山田@example.jp")))
+	assert.Nil(t, MaskEmail(nil))
+
+	h := MaskHMACSHA256([]byte("key"))
+	assert.Exactly(t, h([]byte("jane@example.com")), h("jane@example.com"))
+	assert.NotEqual(t, h("jane@example.com"), MaskHMACSHA256([]byte("other"))("jane@example.com"))
+	assert.Len(t, h(int64(1)), 64)
+	assert.Nil(t, h(nil))
+}
+
+func TestCanal_RegisterFilteredRowsEventHandler(t *testing.T) {
+	c := newEventTestCanal()
+	all := new(recordingTxHandler)
+	analytics := new(recordingTxHandler)
+	products := new(recordingHandler)
+	c.RegisterRowsEventHandler(all)
+	c.RegisterFilteredRowsEventHandler(analytics, MustNewFilter(
+		ExcludeTables("*_tmp"),
+		AllowColumns("customer_*", "entity_id", "EMAIL", "group_id"),
+		MaskColumns("customer_*", MaskEmail, "Email"),
+		MaskColumns("*", MaskNull, "password_hash"),
+	))
+	c.RegisterFilteredRowsEventHandler(products, MustNewFilter(IncludeTables("sales_*")))
+
+	tx := newFilterTestTransaction()
+	require.NoError(t, c.travelTransaction(context.Background(), tx))
+
+	require.Len(t, all.txs, 1)
+	assert.Len(t, all.txs[0].Events, 3)
+	assert.Exactly(t, []byte("jane@example.com"), all.txs[0].Events[1].Rows[0][1], "unfiltered handler must see the original value")
+
+	require.Len(t, analytics.txs, 1)
+	evs := analytics.txs[0].Events
+	require.Len(t, evs, 2)
+	assert.Exactly(t, "catalog_product_entity", evs[0].Table.Name)
+	assert.Exactly(t, []string{"entity_id", "email", "group_id"}, evs[1].Table.Columns.FieldNames())
+	assert.Exactly(t, [][]interface{}{{int64(1), "j***@example.com", int64(4)}}, evs[1].Rows)
+
+	assert.Exactly(t, []string{"delete sales_order_tmp [[3]]"}, products.events)
+
+	// the rows of the original transaction stay untouched
+	assert.Exactly(t, []byte("jane@example.com"), tx.Events[1].Rows[0][1])
+	assert.Len(t, tx.Events[1].Table.Columns, 4)
+}
@@ -2,6 +2,7 @@ package binlogsync
 
 import (
 	"context"
+	"time"
 
 	"github.com/corestoreio/pkg/sql/ddl"
 	"github.com/corestoreio/errors"
@@ -9,20 +10,17 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
-// TODO(CyS) investigate what would happen in case of transaction? should all
-// the events be gathered together once a transaction starts? because on
-// RollBack all events must be invalidated or better RowsEventHandler should not
-// be called at all.
-
 // RowsEventHandler calls your code when an event gets dispatched.
 type RowsEventHandler interface {
 	// Do function handles a RowsEvent bound to a specific database. If it
-	// returns an error behaviour of "Interrupted", the canal type will stop the
-	// syncer. Binlog has three update event version, v0, v1 and v2. For v1 and
+	// returns an error, the canal type will stop the syncer without saving
+	// the position of the transaction, see WithSkipHandlerErrors. Binlog has three update event version, v0, v1 and v2. For v1 and
 	// v2, the rows number must be even. Two rows for one event, format is
 	// [before update row, after update row] for update v0, only one row for a
 	// event, and we don't support this version yet. The Do function will run in
-	// its own Goroutine.
+	// its own Goroutine. Do gets only called once the transaction of the event
+	// has been committed, in the order of the events within the transaction.
+	// The context provides the timestamp of the event, see EventTimestamp.
 	Do(ctx context.Context, action string, t ddl.Table, rows [][]interface{}) error
 	// Complete runs before a binlog rotation event happens. Same error rules
 	// apply here like for function Do(). The Complete function will run in its
@@ -32,40 +30,165 @@ type RowsEventHandler interface {
 	String() string
 }
 
+// TransactionHandler gets implemented by a RowsEventHandler which wants to
+// receive all rows events of a committed transaction as one batch. For such a
+// handler the function DoTransaction gets called instead of Do. Same error
+// rules apply here like for function Do().
+type TransactionHandler interface {
+	RowsEventHandler
+	DoTransaction(ctx context.Context, tx Transaction) error
+}
+
+// SchemaChangeHandler gets implemented by a RowsEventHandler which wants to
+// get notified about DDL statements of the synced database, for example to
+// update a search index mapping. The Canal reloads the table structure itself.
+// Same error rules apply here like for function Do().
+type SchemaChangeHandler interface {
+	RowsEventHandler
+	DoSchemaChange(ctx context.Context, sc SchemaChange) error
+}
+
+// RowsEvent contains the rows of a single binlog rows event. See
+// RowsEventHandler.Do for the format of the rows.
+type RowsEvent struct {
+	Action string
+	Table  ddl.Table
+	Rows   [][]interface{}
+	// Timestamp when the statement has been executed on the master.
+	Timestamp time.Time
+}
+
+// Transaction contains all rows events between BEGIN and COMMIT in the order
+// of the binlog. Rolled back transactions never get dispatched.
+type Transaction struct {
+	// GTID contains the global transaction identifier, for MySQL in the format
+	// source_id:transaction_id and for MariaDB domain_id-server_id-sequence.
+	// Empty if GTIDs are disabled.
+	GTID string
+	// XID contains the ID of the committed XA transaction. Zero if the
+	// transaction has been committed by a COMMIT statement, for example for
+	// non-transactional storage engines.
+	XID uint64
+	// Position points to the end of the transaction in the binlog. The
+	// position gets saved as checkpoint once all handlers have returned.
+	Position ddl.MasterStatus
+	Events   []RowsEvent
+}
+
+// filteredHandler binds an optional Filter to a handler.
+type filteredHandler struct {
+	RowsEventHandler
+	filter *Filter
+}
+
 // RegisterRowsEventHandler adds a new event handler to the internal list.
 func (c *Canal) RegisterRowsEventHandler(h RowsEventHandler) {
+	c.RegisterFilteredRowsEventHandler(h, nil)
+}
+
+// RegisterFilteredRowsEventHandler adds a new event handler to the internal
+// list. The handler receives only the events and columns which pass the
+// filter, with masked column values. A nil filter passes all events.
+func (c *Canal) RegisterFilteredRowsEventHandler(h RowsEventHandler, f *Filter) {
 	c.rsMu.Lock()
 	defer c.rsMu.Unlock()
 
 	if c.rsHandlers == nil {
-		c.rsHandlers = make([]RowsEventHandler, 0, 4)
+		c.rsHandlers = make([]filteredHandler, 0, 4)
 	}
-	c.rsHandlers = append(c.rsHandlers, h)
+	c.rsHandlers = append(c.rsHandlers, filteredHandler{RowsEventHandler: h, filter: f})
+}
+
+type ctxKeyEventTimestamp struct{}
+
+// WithEventTimestamp returns a context with the timestamp of a rows event as
+// passed to the Do function of a RowsEventHandler. Useful to replay events in
+// tests.
+func WithEventTimestamp(ctx context.Context, ts time.Time) context.Context {
+	return context.WithValue(ctx, ctxKeyEventTimestamp{}, ts)
+}
+
+// EventTimestamp returns the timestamp of the rows event passed to the Do
+// function of a RowsEventHandler. For a binlog event it is the timestamp of
+// the event header. Returns false if the context does not belong to a call of
+// Do.
+func EventTimestamp(ctx context.Context) (time.Time, bool) {
+	ts, ok := ctx.Value(ctxKeyEventTimestamp{}).(time.Time)
+	return ts, ok
 }
 
-func (c *Canal) travelRowsEventHandler(ctx context.Context, action string, table ddl.Table, rows [][]interface{}) error {
+// travelTransaction dispatches a committed transaction to all handlers. A
+// TransactionHandler receives the whole transaction, all other handlers
+// receive each rows event in order. The filter of a handler gets applied
+// first and a handler without any passing event does not get called.
+func (c *Canal) travelTransaction(ctx context.Context, tx Transaction) error {
 	c.rsMu.RLock()
 	defer c.rsMu.RUnlock()
 
 	erg, ctx := errgroup.WithContext(ctx)
 
-	for _, h := range c.rsHandlers {
-		h := h
+	for _, fh := range c.rsHandlers {
+		h := fh.RowsEventHandler
+		tx, ok := fh.filter.transaction(tx)
+		if !ok {
+			continue
+		}
 		erg.Go(func() error {
-			err := h.Do(ctx, action, table, rows)
-			isInterr := errors.IsInterrupted(err)
-			if err != nil && !isInterr {
-				c.Log.Info("[binlogsync] Handler.Do error", log.Err(err), log.Stringer("handler_name", h),
-					log.String("action", action), log.String("schema", c.DSN.DBName), log.String("table", table.Name))
-			} else if isInterr {
-				c.Log.Info("[binlogsync] Handler.Do Interrupt", log.Err(err), log.Stringer("handler_name", h),
-					log.String("action", action), log.String("schema", c.DSN.DBName), log.String("table", table.Name))
-				return errors.Wrap(err, "[binlogsync] travelRowsEventHandler interrupted")
+			if th, ok := h.(TransactionHandler); ok {
+				return c.handlerError(th.DoTransaction(ctx, tx), "[binlogsync] Handler.DoTransaction", log.Stringer("handler_name", h),
+					log.String("gtid", tx.GTID), log.Int("events", len(tx.Events)), log.String("schema", c.DSN.DBName))
+			}
+			for _, ev := range tx.Events {
+				if err := c.handlerError(h.Do(WithEventTimestamp(ctx, ev.Timestamp), ev.Action, ev.Table, ev.Rows), "[binlogsync] Handler.Do", log.Stringer("handler_name", h),
+					log.String("action", ev.Action), log.String("schema", c.DSN.DBName), log.String("table", ev.Table.Name)); err != nil {
+					return err
+				}
 			}
 			return nil
 		})
 	}
-	return errors.Wrap(erg.Wait(), "[binlogsync] travelRowsEventHandler errgroup Wait")
+	return errors.Wrap(erg.Wait(), "[binlogsync] travelTransaction errgroup Wait")
+}
+
+// travelSchemaChange dispatches a schema change to all SchemaChangeHandler
+// whose filter includes the table or its new name.
+func (c *Canal) travelSchemaChange(ctx context.Context, sc SchemaChange) error {
+	c.rsMu.RLock()
+	defer c.rsMu.RUnlock()
+
+	erg, ctx := errgroup.WithContext(ctx)
+
+	for _, fh := range c.rsHandlers {
+		sh, ok := fh.RowsEventHandler.(SchemaChangeHandler)
+		if !ok || !fh.filter.IncludesTable(sc.Table) && (sc.NewTable == "" || !fh.filter.IncludesTable(sc.NewTable)) {
+			continue
+		}
+		erg.Go(func() error {
+			return c.handlerError(sh.DoSchemaChange(ctx, sc), "[binlogsync] Handler.DoSchemaChange", log.Stringer("handler_name", sh),
+				log.String("action", sc.Action), log.String("schema", sc.Schema), log.String("table", sc.Table))
+		})
+	}
+	return errors.Wrap(erg.Wait(), "[binlogsync] travelSchemaChange errgroup Wait")
+}
+
+// handlerError logs a failed handler and returns the wrapped error, which
+// stops the Canal before the position of the current transaction gets saved.
+// With WithSkipHandlerErrors an error without behaviour Interrupted gets only
+// logged and the sync continues.
+func (c *Canal) handlerError(err error, msg string, fields ...log.Field) error {
+	if err == nil {
+		return nil
+	}
+	if c.skipHandlerErrors && !errors.IsInterrupted(err) {
+		if c.Log.IsInfo() {
+			c.Log.Info(msg+" error skipped", append(fields, log.Err(err))...)
+		}
+		return nil
+	}
+	if c.Log.IsInfo() {
+		c.Log.Info(msg+" error", append(fields, log.Err(err))...)
+	}
+	return errors.Wrap(err, msg)
 }
 
 func (c *Canal) flushEventHandlers(ctx context.Context) error {
@@ -74,18 +197,10 @@ func (c *Canal) flushEventHandlers(ctx context.Context) error {
 
 	erg, ctx := errgroup.WithContext(ctx)
 
-	for _, h := range c.rsHandlers {
-		h := h
+	for _, fh := range c.rsHandlers {
+		h := fh.RowsEventHandler
 		erg.Go(func() error {
-			err := h.Complete(ctx)
-			isInterr := errors.IsInterrupted(err)
-			if err != nil && !isInterr {
-				c.Log.Info("[binlogsync] flushEventHandlers.Handler.Complete error", log.Err(err), log.Stringer("handler_name", h))
-			} else if isInterr {
-				c.Log.Info("[binlogsync] flushEventHandlers.Handler.Complete interrupted", log.Err(err), log.Stringer("handler_name", h))
-				return errors.Wrap(err, "[binlogsync] flushEventHandlers interrupted")
-			}
-			return nil
+			return c.handlerError(h.Complete(ctx), "[binlogsync] flushEventHandlers.Handler.Complete", log.Stringer("handler_name", h))
 		})
 	}
 	return errors.Wrap(erg.Wait(), "[binlogsync] flushEventHandlers errgroup Wait")
@@ -0,0 +1,853 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/myreplicator"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+)
+
+// Schema change actions passed to the SchemaChangeHandler.
+const (
+	SchemaCreateAction   = "create"
+	SchemaAlterAction    = "alter"
+	SchemaRenameAction   = "rename"
+	SchemaDropAction     = "drop"
+	SchemaTruncateAction = "truncate"
+)
+
+// SchemaChange describes a DDL statement affecting a table.
+type SchemaChange struct {
+	// Action contains one of the schema action constants.
+	Action string
+	Schema string
+	Table  string
+	// NewSchema and NewTable contain the new name of a renamed table.
+	NewSchema string
+	NewTable  string
+	// Query contains the full DDL statement.
+	Query string
+	// Position points to the DDL statement in the binlog.
+	Position ddl.MasterStatus
+	// Timestamp when the statement has been executed on the master.
+	Timestamp time.Time
+}
+
+// ddlLexer splits a DDL statement into words, identifiers, strings and
+// punctuation. Comments get skipped but the body of an executable comment like
+// `/*! ... */` or `/*!50100 ... */` gets lexed because MySQL executes it.
+type ddlLexer struct {
+	q   string
+	pos int
+	// inExec reports whether the lexer is within an executable comment.
+	inExec bool
+}
+
+// next returns the next token and whether it has been quoted as identifier or
+// string. An empty token marks the end.
+func (l *ddlLexer) next() (string, bool) {
+	for l.pos < len(l.q) {
+		switch c := l.q[l.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			l.pos++
+		case c == '#' || strings.HasPrefix(l.q[l.pos:], "-- "):
+			if i := strings.IndexByte(l.q[l.pos:], '\n'); i >= 0 {
+				l.pos += i + 1
+			} else {
+				l.pos = len(l.q)
+			}
+		case strings.HasPrefix(l.q[l.pos:], "/*!"):
+			// executable comment with an optional version number
+			l.pos += 3
+			for l.pos < len(l.q) && l.q[l.pos] >= '0' && l.q[l.pos] <= '9' {
+				l.pos++
+			}
+			l.inExec = true
+		case l.inExec && strings.HasPrefix(l.q[l.pos:], "*/"):
+			l.pos += 2
+			l.inExec = false
+		case strings.HasPrefix(l.q[l.pos:], "/*"):
+			if i := strings.Index(l.q[l.pos+2:], "*/"); i >= 0 {
+				l.pos += i + 4
+			} else {
+				l.pos = len(l.q)
+			}
+		case c == '`' || c == '"' || c == '\'':
+			var buf strings.Builder
+			for l.pos++; l.pos < len(l.q); l.pos++ {
+				if c != '`' && l.q[l.pos] == '\\' && l.pos+1 < len(l.q) { // escaped character in a string
+					l.pos++
+					buf.WriteByte(l.q[l.pos])
+					continue
+				}
+				if l.q[l.pos] == c {
+					if l.pos+1 < len(l.q) && l.q[l.pos+1] == c { // escaped quote
+						buf.WriteByte(c)
+						l.pos++
+						continue
+					}
+					l.pos++
+					break
+				}
+				buf.WriteByte(l.q[l.pos])
+			}
+			return buf.String(), true
+		case isIdentChar(c):
+			start := l.pos
+			for l.pos < len(l.q) && isIdentChar(l.q[l.pos]) {
+				l.pos++
+			}
+			return l.q[start:l.pos], false
+		default:
+			l.pos++
+			return l.q[l.pos-1 : l.pos], false
+		}
+	}
+	return "", false
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// word returns the next unquoted token in upper case.
+func (l *ddlLexer) word() string {
+	t, quoted := l.next()
+	if quoted {
+		return ""
+	}
+	return strings.ToUpper(t)
+}
+
+// peekWord returns the next word without consuming it.
+func (l *ddlLexer) peekWord() string {
+	pos := l.pos
+	w := l.word()
+	l.pos = pos
+	return w
+}
+
+// skipWords consumes the words if they follow in this order.
+func (l *ddlLexer) skipWords(words ...string) bool {
+	pos := l.pos
+	for _, w := range words {
+		if l.word() != w {
+			l.pos = pos
+			return false
+		}
+	}
+	return true
+}
+
+// tableName parses an optional schema qualified table name.
+func (l *ddlLexer) tableName(defaultSchema string) (schema, table string) {
+	t, _ := l.next()
+	pos := l.pos
+	if dot, _ := l.next(); dot == "." {
+		t2, _ := l.next()
+		return t, t2
+	}
+	l.pos = pos
+	return defaultSchema, t
+}
+
+// parseDDL extracts the affected tables of a DDL statement. Returns nil if the
+// query is not a DDL statement for a table. Temporary tables get ignored.
+func parseDDL(defaultSchema, query string) []SchemaChange {
+	l := &ddlLexer{q: query}
+	var scs []SchemaChange
+	add := func(action string) *SchemaChange {
+		scs = append(scs, SchemaChange{Action: action, Query: query})
+		sc := &scs[len(scs)-1]
+		sc.Schema, sc.Table = l.tableName(defaultSchema)
+		return sc
+	}
+
+	switch l.word() {
+	case "CREATE":
+		l.skipWords("OR", "REPLACE")
+		if !l.skipWords("TABLE") {
+			return nil // TEMPORARY, INDEX, VIEW, ...
+		}
+		l.skipWords("IF", "NOT", "EXISTS")
+		add(SchemaCreateAction)
+
+	case "ALTER":
+		l.skipWords("ONLINE")
+		l.skipWords("IGNORE")
+		if !l.skipWords("TABLE") {
+			return nil
+		}
+		l.skipWords("IF", "EXISTS")
+		sc := add(SchemaAlterAction)
+		// ALTER TABLE t RENAME [TO|AS] t2, but not RENAME COLUMN|INDEX|KEY
+		depth := 0
+		for t, quoted := l.next(); t != "" || quoted; t, quoted = l.next() {
+			switch {
+			case t == "(":
+				depth++
+			case t == ")":
+				depth--
+			case depth == 0 && !quoted && strings.EqualFold(t, "RENAME"):
+				switch l.peekWord() {
+				case "COLUMN", "INDEX", "KEY":
+					continue
+				}
+				if !l.skipWords("TO") {
+					l.skipWords("AS")
+				}
+				sc.Action = SchemaRenameAction
+				sc.NewSchema, sc.NewTable = l.tableName(sc.Schema)
+			}
+		}
+
+	case "RENAME":
+		if !l.skipWords("TABLE") {
+			return nil
+		}
+		for {
+			sc := add(SchemaRenameAction)
+			if !l.skipWords("TO") {
+				return nil // broken statement
+			}
+			sc.NewSchema, sc.NewTable = l.tableName(defaultSchema)
+			if t, _ := l.next(); t != "," {
+				break
+			}
+		}
+
+	case "DROP":
+		if !l.skipWords("TABLE") {
+			return nil // TEMPORARY, INDEX, VIEW, ...
+		}
+		l.skipWords("IF", "EXISTS")
+		for {
+			add(SchemaDropAction)
+			if t, _ := l.next(); t != "," {
+				break
+			}
+		}
+
+	case "TRUNCATE":
+		l.skipWords("TABLE")
+		add(SchemaTruncateAction)
+	}
+
+	for i := 0; i < len(scs); i++ {
+		if scs[i].Table == "" {
+			return nil // broken statement
+		}
+	}
+	return scs
+}
+
+// handleSchemaChange invalidates the table cache for the tables affected by a
+// DDL statement, adds the new column list to the schema history and
+// dispatches the change to the SchemaChangeHandler.
+func (c *Canal) handleSchemaChange(ctx context.Context, e *myreplicator.QueryEvent, h *myreplicator.EventHeader, pos ddl.MasterStatus) error {
+	for _, sc := range parseDDL(string(e.Schema), string(e.Query)) {
+		if sc.Schema != c.DSN.DBName && sc.NewSchema != c.DSN.DBName {
+			continue
+		}
+		sc.Position = pos
+		sc.Timestamp = time.Unix(int64(h.Timestamp), 0)
+
+		c.ClearTableCache(sc.Schema, sc.Table)
+		if sc.NewTable != "" {
+			c.ClearTableCache(sc.NewSchema, sc.NewTable)
+		}
+		if c.Log.IsInfo() {
+			c.Log.Info("[binlogsync] Table structure changed, clear table cache",
+				log.String("action", sc.Action), log.String("database", sc.Schema), log.String("table", sc.Table),
+				log.String("new_table", sc.NewTable), log.Stringer("position", pos))
+		}
+		c.applySchemaChange(ctx, sc)
+		if err := c.travelSchemaChange(ctx, sc); err != nil {
+			return errors.Wrap(err, "[binlogsync] handleSchemaChange.travelSchemaChange")
+		}
+	}
+	return nil
+}
+
+// applySchemaChange adds the column list after the DDL statement to the
+// schema history. The columns of the statement get applied to the last
+// version of the table. If the statement contains a clause which cannot be
+// applied, the columns get reloaded from the information_schema. A table
+// without a version gets loaded with the next rows event.
+func (c *Canal) applySchemaChange(ctx context.Context, sc SchemaChange) {
+	if sc.Schema != c.DSN.DBName {
+		// table moved into the synced database, columns unknown
+		c.schemas.drop(sc.NewTable)
+		return
+	}
+
+	switch sc.Action {
+	case SchemaCreateAction:
+		c.schemas.drop(sc.Table)
+		if cols, ok := applyDDL(nil, sc.Query); ok && len(cols) > 0 {
+			c.schemas.add(sc.Table, sc.Position, withColumns(ddl.Table{Name: sc.Table, Schema: sc.Schema}, cols))
+		}
+		return
+	case SchemaDropAction:
+		c.schemas.drop(sc.Table)
+		return
+	case SchemaTruncateAction:
+		return
+	}
+
+	t, ok := c.schemas.last(sc.Table)
+	if !ok {
+		c.schemas.drop(sc.NewTable)
+		return
+	}
+	if sc.Action == SchemaAlterAction || strings.HasPrefix(strings.ToUpper(strings.TrimSpace(sc.Query)), "ALTER") {
+		cols, ok := applyDDL(t.Columns, sc.Query)
+		if ok {
+			t = withColumns(t, cols)
+		} else {
+			c.ClearTableCache(sc.Schema, sc.Table)
+			nt, err := c.FindTable(ctx, sc.Table)
+			if err != nil {
+				if c.Log.IsInfo() {
+					c.Log.Info("[binlogsync] Failed to reload the table structure, loading with the next rows event",
+						log.Err(err), log.String("table", sc.Table), log.Stringer("position", sc.Position))
+				}
+				c.schemas.drop(sc.Table)
+				return
+			}
+			t = nt
+		}
+	}
+
+	switch {
+	case sc.NewTable == "":
+		c.schemas.add(sc.Table, sc.Position, t)
+	case sc.NewSchema != c.DSN.DBName:
+		c.schemas.drop(sc.Table)
+	default:
+		c.schemas.rename(sc.Table, sc.NewTable)
+		t.Name = sc.NewTable
+		c.schemas.add(sc.NewTable, sc.Position, t)
+	}
+}
+
+// tableVersion contains the columns of a table valid from the binlog position
+// of the DDL statement on.
+type tableVersion struct {
+	pos   ddl.MasterStatus
+	table ddl.Table
+}
+
+// schemaHistory keeps the column lists of the tables versioned by the binlog
+// position. The cached table of the information_schema reflects the current
+// structure of the database, which is newer than the rows events while the
+// Canal catches up behind a DDL statement.
+type schemaHistory struct {
+	tables map[string][]tableVersion
+}
+
+// at returns the version of the table valid at the binlog position. A
+// position before the first version returns the first version.
+func (sh *schemaHistory) at(table string, pos ddl.MasterStatus) (ddl.Table, bool) {
+	vs := sh.tables[table]
+	if len(vs) == 0 {
+		return ddl.Table{}, false
+	}
+	for i := len(vs) - 1; i > 0; i-- {
+		if vs[i].pos.Compare(pos) <= 0 {
+			return vs[i].table, true
+		}
+	}
+	return vs[0].table, true
+}
+
+// last returns the newest version of the table.
+func (sh *schemaHistory) last(table string) (ddl.Table, bool) {
+	vs := sh.tables[table]
+	if len(vs) == 0 {
+		return ddl.Table{}, false
+	}
+	return vs[len(vs)-1].table, true
+}
+
+// add appends a new version of the table valid from the position on.
+func (sh *schemaHistory) add(table string, pos ddl.MasterStatus, t ddl.Table) {
+	if sh.tables == nil {
+		sh.tables = make(map[string][]tableVersion)
+	}
+	sh.tables[table] = append(sh.tables[table], tableVersion{pos: pos, table: t})
+}
+
+// rename moves the versions of a table to the new name.
+func (sh *schemaHistory) rename(table, newTable string) {
+	vs := sh.tables[table]
+	delete(sh.tables, table)
+	if len(vs) > 0 {
+		sh.tables[newTable] = vs
+	} else {
+		delete(sh.tables, newTable)
+	}
+}
+
+// drop removes all versions of a table.
+func (sh *schemaHistory) drop(table string) {
+	delete(sh.tables, table)
+}
+
+// ddlToken contains a token of the lexer.
+type ddlToken struct {
+	s      string
+	quoted bool
+}
+
+// is reports whether the token is the unquoted word.
+func (t ddlToken) is(word string) bool {
+	return !t.quoted && strings.EqualFold(t.s, word)
+}
+
+// tableSpecs returns the comma separated column and table definitions of a
+// CREATE TABLE statement or the alter specifications of an ALTER TABLE
+// statement. Returns false if the statement does not define columns, for
+// example CREATE TABLE ... LIKE.
+func tableSpecs(query string) (specs [][]ddlToken, create bool, _ bool) {
+	l := &ddlLexer{q: query}
+	switch l.word() {
+	case "CREATE":
+		l.skipWords("OR", "REPLACE")
+		if !l.skipWords("TABLE") {
+			return nil, false, false
+		}
+		l.skipWords("IF", "NOT", "EXISTS")
+		l.tableName("")
+		if t, quoted := l.next(); t != "(" || quoted {
+			return nil, false, false
+		}
+		create = true
+	case "ALTER":
+		l.skipWords("ONLINE")
+		l.skipWords("IGNORE")
+		if !l.skipWords("TABLE") {
+			return nil, false, false
+		}
+		l.skipWords("IF", "EXISTS")
+		l.tableName("")
+	default:
+		return nil, false, false
+	}
+
+	var spec []ddlToken
+	depth := 0
+	for t, quoted := l.next(); t != "" || quoted; t, quoted = l.next() {
+		switch {
+		case quoted:
+		case t == "(":
+			depth++
+		case t == ")" && depth == 0 && create:
+			return append(specs, spec), true, true
+		case t == ")":
+			depth--
+		case t == "," && depth == 0:
+			specs = append(specs, spec)
+			spec = nil
+			continue
+		}
+		spec = append(spec, ddlToken{s: t, quoted: quoted})
+	}
+	if create {
+		return nil, false, false // broken statement
+	}
+	return append(specs, spec), false, true
+}
+
+// ddlTableOptions contains the first words of alter specifications which do
+// not change the column list.
+var ddlTableOptions = map[string]bool{
+	"ALGORITHM": true, "AUTO_INCREMENT": true, "AVG_ROW_LENGTH": true, "CHARACTER": true,
+	"CHARSET": true, "CHECKSUM": true, "COLLATE": true, "COMMENT": true, "DEFAULT": true,
+	"DISABLE": true, "ENABLE": true, "ENGINE": true, "FORCE": true, "KEY_BLOCK_SIZE": true,
+	"LOCK": true, "MAX_ROWS": true, "MIN_ROWS": true, "PACK_KEYS": true, "ROW_FORMAT": true,
+	"STATS_AUTO_RECALC": true, "STATS_PERSISTENT": true, "STATS_SAMPLE_PAGES": true,
+}
+
+// ddlIndexWords contains the first words of index and constraint definitions.
+var ddlIndexWords = map[string]bool{
+	"CHECK": true, "CONSTRAINT": true, "FOREIGN": true, "FULLTEXT": true, "INDEX": true,
+	"KEY": true, "PERIOD": true, "PRIMARY": true, "SPATIAL": true, "UNIQUE": true,
+}
+
+// applyDDL returns the columns after applying a CREATE TABLE or ALTER TABLE
+// statement to the columns. Supported are ADD, DROP, MODIFY, CHANGE and
+// RENAME COLUMN including FIRST and AFTER and primary keys. The columns
+// argument does not get modified. Returns false if the statement contains a
+// clause which cannot be applied, for example CONVERT TO CHARACTER SET,
+// partitioning or an unknown column.
+func applyDDL(columns ddl.Columns, query string) (ddl.Columns, bool) {
+	specs, create, ok := tableSpecs(query)
+	if !ok {
+		return nil, false
+	}
+	cols := make(ddl.Columns, len(columns))
+	for i, c := range columns {
+		cc := *c
+		cols[i] = &cc
+	}
+	for _, spec := range specs {
+		if cols, ok = applySpec(cols, spec, create); !ok {
+			return nil, false
+		}
+	}
+	for i, c := range cols {
+		c.Pos = uint64(i + 1)
+	}
+	return cols, true
+}
+
+// applySpec applies a single column definition of a CREATE TABLE statement
+// or an alter specification.
+func applySpec(cols ddl.Columns, spec []ddlToken, create bool) (ddl.Columns, bool) {
+	if len(spec) == 0 {
+		return cols, !create
+	}
+	word := func(i int) string {
+		if i < len(spec) && !spec[i].quoted {
+			return strings.ToUpper(spec[i].s)
+		}
+		return ""
+	}
+	if create {
+		if ddlIndexWords[word(0)] {
+			return applyIndex(cols, spec, true), true
+		}
+		c, pos, ok := parseColumnDef(spec)
+		if !ok || pos != nil {
+			return nil, false
+		}
+		return append(cols, c), true
+	}
+
+	i := 1
+	switch w := word(0); {
+	case w == "ADD":
+		if word(i) == "COLUMN" {
+			i++
+		}
+		if ddlIndexWords[word(i)] {
+			return applyIndex(cols, spec[i:], true), true
+		}
+		if i < len(spec) && spec[i].is("(") { // ADD COLUMN (a INT, b INT)
+			return nil, false
+		}
+		ifNotExists := word(i) == "IF" && word(i+1) == "NOT" && word(i+2) == "EXISTS"
+		if ifNotExists {
+			i += 3
+		}
+		c, pos, ok := parseColumnDef(spec[i:])
+		if !ok {
+			return nil, false
+		}
+		if columnIndex(cols, c.Field) >= 0 {
+			return cols, ifNotExists
+		}
+		return insertColumn(cols, len(cols), c, pos)
+
+	case w == "DROP":
+		if word(i) == "COLUMN" {
+			i++
+		} else if ddlIndexWords[word(i)] {
+			return applyIndex(cols, spec[i:], false), true
+		}
+		ifExists := word(i) == "IF" && word(i+1) == "EXISTS"
+		if ifExists {
+			i += 2
+		}
+		if i >= len(spec) {
+			return nil, false
+		}
+		idx := columnIndex(cols, spec[i].s)
+		if idx < 0 {
+			return cols, ifExists
+		}
+		return append(cols[:idx:idx], cols[idx+1:]...), true
+
+	case w == "MODIFY" || w == "CHANGE":
+		if word(i) == "COLUMN" {
+			i++
+		}
+		ifExists := word(i) == "IF" && word(i+1) == "EXISTS"
+		if ifExists {
+			i += 2
+		}
+		if i >= len(spec) {
+			return nil, false
+		}
+		idx := columnIndex(cols, spec[i].s)
+		if w == "CHANGE" {
+			i++
+		}
+		c, pos, ok := parseColumnDef(spec[i:])
+		if !ok {
+			return nil, false
+		}
+		if idx < 0 {
+			return cols, ifExists
+		}
+		if cols[idx].Key == "PRI" {
+			c.Key = "PRI"
+		}
+		cols = append(cols[:idx:idx], cols[idx+1:]...)
+		return insertColumn(cols, idx, c, pos)
+
+	case w == "RENAME":
+		switch word(i) {
+		case "COLUMN":
+			if len(spec) != 5 || word(3) != "TO" {
+				return nil, false
+			}
+			idx := columnIndex(cols, spec[2].s)
+			if idx < 0 {
+				return nil, false
+			}
+			cols[idx].Field = spec[4].s
+		}
+		// RENAME INDEX|KEY or RENAME [TO|AS] new_table
+		return cols, true
+
+	case w == "ALTER":
+		// ALTER [COLUMN] col SET|DROP DEFAULT, ALTER INDEX idx [NOT] VISIBLE
+		return cols, true
+
+	case ddlTableOptions[w]:
+		return cols, true
+	}
+	return nil, false
+}
+
+// applyIndex sets or removes the primary key flag of the columns. All other
+// indexes do not change the column list.
+func applyIndex(cols ddl.Columns, spec []ddlToken, add bool) ddl.Columns {
+	i := 0
+	if spec[i].is("CONSTRAINT") {
+		for i++; i < len(spec) && !spec[i].is("PRIMARY"); i++ {
+		}
+	}
+	if i+1 >= len(spec) || !spec[i].is("PRIMARY") || !spec[i+1].is("KEY") {
+		return cols
+	}
+	if !add {
+		for _, c := range cols {
+			if c.Key == "PRI" {
+				c.Key = ""
+			}
+		}
+		return cols
+	}
+	depth := 0
+	for _, t := range spec[i+2:] {
+		switch {
+		case t.is("("):
+			depth++
+		case t.is(")"):
+			depth--
+		case depth == 1:
+			if idx := columnIndex(cols, t.s); idx >= 0 {
+				cols[idx].Key = "PRI"
+			}
+		}
+	}
+	return cols
+}
+
+// insertColumn inserts the column at the index or at the position of the
+// FIRST or AFTER clause.
+func insertColumn(cols ddl.Columns, idx int, c *ddl.Column, pos []ddlToken) (ddl.Columns, bool) {
+	switch {
+	case len(pos) == 1 && pos[0].is("FIRST"):
+		idx = 0
+	case len(pos) == 2 && pos[0].is("AFTER"):
+		if idx = columnIndex(cols, pos[1].s); idx < 0 {
+			return nil, false
+		}
+		idx++
+	case len(pos) > 0:
+		return nil, false
+	}
+	cols = append(cols, nil)
+	copy(cols[idx+1:], cols[idx:])
+	cols[idx] = c
+	return cols, true
+}
+
+// ddlTypeAliases maps the type synonyms to the data type of the
+// information_schema.
+var ddlTypeAliases = map[string]string{
+	"integer": "int", "bool": "tinyint", "boolean": "tinyint", "dec": "decimal",
+	"numeric": "decimal", "fixed": "decimal", "real": "double", "character": "char",
+}
+
+// parseColumnDef parses a column name and its definition. Returns the tokens
+// of the FIRST or AFTER clause. Returns false if the definition is not valid.
+func parseColumnDef(spec []ddlToken) (_ *ddl.Column, pos []ddlToken, _ bool) {
+	if len(spec) < 2 || spec[1].quoted || spec[0].s == "" {
+		return nil, nil, false
+	}
+	c := &ddl.Column{Field: spec[0].s, Null: "YES"}
+	c.DataType = strings.ToLower(spec[1].s)
+	if dt, ok := ddlTypeAliases[c.DataType]; ok {
+		c.DataType = dt
+	}
+	i := 2
+	if c.DataType == "double" && i < len(spec) && spec[i].is("PRECISION") {
+		i++
+	}
+	var ct strings.Builder
+	ct.WriteString(c.DataType)
+	if i < len(spec) && spec[i].is("(") {
+		for ; i < len(spec); i++ {
+			t := spec[i]
+			if t.quoted {
+				ct.WriteString("'" + strings.Replace(t.s, "'", "''", -1) + "'")
+			} else {
+				ct.WriteString(strings.ToLower(t.s))
+			}
+			if t.is(")") {
+				i++
+				break
+			}
+		}
+	}
+	for ; i < len(spec); i++ {
+		switch t := spec[i]; {
+		case t.is("UNSIGNED") || t.is("ZEROFILL"):
+			ct.WriteString(" " + strings.ToLower(t.s))
+		case t.is("NOT") && i+1 < len(spec) && spec[i+1].is("NULL"):
+			c.Null = "NO"
+			i++
+		case t.is("PRIMARY") && i+1 < len(spec) && spec[i+1].is("KEY"):
+			c.Key = "PRI"
+			c.Null = "NO"
+			i++
+		case t.is("AUTO_INCREMENT"):
+			c.Extra = "auto_increment"
+		case t.is("FIRST") || t.is("AFTER"):
+			pos = spec[i:]
+			i = len(spec)
+		}
+	}
+	c.ColumnType = ct.String()
+	return c, pos, true
+}
+
+// columnIndex returns the index of the column, compared case-insensitive like
+// MySQL does, or -1.
+func columnIndex(cols ddl.Columns, name string) int {
+	for i, c := range cols {
+		if strings.EqualFold(c.Field, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// mysqlDataTypes maps the column types of the binlog to the possible
+// information_schema data types.
+var mysqlDataTypes = map[byte][]string{
+	gomysql.MYSQL_TYPE_TINY:        {"tinyint", "bool", "boolean"},
+	gomysql.MYSQL_TYPE_SHORT:       {"smallint"},
+	gomysql.MYSQL_TYPE_INT24:       {"mediumint"},
+	gomysql.MYSQL_TYPE_LONG:        {"int", "integer"},
+	gomysql.MYSQL_TYPE_LONGLONG:    {"bigint"},
+	gomysql.MYSQL_TYPE_FLOAT:       {"float"},
+	gomysql.MYSQL_TYPE_DOUBLE:      {"double", "real"},
+	gomysql.MYSQL_TYPE_DECIMAL:     {"decimal", "numeric"},
+	gomysql.MYSQL_TYPE_NEWDECIMAL:  {"decimal", "numeric"},
+	gomysql.MYSQL_TYPE_YEAR:        {"year"},
+	gomysql.MYSQL_TYPE_DATE:        {"date"},
+	gomysql.MYSQL_TYPE_NEWDATE:     {"date"},
+	gomysql.MYSQL_TYPE_TIME:        {"time"},
+	gomysql.MYSQL_TYPE_TIME2:       {"time"},
+	gomysql.MYSQL_TYPE_DATETIME:    {"datetime"},
+	gomysql.MYSQL_TYPE_DATETIME2:   {"datetime"},
+	gomysql.MYSQL_TYPE_TIMESTAMP:   {"timestamp"},
+	gomysql.MYSQL_TYPE_TIMESTAMP2:  {"timestamp"},
+	gomysql.MYSQL_TYPE_VARCHAR:     {"varchar", "varbinary"},
+	gomysql.MYSQL_TYPE_VAR_STRING:  {"varchar", "varbinary"},
+	gomysql.MYSQL_TYPE_STRING:      {"char", "binary", "enum", "set"},
+	gomysql.MYSQL_TYPE_ENUM:        {"enum"},
+	gomysql.MYSQL_TYPE_SET:         {"set"},
+	gomysql.MYSQL_TYPE_BIT:         {"bit"},
+	gomysql.MYSQL_TYPE_JSON:        {"json", "longtext"}, // MariaDB stores JSON as longtext
+	gomysql.MYSQL_TYPE_BLOB:        {"tinyblob", "blob", "mediumblob", "longblob", "tinytext", "text", "mediumtext", "longtext"},
+	gomysql.MYSQL_TYPE_TINY_BLOB:   {"tinyblob", "tinytext"},
+	gomysql.MYSQL_TYPE_MEDIUM_BLOB: {"mediumblob", "mediumtext"},
+	gomysql.MYSQL_TYPE_LONG_BLOB:   {"longblob", "longtext"},
+	gomysql.MYSQL_TYPE_GEOMETRY:    {"geometry", "point", "linestring", "polygon", "multipoint", "multilinestring", "multipolygon", "geometrycollection"},
+}
+
+// columnTypesMatch reports whether the binlog column types fit to the columns.
+// Unknown types and columns without a data type match always.
+func columnTypesMatch(types []byte, cols ddl.Columns) bool {
+	for i, c := range cols {
+		if i >= len(types) {
+			return false
+		}
+		dts, ok := mysqlDataTypes[types[i]]
+		if !ok || c.DataType == "" {
+			continue
+		}
+		var found bool
+		for _, dt := range dts {
+			found = found || dt == c.DataType
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// matchColumns checks the column list of the table version against the rows
+// event. The first version of a table gets loaded from the information_schema
+// and might be newer than the event while the Canal catches up behind a DDL
+// statement. If the event has fewer columns and their types match the leading
+// columns of the table, e.g. after ADD COLUMN, the table gets truncated to
+// those columns. Any other mismatch returns a NotValid error, because the
+// values cannot be assigned to the columns without guessing.
+func matchColumns(tme *myreplicator.TableMapEvent, t ddl.Table) (ddl.Table, error) {
+	n := int(tme.ColumnCount)
+	switch {
+	case n == len(t.Columns) && columnTypesMatch(tme.ColumnType, t.Columns):
+		return t, nil
+	case n < len(t.Columns) && columnTypesMatch(tme.ColumnType, t.Columns[:n]):
+		return withColumns(t, t.Columns[:n]), nil
+	}
+	return ddl.Table{}, errors.NewNotValidf("[binlogsync] Table %q: The binlog rows event with %d columns does not match the %d cached columns %v",
+		t.Name, n, len(t.Columns), t.Columns.FieldNames())
+}
+
+// withColumns returns a copy of the table with the columns.
+func withColumns(t ddl.Table, cols ddl.Columns) ddl.Table {
+	nt := ddl.NewTable(t.Name, cols...)
+	nt.DB = t.DB
+	nt.Schema = t.Schema
+	nt.IsView = t.IsView
+	return *nt
+}
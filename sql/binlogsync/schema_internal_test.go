@@ -0,0 +1,271 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/myreplicator"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDDL(t *testing.T) {
+	sc := func(action, schema, table, newSchema, newTable string) SchemaChange {
+		return SchemaChange{Action: action, Schema: schema, Table: table, NewSchema: newSchema, NewTable: newTable}
+	}
+	tests := []struct {
+		query string
+		want  []SchemaChange
+	}{
+		{"ALTER TABLE `catalog_product_entity` ADD COLUMN `sku2` varchar(64)", []SchemaChange{sc("alter", "db", "catalog_product_entity", "", "")}},
+		{"alter table shop.`customer` drop column email", []SchemaChange{sc("alter", "shop", "customer", "", "")}},
+		{"/* deploy 42 */ ALTER ONLINE IGNORE TABLE IF EXISTS `a``b` ADD x INT", []SchemaChange{sc("alter", "db", "a`b", "", "")}},
+		{"ALTER TABLE t1 RENAME COLUMN a TO b, ADD INDEX idx (c)", []SchemaChange{sc("alter", "db", "t1", "", "")}},
+		{"ALTER TABLE t1 ADD x INT, RENAME TO `other`.`t2`", []SchemaChange{sc("rename", "db", "t1", "other", "t2")}},
+		{"ALTER TABLE t1 RENAME AS t2", []SchemaChange{sc("rename", "db", "t1", "db", "t2")}},
+		{"RENAME TABLE a TO b, `x`.`c` TO `x`.d", []SchemaChange{sc("rename", "db", "a", "db", "b"), sc("rename", "x", "c", "x", "d")}},
+		{"DROP TABLE IF EXISTS `a`, b /* generated by server */", []SchemaChange{sc("drop", "db", "a", "", ""), sc("drop", "db", "b", "", "")}},
+		{"/*!40000 ALTER TABLE `catalog_product_entity` DISABLE KEYS */", []SchemaChange{sc("alter", "db", "catalog_product_entity", "", "")}},
+		{"DROP TABLE /*!50100 IF EXISTS */ `a` /* b */", []SchemaChange{sc("drop", "db", "a", "", "")}},
+		{"CREATE /*! TEMPORARY */ TABLE n (id int)", nil},
+		{"RENAME TABLE a TO /*!*/ b", []SchemaChange{sc("rename", "db", "a", "db", "b")}},
+		{"DROP TEMPORARY TABLE a", nil},
+		{"CREATE TABLE IF NOT EXISTS `n` (id int) ENGINE=InnoDB", []SchemaChange{sc("create", "db", "n", "", "")}},
+		{"CREATE OR REPLACE TABLE s.n LIKE s.o", []SchemaChange{sc("create", "s", "n", "", "")}},
+		{"CREATE TEMPORARY TABLE n (id int)", nil},
+		{"CREATE INDEX i ON t (a)", nil},
+		{"TRUNCATE TABLE `sales_order`", []SchemaChange{sc("truncate", "db", "sales_order", "", "")}},
+		{"-- comment\nTRUNCATE log", []SchemaChange{sc("truncate", "db", "log", "", "")}},
+		{"INSERT INTO t VALUES (1)", nil},
+		{"ALTER TABLE", nil},
+		{"RENAME TABLE a", nil},
+	}
+	for _, test := range tests {
+		have := parseDDL("db", test.query)
+		for i := range have {
+			assert.Exactly(t, test.query, have[i].Query)
+			have[i].Query = ""
+		}
+		assert.Exactly(t, test.want, have, "Query: %q", test.query)
+	}
+}
+
+type recordingSchemaHandler struct {
+	recordingHandler
+	changes []SchemaChange
+}
+
+func (rh *recordingSchemaHandler) DoSchemaChange(_ context.Context, sc SchemaChange) error {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	rh.changes = append(rh.changes, sc)
+	return nil
+}
+
+func TestCanal_HandleEvent_SchemaChange(t *testing.T) {
+	c := newEventTestCanal()
+	require.NoError(t, c.tables.Options(ddl.WithTable("sales_order", &ddl.Column{Field: "entity_id"})))
+	sh := new(recordingSchemaHandler)
+	filtered := new(recordingSchemaHandler)
+	c.RegisterRowsEventHandler(sh)
+	c.RegisterFilteredRowsEventHandler(filtered, MustNewFilter(IncludeTables("sales_*")))
+
+	pos := ddl.MasterStatus{File: "mysql-bin.000003", Position: 815}
+	for _, q := range []string{
+		"ALTER TABLE `catalog_product_entity` ADD COLUMN `sku` varchar(64)",
+		"ALTER TABLE `OtherDB`.`sales_order` ADD COLUMN `note` text",
+	} {
+		savePos, err := c.handleEvent(context.Background(), queryEvent(q), &pos)
+		require.NoError(t, err)
+		assert.True(t, savePos)
+	}
+
+	_, err := c.tables.Table("catalog_product_entity")
+	assert.True(t, errors.IsNotFound(err), "table cache must be cleared: %+v", err)
+	_, err = c.tables.Table("sales_order")
+	assert.NoError(t, err, "table of another database must stay in the cache")
+
+	require.Len(t, sh.changes, 1)
+	assert.Exactly(t, SchemaAlterAction, sh.changes[0].Action)
+	assert.Exactly(t, "TestDB", sh.changes[0].Schema)
+	assert.Exactly(t, "catalog_product_entity", sh.changes[0].Table)
+	assert.Exactly(t, pos, sh.changes[0].Position)
+	assert.Empty(t, filtered.changes)
+}
+
+func TestApplyDDL(t *testing.T) {
+	base := ddl.Columns{
+		{Field: "entity_id", DataType: "int", ColumnType: "int(10) unsigned", Key: "PRI"},
+		{Field: "sku", DataType: "varchar", ColumnType: "varchar(64)"},
+		{Field: "price", DataType: "decimal", ColumnType: "decimal(12,4)"},
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"ALTER TABLE t ADD COLUMN `name` varchar(255) NOT NULL DEFAULT 'a, b' AFTER entity_id",
+			[]string{"entity_id int(10) unsigned PRI", "name varchar(255) ", "sku varchar(64) ", "price decimal(12,4) "}},
+		{"ALTER TABLE t ADD note TEXT FIRST, ENGINE=InnoDB, COMMENT 'x,y'",
+			[]string{"note text ", "entity_id int(10) unsigned PRI", "sku varchar(64) ", "price decimal(12,4) "}},
+		{"ALTER TABLE t DROP COLUMN sku, DROP INDEX idx_sku",
+			[]string{"entity_id int(10) unsigned PRI", "price decimal(12,4) "}},
+		{"ALTER TABLE t MODIFY price double precision FIRST",
+			[]string{"price double ", "entity_id int(10) unsigned PRI", "sku varchar(64) "}},
+		{"ALTER TABLE t CHANGE COLUMN sku code bigint(20) unsigned AFTER price",
+			[]string{"entity_id int(10) unsigned PRI", "price decimal(12,4) ", "code bigint(20) unsigned "}},
+		{"ALTER TABLE t MODIFY entity_id integer NOT NULL AUTO_INCREMENT",
+			[]string{"entity_id int PRI", "sku varchar(64) ", "price decimal(12,4) "}},
+		{"ALTER TABLE t RENAME COLUMN sku TO code, ADD INDEX idx_code (code), ALTER COLUMN price SET DEFAULT 0",
+			[]string{"entity_id int(10) unsigned PRI", "code varchar(64) ", "price decimal(12,4) "}},
+		{"ALTER TABLE t DROP PRIMARY KEY, ADD PRIMARY KEY (sku)",
+			[]string{"entity_id int(10) unsigned ", "sku varchar(64) PRI", "price decimal(12,4) "}},
+		{"ALTER TABLE t ADD COLUMN IF NOT EXISTS sku text, DROP COLUMN IF EXISTS name",
+			[]string{"entity_id int(10) unsigned PRI", "sku varchar(64) ", "price decimal(12,4) "}},
+		{"ALTER TABLE t ADD `status` enum('a','b') AFTER sku",
+			[]string{"entity_id int(10) unsigned PRI", "sku varchar(64) ", "status enum('a','b') ", "price decimal(12,4) "}},
+		{"CREATE TABLE n (`id` int unsigned NOT NULL AUTO_INCREMENT, note text, PRIMARY KEY (`id`), KEY idx_note (note(10))) ENGINE=InnoDB",
+			[]string{"id int unsigned PRI", "note text "}},
+		{"ALTER TABLE t CONVERT TO CHARACTER SET utf8mb4", nil},
+		{"ALTER TABLE t DROP COLUMN name", nil},
+		{"ALTER TABLE t ADD name text AFTER missing", nil},
+		{"ALTER TABLE t ADD (a int, b int)", nil},
+		{"ALTER TABLE t PARTITION BY HASH(entity_id)", nil},
+		{"CREATE TABLE n LIKE o", nil},
+		{"CREATE TABLE n AS SELECT * FROM o", nil},
+	}
+	for _, test := range tests {
+		cols := base
+		if strings.HasPrefix(test.query, "CREATE") {
+			cols = nil
+		}
+		have, ok := applyDDL(cols, test.query)
+		if test.want == nil {
+			assert.False(t, ok, "Query: %q", test.query)
+			continue
+		}
+		require.True(t, ok, "Query: %q", test.query)
+		var haveCols []string
+		for i, c := range have {
+			haveCols = append(haveCols, c.Field+" "+c.ColumnType+" "+c.Key)
+			assert.Exactly(t, uint64(i+1), c.Pos, "Query: %q", test.query)
+		}
+		assert.Exactly(t, test.want, haveCols, "Query: %q", test.query)
+	}
+	assert.Exactly(t, []string{"entity_id", "sku", "price"}, base.FieldNames(), "columns must not be modified")
+	assert.Exactly(t, "PRI", base[0].Key, "columns must not be modified")
+}
+
+func TestCanal_SchemaHistory(t *testing.T) {
+	c := newEventTestCanal()
+	ctx := context.Background()
+	rows := func(types ...byte) *myreplicator.BinlogEvent {
+		return &myreplicator.BinlogEvent{
+			Header: &myreplicator.EventHeader{EventType: myreplicator.WRITE_ROWS_EVENTv2},
+			Event: &myreplicator.RowsEvent{
+				Table: &myreplicator.TableMapEvent{
+					Schema: []byte("TestDB"), Table: []byte("catalog_product_entity"),
+					ColumnCount: uint64(len(types)), ColumnType: types,
+				},
+			},
+		}
+	}
+	fieldsAt := func(position uint, ev *myreplicator.BinlogEvent) []string {
+		rev, ok, err := c.newRowsEvent(ctx, ev, ddl.MasterStatus{File: "mysql-bin.000003", Position: position})
+		require.NoError(t, err)
+		require.True(t, ok)
+		return rev.Table.Columns.FieldNames()
+	}
+	query := func(position uint, q string) {
+		pos := ddl.MasterStatus{File: "mysql-bin.000003", Position: position}
+		_, err := c.handleEvent(ctx, queryEvent(q), &pos)
+		require.NoError(t, err)
+	}
+
+	assert.Exactly(t, []string{"entity_id"}, fieldsAt(100, rows(gomysql.MYSQL_TYPE_LONGLONG)))
+
+	query(200, "ALTER TABLE `catalog_product_entity` ADD COLUMN `sku` varchar(64) AFTER entity_id, ADD `qty` int FIRST")
+	assert.Exactly(t, []string{"qty", "entity_id", "sku"},
+		fieldsAt(300, rows(gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_LONGLONG, gomysql.MYSQL_TYPE_VARCHAR)))
+
+	query(400, "ALTER TABLE catalog_product_entity DROP COLUMN entity_id, MODIFY qty decimal(12,4) AFTER sku")
+	assert.Exactly(t, []string{"sku", "qty"}, fieldsAt(500, rows(gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_NEWDECIMAL)))
+
+	// events get decoded with the version valid at their position
+	assert.Exactly(t, []string{"entity_id"}, fieldsAt(150, rows(gomysql.MYSQL_TYPE_LONGLONG)))
+	assert.Exactly(t, []string{"qty", "entity_id", "sku"},
+		fieldsAt(399, rows(gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_LONGLONG, gomysql.MYSQL_TYPE_VARCHAR)))
+
+	// type changed, the old column list does not match anymore
+	query(600, "ALTER TABLE catalog_product_entity MODIFY qty int")
+	_, _, err := c.newRowsEvent(ctx, rows(gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_NEWDECIMAL), ddl.MasterStatus{File: "mysql-bin.000003", Position: 700})
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+	assert.Exactly(t, []string{"sku", "qty"}, fieldsAt(700, rows(gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_LONG)))
+
+	query(800, "RENAME TABLE catalog_product_entity TO cpe")
+	_, ok := c.schemas.last("catalog_product_entity")
+	assert.False(t, ok)
+	cpe, ok := c.schemas.last("cpe")
+	require.True(t, ok)
+	assert.Exactly(t, "cpe", cpe.Name)
+	assert.Exactly(t, []string{"sku", "qty"}, cpe.Columns.FieldNames())
+
+	query(900, "CREATE TABLE catalog_product_entity (entity_id int NOT NULL, type_id varchar(32), PRIMARY KEY (entity_id))")
+	assert.Exactly(t, []string{"entity_id", "type_id"}, fieldsAt(1000, rows(gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_VARCHAR)))
+
+	// CONVERT TO cannot be applied, the reload from the information_schema
+	// fails and the table gets loaded with the next rows event.
+	query(1100, "ALTER TABLE cpe CONVERT TO CHARACTER SET utf8mb4")
+	_, ok = c.schemas.last("cpe")
+	assert.False(t, ok)
+
+	query(1200, "DROP TABLE catalog_product_entity")
+	_, ok = c.schemas.last("catalog_product_entity")
+	assert.False(t, ok)
+}
+
+func TestMatchColumns(t *testing.T) {
+	tbl := *ddl.NewTable("customer",
+		&ddl.Column{Field: "entity_id", DataType: "int"},
+		&ddl.Column{Field: "email", DataType: "varchar"},
+		&ddl.Column{Field: "note", DataType: "text"},
+	)
+	tme := func(types ...byte) *myreplicator.TableMapEvent {
+		return &myreplicator.TableMapEvent{ColumnCount: uint64(len(types)), ColumnType: types}
+	}
+
+	have, err := matchColumns(tme(gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_BLOB), tbl)
+	require.NoError(t, err)
+	assert.Exactly(t, []string{"entity_id", "email", "note"}, have.Columns.FieldNames())
+
+	// event written before ALTER TABLE customer ADD COLUMN note
+	have, err = matchColumns(tme(gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_VARCHAR), tbl)
+	require.NoError(t, err)
+	assert.Exactly(t, []string{"entity_id", "email"}, have.Columns.FieldNames())
+	assert.Len(t, tbl.Columns, 3, "cached table must not be modified")
+
+	// event written before a column has been added in the middle
+	_, err = matchColumns(tme(gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_BLOB), tbl)
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+
+	// event written before a column has been dropped
+	_, err = matchColumns(tme(gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_BLOB, gomysql.MYSQL_TYPE_LONG), tbl)
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+}
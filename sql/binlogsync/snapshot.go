@@ -0,0 +1,282 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+)
+
+// Snapshot configures the initial snapshot of existing rows before the binlog
+// streaming starts.
+type Snapshot struct {
+	// Tables contains the names of the tables whose rows get dispatched.
+	Tables []string
+	// BatchSize defines the maximum number of rows per dispatched rows event.
+	// Default 1000.
+	BatchSize int
+	// NoGlobalLock skips FLUSH TABLES WITH READ LOCK, which requires the
+	// RELOAD privilege and blocks all writes for a short moment. Without the
+	// lock the binlog position gets recorded right before the consistent
+	// snapshot transaction starts, so changes committed in between get
+	// dispatched twice: as snapshot rows and as binlog events.
+	NoGlobalLock bool
+}
+
+// WithSnapshot dispatches all existing rows of the tables with action
+// SnapshotAction before the binlog streaming starts. The rows get read within
+// a consistent snapshot transaction and the streaming continues at the binlog
+// position of the snapshot, so no change gets lost. The snapshot gets skipped
+// if the Canal resumes from a checkpoint. The snapshot rows contain the values
+// as returned by the database driver, mostly []byte.
+func WithSnapshot(s Snapshot) Option {
+	return func(c *Canal) error {
+		if s.BatchSize < 1 {
+			s.BatchSize = 1000
+		}
+		c.snapshot = &s
+		return nil
+	}
+}
+
+// runSnapshot executes the snapshot on a dedicated connection and updates the
+// master status to the position of the snapshot.
+func (c *Canal) runSnapshot(ctx context.Context) (err error) {
+	conn, err := c.db.Conn(ctx)
+	if err != nil {
+		return errors.Wrap(err, "[binlogsync] runSnapshot.Conn")
+	}
+	defer conn.Close()
+
+	var locked, inTx bool
+	defer func() {
+		// The session state survives when the connection returns to the pool.
+		if locked {
+			_, _ = conn.ExecContext(context.Background(), "UNLOCK TABLES")
+		}
+		if inTx {
+			_, _ = conn.ExecContext(context.Background(), "ROLLBACK")
+		}
+	}()
+
+	exec := func(query string) error {
+		_, err := conn.ExecContext(ctx, query)
+		return errors.Wrapf(err, "[binlogsync] runSnapshot %q", query)
+	}
+
+	var ms ddl.MasterStatus
+	if !c.snapshot.NoGlobalLock {
+		if err := exec("FLUSH TABLES WITH READ LOCK"); err != nil {
+			return err
+		}
+		locked = true
+	} else if _, err := dml.Load(ctx, conn, &ms, &ms); err != nil {
+		return errors.Wrap(err, "[binlogsync] runSnapshot.ShowMasterStatus")
+	}
+
+	if err := exec("SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+		return err
+	}
+	if err := exec("START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+		return err
+	}
+	inTx = true
+
+	if locked {
+		if _, err := dml.Load(ctx, conn, &ms, &ms); err != nil {
+			return errors.Wrap(err, "[binlogsync] runSnapshot.ShowMasterStatus")
+		}
+		if err := exec("UNLOCK TABLES"); err != nil {
+			return err
+		}
+		locked = false
+	}
+
+	if c.Log.IsInfo() {
+		c.Log.Info("[binlogsync] Snapshot started", log.Stringer("position", ms), log.Strings("tables", c.snapshot.Tables...))
+	}
+
+	for _, tn := range c.snapshot.Tables {
+		if err := c.snapshotTable(ctx, conn, tn, ms); err != nil {
+			return errors.Wrapf(err, "[binlogsync] runSnapshot table %q", tn)
+		}
+	}
+
+	if err := exec("COMMIT"); err != nil {
+		return err
+	}
+	inTx = false
+
+	c.masterUpdate(ms)
+	if err := c.masterSave(ctx, true); err != nil {
+		return errors.Wrap(err, "[binlogsync] runSnapshot.masterSave")
+	}
+	if c.Log.IsInfo() {
+		c.Log.Info("[binlogsync] Snapshot finished", log.Stringer("position", ms))
+	}
+	return nil
+}
+
+// snapshotTable dispatches all rows of a table in batches to the handlers. The
+// values have the same types as the values of a binlog rows event.
+func (c *Canal) snapshotTable(ctx context.Context, conn *sql.Conn, tableName string, ms ddl.MasterStatus) error {
+	t, err := c.FindTable(ctx, tableName)
+	if err != nil {
+		return errors.Wrap(err, "[binlogsync] snapshotTable.FindTable")
+	}
+
+	sqlStr, _, err := dml.NewSelect(t.Columns.FieldNames()...).From(t.Name).ToSQL()
+	if err != nil {
+		return errors.Wrap(err, "[binlogsync] snapshotTable.ToSQL")
+	}
+	rows, err := conn.QueryContext(ctx, sqlStr)
+	if err != nil {
+		return errors.Wrapf(err, "[binlogsync] snapshotTable.Query %q", sqlStr)
+	}
+	defer rows.Close()
+
+	dispatch := func(batch [][]interface{}) error {
+		if len(batch) == 0 {
+			return nil
+		}
+		return c.travelTransaction(ctx, Transaction{
+			Position: ms,
+			Events:   []RowsEvent{{Action: SnapshotAction, Table: t, Rows: batch, Timestamp: time.Now()}},
+		})
+	}
+
+	colCount := len(t.Columns)
+	batch := make([][]interface{}, 0, c.snapshot.BatchSize)
+	for rows.Next() {
+		row := make([]interface{}, colCount)
+		ptrs := make([]interface{}, colCount)
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return errors.Wrap(err, "[binlogsync] snapshotTable.Scan")
+		}
+		for i, c := range t.Columns {
+			if row[i], err = snapshotValue(c, row[i]); err != nil {
+				return errors.Wrapf(err, "[binlogsync] snapshotTable %q column %q", t.Name, c.Field)
+			}
+		}
+		batch = append(batch, row)
+		if len(batch) == c.snapshot.BatchSize {
+			if err := dispatch(batch); err != nil {
+				return err
+			}
+			batch = make([][]interface{}, 0, c.snapshot.BatchSize)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "[binlogsync] snapshotTable.Rows")
+	}
+	return dispatch(batch)
+}
+
+// snapshotValue converts a value of the text protocol into the Go type of the
+// binlog rows event decoder, so a handler receives the same types for snapshot
+// and binlog rows. Integers have the size of the column type and unsigned
+// values wrap like in the binlog. DECIMAL gets converted to float64, ENUM and
+// SET to their index respective bit mask, BIT to int64. Strings and temporal
+// types become a string, BLOB, TEXT and JSON stay []byte. Values of columns
+// without a data type do not get converted.
+func snapshotValue(c *ddl.Column, v interface{}) (interface{}, error) {
+	b, ok := v.([]byte)
+	if !ok || c.DataType == "" {
+		return v, nil
+	}
+	s := string(b)
+	switch c.DataType {
+	case "tinyint", "bool", "boolean":
+		i, err := parseSnapshotInt(s, 8, c.IsUnsigned())
+		return int8(i), err
+	case "smallint":
+		i, err := parseSnapshotInt(s, 16, c.IsUnsigned())
+		return int16(i), err
+	case "mediumint", "int", "integer":
+		i, err := parseSnapshotInt(s, 32, c.IsUnsigned())
+		return int32(i), err
+	case "bigint":
+		return parseSnapshotInt(s, 64, c.IsUnsigned())
+	case "year":
+		i, err := strconv.Atoi(s)
+		return i, errors.WithStack(err)
+	case "float":
+		f, err := strconv.ParseFloat(s, 32)
+		return float32(f), errors.WithStack(err)
+	case "double", "real", "decimal", "numeric":
+		f, err := strconv.ParseFloat(s, 64)
+		return f, errors.WithStack(err)
+	case "bit":
+		var i int64
+		for _, c := range b {
+			i = i<<8 | int64(c)
+		}
+		return i, nil
+	case "enum":
+		for i, ev := range enumValues(c.ColumnType) {
+			if ev == s {
+				return int64(i + 1), nil
+			}
+		}
+		return int64(0), nil
+	case "set":
+		var mask int64
+		evs := enumValues(c.ColumnType)
+		for _, sv := range strings.Split(s, ",") {
+			for i, ev := range evs {
+				if ev == sv {
+					mask |= 1 << uint(i)
+				}
+			}
+		}
+		return mask, nil
+	case "char", "varchar", "binary", "varbinary", "date", "time", "datetime", "timestamp":
+		return s, nil
+	}
+	return b, nil
+}
+
+func parseSnapshotInt(s string, bitSize int, unsigned bool) (int64, error) {
+	if unsigned {
+		u, err := strconv.ParseUint(s, 10, bitSize)
+		return int64(u), errors.WithStack(err)
+	}
+	i, err := strconv.ParseInt(s, 10, bitSize)
+	return i, errors.WithStack(err)
+}
+
+// enumValues returns the values of the column type enum('a','b') or
+// set('a','b').
+func enumValues(columnType string) []string {
+	l := &ddlLexer{q: columnType}
+	var vals []string
+	for t, quoted := l.next(); t != "" || quoted; t, quoted = l.next() {
+		if quoted {
+			vals = append(vals, t)
+		}
+	}
+	return vals
+}
@@ -0,0 +1,206 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryCheckpoint struct {
+	ms ddl.MasterStatus
+}
+
+func (mc *memoryCheckpoint) LoadCheckpoint(context.Context) (ddl.MasterStatus, error) {
+	if mc.ms.File == "" {
+		return ddl.MasterStatus{}, errors.NewNotFoundf("checkpoint not found")
+	}
+	return mc.ms, nil
+}
+
+func (mc *memoryCheckpoint) SaveCheckpoint(_ context.Context, ms ddl.MasterStatus) error {
+	mc.ms = ms
+	return nil
+}
+
+func expectSnapshotExec(dbMock sqlmock.Sqlmock, queries ...string) {
+	for _, q := range queries {
+		dbMock.ExpectExec(regexp.QuoteMeta(q)).WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+}
+
+func TestCanal_runSnapshot(t *testing.T) {
+	db, dbMock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	masterRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}).
+			AddRow("mysql-bin.000004", 815, "", "", "")
+	}
+
+	t.Run("global lock", func(t *testing.T) {
+		c := newEventTestCanal()
+		c.db = db
+		cp := new(memoryCheckpoint)
+		c.checkpoint = cp
+		require.NoError(t, WithSnapshot(Snapshot{Tables: []string{"catalog_product_entity"}, BatchSize: 2})(c))
+		th := new(recordingTxHandler)
+		c.RegisterRowsEventHandler(th)
+
+		expectSnapshotExec(dbMock, "FLUSH TABLES WITH READ LOCK", "SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ", "START TRANSACTION WITH CONSISTENT SNAPSHOT")
+		dbMock.ExpectQuery(regexp.QuoteMeta("SHOW MASTER STATUS")).WillReturnRows(masterRows())
+		expectSnapshotExec(dbMock, "UNLOCK TABLES")
+		dbMock.ExpectQuery(regexp.QuoteMeta("SELECT `entity_id` FROM `catalog_product_entity`")).
+			WillReturnRows(sqlmock.NewRows([]string{"entity_id"}).AddRow(1).AddRow(2).AddRow(3))
+		expectSnapshotExec(dbMock, "COMMIT")
+
+		require.NoError(t, c.runSnapshot(context.Background()))
+		require.NoError(t, dbMock.ExpectationsWereMet())
+
+		wantPos := ddl.MasterStatus{File: "mysql-bin.000004", Position: 815}
+		require.Len(t, th.txs, 2)
+		assert.Exactly(t, SnapshotAction, th.txs[0].Events[0].Action)
+		assert.Exactly(t, [][]interface{}{{int64(1)}, {int64(2)}}, th.txs[0].Events[0].Rows)
+		assert.Exactly(t, [][]interface{}{{int64(3)}}, th.txs[1].Events[0].Rows)
+		assert.Exactly(t, wantPos, th.txs[1].Position)
+		assert.Exactly(t, wantPos.String(), c.SyncedPosition().String())
+		assert.Exactly(t, wantPos.String(), cp.ms.String(), "snapshot position must be saved")
+	})
+
+	t.Run("no global lock", func(t *testing.T) {
+		c := newEventTestCanal()
+		c.db = db
+		require.NoError(t, WithSnapshot(Snapshot{Tables: []string{"catalog_product_entity"}, NoGlobalLock: true})(c))
+		rh := new(recordingHandler)
+		c.RegisterRowsEventHandler(rh)
+
+		dbMock.ExpectQuery(regexp.QuoteMeta("SHOW MASTER STATUS")).WillReturnRows(masterRows())
+		expectSnapshotExec(dbMock, "SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ", "START TRANSACTION WITH CONSISTENT SNAPSHOT")
+		dbMock.ExpectQuery(regexp.QuoteMeta("SELECT `entity_id` FROM `catalog_product_entity`")).
+			WillReturnRows(sqlmock.NewRows([]string{"entity_id"}).AddRow(5))
+		expectSnapshotExec(dbMock, "COMMIT")
+
+		require.NoError(t, c.runSnapshot(context.Background()))
+		require.NoError(t, dbMock.ExpectationsWereMet())
+		assert.Exactly(t, []string{"snapshot catalog_product_entity [[5]]"}, rh.events)
+	})
+
+	t.Run("typed values", func(t *testing.T) {
+		c := newEventTestCanal()
+		c.db = db
+		require.NoError(t, c.tables.Options(ddl.WithTable("sales_order",
+			&ddl.Column{Field: "entity_id", DataType: "int", ColumnType: "int(10) unsigned", Key: "PRI"},
+			&ddl.Column{Field: "grand_total", DataType: "decimal", ColumnType: "decimal(20,4)"},
+			&ddl.Column{Field: "status", DataType: "varchar", ColumnType: "varchar(32)"},
+			&ddl.Column{Field: "note", DataType: "text", ColumnType: "text"},
+		)))
+		require.NoError(t, WithSnapshot(Snapshot{Tables: []string{"sales_order"}, NoGlobalLock: true})(c))
+		th := new(recordingTxHandler)
+		c.RegisterRowsEventHandler(th)
+
+		dbMock.ExpectQuery(regexp.QuoteMeta("SHOW MASTER STATUS")).WillReturnRows(masterRows())
+		expectSnapshotExec(dbMock, "SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ", "START TRANSACTION WITH CONSISTENT SNAPSHOT")
+		dbMock.ExpectQuery(regexp.QuoteMeta("SELECT `entity_id`, `grand_total`, `status`, `note` FROM `sales_order`")).
+			WillReturnRows(sqlmock.NewRows([]string{"entity_id", "grand_total", "status", "note"}).
+				AddRow([]byte("1"), []byte("47.1100"), []byte("pending"), nil).
+				AddRow([]byte("2"), []byte("-0.5000"), []byte("complete"), []byte("gift")))
+		expectSnapshotExec(dbMock, "COMMIT")
+
+		require.NoError(t, c.runSnapshot(context.Background()))
+		require.NoError(t, dbMock.ExpectationsWereMet())
+		require.Len(t, th.txs, 1)
+		assert.Exactly(t, [][]interface{}{
+			{int32(1), 47.11, "pending", nil},
+			{int32(2), -0.5, "complete", []byte("gift")},
+		}, th.txs[0].Events[0].Rows)
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		c := newEventTestCanal()
+		c.db = db
+		require.NoError(t, c.tables.Options(ddl.WithTable("sales_order",
+			&ddl.Column{Field: "entity_id", DataType: "int", ColumnType: "int(10)"},
+		)))
+		require.NoError(t, WithSnapshot(Snapshot{Tables: []string{"sales_order"}, NoGlobalLock: true})(c))
+
+		dbMock.ExpectQuery(regexp.QuoteMeta("SHOW MASTER STATUS")).WillReturnRows(masterRows())
+		expectSnapshotExec(dbMock, "SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ", "START TRANSACTION WITH CONSISTENT SNAPSHOT")
+		dbMock.ExpectQuery(regexp.QuoteMeta("SELECT `entity_id` FROM `sales_order`")).
+			WillReturnRows(sqlmock.NewRows([]string{"entity_id"}).AddRow([]byte("x")))
+		expectSnapshotExec(dbMock, "ROLLBACK")
+
+		assert.Error(t, c.runSnapshot(context.Background()))
+		require.NoError(t, dbMock.ExpectationsWereMet())
+	})
+
+	t.Run("query error releases lock", func(t *testing.T) {
+		c := newEventTestCanal()
+		c.db = db
+		require.NoError(t, WithSnapshot(Snapshot{Tables: []string{"catalog_product_entity"}})(c))
+
+		dbMock.ExpectExec(regexp.QuoteMeta("FLUSH TABLES WITH READ LOCK")).WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectExec(regexp.QuoteMeta("SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ")).WillReturnError(errors.NewFatalf("access denied"))
+		expectSnapshotExec(dbMock, "UNLOCK TABLES")
+
+		assert.Error(t, c.runSnapshot(context.Background()))
+		require.NoError(t, dbMock.ExpectationsWereMet())
+	})
+}
+
+func TestSnapshotValue(t *testing.T) {
+	tests := []struct {
+		dataType   string
+		columnType string
+		in         interface{}
+		want       interface{}
+	}{
+		{"tinyint", "tinyint(1)", []byte("-1"), int8(-1)},
+		{"tinyint", "tinyint(3) unsigned", []byte("255"), int8(-1)},
+		{"smallint", "smallint(5)", []byte("300"), int16(300)},
+		{"mediumint", "mediumint(8)", []byte("70000"), int32(70000)},
+		{"int", "int(10) unsigned", []byte("4294967295"), int32(-1)},
+		{"bigint", "bigint(20)", []byte("-9000000000"), int64(-9000000000)},
+		{"year", "year(4)", []byte("2019"), 2019},
+		{"float", "float", []byte("1.5"), float32(1.5)},
+		{"double", "double", []byte("2.25"), 2.25},
+		{"decimal", "decimal(12,4)", []byte("47.1100"), 47.11},
+		{"bit", "bit(10)", []byte{0x02, 0x01}, int64(513)},
+		{"enum", "enum('simple','configurable')", []byte("configurable"), int64(2)},
+		{"set", "set('a','b','c')", []byte("a,c"), int64(5)},
+		{"varchar", "varchar(64)", []byte("sku"), "sku"},
+		{"datetime", "datetime", []byte("2019-01-02 15:04:05"), "2019-01-02 15:04:05"},
+		{"text", "text", []byte("note"), []byte("note")},
+		{"json", "json", []byte(`{"a":1}`), []byte(`{"a":1}`)},
+		{"int", "int(10)", nil, nil},
+		{"int", "int(10)", int64(3), int64(3)},
+		{"", "", []byte("1"), []byte("1")},
+	}
+	for _, test := range tests {
+		have, err := snapshotValue(&ddl.Column{Field: "c", DataType: test.dataType, ColumnType: test.columnType}, test.in)
+		require.NoError(t, err, "%s: %+v", test.columnType, err)
+		assert.Exactly(t, test.want, have, "%s", test.columnType)
+	}
+
+	_, err := snapshotValue(&ddl.Column{Field: "c", DataType: "int", ColumnType: "int(10)"}, []byte("4294967295"))
+	assert.Error(t, err)
+}
@@ -1,12 +1,17 @@
 package binlogsync
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/corestoreio/pkg/sql/ddl"
 	"github.com/corestoreio/pkg/sql/myreplicator"
 	"github.com/corestoreio/errors"
 	"github.com/corestoreio/log"
+	"github.com/satori/go.uuid"
+	gomysql "github.com/siddontang/go-mysql/mysql"
 )
 
 // Action constants to figure out the type of an event. Those constants will be
@@ -15,23 +20,11 @@ const (
 	UpdateAction = "update"
 	InsertAction = "insert"
 	DeleteAction = "delete"
+	// SnapshotAction marks the existing rows of a table read during the
+	// initial snapshot, see WithSnapshot.
+	SnapshotAction = "snapshot"
 )
 
-func (c *Canal) clearTableCacheOnAlterTableStatement(schema, query []byte) {
-	if mb := c.expAlterTable.FindSubmatch(query); mb != nil {
-		if len(mb[1]) == 0 {
-			mb[1] = schema
-		}
-		scma := string(mb[1])
-		tbl := string(mb[2])
-		c.ClearTableCache(scma, tbl)
-		if c.Log.IsInfo() {
-			c.Log.Info("[binlogsync] Table structure changed, clear table cache",
-				log.String("database", scma), log.String("table", tbl))
-		}
-	}
-}
-
 func (c *Canal) startSyncBinlog(ctxArg context.Context) error {
 	pos := c.masterStatus
 
@@ -39,9 +32,22 @@ func (c *Canal) startSyncBinlog(ctxArg context.Context) error {
 		c.Log.Info("[binlogsync] Start syncing of binlog", log.Stringer("position", pos))
 	}
 
-	s, err := c.syncer.StartSync(pos)
-	if err != nil {
-		return errors.NewFatalf("[binlogsync] Start sync replication at %s error %v", pos, err)
+	var s *myreplicator.BinlogStreamer
+	if pos.ExecutedGTIDSet != "" {
+		// Parse twice because the syncer modifies its own GTID set.
+		gset, err := gomysql.ParseGTIDSet(c.flavor(), pos.ExecutedGTIDSet)
+		if err != nil {
+			return errors.Wrapf(err, "[binlogsync] ParseGTIDSet %q", pos.ExecutedGTIDSet)
+		}
+		c.gset, _ = gomysql.ParseGTIDSet(c.flavor(), pos.ExecutedGTIDSet)
+		if s, err = c.syncer.StartSyncGTID(gset); err != nil {
+			return errors.NewFatalf("[binlogsync] Start sync replication at GTID set %q error %v", pos.ExecutedGTIDSet, err)
+		}
+	} else {
+		var err error
+		if s, err = c.syncer.StartSync(pos); err != nil {
+			return errors.NewFatalf("[binlogsync] Start sync replication at %s error %v", pos, err)
+		}
 	}
 
 	timeout := time.Second
@@ -63,78 +69,209 @@ func (c *Canal) startSyncBinlog(ctxArg context.Context) error {
 		//next binlog pos
 		pos.Position = uint(ev.Header.LogPos)
 
-		switch e := ev.Event.(type) {
-		case *myreplicator.RotateEvent:
-			if err := c.flushEventHandlers(ctxArg); err != nil {
-				// todo maybe better err handling ...
-				return errors.Wrap(err, "[binlogsync] startSyncBinlog.flushEventHandlers")
-			}
-			pos.File = string(e.NextLogName)
-			pos.Position = uint(e.Position)
-			// r.ev <- pos
+		savePos, err := c.handleEvent(ctxArg, ev, &pos)
+		if err != nil {
+			return errors.Wrap(err, "[binlogsync] startSyncBinlog.handleEvent")
+		}
+		if !savePos {
+			continue
+		}
 
+		if c.gset != nil {
+			pos.ExecutedGTIDSet = c.gset.String()
+		}
+		c.masterUpdate(pos)
+		if err := c.masterSave(ctxArg, false); err != nil {
+			c.Log.Info("[binlogsync] startSyncBinlog: Failed to save master position", log.Err(err), log.Stringer("position", pos))
+		}
+	}
+}
+
+// handleEvent buffers the rows events of a transaction and dispatches them to
+// the handlers on commit. Argument pos gets updated on a rotate event. Returns
+// true if the position should be saved. Within a transaction the position does
+// not get saved, otherwise a restart would only sync half of it.
+func (c *Canal) handleEvent(ctx context.Context, ev *myreplicator.BinlogEvent, pos *ddl.MasterStatus) (savePos bool, _ error) {
+	switch e := ev.Event.(type) {
+	case *myreplicator.RotateEvent:
+		if err := c.flushEventHandlers(ctx); err != nil {
+			// todo maybe better err handling ...
+			return false, errors.Wrap(err, "[binlogsync] handleEvent.flushEventHandlers")
+		}
+		pos.File = string(e.NextLogName)
+		pos.Position = uint(e.Position)
+
+		if c.Log.IsInfo() {
+			c.Log.Info("[binlogsync] Rotate binlog to a new position", log.Stringer("position", pos))
+		}
+
+	case *myreplicator.GTIDEvent:
+		c.txnGTID = fmt.Sprintf("%s:%d", uuid.FromBytesOrNil(e.SID), e.GNO)
+		return false, nil
+	case *myreplicator.MariadbGTIDEvent:
+		// MariaDB writes no BEGIN statement, the GTID event starts the
+		// transaction.
+		c.txnGTID = e.GTID.String()
+		if e.Flags&myreplicator.MariadbGTIDFlagStandalone == 0 {
+			c.txnBegin()
+		}
+		return false, nil
+	case *myreplicator.RowsEvent:
+		// we only focus row based event.
+		// NotFound errors get ignores. For example table has been deleted
+		// and an old event pops in.
+		rev, ok, err := c.newRowsEvent(ctx, ev, *pos)
+		if err != nil {
+			isNotFound := errors.IsNotFound(err)
 			if c.Log.IsInfo() {
-				c.Log.Info("[binlogsync] Rotate binlog to a new position", log.Stringer("position", pos))
+				c.Log.Info("[binlogsync] Failed to decode rows event", log.Err(err), log.Stringer("position", pos), log.Bool("ignore_not_found_error", isNotFound))
 			}
-
-		case *myreplicator.RowsEvent:
-			// we only focus row based event.
-			// NotFound errors get ignores. For example table has been deleted
-			// and an old event pops in.
-			if err = c.handleRowsEvent(ctxArg, ev); err != nil {
-				isNotFound := errors.IsNotFound(err)
-				if c.Log.IsInfo() {
-					c.Log.Info("[binlogsync] Rotate binlog to a new position", log.Err(err), log.Stringer("position", pos), log.Bool("ignore_not_found_error", isNotFound))
-				}
-				if !isNotFound {
-					return errors.Wrap(err, "[binlogsync] handleRowsEvent")
-				}
-				continue
+			if !isNotFound {
+				return false, errors.Wrap(err, "[binlogsync] handleEvent.newRowsEvent")
+			}
+			return false, nil
+		}
+		switch {
+		case !ok:
+		case c.txn != nil:
+			c.txn.Events = append(c.txn.Events, rev)
+		default:
+			// Rows event without surrounding transaction.
+			if err := c.travelTransaction(ctx, Transaction{GTID: c.txnGTID, Position: *pos, Events: []RowsEvent{rev}}); err != nil {
+				return false, errors.Wrap(err, "[binlogsync] handleEvent.travelTransaction")
+			}
+		}
+	case *myreplicator.XIDEvent:
+		if err := c.txnCommit(ctx, e.XID, *pos); err != nil {
+			return false, errors.Wrap(err, "[binlogsync] XIDEvent.txnCommit")
+		}
+	case *myreplicator.QueryEvent:
+		switch q := bytes.TrimSpace(e.Query); {
+		case bytes.EqualFold(q, queryBegin):
+			c.txnBegin()
+		case bytes.EqualFold(q, queryCommit):
+			if err := c.txnCommit(ctx, 0, *pos); err != nil {
+				return false, errors.Wrap(err, "[binlogsync] QueryEvent.txnCommit")
+			}
+		case bytes.EqualFold(q, queryRollback):
+			c.txnRollback()
+		case isXAQuery(q, "START"), isXAQuery(q, "BEGIN"):
+			c.txnBegin()
+		case isXAQuery(q, "COMMIT"):
+			if err := c.txnCommit(ctx, 0, *pos); err != nil {
+				return false, errors.Wrap(err, "[binlogsync] QueryEvent.txnCommit XA")
 			}
-		case *myreplicator.XIDEvent:
-			// try to save the position later
-		case *myreplicator.QueryEvent:
-			// handle alert table query
-			c.clearTableCacheOnAlterTableStatement(e.Schema, e.Query)
-			// save master position, so no continue
-		case
-			*myreplicator.TableMapEvent,
-			*myreplicator.FormatDescriptionEvent:
-			// maybe add: *replication.XIDEvent
-			// don't update Master with file and position
+		case isXAQuery(q, "ROLLBACK"):
+			c.txnRollback()
+		case isXAQuery(q, "END"), isXAQuery(q, "PREPARE"):
+			// the XA transaction ends with XA COMMIT or XA ROLLBACK
 		default:
-			continue
+			if err := c.handleSchemaChange(ctx, e, ev.Header, *pos); err != nil {
+				return false, errors.Wrap(err, "[binlogsync] QueryEvent.handleSchemaChange")
+			}
+			if c.txn == nil {
+				c.gtidDone() // statement without transaction, e.g. DDL
+			}
 		}
+		// save master position, so no return
+	case
+		*myreplicator.TableMapEvent,
+		*myreplicator.FormatDescriptionEvent:
+		// don't update Master with file and position
+	default:
+		return false, nil
+	}
+	return c.txn == nil, nil
+}
 
-		c.masterUpdate(pos.File, pos.Position)
-		if err := c.masterSave(); err != nil {
-			c.Log.Info("[binlogsync] startSyncBinlog: Failed to save master position", log.Err(err), log.Stringer("position", pos))
+var (
+	queryBegin    = []byte("BEGIN")
+	queryCommit   = []byte("COMMIT")
+	queryRollback = []byte("ROLLBACK")
+	queryXA       = []byte("XA")
+)
+
+// isXAQuery reports whether the query is the XA statement `verb`, e.g.
+// `XA START X'7831'` for the verb START.
+func isXAQuery(q []byte, verb string) bool {
+	f := bytes.Fields(q)
+	return len(f) >= 2 && bytes.EqualFold(f[0], queryXA) && bytes.EqualFold(f[1], []byte(verb))
+}
+
+// txnBegin starts buffering the rows events. A transaction which has not been
+// committed gets discarded.
+func (c *Canal) txnBegin() {
+	if c.txn != nil && c.Log.IsInfo() {
+		c.Log.Info("[binlogsync] Discarding uncommitted transaction", log.String("gtid", c.txn.GTID), log.Int("events", len(c.txn.Events)))
+	}
+	c.txn = &Transaction{GTID: c.txnGTID}
+}
+
+// txnCommit dispatches the buffered rows events to all handlers.
+func (c *Canal) txnCommit(ctx context.Context, xid uint64, pos ddl.MasterStatus) error {
+	tx := c.txn
+	c.txn = nil
+	if tx != nil && len(tx.Events) > 0 {
+		tx.XID = xid
+		tx.Position = pos
+		if err := c.travelTransaction(ctx, *tx); err != nil {
+			return errors.Wrap(err, "[binlogsync] txnCommit.travelTransaction")
 		}
 	}
+	c.gtidDone()
+	return nil
 }
 
-// handleRowsEvent handles an event on the rows and calls all registered rows
-// event handler. can return different error behaviours.
-func (c *Canal) handleRowsEvent(ctx context.Context, e *myreplicator.BinlogEvent) error {
+// txnRollback discards the buffered rows events.
+func (c *Canal) txnRollback() {
+	if c.txn != nil && c.Log.IsDebug() {
+		c.Log.Debug("[binlogsync] Transaction rolled back", log.String("gtid", c.txn.GTID), log.Int("events", len(c.txn.Events)))
+	}
+	c.txn = nil
+	c.gtidDone()
+}
+
+// gtidDone adds the GTID of the finished transaction to the executed GTID
+// set.
+func (c *Canal) gtidDone() {
+	if c.gset != nil && c.txnGTID != "" {
+		if err := c.gset.Update(c.txnGTID); err != nil && c.Log.IsInfo() {
+			c.Log.Info("[binlogsync] Failed to update the GTID set", log.Err(err), log.String("gtid", c.txnGTID))
+		}
+	}
+	c.txnGTID = ""
+}
+
+// newRowsEvent decodes the action, table and rows of a binlog event. The
+// columns of the table are the version valid at the position of the event.
+// Returns false if the event belongs to a different database. can return
+// different error behaviours.
+func (c *Canal) newRowsEvent(ctx context.Context, e *myreplicator.BinlogEvent, pos ddl.MasterStatus) (RowsEvent, bool, error) {
 	ev, ok := e.Event.(*myreplicator.RowsEvent)
 	if !ok {
-		return errors.NewFatalf("[binlogsync] handleRowsEvent: Failed to cast to *myreplicator.RowsEvent type")
+		return RowsEvent{}, false, errors.NewFatalf("[binlogsync] newRowsEvent: Failed to cast to *myreplicator.RowsEvent type")
 	}
 
-	// Caveat: table may be altered at runtime.
-
 	if in := string(ev.Table.Schema); c.DSN.DBName != in {
 		if c.Log.IsDebug() {
 			c.Log.Debug("[binlogsync] Skipping database", log.String("database_have", in), log.String("database_want", c.DSN.DBName), log.Int("table_id", int(ev.TableID)))
 		}
-		return nil
+		return RowsEvent{}, false, nil
 	}
 
 	table := string(ev.Table.Table)
 
-	t, err := c.FindTable(ctx, table)
+	t, ok := c.schemas.at(table, pos)
+	if !ok {
+		var err error
+		if t, err = c.FindTable(ctx, table); err != nil {
+			return RowsEvent{}, false, errors.Wrapf(err, "[binlogsync] GetTable %q.%q", c.DSN.DBName, table)
+		}
+		c.schemas.add(table, ddl.MasterStatus{}, t)
+	}
+	t, err := matchColumns(ev.Table, t)
 	if err != nil {
-		return errors.Wrapf(err, "[binlogsync] GetTable %q.%q", c.DSN.DBName, table)
+		return RowsEvent{}, false, errors.Wrapf(err, "[binlogsync] matchColumns %q.%q", c.DSN.DBName, table)
 	}
 	var a string
 	switch e.Header.EventType {
@@ -145,9 +282,9 @@ func (c *Canal) handleRowsEvent(ctx context.Context, e *myreplicator.BinlogEvent
 	case myreplicator.UPDATE_ROWS_EVENTv1, myreplicator.UPDATE_ROWS_EVENTv2:
 		a = UpdateAction
 	default:
-		return errors.NewNotSupportedf("[binlogsync] EventType %v not yet supported. Table %q.%q", e.Header.EventType, c.DSN.DBName, table)
+		return RowsEvent{}, false, errors.NewNotSupportedf("[binlogsync] EventType %v not yet supported. Table %q.%q", e.Header.EventType, c.DSN.DBName, table)
 	}
-	return c.travelRowsEventHandler(ctx, a, t, ev.Rows)
+	return RowsEvent{Action: a, Table: t, Rows: ev.Rows, Timestamp: time.Unix(int64(e.Header.Timestamp), 0)}, true, nil
 }
 
 // todo: implement when needed
@@ -0,0 +1,320 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/myreplicator"
+	"github.com/go-sql-driver/mysql"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingHandler struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (rh *recordingHandler) Do(_ context.Context, action string, t ddl.Table, rows [][]interface{}) error {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	rh.events = append(rh.events, fmt.Sprintf("%s %s %v", action, t.Name, rows))
+	return nil
+}
+func (rh *recordingHandler) Complete(_ context.Context) error { return nil }
+func (rh *recordingHandler) String() string                   { return "recordingHandler" }
+
+type recordingTxHandler struct {
+	recordingHandler
+	txs []Transaction
+}
+
+func (rh *recordingTxHandler) DoTransaction(_ context.Context, tx Transaction) error {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+	rh.txs = append(rh.txs, tx)
+	return nil
+}
+
+type failingHandler struct {
+	recordingHandler
+	err error
+}
+
+func (fh *failingHandler) Do(_ context.Context, _ string, _ ddl.Table, _ [][]interface{}) error {
+	return fh.err
+}
+
+func newEventTestCanal() *Canal {
+	return &Canal{
+		DSN:         &mysql.Config{DBName: "TestDB"},
+		tables:      ddl.MustNewTables(ddl.WithTable("catalog_product_entity", &ddl.Column{Field: "entity_id"})),
+		Log:         log.BlackHole{},
+		closed:      new(int32),
+		canalParams: map[string]string{},
+	}
+}
+
+func rowsEvent(id int64) *myreplicator.BinlogEvent {
+	return &myreplicator.BinlogEvent{
+		Header: &myreplicator.EventHeader{EventType: myreplicator.WRITE_ROWS_EVENTv2},
+		Event: &myreplicator.RowsEvent{
+			Table: &myreplicator.TableMapEvent{
+				Schema: []byte("TestDB"), Table: []byte("catalog_product_entity"),
+				ColumnCount: 1, ColumnType: []byte{gomysql.MYSQL_TYPE_LONGLONG},
+			},
+			Rows: [][]interface{}{{id}},
+		},
+	}
+}
+
+func queryEvent(query string) *myreplicator.BinlogEvent {
+	return &myreplicator.BinlogEvent{
+		Header: &myreplicator.EventHeader{EventType: myreplicator.QUERY_EVENT},
+		Event:  &myreplicator.QueryEvent{Schema: []byte("TestDB"), Query: []byte(query)},
+	}
+}
+
+func xidEvent(xid uint64) *myreplicator.BinlogEvent {
+	return &myreplicator.BinlogEvent{
+		Header: &myreplicator.EventHeader{EventType: myreplicator.XID_EVENT},
+		Event:  &myreplicator.XIDEvent{XID: xid},
+	}
+}
+
+func TestCanal_HandleEvent_Transaction(t *testing.T) {
+	c := newEventTestCanal()
+	rh := new(recordingHandler)
+	th := new(recordingTxHandler)
+	c.RegisterRowsEventHandler(rh)
+	c.RegisterRowsEventHandler(th)
+
+	gtid := &myreplicator.BinlogEvent{
+		Header: &myreplicator.EventHeader{EventType: myreplicator.GTID_EVENT},
+		Event:  &myreplicator.GTIDEvent{SID: []byte{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62}, GNO: 23},
+	}
+
+	pos := ddl.MasterStatus{File: "mysql-bin.000003", Position: 4711}
+	var savePositions []bool
+	for _, ev := range []*myreplicator.BinlogEvent{
+		gtid, queryEvent("BEGIN"), rowsEvent(1), rowsEvent(2), xidEvent(815),
+		queryEvent("BEGIN"), rowsEvent(3), queryEvent("ROLLBACK"),
+		queryEvent("BEGIN"), rowsEvent(4), // never committed, discarded by the next BEGIN
+		queryEvent("BEGIN"), rowsEvent(5), queryEvent("COMMIT"),
+	} {
+		savePos, err := c.handleEvent(context.Background(), ev, &pos)
+		require.NoError(t, err)
+		savePositions = append(savePositions, savePos)
+	}
+
+	assert.Exactly(t, []bool{
+		false, false, false, false, true,
+		false, false, true,
+		false, false,
+		false, false, true,
+	}, savePositions)
+
+	assert.Exactly(t, []string{
+		"insert catalog_product_entity [[1]]",
+		"insert catalog_product_entity [[2]]",
+		"insert catalog_product_entity [[5]]",
+	}, rh.events)
+
+	assert.Empty(t, th.events, "TransactionHandler must not receive Do calls")
+	require.Len(t, th.txs, 2)
+	assert.Exactly(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:23", th.txs[0].GTID)
+	assert.Exactly(t, uint64(815), th.txs[0].XID)
+	assert.Exactly(t, pos, th.txs[0].Position)
+	assert.Len(t, th.txs[0].Events, 2)
+	assert.Exactly(t, InsertAction, th.txs[0].Events[1].Action)
+	assert.Exactly(t, [][]interface{}{{int64(2)}}, th.txs[0].Events[1].Rows)
+	assert.Exactly(t, "", th.txs[1].GTID)
+	assert.Exactly(t, uint64(0), th.txs[1].XID)
+	assert.Exactly(t, [][]interface{}{{int64(5)}}, th.txs[1].Events[0].Rows)
+}
+
+type timestampHandler struct {
+	recordingHandler
+	timestamps []time.Time
+}
+
+func (th *timestampHandler) Do(ctx context.Context, _ string, _ ddl.Table, _ [][]interface{}) error {
+	ts, ok := EventTimestamp(ctx)
+	if !ok {
+		return errors.NewNotFoundf("event timestamp missing")
+	}
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	th.timestamps = append(th.timestamps, ts)
+	return nil
+}
+
+func TestCanal_HandleEvent_EventTimestamp(t *testing.T) {
+	c := newEventTestCanal()
+	th := new(timestampHandler)
+	c.RegisterRowsEventHandler(th)
+
+	ev1, ev2 := rowsEvent(1), rowsEvent(2)
+	ev1.Header.Timestamp = 1500000000
+	ev2.Header.Timestamp = 1500000060
+
+	pos := ddl.MasterStatus{File: "mysql-bin.000003", Position: 4711}
+	for _, ev := range []*myreplicator.BinlogEvent{queryEvent("BEGIN"), ev1, ev2, xidEvent(815)} {
+		_, err := c.handleEvent(context.Background(), ev, &pos)
+		require.NoError(t, err)
+	}
+	assert.Exactly(t, []time.Time{time.Unix(1500000000, 0), time.Unix(1500000060, 0)}, th.timestamps)
+
+	_, ok := EventTimestamp(context.Background())
+	assert.False(t, ok)
+}
+
+func TestCanal_HandleEvent_XATransaction(t *testing.T) {
+	c := newEventTestCanal()
+	th := new(recordingTxHandler)
+	c.RegisterRowsEventHandler(th)
+
+	pos := ddl.MasterStatus{File: "mysql-bin.000003", Position: 4711}
+	var savePositions []bool
+	for _, ev := range []*myreplicator.BinlogEvent{
+		queryEvent("XA START X'7831',X'',1"), rowsEvent(1), rowsEvent(2),
+		queryEvent("XA END X'7831',X'',1"), queryEvent("XA PREPARE X'7831',X'',1"),
+		queryEvent("XA COMMIT X'7831',X'',1"),
+		queryEvent("xa start X'7832',X'',1"), rowsEvent(3), queryEvent("XA END X'7832',X'',1"),
+		queryEvent("XA ROLLBACK X'7832',X'',1"),
+		queryEvent("XA START X'7833',X'',1"), rowsEvent(4), queryEvent("XA END X'7833',X'',1"),
+		queryEvent("XA COMMIT X'7833',X'',1 ONE PHASE"),
+	} {
+		savePos, err := c.handleEvent(context.Background(), ev, &pos)
+		require.NoError(t, err)
+		savePositions = append(savePositions, savePos)
+	}
+
+	assert.Exactly(t, []bool{
+		false, false, false,
+		false, false,
+		true,
+		false, false, false,
+		true,
+		false, false, false,
+		true,
+	}, savePositions)
+
+	require.Len(t, th.txs, 2)
+	require.Len(t, th.txs[0].Events, 2)
+	assert.Exactly(t, [][]interface{}{{int64(1)}}, th.txs[0].Events[0].Rows)
+	assert.Exactly(t, [][]interface{}{{int64(2)}}, th.txs[0].Events[1].Rows)
+	require.Len(t, th.txs[1].Events, 1)
+	assert.Exactly(t, [][]interface{}{{int64(4)}}, th.txs[1].Events[0].Rows)
+	assert.Nil(t, c.txn)
+}
+
+func TestIsXAQuery(t *testing.T) {
+	assert.True(t, isXAQuery([]byte("XA START X'31',X'',1"), "START"))
+	assert.True(t, isXAQuery([]byte("xa  commit X'31'"), "COMMIT"))
+	assert.False(t, isXAQuery([]byte("XA COMMIT X'31'"), "START"))
+	assert.False(t, isXAQuery([]byte("XA"), "START"))
+	assert.False(t, isXAQuery([]byte("ALTER TABLE xa START"), "START"))
+}
+
+func TestCanal_HandleEvent_HandlerError(t *testing.T) {
+	runEvents := func(c *Canal) (savePos bool, err error) {
+		pos := ddl.MasterStatus{File: "mysql-bin.000003", Position: 4711}
+		for _, ev := range []*myreplicator.BinlogEvent{queryEvent("BEGIN"), rowsEvent(1), xidEvent(815)} {
+			if savePos, err = c.handleEvent(context.Background(), ev, &pos); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	t.Run("error stops without saving the position", func(t *testing.T) {
+		c := newEventTestCanal()
+		c.RegisterRowsEventHandler(&failingHandler{err: errors.NewNotValidf("invalid row")})
+		savePos, err := runEvents(c)
+		assert.True(t, errors.IsNotValid(err), "%+v", err)
+		assert.False(t, savePos)
+	})
+	t.Run("skipped error saves the position", func(t *testing.T) {
+		c := newEventTestCanal()
+		require.NoError(t, WithSkipHandlerErrors()(c))
+		c.RegisterRowsEventHandler(&failingHandler{err: errors.NewNotValidf("invalid row")})
+		savePos, err := runEvents(c)
+		require.NoError(t, err)
+		assert.True(t, savePos)
+	})
+	t.Run("interrupted error stops despite skipping", func(t *testing.T) {
+		c := newEventTestCanal()
+		require.NoError(t, WithSkipHandlerErrors()(c))
+		c.RegisterRowsEventHandler(&failingHandler{err: errors.NewInterruptedf("stop")})
+		savePos, err := runEvents(c)
+		assert.True(t, errors.IsInterrupted(err), "%+v", err)
+		assert.False(t, savePos)
+	})
+}
+
+func TestCanal_HandleEvent_MariaDB(t *testing.T) {
+	c := newEventTestCanal()
+	th := new(recordingTxHandler)
+	c.RegisterRowsEventHandler(th)
+
+	gtid := &myreplicator.BinlogEvent{
+		Header: &myreplicator.EventHeader{EventType: myreplicator.MARIADB_GTID_EVENT},
+		Event:  &myreplicator.MariadbGTIDEvent{GTID: gomysql.MariadbGTID{DomainID: 0, ServerID: 1, SequenceNumber: 7}},
+	}
+
+	var pos ddl.MasterStatus
+	for _, ev := range []*myreplicator.BinlogEvent{gtid, rowsEvent(1), xidEvent(3)} {
+		_, err := c.handleEvent(context.Background(), ev, &pos)
+		require.NoError(t, err)
+	}
+	require.Len(t, th.txs, 1)
+	assert.Exactly(t, "0-1-7", th.txs[0].GTID)
+	assert.Len(t, th.txs[0].Events, 1)
+}
+
+func TestCanal_HandleEvent_GTIDSet(t *testing.T) {
+	c := newEventTestCanal()
+	gset, err := gomysql.ParseGTIDSet(MySQLFlavor, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-22")
+	require.NoError(t, err)
+	c.gset = gset
+
+	gtid := func(gno int64) *myreplicator.BinlogEvent {
+		return &myreplicator.BinlogEvent{
+			Header: &myreplicator.EventHeader{EventType: myreplicator.GTID_EVENT},
+			Event:  &myreplicator.GTIDEvent{SID: []byte{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62}, GNO: gno},
+		}
+	}
+
+	var pos ddl.MasterStatus
+	for _, ev := range []*myreplicator.BinlogEvent{
+		gtid(23), queryEvent("BEGIN"), rowsEvent(1), xidEvent(1),
+		gtid(24), queryEvent("ALTER TABLE catalog_product_entity ADD COLUMN sku varchar(64)"),
+	} {
+		_, err := c.handleEvent(context.Background(), ev, &pos)
+		require.NoError(t, err)
+	}
+	assert.Exactly(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-24", c.gset.String())
+	assert.Empty(t, c.txnGTID)
+}
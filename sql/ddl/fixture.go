@@ -0,0 +1,269 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddl
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+)
+
+// Fixture describes how to insert random rows into a table. The factory
+// functions generated by package dmlgen with the option Fixture return a
+// Fixture for each table.
+type Fixture struct {
+	// TableName of the table the rows get inserted into.
+	TableName string
+	// Count defines the number of rows to insert.
+	Count int
+	// Insert creates and inserts a single random row. It must add the values
+	// of the columns referenced by foreign keys of other tables to refs.
+	Insert func(ctx context.Context, db *dml.ConnPool, r *rand.Rand, refs FixtureRefs) error
+}
+
+// FixtureRefs contains the values of already inserted columns which might get
+// referenced by foreign keys of other tables. The map key has the format
+// table_name.column_name.
+type FixtureRefs map[string][]interface{}
+
+// Add appends the value of an inserted column. Supported types are int64,
+// uint64, string, dml.NullInt64 and dml.NullString. Invalid Null types get
+// ignored.
+func (fr FixtureRefs) Add(tableColumn string, value interface{}) {
+	switch v := value.(type) {
+	case dml.NullInt64:
+		if !v.Valid {
+			return
+		}
+		value = v.Int64
+	case dml.NullString:
+		if !v.Valid {
+			return
+		}
+		value = v.String
+	}
+	fr[tableColumn] = append(fr[tableColumn], value)
+}
+
+func (fr FixtureRefs) pick(r *rand.Rand, tableColumn string) (interface{}, bool) {
+	vals := fr[tableColumn]
+	if len(vals) == 0 {
+		return nil, false
+	}
+	return vals[r.Intn(len(vals))], true
+}
+
+// Int64 returns a random value of an inserted column. Returns false if the
+// column has no values or the value cannot be converted to an int64.
+func (fr FixtureRefs) Int64(r *rand.Rand, tableColumn string) (int64, bool) {
+	v, ok := fr.pick(r, tableColumn)
+	if !ok {
+		return 0, false
+	}
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), true
+	case string:
+		i, err := strconv.ParseInt(v, 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// Uint64 returns a random value of an inserted column. Returns false if the
+// column has no values or the value cannot be converted to an uint64.
+func (fr FixtureRefs) Uint64(r *rand.Rand, tableColumn string) (uint64, bool) {
+	v, ok := fr.pick(r, tableColumn)
+	if !ok {
+		return 0, false
+	}
+	switch v := v.(type) {
+	case int64:
+		return uint64(v), v >= 0
+	case uint64:
+		return v, true
+	case string:
+		i, err := strconv.ParseUint(v, 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// String returns a random value of an inserted column. Returns false if the
+// column has no values.
+func (fr FixtureRefs) String(r *rand.Rand, tableColumn string) (string, bool) {
+	v, ok := fr.pick(r, tableColumn)
+	if !ok {
+		return "", false
+	}
+	switch v := v.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case string:
+		return v, true
+	}
+	return "", false
+}
+
+// SeedFixtures inserts the rows of all fixtures into the database. The order
+// of the tables gets resolved from the foreign keys loaded with
+// LoadKeyColumnUsage, so parent tables get seeded before their child tables.
+// Tables without a dependency keep their order. Argument r can be nil, a
+// source seeded with the current time gets used then. Returns the values of
+// all inserted columns which are referenced by foreign keys.
+func SeedFixtures(ctx context.Context, db *dml.ConnPool, r *rand.Rand, fixtures ...Fixture) (FixtureRefs, error) {
+	tableNames := make([]string, 0, len(fixtures))
+	for _, f := range fixtures {
+		tableNames = append(tableNames, f.TableName)
+	}
+	tblFks, err := LoadKeyColumnUsage(ctx, db.DB, tableNames...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fixtures, err = sortFixtures(fixtures, tblFks)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	refs := make(FixtureRefs)
+	for _, f := range fixtures {
+		for i := 0; i < f.Count; i++ {
+			if err := f.Insert(ctx, db, r, refs); err != nil {
+				return nil, errors.Wrapf(err, "[ddl] SeedFixtures: Table %q row %d", f.TableName, i)
+			}
+		}
+	}
+	return refs, nil
+}
+
+// sortFixtures sorts the fixtures topologically by their foreign keys. The map
+// key of tblFks has the format REFERENCED_TABLE_NAME.REFERENCED_COLUMN_NAME.
+// Self references get ignored.
+func sortFixtures(fixtures []Fixture, tblFks map[string]KeyColumnUsageCollection) ([]Fixture, error) {
+	has := make(map[string]bool, len(fixtures))
+	for _, f := range fixtures {
+		has[f.TableName] = true
+	}
+	parents := make(map[string]map[string]bool) // key=child table, value=parent tables
+	for tblPkCol, kcuc := range tblFks {
+		refTable := tblPkCol
+		if dotPos := strings.IndexByte(tblPkCol, '.'); dotPos > 0 {
+			refTable = tblPkCol[:dotPos]
+		}
+		for _, kcu := range kcuc.Data {
+			if kcu.TableName == refTable || !has[kcu.TableName] || !has[refTable] {
+				continue
+			}
+			if parents[kcu.TableName] == nil {
+				parents[kcu.TableName] = make(map[string]bool)
+			}
+			parents[kcu.TableName][refTable] = true
+		}
+	}
+
+	sorted := make([]Fixture, 0, len(fixtures))
+	seeded := make(map[string]bool, len(fixtures))
+	pending := append([]Fixture(nil), fixtures...)
+	for len(pending) > 0 {
+		next := pending[:0]
+		for _, f := range pending {
+			ready := true
+			for p := range parents[f.TableName] {
+				if !seeded[p] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				sorted = append(sorted, f)
+				seeded[f.TableName] = true
+			} else {
+				next = append(next, f)
+			}
+		}
+		if len(next) == len(pending) {
+			names := make([]string, 0, len(next))
+			for _, f := range next {
+				names = append(names, f.TableName)
+			}
+			return nil, errors.NotAcceptable.Newf("[ddl] SeedFixtures: Circular foreign keys between tables %v", names)
+		}
+		pending = next
+	}
+	return sorted, nil
+}
+
+const fixtureAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// FixtureString creates a random alphanumeric string with at least one and at
+// most maxLen characters. Long strings get capped at 64 characters.
+func FixtureString(r *rand.Rand, maxLen int64) string {
+	if maxLen > 64 || maxLen <= 0 {
+		maxLen = 64
+	}
+	b := make([]byte, 1+r.Int63n(maxLen))
+	for i := range b {
+		b[i] = fixtureAlphabet[r.Intn(len(fixtureAlphabet))]
+	}
+	return string(b)
+}
+
+// FixtureBytes creates a random byte slice with at least one and at most
+// maxLen bytes. Long slices get capped at 64 bytes.
+func FixtureBytes(r *rand.Rand, maxLen int64) []byte {
+	if maxLen > 64 || maxLen <= 0 {
+		maxLen = 64
+	}
+	b := make([]byte, 1+r.Int63n(maxLen))
+	r.Read(b)
+	return b
+}
+
+// FixtureEmail creates a random email address with at most maxLen
+// characters. A maxLen of zero or less means unbounded, for example for a
+// TEXT column. A short maxLen shortens the local part first and then the
+// domain. Returns a NotValid error if maxLen is too short for any valid
+// address.
+func FixtureEmail(r *rand.Rand, maxLen int64) (string, error) {
+	const domain, shortDomain = "@example.com", "@x.test"
+	switch {
+	case maxLen <= 0:
+		return FixtureString(r, 0) + domain, nil
+	case maxLen > int64(len(domain)):
+		return FixtureString(r, maxLen-int64(len(domain))) + domain, nil
+	case maxLen > int64(len(shortDomain)):
+		return FixtureString(r, maxLen-int64(len(shortDomain))) + shortDomain, nil
+	}
+	return "", errors.NotValid.Newf("[ddl] FixtureEmail: Maximum length %d too short for an email address", maxLen)
+}
+
+// FixtureTime creates a random time within the last two years, truncated to
+// seconds, in UTC.
+func FixtureTime(r *rand.Rand) time.Time {
+	const twoYears = 2 * 365 * 24 * 3600
+	return time.Unix(time.Now().Unix()-r.Int63n(twoYears), 0).UTC()
+}
@@ -0,0 +1,192 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddl_test
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mockKeyColumnUsageRows(csv string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"CONSTRAINT_CATALOG", "CONSTRAINT_SCHEMA", "CONSTRAINT_NAME", "TABLE_CATALOG", "TABLE_SCHEMA", "TABLE_NAME", "COLUMN_NAME", "ORDINAL_POSITION", "POSITION_IN_UNIQUE_CONSTRAINT", "REFERENCED_TABLE_SCHEMA", "REFERENCED_TABLE_NAME", "REFERENCED_COLUMN_NAME"}).
+		FromCSVString(csv)
+}
+
+func TestSeedFixtures(t *testing.T) {
+	t.Parallel()
+
+	t.Run("dependency order", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery("SELECT.+FROM information_schema.KEY_COLUMN_USAGE.+").
+			WillReturnRows(mockKeyColumnUsageRows(
+				`"def","shop","FK_ORDER_CUSTOMER","def","shop","sales_order","customer_id",1,1,"shop","customer_entity","entity_id"
+"def","shop","FK_ITEM_ORDER","def","shop","sales_order_item","order_id",1,1,"shop","sales_order","entity_id"
+"def","shop","FK_ITEM_PRODUCT","def","shop","sales_order_item","product_id",1,1,"shop","catalog_product_entity","entity_id"
+"def","shop","FK_ORDER_PARENT","def","shop","sales_order","parent_id",1,1,"shop","sales_order","entity_id"
+`))
+
+		var order []string
+		var lastID int64
+		fixture := func(tableName string, count int, parent string) ddl.Fixture {
+			return ddl.Fixture{
+				TableName: tableName,
+				Count:     count,
+				Insert: func(_ context.Context, db *dml.ConnPool, r *rand.Rand, refs ddl.FixtureRefs) error {
+					assert.Exactly(t, dbc, db)
+					if parent != "" {
+						_, ok := refs.Int64(r, parent)
+						assert.True(t, ok, "Table %q: missing reference %q", tableName, parent)
+					}
+					order = append(order, tableName)
+					lastID++
+					refs.Add(tableName+".entity_id", lastID)
+					return nil
+				},
+			}
+		}
+
+		refs, err := ddl.SeedFixtures(context.TODO(), dbc, rand.New(rand.NewSource(1)),
+			fixture("sales_order_item", 1, "sales_order.entity_id"),
+			fixture("sales_order", 2, "customer_entity.entity_id"),
+			fixture("store", 1, ""),
+			fixture("customer_entity", 2, ""),
+			fixture("catalog_product_entity", 1, ""),
+		)
+		require.NoError(t, err)
+		assert.Exactly(t, []string{
+			"store", "customer_entity", "customer_entity", "catalog_product_entity",
+			"sales_order", "sales_order", "sales_order_item",
+		}, order)
+		assert.Len(t, refs["customer_entity.entity_id"], 2)
+		assert.Len(t, refs["sales_order_item.entity_id"], 1)
+	})
+
+	t.Run("circular foreign keys", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery("SELECT.+FROM information_schema.KEY_COLUMN_USAGE.+").
+			WillReturnRows(mockKeyColumnUsageRows(
+				`"def","shop","FK_A_B","def","shop","a","b_id",1,1,"shop","b","id"
+"def","shop","FK_B_A","def","shop","b","a_id",1,1,"shop","a","id"
+`))
+		noop := func(context.Context, *dml.ConnPool, *rand.Rand, ddl.FixtureRefs) error { return nil }
+		refs, err := ddl.SeedFixtures(context.TODO(), dbc, nil,
+			ddl.Fixture{TableName: "a", Count: 1, Insert: noop},
+			ddl.Fixture{TableName: "b", Count: 1, Insert: noop},
+		)
+		assert.Nil(t, refs)
+		assert.True(t, errors.NotAcceptable.Match(err), "%+v", err)
+	})
+
+	t.Run("insert error", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery("SELECT.+FROM information_schema.KEY_COLUMN_USAGE.+").
+			WillReturnRows(mockKeyColumnUsageRows(""))
+		_, err := ddl.SeedFixtures(context.TODO(), dbc, nil, ddl.Fixture{
+			TableName: "a",
+			Count:     1,
+			Insert: func(context.Context, *dml.ConnPool, *rand.Rand, ddl.FixtureRefs) error {
+				return errors.AlreadyExists.Newf("Duplicate entry")
+			},
+		})
+		assert.True(t, errors.AlreadyExists.Match(err), "%+v", err)
+	})
+}
+
+func TestFixtureRefs(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(1))
+	refs := make(ddl.FixtureRefs)
+	refs.Add("a.id", uint64(3))
+	refs.Add("b.id", dml.MakeNullInt64(-4))
+	refs.Add("c.code", dml.MakeNullString("DE"))
+	refs.Add("d.id", dml.NullInt64{})
+
+	u, ok := refs.Uint64(r, "a.id")
+	assert.True(t, ok)
+	assert.Exactly(t, uint64(3), u)
+	i, ok := refs.Int64(r, "a.id")
+	assert.True(t, ok)
+	assert.Exactly(t, int64(3), i)
+	_, ok = refs.Uint64(r, "b.id")
+	assert.False(t, ok, "negative values cannot be converted")
+	s, ok := refs.String(r, "b.id")
+	assert.True(t, ok)
+	assert.Exactly(t, "-4", s)
+	s, ok = refs.String(r, "c.code")
+	assert.True(t, ok)
+	assert.Exactly(t, "DE", s)
+	_, ok = refs.Int64(r, "c.code")
+	assert.False(t, ok)
+	_, ok = refs.Int64(r, "d.id")
+	assert.False(t, ok, "invalid Null types must be ignored")
+}
+
+func TestFixtureEmail(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(1))
+	for maxLen := int64(8); maxLen <= 14; maxLen++ {
+		for i := 0; i < 20; i++ {
+			e, err := ddl.FixtureEmail(r, maxLen)
+			require.NoError(t, err, "maxLen %d", maxLen)
+			assert.True(t, int64(len(e)) <= maxLen, "%q exceeds %d", e, maxLen)
+			at := strings.IndexByte(e, '@')
+			assert.True(t, at > 0 && strings.Contains(e[at:], "."), "%q", e)
+		}
+	}
+	e, err := ddl.FixtureEmail(r, 13)
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(e, "@example.com"), "%q", e)
+
+	for _, maxLen := range []int64{1, 7} {
+		e, err := ddl.FixtureEmail(r, maxLen)
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+		assert.Empty(t, e)
+	}
+}
+
+func TestFixtureValues(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		s := ddl.FixtureString(r, 8)
+		assert.True(t, len(s) >= 1 && len(s) <= 8, "%q", s)
+		assert.True(t, len(ddl.FixtureString(r, 65535)) <= 64)
+		b := ddl.FixtureBytes(r, 3)
+		assert.True(t, len(b) >= 1 && len(b) <= 3, "%v", b)
+		e, err := ddl.FixtureEmail(r, 20)
+		require.NoError(t, err)
+		assert.True(t, len(e) <= 20 && strings.HasSuffix(e, "@example.com"), "%q", e)
+		e, err = ddl.FixtureEmail(r, 0)
+		require.NoError(t, err)
+		assert.True(t, len(e) > len("@example.com") && strings.HasSuffix(e, "@example.com"), "%q", e)
+		assert.False(t, ddl.FixtureTime(r).IsZero())
+	}
+}
@@ -17,6 +17,7 @@ package ddl
 import (
 	"bytes"
 	"context"
+	"database/sql"
 	"fmt"
 	"strconv"
 	"time"
@@ -115,6 +116,34 @@ func (t *Table) UpdateByPK() *dml.Update {
 	return u.WithDB(t.DB)
 }
 
+// txBeginner gets implemented by *sql.DB and *sql.Conn.
+type txBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// RunInTx runs the function within a new transaction if the field DB can
+// start one, for example a *sql.DB or a *sql.Conn. The transaction gets
+// committed if the function returns nil, otherwise rolled back. If DB is
+// already a *sql.Tx, the function runs with DB and the caller owns the
+// transaction.
+func (t *Table) RunInTx(ctx context.Context, opts *sql.TxOptions, f func(dml.QueryExecPreparer) error) error {
+	db, ok := t.DB.(txBeginner)
+	if !ok {
+		return errors.WithStack(f(t.DB))
+	}
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return errors.Wrapf(err, "[ddl] Table %q BeginTx", t.Name)
+	}
+	if err := f(tx); err != nil {
+		if rErr := tx.Rollback(); rErr != nil {
+			return errors.Wrapf(rErr, "[ddl] Table %q Rollback after error: %s", t.Name, err)
+		}
+		return errors.WithStack(err)
+	}
+	return errors.Wrapf(tx.Commit(), "[ddl] Table %q Commit", t.Name)
+}
+
 func (t *Table) whereByPK(op dml.Op) dml.Conditions {
 	cnds := make(dml.Conditions, 0, 1)
 	for _, pk := range t.columnsPK {
@@ -247,6 +247,61 @@ func TestTable_Drop(t *testing.T) {
 	})
 }
 
+func TestTable_RunInTx(t *testing.T) {
+	t.Parallel()
+
+	run := func(t *testing.T, wantErr error) error {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("DELETE FROM `admin_user` WHERE (`user_id` = 1)")).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		if wantErr == nil {
+			dbMock.ExpectCommit()
+		} else {
+			dbMock.ExpectRollback()
+		}
+
+		tbl := ddl.NewTable("admin_user")
+		tbl.DB = dbc.DB
+		return tbl.RunInTx(context.TODO(), nil, func(db dml.QueryExecPreparer) error {
+			if _, err := db.ExecContext(context.TODO(), "DELETE FROM `admin_user` WHERE (`user_id` = 1)"); err != nil {
+				return err
+			}
+			return wantErr
+		})
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		err := run(t, nil)
+		assert.NoError(t, err, "%+v", err)
+	})
+	t.Run("rollback", func(t *testing.T) {
+		err := run(t, errors.AlreadyExists.Newf("row exists"))
+		assert.True(t, errors.AlreadyExists.Match(err), "%+v", err)
+	})
+	t.Run("within transaction", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("DELETE FROM `admin_user` WHERE (`user_id` = 1)")).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectCommit()
+
+		tx, err := dbc.DB.Begin()
+		require.NoError(t, err)
+		tbl := ddl.NewTable("admin_user")
+		tbl.DB = tx
+		require.NoError(t, tbl.RunInTx(context.TODO(), nil, func(db dml.QueryExecPreparer) error {
+			_, err := db.ExecContext(context.TODO(), "DELETE FROM `admin_user` WHERE (`user_id` = 1)")
+			return err
+		}))
+		require.NoError(t, tx.Commit())
+	})
+}
+
 func TestTable_LoadDataInfile(t *testing.T) {
 	t.Parallel()
 
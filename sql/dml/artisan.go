@@ -54,6 +54,9 @@ type Artisan struct {
 	insertColumnCount   uint
 	insertRowCount      uint
 	insertIsBuildValues bool
+	// insertIsOnDuplicateKey skips the assignment of the last insert IDs
+	// because updated rows do not generate an ID.
+	insertIsOnDuplicateKey bool
 	//LimitValid            bool
 	// isPrepared if true the cachedSQL field in base gets ignored
 	isPrepared bool
@@ -1101,7 +1104,11 @@ func (a *Artisan) exec(ctx context.Context, args ...interface{}) (result sql.Res
 		return
 	}
 
-	if a.recs == nil {
+	if a.recs == nil || a.base.source != dmlSourceInsert || a.insertIsOnDuplicateKey {
+		// Only an INSERT statement generates new IDs. UPDATE or DELETE with
+		// records must not override the primary keys. With ON DUPLICATE KEY
+		// UPDATE the IDs are not consecutive because updated rows do not
+		// generate an ID.
 		return result, nil
 	}
 	lID, err := result.LastInsertId()
@@ -0,0 +1,559 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml
+
+import (
+	"encoding/base64"
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/corestoreio/errors"
+)
+
+// This file contains the reflection free JSON helper functions used by the
+// code generated with package dmlgen and the encoder "json". The functions
+// work without JSONMarshalFn and JSONUnMarshalFn.
+
+const jsonHex = "0123456789abcdef"
+
+// JSONAppendString appends s as a quoted and escaped JSON string to buf.
+// Invalid UTF-8 gets replaced by the Unicode replacement character. HTML
+// characters do not get escaped.
+func JSONAppendString(buf []byte, s string) []byte {
+	buf = append(buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' {
+				i++
+				continue
+			}
+			buf = append(buf, s[start:i]...)
+			switch b {
+			case '"', '\\':
+				buf = append(buf, '\\', b)
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			default:
+				buf = append(buf, '\\', 'u', '0', '0', jsonHex[b>>4], jsonHex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, `\ufffd`...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are valid JSON but break JavaScript.
+		if r == '\u2028' || r == '\u2029' {
+			buf = append(buf, s[start:i]...)
+			buf = append(buf, '\\', 'u', '2', '0', '2', jsonHex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	buf = append(buf, s[start:]...)
+	return append(buf, '"')
+}
+
+// JSONAppendBytes appends b as a base64 encoded JSON string to buf. A nil
+// slice gets encoded as null.
+func JSONAppendBytes(buf []byte, b []byte) []byte {
+	if b == nil {
+		return append(buf, sqlStrNullLC...)
+	}
+	buf = append(buf, '"')
+	n := base64.StdEncoding.EncodedLen(len(b))
+	if cap(buf)-len(buf) < n {
+		nb := make([]byte, len(buf), len(buf)+n+1)
+		copy(nb, buf)
+		buf = nb
+	}
+	base64.StdEncoding.Encode(buf[len(buf):len(buf)+n], b)
+	buf = buf[:len(buf)+n]
+	return append(buf, '"')
+}
+
+// JSONAppendTime appends t as a quoted RFC 3339 string with sub-second
+// precision to buf.
+func JSONAppendTime(buf []byte, t time.Time) []byte {
+	buf = append(buf, '"')
+	buf = t.AppendFormat(buf, time.RFC3339Nano)
+	return append(buf, '"')
+}
+
+// JSONAppendFloat64 appends f to buf using the same format as package
+// encoding/json. NaN and infinity cannot be represented in JSON and get
+// encoded as null.
+func JSONAppendFloat64(buf []byte, f float64) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return append(buf, sqlStrNullLC...)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	buf = strconv.AppendFloat(buf, f, format, -1, 64)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		if n := len(buf); n >= 4 && buf[n-4] == 'e' && buf[n-3] == '-' && buf[n-2] == '0' {
+			buf[n-2] = buf[n-1]
+			buf = buf[:n-1]
+		}
+	}
+	return buf
+}
+
+// JSONAppendDecimal appends d to buf. An invalid Decimal gets encoded as null.
+// Quoted decimals get written as a JSON string.
+func JSONAppendDecimal(buf []byte, d Decimal) []byte {
+	b, _ := d.MarshalJSON() // never returns an error
+	return append(buf, b...)
+}
+
+// JSONIsNull reports whether value contains the JSON literal null.
+func JSONIsNull(value []byte) bool {
+	return string(value) == sqlStrNullLC
+}
+
+func jsonSkipSpace(data []byte, i int) int {
+	for i < len(data) {
+		switch data[i] {
+		case ' ', '\t', '\n', '\r':
+			i++
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+// jsonScanValue returns the index after the JSON value starting at data[i].
+func jsonScanValue(data []byte, i int) (int, error) {
+	if i >= len(data) {
+		return 0, errors.NotValid.Newf("[dml] JSON: unexpected end of input")
+	}
+	switch data[i] {
+	case '"':
+		for j := i + 1; j < len(data); j++ {
+			switch data[j] {
+			case '\\':
+				j++
+			case '"':
+				return j + 1, nil
+			}
+		}
+		return 0, errors.NotValid.Newf("[dml] JSON: unterminated string at offset %d", i)
+	case '{', '[':
+		depth := 0
+		for j := i; j < len(data); j++ {
+			switch data[j] {
+			case '"':
+				end, err := jsonScanValue(data, j)
+				if err != nil {
+					return 0, err
+				}
+				j = end - 1
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return j + 1, nil
+				}
+			}
+		}
+		return 0, errors.NotValid.Newf("[dml] JSON: unterminated object or array at offset %d", i)
+	}
+	j := i
+	for ; j < len(data); j++ {
+		switch data[j] {
+		case ',', '}', ']', ' ', '\t', '\n', '\r':
+			return j, nil
+		}
+	}
+	return j, nil
+}
+
+// JSONObjectEach iterates over the top level keys of a JSON object and calls
+// fn with the unescaped key and the raw value. The JSON literal null as input
+// does not call fn.
+func JSONObjectEach(data []byte, fn func(key string, value []byte) error) error {
+	i := jsonSkipSpace(data, 0)
+	if i >= len(data) {
+		return errors.NotValid.Newf("[dml] JSON: expecting an object but got empty input")
+	}
+	if JSONIsNull(data[i:jsonSkipSpaceRight(data)]) {
+		return nil
+	}
+	if data[i] != '{' {
+		return errors.NotValid.Newf("[dml] JSON: expecting an object at offset %d", i)
+	}
+	i++
+	for {
+		i = jsonSkipSpace(data, i)
+		if i < len(data) && data[i] == '}' {
+			return nil
+		}
+		end, err := jsonScanValue(data, i)
+		if err != nil {
+			return err
+		}
+		if data[i] != '"' {
+			return errors.NotValid.Newf("[dml] JSON: expecting a key at offset %d", i)
+		}
+		key, err := JSONParseString(data[i:end])
+		if err != nil {
+			return err
+		}
+		i = jsonSkipSpace(data, end)
+		if i >= len(data) || data[i] != ':' {
+			return errors.NotValid.Newf("[dml] JSON: expecting a colon at offset %d", i)
+		}
+		i = jsonSkipSpace(data, i+1)
+		if end, err = jsonScanValue(data, i); err != nil {
+			return err
+		}
+		if err := fn(key, data[i:end]); err != nil {
+			return err
+		}
+		i = jsonSkipSpace(data, end)
+		if i >= len(data) {
+			return errors.NotValid.Newf("[dml] JSON: unexpected end of object")
+		}
+		switch data[i] {
+		case ',':
+			i++
+		case '}':
+			return nil
+		default:
+			return errors.NotValid.Newf("[dml] JSON: expecting a comma or closing brace at offset %d", i)
+		}
+	}
+}
+
+// JSONArrayEach iterates over the elements of a JSON array and calls fn with
+// the raw value of each element. The JSON literal null as input does not call
+// fn.
+func JSONArrayEach(data []byte, fn func(value []byte) error) error {
+	i := jsonSkipSpace(data, 0)
+	if i >= len(data) {
+		return errors.NotValid.Newf("[dml] JSON: expecting an array but got empty input")
+	}
+	if JSONIsNull(data[i:jsonSkipSpaceRight(data)]) {
+		return nil
+	}
+	if data[i] != '[' {
+		return errors.NotValid.Newf("[dml] JSON: expecting an array at offset %d", i)
+	}
+	i++
+	for {
+		i = jsonSkipSpace(data, i)
+		if i < len(data) && data[i] == ']' {
+			return nil
+		}
+		end, err := jsonScanValue(data, i)
+		if err != nil {
+			return err
+		}
+		if err := fn(data[i:end]); err != nil {
+			return err
+		}
+		i = jsonSkipSpace(data, end)
+		if i >= len(data) {
+			return errors.NotValid.Newf("[dml] JSON: unexpected end of array")
+		}
+		switch data[i] {
+		case ',':
+			i++
+		case ']':
+			return nil
+		default:
+			return errors.NotValid.Newf("[dml] JSON: expecting a comma or closing bracket at offset %d", i)
+		}
+	}
+}
+
+func jsonSkipSpaceRight(data []byte) int {
+	j := len(data)
+	for j > 0 {
+		switch data[j-1] {
+		case ' ', '\t', '\n', '\r':
+			j--
+		default:
+			return j
+		}
+	}
+	return j
+}
+
+// JSONParseString unquotes and unescapes a JSON string. null returns an empty
+// string.
+func JSONParseString(value []byte) (string, error) {
+	if JSONIsNull(value) {
+		return "", nil
+	}
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return "", errors.NotValid.Newf("[dml] JSON: cannot parse %q as string", value)
+	}
+	value = value[1 : len(value)-1]
+	hasEscape := false
+	for _, b := range value {
+		if b == '\\' {
+			hasEscape = true
+			break
+		}
+	}
+	if !hasEscape {
+		return string(value), nil
+	}
+
+	buf := make([]byte, 0, len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			buf = append(buf, value[i])
+			continue
+		}
+		i++
+		if i >= len(value) {
+			return "", errors.NotValid.Newf("[dml] JSON: invalid escape sequence in %q", value)
+		}
+		switch value[i] {
+		case '"', '\\', '/':
+			buf = append(buf, value[i])
+		case 'b':
+			buf = append(buf, '\b')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'r':
+			buf = append(buf, '\r')
+		case 't':
+			buf = append(buf, '\t')
+		case 'u':
+			r, ok := jsonParseHex4(value, i+1)
+			if !ok {
+				return "", errors.NotValid.Newf("[dml] JSON: invalid unicode escape sequence in %q", value)
+			}
+			i += 4
+			if utf16.IsSurrogate(r) {
+				if r2, ok := jsonParseHex4(value, i+3); ok && i+2 < len(value) && value[i+1] == '\\' && value[i+2] == 'u' {
+					if dec := utf16.DecodeRune(r, r2); dec != utf8.RuneError {
+						r = dec
+						i += 6
+					} else {
+						r = utf8.RuneError
+					}
+				} else {
+					r = utf8.RuneError
+				}
+			}
+			buf = append(buf, string(r)...)
+		default:
+			return "", errors.NotValid.Newf("[dml] JSON: invalid escape sequence in %q", value)
+		}
+	}
+	return string(buf), nil
+}
+
+func jsonParseHex4(value []byte, i int) (rune, bool) {
+	if i+4 > len(value) {
+		return 0, false
+	}
+	var r rune
+	for _, c := range value[i : i+4] {
+		switch {
+		case '0' <= c && c <= '9':
+			c = c - '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return 0, false
+		}
+		r = r*16 + rune(c)
+	}
+	return r, true
+}
+
+// JSONParseInt64 parses a JSON number. null returns 0.
+func JSONParseInt64(value []byte) (int64, error) {
+	if JSONIsNull(value) {
+		return 0, nil
+	}
+	i, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, errors.NotValid.Newf("[dml] JSON: cannot parse %q as int64: %s", value, err)
+	}
+	return i, nil
+}
+
+// JSONParseUint64 parses a JSON number. null returns 0.
+func JSONParseUint64(value []byte) (uint64, error) {
+	if JSONIsNull(value) {
+		return 0, nil
+	}
+	i, err := strconv.ParseUint(string(value), 10, 64)
+	if err != nil {
+		return 0, errors.NotValid.Newf("[dml] JSON: cannot parse %q as uint64: %s", value, err)
+	}
+	return i, nil
+}
+
+// JSONParseFloat64 parses a JSON number. null returns 0.
+func JSONParseFloat64(value []byte) (float64, error) {
+	if JSONIsNull(value) {
+		return 0, nil
+	}
+	f, err := strconv.ParseFloat(string(value), 64)
+	if err != nil {
+		return 0, errors.NotValid.Newf("[dml] JSON: cannot parse %q as float64: %s", value, err)
+	}
+	return f, nil
+}
+
+// JSONParseBool parses the JSON literals true and false. null returns false.
+func JSONParseBool(value []byte) (bool, error) {
+	switch string(value) {
+	case "true":
+		return true, nil
+	case "false", sqlStrNullLC:
+		return false, nil
+	}
+	return false, errors.NotValid.Newf("[dml] JSON: cannot parse %q as bool", value)
+}
+
+// JSONParseTime parses a quoted RFC 3339 string. null returns the zero time.
+func JSONParseTime(value []byte) (time.Time, error) {
+	if JSONIsNull(value) {
+		return time.Time{}, nil
+	}
+	s, err := JSONParseString(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, errors.NotValid.Newf("[dml] JSON: cannot parse %q as time: %s", value, err)
+	}
+	return t, nil
+}
+
+// JSONParseBytes decodes a base64 encoded JSON string. null returns nil.
+func JSONParseBytes(value []byte) ([]byte, error) {
+	if JSONIsNull(value) {
+		return nil, nil
+	}
+	s, err := JSONParseString(value)
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.NotValid.Newf("[dml] JSON: cannot decode %q as base64: %s", value, err)
+	}
+	return b, nil
+}
+
+// JSONParseNullString parses a JSON string. null returns an invalid
+// NullString.
+func JSONParseNullString(value []byte) (NullString, error) {
+	if JSONIsNull(value) {
+		return NullString{}, nil
+	}
+	s, err := JSONParseString(value)
+	if err != nil {
+		return NullString{}, err
+	}
+	return MakeNullString(s), nil
+}
+
+// JSONParseNullInt64 parses a JSON number. null returns an invalid NullInt64.
+func JSONParseNullInt64(value []byte) (NullInt64, error) {
+	if JSONIsNull(value) {
+		return NullInt64{}, nil
+	}
+	i, err := JSONParseInt64(value)
+	if err != nil {
+		return NullInt64{}, err
+	}
+	return MakeNullInt64(i), nil
+}
+
+// JSONParseNullFloat64 parses a JSON number. null returns an invalid
+// NullFloat64.
+func JSONParseNullFloat64(value []byte) (NullFloat64, error) {
+	if JSONIsNull(value) {
+		return NullFloat64{}, nil
+	}
+	f, err := JSONParseFloat64(value)
+	if err != nil {
+		return NullFloat64{}, err
+	}
+	return MakeNullFloat64(f), nil
+}
+
+// JSONParseNullBool parses the JSON literals true and false. null returns an
+// invalid NullBool.
+func JSONParseNullBool(value []byte) (NullBool, error) {
+	if JSONIsNull(value) {
+		return NullBool{}, nil
+	}
+	b, err := JSONParseBool(value)
+	if err != nil {
+		return NullBool{}, err
+	}
+	return MakeNullBool(b), nil
+}
+
+// JSONParseNullTime parses a quoted RFC 3339 string. null returns an invalid
+// NullTime.
+func JSONParseNullTime(value []byte) (NullTime, error) {
+	if JSONIsNull(value) {
+		return NullTime{}, nil
+	}
+	t, err := JSONParseTime(value)
+	if err != nil {
+		return NullTime{}, err
+	}
+	return MakeNullTime(t), nil
+}
+
+// JSONParseDecimal parses a quoted or unquoted JSON number. null returns an
+// invalid Decimal.
+func JSONParseDecimal(value []byte) (Decimal, error) {
+	var d Decimal
+	if JSONIsNull(value) {
+		return d, nil
+	}
+	err := d.UnmarshalJSON(value)
+	return d, err
+}
@@ -0,0 +1,218 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dml_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONAppendString(t *testing.T) {
+	t.Parallel()
+	tests := []string{
+		"",
+		"Hello World",
+		"quote \" backslash \\ slash /",
+		"tab\tnew line\nreturn\r",
+		"control \x00\x01\x1f",
+		"HTML <b>&amp;</b>",
+		"Ünicødé 世界 😀",
+		"separator \u2028\u2029",
+	}
+	for _, s := range tests {
+		buf := dml.JSONAppendString(nil, s)
+		var have string
+		require.NoError(t, json.Unmarshal(buf, &have), "%q", buf)
+		assert.Exactly(t, s, have)
+
+		parsed, err := dml.JSONParseString(buf)
+		require.NoError(t, err, "%q", buf)
+		assert.Exactly(t, s, parsed)
+	}
+	assert.Exactly(t, `"invalid \ufffd"`, string(dml.JSONAppendString(nil, "invalid \xff")))
+}
+
+func TestJSONParseString(t *testing.T) {
+	t.Parallel()
+	t.Run("escapes", func(t *testing.T) {
+		s, err := dml.JSONParseString([]byte(`"a\"b\\c\/d\b\f\n\r\tü😀"`))
+		require.NoError(t, err)
+		assert.Exactly(t, "a\"b\\c/d\b\f\n\r\tü😀", s)
+	})
+	t.Run("null", func(t *testing.T) {
+		s, err := dml.JSONParseString([]byte(`null`))
+		require.NoError(t, err)
+		assert.Empty(t, s)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		for _, in := range []string{`a`, `"a`, `"\x"`, `"\u12"`} {
+			_, err := dml.JSONParseString([]byte(in))
+			assert.True(t, errors.NotValid.Match(err), "%q: %+v", in, err)
+		}
+	})
+}
+
+func TestJSONAppendFloat64(t *testing.T) {
+	t.Parallel()
+	for _, f := range []float64{0, 1, -1.5, 3.14159, 1e-7, 1e21, 123456789.123} {
+		want, err := json.Marshal(f)
+		require.NoError(t, err)
+		assert.Exactly(t, string(want), string(dml.JSONAppendFloat64(nil, f)))
+	}
+	assert.Exactly(t, `null`, string(dml.JSONAppendFloat64(nil, math.NaN())))
+	assert.Exactly(t, `null`, string(dml.JSONAppendFloat64(nil, math.Inf(1))))
+}
+
+func TestJSONAppendBytes(t *testing.T) {
+	t.Parallel()
+	b := []byte("Gopher \x00\xff")
+	buf := dml.JSONAppendBytes([]byte(`x`), b)
+	want, err := json.Marshal(b)
+	require.NoError(t, err)
+	assert.Exactly(t, `x`+string(want), string(buf))
+
+	have, err := dml.JSONParseBytes(buf[1:])
+	require.NoError(t, err)
+	assert.Exactly(t, b, have)
+
+	assert.Exactly(t, `null`, string(dml.JSONAppendBytes(nil, nil)))
+	have, err = dml.JSONParseBytes([]byte(`null`))
+	require.NoError(t, err)
+	assert.Nil(t, have)
+}
+
+func TestJSONAppendTime(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2006, 1, 2, 15, 4, 5, 123456, time.FixedZone("Custom", 3600))
+	buf := dml.JSONAppendTime(nil, now)
+	want, err := now.MarshalJSON()
+	require.NoError(t, err)
+	assert.Exactly(t, string(want), string(buf))
+
+	have, err := dml.JSONParseTime(buf)
+	require.NoError(t, err)
+	assert.True(t, now.Equal(have))
+}
+
+func TestJSONObjectEach(t *testing.T) {
+	t.Parallel()
+	t.Run("nested values", func(t *testing.T) {
+		data := []byte(` { "a" : 1, "b\"c":"x,}", "d":{"e":[1,{"f":"]"}]}, "g":[], "h":null,"i":true } `)
+		var keys, values []string
+		require.NoError(t, dml.JSONObjectEach(data, func(key string, value []byte) error {
+			keys = append(keys, key)
+			values = append(values, string(value))
+			return nil
+		}))
+		assert.Exactly(t, []string{"a", "b\"c", "d", "g", "h", "i"}, keys)
+		assert.Exactly(t, []string{`1`, `"x,}"`, `{"e":[1,{"f":"]"}]}`, `[]`, `null`, `true`}, values)
+	})
+	t.Run("null and empty object", func(t *testing.T) {
+		called := false
+		fn := func(string, []byte) error { called = true; return nil }
+		require.NoError(t, dml.JSONObjectEach([]byte(` null `), fn))
+		require.NoError(t, dml.JSONObjectEach([]byte(`{ }`), fn))
+		assert.False(t, called)
+	})
+	t.Run("callback error", func(t *testing.T) {
+		err := dml.JSONObjectEach([]byte(`{"a":1}`), func(string, []byte) error {
+			return errors.NotAcceptable.Newf("stop")
+		})
+		assert.True(t, errors.NotAcceptable.Match(err), "%+v", err)
+	})
+	t.Run("invalid", func(t *testing.T) {
+		for _, in := range []string{``, `  `, `[]`, `{"a"}`, `{1:2}`, `{"a":1`, `{"a":1 "b":2}`, `{"a":"1}`} {
+			err := dml.JSONObjectEach([]byte(in), func(string, []byte) error { return nil })
+			assert.True(t, errors.NotValid.Match(err), "%q: %+v", in, err)
+		}
+	})
+}
+
+func TestJSONArrayEach(t *testing.T) {
+	t.Parallel()
+	var values []string
+	require.NoError(t, dml.JSONArrayEach([]byte(`[{"a":[1,2]}, "b" ,3,null]`), func(value []byte) error {
+		values = append(values, string(value))
+		return nil
+	}))
+	assert.Exactly(t, []string{`{"a":[1,2]}`, `"b"`, `3`, `null`}, values)
+
+	for _, in := range []string{``, `{}`, `[1,2`, `[1 2]`} {
+		err := dml.JSONArrayEach([]byte(in), func([]byte) error { return nil })
+		assert.True(t, errors.NotValid.Match(err), "%q: %+v", in, err)
+	}
+}
+
+func TestJSONParseNullTypes(t *testing.T) {
+	t.Parallel()
+	null := []byte(`null`)
+
+	ns, err := dml.JSONParseNullString([]byte(`"x"`))
+	require.NoError(t, err)
+	assert.Exactly(t, dml.MakeNullString("x"), ns)
+	ns, err = dml.JSONParseNullString(null)
+	require.NoError(t, err)
+	assert.False(t, ns.Valid)
+
+	ni, err := dml.JSONParseNullInt64([]byte(`-42`))
+	require.NoError(t, err)
+	assert.Exactly(t, dml.MakeNullInt64(-42), ni)
+	ni, err = dml.JSONParseNullInt64(null)
+	require.NoError(t, err)
+	assert.False(t, ni.Valid)
+	_, err = dml.JSONParseNullInt64([]byte(`1.5`))
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+
+	u, err := dml.JSONParseUint64([]byte(`18446744073709551615`))
+	require.NoError(t, err)
+	assert.Exactly(t, uint64(math.MaxUint64), u)
+
+	nf, err := dml.JSONParseNullFloat64([]byte(`2.5e3`))
+	require.NoError(t, err)
+	assert.Exactly(t, dml.MakeNullFloat64(2500), nf)
+
+	nb, err := dml.JSONParseNullBool([]byte(`true`))
+	require.NoError(t, err)
+	assert.Exactly(t, dml.MakeNullBool(true), nb)
+	_, err = dml.JSONParseNullBool([]byte(`1`))
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+
+	nt, err := dml.JSONParseNullTime(null)
+	require.NoError(t, err)
+	assert.False(t, nt.Valid)
+
+	d, err := dml.JSONParseDecimal([]byte(`"-12.345"`))
+	require.NoError(t, err)
+	assert.Exactly(t, "-12.345", d.String())
+	d, err = dml.JSONParseDecimal(null)
+	require.NoError(t, err)
+	assert.False(t, d.Valid)
+}
+
+func TestJSONAppendDecimal(t *testing.T) {
+	t.Parallel()
+	assert.Exactly(t, `x-12.345`, string(dml.JSONAppendDecimal([]byte(`x`), dml.MakeDecimalInt64(-12345, 3))))
+	d := dml.MakeDecimalInt64(5, 0)
+	d.Quote = true
+	assert.Exactly(t, `"5"`, string(dml.JSONAppendDecimal(nil, d)))
+	assert.Exactly(t, `null`, string(dml.JSONAppendDecimal(nil, dml.Decimal{})))
+}
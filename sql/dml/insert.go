@@ -87,6 +87,9 @@ type Insert struct {
 	// VALUES do not need to get build by default because mostly WithArgs gets
 	// called to build the VALUES part dynamically.
 	IsBuildValues bool
+	// onDuplicateKeyCached gets set when the build cache clears the field
+	// OnDuplicateKeys.
+	onDuplicateKeyCached bool
 	// Listeners allows to dispatch certain functions in different
 	// situations.
 	Listeners ListenersInsert
@@ -284,6 +287,7 @@ func (b *Insert) WithArgs() *Artisan {
 	}
 	a.insertRowCount = uint(b.RowCount)
 	a.insertIsBuildValues = b.IsBuildValues
+	a.insertIsOnDuplicateKey = b.hasOnDuplicateKey()
 	return a
 }
 
@@ -303,6 +307,7 @@ func (b *Insert) writeBuildCache(sql []byte, qualifiedColumns []string) {
 		b.cachedSQL = sql
 		b.Select = nil
 		b.Pairs = nil
+		b.onDuplicateKeyCached = b.onDuplicateKeyCached || len(b.OnDuplicateKeys) > 0
 		b.OnDuplicateKeys = nil
 		b.OnDuplicateKeyExclude = nil
 	}
@@ -468,7 +473,18 @@ func strInSlice(search string, sl []string) bool {
 // of the statement. The returned Stmter is not safe for concurrent use, despite
 // the underlying *sql.Stmt is.
 func (b *Insert) Prepare(ctx context.Context) (*Stmt, error) {
-	return b.prepare(ctx, b.DB, b, dmlSourceInsert)
+	stmt, err := b.prepare(ctx, b.DB, b, dmlSourceInsert)
+	if err != nil {
+		return nil, err
+	}
+	stmt.insertIsOnDuplicateKey = b.hasOnDuplicateKey()
+	return stmt, nil
+}
+
+// hasOnDuplicateKey reports whether the statement contains an ON DUPLICATE
+// KEY UPDATE clause.
+func (b *Insert) hasOnDuplicateKey() bool {
+	return b.IsOnDuplicateKey || len(b.OnDuplicateKeys) > 0 || b.onDuplicateKeyCached
 }
 
 // Clone creates a clone of the current object, leaving fields DB and Log
@@ -397,3 +397,60 @@ func TestInsert_Clone(t *testing.T) {
 		notEqualPointers(t, i.OnDuplicateKeys, i2.OnDuplicateKeys)
 	})
 }
+
+func TestInsert_AssignLastInsertID(t *testing.T) {
+	t.Parallel()
+
+	t.Run("assigned to all records", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `dml_person` (`name`,`email`) VALUES (?,?),(?,?)")).
+			WillReturnResult(sqlmock.NewResult(11, 2))
+
+		p1 := &dmlPerson{Name: "Peter Gopher"}
+		p2 := &dmlPerson{Name: "John Doe"}
+		_, err := dml.NewInsert("dml_person").AddColumns("name", "email").WithDB(dbc.DB).
+			WithArgs().Record("", p1).Record("", p2).ExecContext(context.TODO())
+		require.NoError(t, err)
+		assert.Exactly(t, int64(11), p1.ID)
+		assert.Exactly(t, int64(12), p2.ID)
+	})
+
+	t.Run("skipped with ON DUPLICATE KEY", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `dml_person` (`id`,`name`,`email`) VALUES (?,?,?),(?,?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`), `email`=VALUES(`email`)")).
+			WillReturnResult(sqlmock.NewResult(11, 3))
+
+		p1 := &dmlPerson{ID: 7, Name: "Peter Gopher"}
+		p2 := &dmlPerson{Name: "John Doe"}
+		_, err := dml.NewInsert("dml_person").AddColumns("id", "name", "email").
+			AddOnDuplicateKeyExclude("id").OnDuplicateKey().WithDB(dbc.DB).
+			WithArgs().Record("", p1).Record("", p2).ExecContext(context.TODO())
+		require.NoError(t, err)
+		assert.Exactly(t, int64(7), p1.ID, "ID of an updated row must not be overwritten")
+		assert.Exactly(t, int64(0), p2.ID)
+	})
+
+	t.Run("skipped with ON DUPLICATE KEY prepared", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		prep := dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta("INSERT INTO `dml_person` (`id`,`name`) VALUES (?,?) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)"))
+		prep.ExpectExec().WithArgs(int64(7), "Peter Gopher").WillReturnResult(sqlmock.NewResult(11, 2))
+
+		stmt, err := dml.NewInsert("dml_person").AddColumns("id", "name").BuildValues().
+			AddOnDuplicateKey(dml.Column("name").Values()).WithDB(dbc.DB).Prepare(context.TODO())
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, stmt.Close(), "Close on a prepared statement")
+		}()
+
+		p := &dmlPerson{ID: 7, Name: "Peter Gopher"}
+		_, err = stmt.WithArgs().Record("", p).ExecContext(context.TODO())
+		require.NoError(t, err)
+		assert.Exactly(t, int64(7), p.ID)
+	})
+}
@@ -64,6 +64,8 @@ func (sw stmtWrapper) QueryRowContext(ctx context.Context, sql string, args ...i
 type Stmt struct {
 	base builderCommon
 	Stmt *sql.Stmt
+	// insertIsOnDuplicateKey see Artisan.insertIsOnDuplicateKey
+	insertIsOnDuplicateKey bool
 }
 
 // WithArgs creates a new argument handler.
@@ -74,6 +76,7 @@ func (st *Stmt) WithArgs() *Artisan {
 		arguments:  args[:0],
 		isPrepared: true,
 	}
+	a.insertIsOnDuplicateKey = st.insertIsOnDuplicateKey
 	a.base.DB = stmtWrapper{stmt: st.Stmt}
 	return a
 }
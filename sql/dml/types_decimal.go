@@ -164,6 +164,29 @@ func (d Decimal) Float64() (value float64) {
 	return value
 }
 
+// FitsColumn reports whether the value can be stored in a column of type
+// DECIMAL(precision,scale) without losing digits: at most precision-scale
+// digits before and at most scale digits after the decimal point. Trailing
+// zeros after the decimal point get ignored. A NULL value always fits.
+func (d Decimal) FitsColumn(precision, scale int32) bool {
+	if !d.Valid {
+		return true
+	}
+	v, s := d.Precision, d.Scale
+	for s > scale && v%10 == 0 {
+		v /= 10
+		s--
+	}
+	if s > scale {
+		return false
+	}
+	var digits int32
+	for ; v > 0; v /= 10 {
+		digits++
+	}
+	return digits-s <= precision-scale
+}
+
 // String returns the string representation of the fixed with decimal. Returns
 // the word `NULL` if the current value is not valid, for now.
 func (d Decimal) String() string {
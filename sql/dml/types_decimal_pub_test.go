@@ -424,6 +424,30 @@ func TestDecimal_Float64(t *testing.T) {
 	})
 }
 
+func TestDecimal_FitsColumn(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		d    dml.Decimal
+		want bool
+	}{
+		{dml.Decimal{}, true},
+		{dml.Decimal{Valid: true}, true},
+		{dml.MakeDecimalInt64(99999999, 4), true},   // 9999.9999
+		{dml.MakeDecimalInt64(-99999999, 4), true},  // -9999.9999
+		{dml.MakeDecimalInt64(100000000, 4), false}, // 10000.0000
+		{dml.MakeDecimalInt64(123456, 5), false},    // 1.23456
+		{dml.MakeDecimalInt64(1234500, 6), true},    // 1.234500
+		{dml.MakeDecimalInt64(12, 0), true},         // 12
+		{dml.MakeDecimalInt64(12345, 0), false},     // 12345
+		{dml.MakeDecimalInt64(5, -4), false},        // 50000
+		{dml.Decimal{Valid: true, Precision: math.MaxUint64}, false},
+	}
+	for _, test := range tests {
+		assert.Exactly(t, test.want, test.d.FitsColumn(8, 4), "%s", test.d)
+	}
+}
+
 func TestDecimal_Scan(t *testing.T) {
 	t.Parallel()
 
@@ -15,7 +15,9 @@ func New{{.Entity}}() *{{.Entity}} {
 // AssignLastInsertID updates the increment ID field with the last inserted ID
 // from an INSERT operation. Implements dml.InsertIDAssigner. Auto generated.
 func (e *{{.Entity}}) AssignLastInsertID(id int64) {
-	{{range .Columns}}{{if .IsPK}} e.{{ToGoCamelCase .Field}} = {{GoTypeNull .}}(id) {{end}} {{end}}
+	{{- range .Columns}}{{if .IsAutoIncrement}}
+	e.{{ToGoCamelCase .Field}} = {{GoTypeNull .}}(id)
+	{{- end}}{{end}}
 }
 
 // MapColumns implements interface ColumnMapper only partially. Auto generated.
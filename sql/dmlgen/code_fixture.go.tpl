@@ -0,0 +1,35 @@
+
+// NewFixture{{.Entity}} creates a new entity for table `{{.TableName}}`
+// filled with random but valid values. Columns referencing other tables get
+// their values from refs, if available. Auto increment columns stay empty.
+// Returns an error if a column is too short for its random value. Auto
+// generated.
+func NewFixture{{.Entity}}(r *rand.Rand, refs ddl.FixtureRefs) (*{{.Entity}}, error) {
+	e := new({{.Entity}})
+	{{- range .Columns}}{{GoFixture $.TableName .}}{{end}}
+	return e, nil
+}
+
+// Fixture{{.Entity}} returns the fixture which inserts n random rows into
+// table `{{.TableName}}`. Use it with function ddl.SeedFixtures. Auto
+// generated.
+func Fixture{{.Entity}}(n int) ddl.Fixture {
+	return ddl.Fixture{
+		TableName: "{{.TableName}}",
+		Count:     n,
+		Insert: func(ctx context.Context, db *dml.ConnPool, r *rand.Rand, refs ddl.FixtureRefs) error {
+			e, err := NewFixture{{.Entity}}(r, refs)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			ins := dml.NewInsert("{{.TableName}}").AddColumns({{range .Columns}}{{if not .IsAutoIncrement}}"{{.Field}}", {{end}}{{end}})
+			if _, err := ins.SetRecordPlaceHolderCount(len(ins.Columns)).WithDB(db.DB).WithArgs().Record("", e).ExecContext(ctx); err != nil {
+				return errors.WithStack(err)
+			}
+			{{- range .Columns}}{{if or .IsPK .Aliases}}
+			refs.Add("{{$.TableName}}.{{.Field}}", e.{{ToGoCamelCase .Field}})
+			{{- end}}{{end}}
+			return nil
+		},
+	}
+}
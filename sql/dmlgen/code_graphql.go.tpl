@@ -0,0 +1,14 @@
+
+# {{.Entity}} represents a single row for DB table `{{.TableName}}`. Auto generated.
+type {{.Entity}} {
+	{{- range .Columns}}
+	{{.Field}}: {{GraphQLType .}}
+	{{- end}}
+}
+
+# {{.Entity}}Input creates or updates a single row in DB table `{{.TableName}}`. Auto generated.
+input {{.Entity}}Input {
+	{{- range .Columns}}
+	{{.Field}}: {{GraphQLInputType .}}
+	{{- end}}
+}
@@ -0,0 +1,14 @@
+# Auto generated via github.com/corestoreio/pkg/sql/dmlgen
+
+# Int64 represents a signed 64 bit integer of a BIGINT column.
+scalar Int64
+# UInt64 represents an unsigned 64 bit integer of an INT UNSIGNED or BIGINT
+# UNSIGNED column.
+scalar UInt64
+# Decimal represents the fixed point number of a DECIMAL column. It gets
+# encoded as a JSON number or string.
+scalar Decimal
+# Time represents a date and time in the RFC 3339 format.
+scalar Time
+# Bytes represents base64 encoded binary data.
+scalar Bytes
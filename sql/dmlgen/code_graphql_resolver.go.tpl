@@ -0,0 +1,88 @@
+
+// {{.Entity}}Resolver resolves the GraphQL Query and Mutation fields of DB
+// table `{{.TableName}}` with the repository methods. Embed it into the root
+// resolver of the GraphQL server. The schema gets written by
+// Tables.WriteGraphQL. Auto generated.
+type {{.Entity}}Resolver struct {
+	Tables *ddl.Tables
+	// MaxLimit caps the argument limit of the query field
+	// `{{LowerFirst .Collection}}`. Defaults to 100 if zero.
+	MaxLimit int32
+}
+{{- if .Columns.PrimaryKeys}}
+
+// {{.Entity}} resolves the query field `{{LowerFirst .Entity}}`. Returns nil if
+// the row does not exist. Auto generated.
+func (r {{.Entity}}Resolver) {{.Entity}}(ctx context.Context{{range .Columns.PrimaryKeys}}, pk{{ToGoCamelCase .Field}} {{GoType .}}{{end}}) (*{{.Entity}}, error) {
+	e := new({{.Entity}})
+	err := e.Load(ctx, r.Tables{{range .Columns.PrimaryKeys}}, pk{{ToGoCamelCase .Field}}{{end}})
+	switch {
+	case errors.NotFound.Match(err):
+		return nil, nil
+	case err != nil:
+		return nil, errors.WithStack(err)
+	}
+	return e, nil
+}
+{{- end}}
+
+// {{.Collection}} resolves the query field `{{LowerFirst .Collection}}`
+// and returns at most `limit` rows starting at `offset`. A limit smaller than
+// one or greater than MaxLimit gets set to MaxLimit. Auto generated.
+func (r {{.Entity}}Resolver) {{.Collection}}(ctx context.Context, offset, limit int32) ([]*{{.Entity}}, error) {
+	maxLimit := r.MaxLimit
+	if maxLimit < 1 {
+		maxLimit = 100
+	}
+	if limit < 1 || limit > maxLimit {
+		limit = maxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	tbl, err := r.Tables.Table(TableName{{.Entity}})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cc := Make{{.Collection}}()
+	if _, err := tbl.SelectAll().Limit(uint64(offset), uint64(limit)).WithArgs().Load(ctx, &cc); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return cc.Data, nil
+}
+
+// Create{{.Entity}} resolves the mutation field `create{{.Entity}}`. Auto
+// generated.
+func (r {{.Entity}}Resolver) Create{{.Entity}}(ctx context.Context, input *{{.Entity}}) (*{{.Entity}}, error) {
+	if _, err := input.Insert(ctx, r.Tables); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return input, nil
+}
+{{- if .Columns.PrimaryKeys}}
+
+// Update{{.Entity}} resolves the mutation field `update{{.Entity}}`. The
+// primary key of the input identifies the row. Auto generated.
+func (r {{.Entity}}Resolver) Update{{.Entity}}(ctx context.Context, input *{{.Entity}}) (*{{.Entity}}, error) {
+	if _, err := input.Update(ctx, r.Tables); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return input, nil
+}
+
+// Delete{{.Entity}} resolves the mutation field `delete{{.Entity}}`. Returns
+// false if the row does not exist. Auto generated.
+func (r {{.Entity}}Resolver) Delete{{.Entity}}(ctx context.Context{{range .Columns.PrimaryKeys}}, pk{{ToGoCamelCase .Field}} {{GoType .}}{{end}}) (bool, error) {
+	e := &{{.Entity}}{
+		{{- range .Columns.PrimaryKeys}}
+		{{ToGoCamelCase .Field}}: pk{{ToGoCamelCase .Field}},
+		{{- end}}
+	}
+	res, err := e.Delete(ctx, r.Tables)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, errors.WithStack(err)
+}
+{{- end}}
@@ -0,0 +1,19 @@
+
+type Query {
+	{{- range .}}{{$e := ToGoCamelCase .TableName}}
+	{{- if .Columns.PrimaryKeys}}
+	{{LowerFirst $e}}({{range $i, $c := .Columns.PrimaryKeys}}{{if $i}}, {{end}}{{$c.Field}}: {{GraphQLType $c}}{{end}}): {{$e}}
+	{{- end}}
+	{{LowerFirst $e}}Collection(offset: Int! = 0, limit: Int! = 100): [{{$e}}!]!
+	{{- end}}
+}
+
+type Mutation {
+	{{- range .}}{{$e := ToGoCamelCase .TableName}}
+	create{{$e}}(input: {{$e}}Input!): {{$e}}!
+	{{- if .Columns.PrimaryKeys}}
+	update{{$e}}(input: {{$e}}Input!): {{$e}}!
+	delete{{$e}}({{range $i, $c := .Columns.PrimaryKeys}}{{if $i}}, {{end}}{{$c.Field}}: {{GraphQLType $c}}{{end}}): Boolean!
+	{{- end}}
+	{{- end}}
+}
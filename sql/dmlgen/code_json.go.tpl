@@ -0,0 +1,70 @@
+
+// AppendJSON appends the JSON encoding of the entity to buf without using
+// reflection. The object keys are the names of the json struct tags or the
+// column names of table `{{.TableName}}`.{{if .JSONOmitEmpty}} Empty fields are omitted.{{end}} Auto
+// generated.
+func (e *{{.Entity}}) AppendJSON(buf []byte) ([]byte, error) {
+	buf = append(buf, '{')
+	{{- range .Columns}}
+	{{GoJSONMarshal $.JSONOmitEmpty .}}
+	{{- end}}
+	if buf[len(buf)-1] == ',' {
+		buf = buf[:len(buf)-1]
+	}
+	return append(buf, '}'), nil
+}
+
+// MarshalJSON implements interface json.Marshaler. Auto generated.
+func (e *{{.Entity}}) MarshalJSON() ([]byte, error) {
+	return e.AppendJSON(make([]byte, 0, {{len .Columns}}*16))
+}
+
+// UnmarshalJSON implements interface json.Unmarshaler without using
+// reflection. Unknown keys are ignored. Auto generated.
+func (e *{{.Entity}}) UnmarshalJSON(data []byte) error {
+	return dml.JSONObjectEach(data, func(key string, value []byte) (err error) {
+		switch key {
+		{{- range .Columns}}
+		{{GoJSONUnmarshal .}}
+		{{- end}}
+		}
+		if err != nil {
+			return errors.Wrapf(err, "[{{.Package}}] {{.Entity}}.UnmarshalJSON key %q", key)
+		}
+		return nil
+	})
+}
+
+// AppendJSON appends the JSON array of all entities to buf. Auto generated.
+func (cc *{{.Collection}}) AppendJSON(buf []byte) (_ []byte, err error) {
+	buf = append(buf, '[')
+	for i, e := range cc.Data {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if buf, err = e.AppendJSON(buf); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return append(buf, ']'), nil
+}
+
+// MarshalJSON implements interface json.Marshaler. Auto generated.
+func (cc *{{.Collection}}) MarshalJSON() ([]byte, error) {
+	return cc.AppendJSON(make([]byte, 0, len(cc.Data)*{{len .Columns}}*16+2))
+}
+
+// UnmarshalJSON implements interface json.Unmarshaler and replaces the
+// entities of the collection with the entities of the JSON array. Auto
+// generated.
+func (cc *{{.Collection}}) UnmarshalJSON(data []byte) error {
+	cc.Data = cc.Data[:0]
+	return dml.JSONArrayEach(data, func(value []byte) error {
+		e := new({{.Entity}})
+		if err := e.UnmarshalJSON(value); err != nil {
+			return errors.WithStack(err)
+		}
+		cc.Data = append(cc.Data, e)
+		return nil
+	})
+}
@@ -0,0 +1,219 @@
+// TableName{{.Entity}} defines the name of the DB table `{{.TableName}}`. Auto
+// generated.
+const TableName{{.Entity}} = "{{.TableName}}"
+
+// Load loads a single row from table `{{.TableName}}` identified by its primary
+// key into the entity. Returns a NotFound error if the row does not exist.
+// Auto generated.
+func (e *{{.Entity}}) Load(ctx context.Context, tbls *ddl.Tables{{range .Columns.PrimaryKeys}}, pk{{ToGoCamelCase .Field}} {{GoType .}}{{end}}) error {
+	tbl, err := tbls.Table(TableName{{.Entity}})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	rowCount, err := tbl.SelectByPK().WithArgs().Load(ctx, e{{range .Columns.PrimaryKeys}}, pk{{ToGoCamelCase .Field}}{{end}})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if rowCount == 0 {
+		return errors.NotFound.Newf("[{{.Package}}] {{.Entity}} not found")
+	}
+	return nil
+}
+
+// Insert inserts the entity into table `{{.TableName}}`. An auto increment ID
+// gets assigned to the entity. Auto generated.
+func (e *{{.Entity}}) Insert(ctx context.Context, tbls *ddl.Tables) (sql.Result, error) {
+{{- if .Validation}}
+	if err := e.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+{{- end}}
+	tbl, err := tbls.Table(TableName{{.Entity}})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	res, err := repositoryInsert{{.Entity}}(tbl).WithArgs().Record("", e).ExecContext(ctx)
+	return res, errors.WithStack(err)
+}
+
+// Upsert inserts the entity into table `{{.TableName}}` or updates all non
+// primary key columns if the primary key already exists. An auto increment ID
+// does not get assigned because an updated row does not generate one. Auto
+// generated.
+func (e *{{.Entity}}) Upsert(ctx context.Context, tbls *ddl.Tables) (sql.Result, error) {
+{{- if .Validation}}
+	if err := e.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+{{- end}}
+	tbl, err := tbls.Table(TableName{{.Entity}})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	res, err := repositoryUpsert{{.Entity}}(tbl).WithArgs().Record("", e).ExecContext(ctx)
+	return res, errors.WithStack(err)
+}
+
+// Update updates all non primary key columns of the entity in table
+// `{{.TableName}}`. The primary key identifies the row. Auto generated.
+func (e *{{.Entity}}) Update(ctx context.Context, tbls *ddl.Tables) (sql.Result, error) {
+{{- if .Validation}}
+	if err := e.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+{{- end}}
+	tbl, err := tbls.Table(TableName{{.Entity}})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	res, err := tbl.UpdateByPK().WithArgs().Record("", e).ExecContext(ctx)
+	return res, errors.WithStack(err)
+}
+
+// Delete deletes the entity, identified by its primary key, from table
+// `{{.TableName}}`. Auto generated.
+func (e *{{.Entity}}) Delete(ctx context.Context, tbls *ddl.Tables) (sql.Result, error) {
+	tbl, err := tbls.Table(TableName{{.Entity}})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	res, err := tbl.DeleteByPK().WithArgs().Record("", e).ExecContext(ctx)
+	return res, errors.WithStack(err)
+}
+
+// repositoryInsert{{.Entity}} creates the INSERT statement for table
+// `{{.TableName}}`. Primary key columns without auto increment must be part of
+// the statement. Auto generated.
+func repositoryInsert{{.Entity}}(tbl *ddl.Table) *dml.Insert {
+	ins := tbl.Insert()
+	{{- range .Columns.PrimaryKeys}}{{if not .IsAutoIncrement}}
+	ins.AddColumns("{{.Field}}")
+	{{- end}}{{end}}
+	return ins.SetRecordPlaceHolderCount(len(ins.Columns))
+}
+
+// repositoryUpsert{{.Entity}} creates the INSERT ... ON DUPLICATE KEY UPDATE
+// statement for table `{{.TableName}}`. Auto generated.
+func repositoryUpsert{{.Entity}}(tbl *ddl.Table) *dml.Insert {
+	ins := tbl.Insert()
+	{{- range .Columns.PrimaryKeys}}
+	ins.AddColumns("{{.Field}}").AddOnDuplicateKeyExclude("{{.Field}}")
+	{{- end}}
+	return ins.SetRecordPlaceHolderCount(len(ins.Columns)).OnDuplicateKey()
+}
+
+// LoadAll loads all rows from table `{{.TableName}}` into the collection. The
+// optional conditions get added to the WHERE clause. Auto generated.
+func (cc *{{.Collection}}) LoadAll(ctx context.Context, tbls *ddl.Tables, wheres ...*dml.Condition) error {
+	tbl, err := tbls.Table(TableName{{.Entity}})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = tbl.SelectAll().Where(wheres...).WithArgs().Load(ctx, cc)
+	return errors.WithStack(err)
+}
+{{- if eq (len .Columns.PrimaryKeys) 1}}{{with .Columns.PrimaryKeys.First}}
+
+// Load loads all rows from table `{{$.TableName}}` identified by the list of
+// primary keys into the collection. Auto generated.
+func (cc *{{$.Collection}}) Load(ctx context.Context, tbls *ddl.Tables, pk{{ToGoCamelCase .Field}}s ...{{GoType .}}) error {
+	tbl, err := tbls.Table(TableName{{$.Entity}})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = tbl.SelectByPK().WithArgs().{{GoFunc .}}s(pk{{ToGoCamelCase .Field}}s...).ExpandPlaceHolders().Load(ctx, cc)
+	return errors.WithStack(err)
+}
+{{- end}}{{end}}
+
+// Insert inserts all entities of the collection with one statement into table
+// `{{.TableName}}`. Auto increment IDs get assigned to the entities. Auto
+// generated.
+func (cc *{{.Collection}}) Insert(ctx context.Context, tbls *ddl.Tables) (sql.Result, error) {
+	if len(cc.Data) == 0 {
+		return nil, nil
+	}
+{{- if .Validation}}
+	if err := cc.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+{{- end}}
+	tbl, err := tbls.Table(TableName{{.Entity}})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	a := repositoryInsert{{.Entity}}(tbl).WithArgs()
+	for _, e := range cc.Data {
+		a.Record("", e)
+	}
+	res, err := a.ExecContext(ctx)
+	return res, errors.WithStack(err)
+}
+
+// Upsert inserts or updates all entities of the collection with one statement
+// in table `{{.TableName}}`. Auto increment IDs do not get assigned. Auto
+// generated.
+func (cc *{{.Collection}}) Upsert(ctx context.Context, tbls *ddl.Tables) (sql.Result, error) {
+	if len(cc.Data) == 0 {
+		return nil, nil
+	}
+{{- if .Validation}}
+	if err := cc.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+{{- end}}
+	tbl, err := tbls.Table(TableName{{.Entity}})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	a := repositoryUpsert{{.Entity}}(tbl).WithArgs()
+	for _, e := range cc.Data {
+		a.Record("", e)
+	}
+	res, err := a.ExecContext(ctx)
+	return res, errors.WithStack(err)
+}
+
+// Update updates each entity of the collection in table `{{.TableName}}`. The
+// statement gets built once and executed for each entity within one
+// transaction, see ddl.Table.RunInTx. Auto generated.
+func (cc *{{.Collection}}) Update(ctx context.Context, tbls *ddl.Tables) error {
+{{- if .Validation}}
+	if err := cc.Validate(); err != nil {
+		return errors.WithStack(err)
+	}
+{{- end}}
+	tbl, err := tbls.Table(TableName{{.Entity}})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return tbl.RunInTx(ctx, nil, func(db dml.QueryExecPreparer) error {
+		a := tbl.UpdateByPK().WithArgs().WithDB(db)
+		for _, e := range cc.Data {
+			if _, err := a.Record("", e).ExecContext(ctx); err != nil {
+				return errors.WithStack(err)
+			}
+			a.Reset()
+		}
+		return nil
+	})
+}
+
+// Delete deletes each entity of the collection from table `{{.TableName}}`
+// within one transaction, see ddl.Table.RunInTx. Auto generated.
+func (cc *{{.Collection}}) Delete(ctx context.Context, tbls *ddl.Tables) error {
+	tbl, err := tbls.Table(TableName{{.Entity}})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return tbl.RunInTx(ctx, nil, func(db dml.QueryExecPreparer) error {
+		a := tbl.DeleteByPK().WithArgs().WithDB(db)
+		for _, e := range cc.Data {
+			if _, err := a.Record("", e).ExecContext(ctx); err != nil {
+				return errors.WithStack(err)
+			}
+			a.Reset()
+		}
+		return nil
+	})
+}
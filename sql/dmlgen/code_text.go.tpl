@@ -1,3 +1,4 @@
+{{- if not .JSONMarshaler}}
 // UnmarshalJSON implements interface json.Unmarshaler.
 func (cc *{{$.Collection}}) UnmarshalJSON(b []byte) (err error) {
 	return json.Unmarshal(b, cc.Data)
@@ -7,5 +8,6 @@ func (cc *{{$.Collection}}) UnmarshalJSON(b []byte) (err error) {
 func (cc *{{$.Collection}}) MarshalJSON() ([]byte, error) {
 	return json.Marshal(cc.Data)
 }
+{{- end}}
 
 // TODO add MarshalText and UnmarshalText.
@@ -0,0 +1,20 @@
+
+// Validate checks the fields of the entity against the constraints of the
+// columns in table `{{.TableName}}`: maximum length, unsigned numbers, integer
+// ranges, DECIMAL precision and scale, ENUM and SET members and NOT NULL columns
+// without a default value. Returns the first NotValid error. Auto generated.
+func (e *{{.Entity}}) Validate() error {
+	{{- $prefix := printf "[%s] %s" .Package .Entity}}
+	{{- range .Columns}}{{GoValidation $prefix .}}{{end}}
+	return nil
+}
+
+// Validate checks each entity of the collection. Auto generated.
+func (cc {{.Collection}}) Validate() error {
+	for _, e := range cc.Data {
+		if err := e.Validate(); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,415 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmlgen
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"gopkg.in/yaml.v2"
+)
+
+// Config defines the settings to generate the Go and Protocol Buffer source
+// code without writing a Go program. The configuration can be loaded from a
+// YAML or TOML file with function LoadConfig. Either the DSN or the
+// SchemaCSV must be provided.
+type Config struct {
+	// Package defines the name of the Go package. Required.
+	Package string `yaml:"package" toml:"package"`
+	// DSN data source name to connect to a MySQL/MariaDB database and to read
+	// the information_schema.
+	DSN string `yaml:"dsn" toml:"dsn"`
+	// SchemaCSV defines the path to an offline CSV dump of the
+	// information_schema.COLUMNS table. The CSV file must contain a header
+	// line with the column names. If set, the DSN gets ignored.
+	SchemaCSV string `yaml:"schema_csv" toml:"schema_csv"`
+	// KeyColumnUsageCSV defines the optional path to an offline CSV dump of
+	// the information_schema.KEY_COLUMN_USAGE table. If set, the foreign keys
+	// become column aliases.
+	KeyColumnUsageCSV string `yaml:"key_column_usage_csv" toml:"key_column_usage_csv"`
+	// Tables contains a list of table names or glob patterns as defined in
+	// path.Match. For example: catalog_product_* or core_config_data. Empty
+	// Tables selects all available tables.
+	Tables []string `yaml:"tables" toml:"tables"`
+	// ForeignKeyAliases loads the foreign keys via the DSN and sets the
+	// referencing columns as aliases. See WithColumnAliasesFromForeignKeys.
+	ForeignKeyAliases bool `yaml:"foreign_key_aliases" toml:"foreign_key_aliases"`
+	// TableOptions maps a table name or a glob pattern to its options.
+	TableOptions map[string]ConfigTableOption `yaml:"table_options" toml:"table_options"`
+	// OutputGo defines the path to the generated Go file. Required.
+	OutputGo string `yaml:"output_go" toml:"output_go"`
+	// OutputProto defines the path to the generated proto file. The file only
+	// gets written if at least one table has the protobuf encoder enabled.
+	OutputProto string `yaml:"output_proto" toml:"output_proto"`
+	// GenerateProto runs protoc in the directory of OutputProto. See function
+	// GenerateProto.
+	GenerateProto bool `yaml:"generate_proto" toml:"generate_proto"`
+	// OutputGraphQL defines the path to the generated GraphQL schema file. The
+	// file only gets written if at least one table has the graphql encoder
+	// enabled.
+	OutputGraphQL       string `yaml:"output_graphql" toml:"output_graphql"`
+	DisableTableSchemas bool   `yaml:"disable_table_schemas" toml:"disable_table_schemas"`
+}
+
+// ConfigTableOption same as TableOption but can be read from a YAML or TOML
+// file.
+type ConfigTableOption struct {
+	Encoders   []string `yaml:"encoders" toml:"encoders"`
+	StructTags []string `yaml:"struct_tags" toml:"struct_tags"`
+	// CustomStructTags maps the column name to its struct tag.
+	CustomStructTags  map[string]string   `yaml:"custom_struct_tags" toml:"custom_struct_tags"`
+	Comment           string              `yaml:"comment" toml:"comment"`
+	ColumnAliases     map[string][]string `yaml:"column_aliases" toml:"column_aliases"`
+	UniquifiedColumns []string            `yaml:"uniquified_columns" toml:"uniquified_columns"`
+	Repository        bool                `yaml:"repository" toml:"repository"`
+	Validation        bool                `yaml:"validation" toml:"validation"`
+	JSONOmitEmpty     bool                `yaml:"json_omit_empty" toml:"json_omit_empty"`
+	Fixture           bool                `yaml:"fixture" toml:"fixture"`
+}
+
+func (cto ConfigTableOption) tableOption() *TableOption {
+	cols := make([]string, 0, len(cto.CustomStructTags))
+	for col := range cto.CustomStructTags {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	var cst []string
+	for _, col := range cols {
+		cst = append(cst, col, cto.CustomStructTags[col])
+	}
+	return &TableOption{
+		Encoders:          cto.Encoders,
+		StructTags:        cto.StructTags,
+		CustomStructTags:  cst,
+		Comment:           cto.Comment,
+		ColumnAliases:     cto.ColumnAliases,
+		UniquifiedColumns: cto.UniquifiedColumns,
+		Repository:        cto.Repository,
+		Validation:        cto.Validation,
+		JSONOmitEmpty:     cto.JSONOmitEmpty,
+		Fixture:           cto.Fixture,
+	}
+}
+
+// LoadConfig reads a YAML or TOML configuration file. The file extension
+// determines the format: .yaml, .yml or .toml. Relative paths in the
+// configuration are relative to the directory of the configuration file.
+// LoadConfig does not validate the configuration, so that values like the DSN
+// can be overwritten before calling Validate. NewTables validates too.
+func LoadConfig(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[dmlgen] LoadConfig failed to read file %q", file)
+	}
+	c := new(Config)
+	switch ext := strings.ToLower(filepath.Ext(file)); ext {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, c)
+	case ".toml":
+		_, err = toml.Decode(string(data), c)
+	default:
+		return nil, errors.NotSupported.Newf("[dmlgen] LoadConfig file extension %q not supported in file %q", ext, file)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "[dmlgen] LoadConfig failed to decode file %q", file)
+	}
+
+	dir := filepath.Dir(file)
+	for _, p := range []*string{&c.SchemaCSV, &c.KeyColumnUsageCSV, &c.OutputGo, &c.OutputProto, &c.OutputGraphQL} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	return c, nil
+}
+
+// Validate checks if all required fields are set.
+func (c *Config) Validate() error {
+	switch {
+	case c.Package == "":
+		return errors.Empty.Newf("[dmlgen] Config.Package cannot be empty")
+	case c.DSN == "" && c.SchemaCSV == "":
+		return errors.Empty.Newf("[dmlgen] Config requires either a DSN or a SchemaCSV")
+	case c.OutputGo == "":
+		return errors.Empty.Newf("[dmlgen] Config.OutputGo cannot be empty")
+	}
+	for _, pattern := range append(c.Tables, c.tableOptionPatterns()...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return errors.NotValid.Newf("[dmlgen] Config invalid table pattern %q: %s", pattern, err)
+		}
+	}
+	return nil
+}
+
+func (c *Config) tableOptionPatterns() []string {
+	ret := make([]string, 0, len(c.TableOptions))
+	for pattern := range c.TableOptions {
+		ret = append(ret, pattern)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// matchTable reports whether the table name matches one of the Tables
+// patterns.
+func (c *Config) matchTable(tableName string) bool {
+	if len(c.Tables) == 0 {
+		return true
+	}
+	for _, pattern := range c.Tables {
+		if ok, _ := path.Match(pattern, tableName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// NewTables loads the table schemas either from the database or from the CSV
+// files and applies all table options. Argument db can be nil if the
+// SchemaCSV has been set.
+func (c *Config) NewTables(ctx context.Context, db dml.Querier) (*Tables, error) {
+	if err := c.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var allTables map[string]ddl.Columns
+	var err error
+	if c.SchemaCSV != "" {
+		allTables, err = loadColumnsCSVFile(c.SchemaCSV)
+	} else {
+		allTables, err = ddl.LoadColumns(ctx, db)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var opts []Option
+	var tableNames []string
+	for tblName, cols := range allTables {
+		if c.matchTable(tblName) {
+			tableNames = append(tableNames, tblName)
+			opts = append(opts, WithTable(tblName, cols))
+		}
+	}
+	if len(tableNames) == 0 {
+		return nil, errors.NotFound.Newf("[dmlgen] Config: No tables found for patterns %v", c.Tables)
+	}
+	sort.Strings(tableNames)
+
+	// Each table gets only one TableOption because the options of a table
+	// get applied once. Exact table names win over patterns.
+	for _, tblName := range tableNames {
+		if cto, ok := c.TableOptions[tblName]; ok {
+			opts = append(opts, WithTableOption(tblName, cto.tableOption()))
+			continue
+		}
+		for _, pattern := range c.tableOptionPatterns() {
+			if ok, _ := path.Match(pattern, tblName); ok {
+				opts = append(opts, WithTableOption(tblName, c.TableOptions[pattern].tableOption()))
+				break
+			}
+		}
+	}
+
+	switch {
+	case c.KeyColumnUsageCSV != "":
+		opts = append(opts, withKeyColumnUsageCSVFile(c.KeyColumnUsageCSV))
+	case c.ForeignKeyAliases && c.SchemaCSV == "":
+		opts = append(opts, WithColumnAliasesFromForeignKeys(ctx, db))
+	}
+
+	ts, err := NewTables(c.Package, opts...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ts.DisableTableSchemas = c.DisableTableSchemas
+	return ts, nil
+}
+
+// WriteFiles generates the Go and, if enabled, the proto and GraphQL file.
+func (c *Config) WriteFiles(ts *Tables) error {
+	if err := writeFile(c.OutputGo, ts.WriteGo); err != nil {
+		return errors.WithStack(err)
+	}
+	if c.OutputGraphQL != "" && ts.writeGraphQL {
+		if err := writeFile(c.OutputGraphQL, ts.WriteGraphQL); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if c.OutputProto == "" || !ts.writeProto {
+		return nil
+	}
+	if err := writeFile(c.OutputProto, ts.WriteProto); err != nil {
+		return errors.WithStack(err)
+	}
+	if c.GenerateProto {
+		return errors.WithStack(GenerateProto(filepath.Dir(c.OutputProto)))
+	}
+	return nil
+}
+
+func writeFile(file string, w func(io.Writer) error) (err error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		if cErr := f.Close(); err == nil && cErr != nil {
+			err = errors.WithStack(cErr)
+		}
+	}()
+	if err = w(f); err != nil {
+		return errors.WriteFailed.New(err, "[dmlgen] Failed to write file %q", file)
+	}
+	return nil
+}
+
+// readCSVFile reads a CSV file with a header line. Each returned map
+// represents a row and maps the column name to its value. An unquoted or
+// quoted NULL gets treated as SQL NULL.
+func readCSVFile(file string) ([]map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, errors.Wrapf(err, "[dmlgen] Failed to read CSV file %q", file)
+	}
+	if len(records) == 0 {
+		return nil, errors.Empty.Newf("[dmlgen] CSV file %q has no header line", file)
+	}
+	header := records[0]
+	ret := make([]map[string]string, 0, len(records)-1)
+	for _, rec := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, h := range header {
+			if i < len(rec) {
+				row[strings.TrimSpace(h)] = strings.TrimSpace(rec[i])
+			}
+		}
+		ret = append(ret, row)
+	}
+	return ret, nil
+}
+
+func csvNullString(s string) dml.NullString {
+	if strings.EqualFold(s, "null") {
+		return dml.NullString{}
+	}
+	return dml.MakeNullString(s)
+}
+
+func csvNullInt64(s string) (dml.NullInt64, error) {
+	if s == "" || strings.EqualFold(s, "null") {
+		return dml.NullInt64{}, nil
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return dml.NullInt64{}, errors.NotValid.Newf("[dmlgen] Cannot parse %q as integer: %s", s, err)
+	}
+	return dml.MakeNullInt64(i), nil
+}
+
+// loadColumnsCSVFile reads an offline dump of table
+// information_schema.COLUMNS. The column names in the header line are the
+// same as in ddl.LoadColumns.
+func loadColumnsCSVFile(file string) (map[string]ddl.Columns, error) {
+	rows, err := readCSVFile(file)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	tc := make(map[string]ddl.Columns)
+	for i, row := range rows {
+		tableName := row["TABLE_NAME"]
+		if tableName == "" || row["COLUMN_NAME"] == "" {
+			return nil, errors.NotValid.Newf("[dmlgen] CSV file %q row %d: TABLE_NAME or COLUMN_NAME empty", file, i+2)
+		}
+		c := &ddl.Column{
+			Field:      row["COLUMN_NAME"],
+			Default:    csvNullString(row["COLUMN_DEFAULT"]),
+			Null:       row["IS_NULLABLE"],
+			DataType:   strings.ToLower(row["DATA_TYPE"]),
+			ColumnType: row["COLUMN_TYPE"],
+			Key:        row["COLUMN_KEY"],
+			Extra:      row["EXTRA"],
+			Comment:    row["COLUMN_COMMENT"],
+		}
+		if c.Pos, err = strconv.ParseUint(row["ORDINAL_POSITION"], 10, 64); err != nil {
+			return nil, errors.NotValid.Newf("[dmlgen] CSV file %q row %d: ORDINAL_POSITION: %s", file, i+2, err)
+		}
+		if c.CharMaxLength, err = csvNullInt64(row["CHARACTER_MAXIMUM_LENGTH"]); err != nil {
+			return nil, errors.Wrapf(err, "[dmlgen] CSV file %q row %d", file, i+2)
+		}
+		if c.Precision, err = csvNullInt64(row["NUMERIC_PRECISION"]); err != nil {
+			return nil, errors.Wrapf(err, "[dmlgen] CSV file %q row %d", file, i+2)
+		}
+		if c.Scale, err = csvNullInt64(row["NUMERIC_SCALE"]); err != nil {
+			return nil, errors.Wrapf(err, "[dmlgen] CSV file %q row %d", file, i+2)
+		}
+		tc[tableName] = append(tc[tableName], c)
+	}
+	if len(tc) == 0 {
+		return nil, errors.NotFound.Newf("[dmlgen] CSV file %q contains no columns", file)
+	}
+	return tc, nil
+}
+
+// withKeyColumnUsageCSVFile same as WithColumnAliasesFromForeignKeys but reads
+// the foreign keys from an offline dump of table
+// information_schema.KEY_COLUMN_USAGE.
+func withKeyColumnUsageCSVFile(file string) (opt Option) {
+	opt.sortOrder = 200
+	opt.fn = func(ts *Tables) error {
+		rows, err := readCSVFile(file)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		tblFks := make(map[string]ddl.KeyColumnUsageCollection)
+		for _, row := range rows {
+			kcu := &ddl.KeyColumnUsage{
+				ConstraintName:       row["CONSTRAINT_NAME"],
+				TableName:            row["TABLE_NAME"],
+				ColumnName:           row["COLUMN_NAME"],
+				ReferencedTableName:  csvNullString(row["REFERENCED_TABLE_NAME"]),
+				ReferencedColumnName: csvNullString(row["REFERENCED_COLUMN_NAME"]),
+			}
+			if !kcu.ReferencedTableName.Valid || !kcu.ReferencedColumnName.Valid {
+				continue
+			}
+			key := kcu.ReferencedTableName.String + "." + kcu.ReferencedColumnName.String
+			kcuc := tblFks[key]
+			kcuc.Data = append(kcuc.Data, kcu)
+			tblFks[key] = kcuc
+		}
+		ts.applyForeignKeyAliases(tblFks)
+		return nil
+	}
+	return
+}
@@ -0,0 +1,127 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmlgen_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dmlgen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	t.Run("YAML", func(t *testing.T) {
+		cfg, err := dmlgen.LoadConfig("testdata/dmlgen.yaml")
+		require.NoError(t, err)
+		assert.Exactly(t, "testdata", cfg.Package)
+		assert.Exactly(t, filepath.Join("testdata", "INFORMATION_SCHEMA.COLUMNS.csv"), cfg.SchemaCSV)
+		assert.Exactly(t, []string{"customer_*", "dmlgen_types"}, cfg.Tables)
+		assert.Exactly(t, []string{"text"}, cfg.TableOptions["customer_*"].Encoders)
+		assert.True(t, cfg.TableOptions["customer_*"].Repository)
+		assert.Exactly(t, `json:"varchar16"`, cfg.TableOptions["dmlgen_types"].CustomStructTags["col_varchar_16"])
+	})
+	t.Run("TOML", func(t *testing.T) {
+		cfg, err := dmlgen.LoadConfig("testdata/dmlgen.toml")
+		require.NoError(t, err)
+		assert.Exactly(t, []string{"customer_entity"}, cfg.Tables)
+		assert.Exactly(t, []string{"protobuf"}, cfg.TableOptions["customer_entity"].Encoders)
+		assert.Exactly(t, []string{"email"}, cfg.TableOptions["customer_entity"].UniquifiedColumns)
+	})
+	t.Run("without DSN gets validated later", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "dmlgen")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		file := filepath.Join(dir, "dmlgen.yaml")
+		require.NoError(t, ioutil.WriteFile(file, []byte("package: catalog\noutput_go: catalog_gen.go\n"), 0644))
+
+		cfg, err := dmlgen.LoadConfig(file)
+		require.NoError(t, err)
+		err = cfg.Validate()
+		assert.True(t, errors.Empty.Match(err), "%+v", err)
+
+		cfg.DSN = "magento:magento@tcp(localhost:3306)/magento?parseTime=true"
+		assert.NoError(t, cfg.Validate())
+	})
+	t.Run("extension not supported", func(t *testing.T) {
+		cfg, err := dmlgen.LoadConfig("testdata/INFORMATION_SCHEMA.COLUMNS.csv")
+		assert.Nil(t, cfg)
+		assert.True(t, errors.NotSupported.Match(err), "%+v", err)
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	err := (&dmlgen.Config{OutputGo: "x.go", SchemaCSV: "x.csv"}).Validate()
+	assert.True(t, errors.Empty.Match(err), "%+v", err)
+
+	err = (&dmlgen.Config{Package: "x", OutputGo: "x.go"}).Validate()
+	assert.True(t, errors.Empty.Match(err), "%+v", err)
+
+	err = (&dmlgen.Config{Package: "x", OutputGo: "x.go", SchemaCSV: "x.csv", Tables: []string{"catalog_["}}).Validate()
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+}
+
+func TestConfig_NewTables(t *testing.T) {
+	t.Parallel()
+
+	t.Run("offline CSV", func(t *testing.T) {
+		cfg, err := dmlgen.LoadConfig("testdata/dmlgen.yaml")
+		require.NoError(t, err)
+
+		dir, err := ioutil.TempDir("", "dmlgen")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		cfg.OutputGo = filepath.Join(dir, "config_gen.go")
+		cfg.OutputProto = filepath.Join(dir, "config_gen.proto")
+
+		ts, err := cfg.NewTables(context.Background(), nil)
+		require.NoError(t, err)
+		require.NoError(t, cfg.WriteFiles(ts))
+
+		data, err := ioutil.ReadFile(cfg.OutputGo)
+		require.NoError(t, err)
+		src := string(data)
+		assert.Contains(t, src, "type CustomerEntity struct")
+		assert.Contains(t, src, "func (e *CustomerEntity) Upsert(ctx context.Context, tbls *ddl.Tables) (sql.Result, error) {")
+		assert.Contains(t, src, `case "entity_id", "customer_id", "parent_id":`, "Aliases from the KEY_COLUMN_USAGE CSV file")
+		assert.Contains(t, src, `ColVarchar16   string         `+"`"+`json:"varchar16"`+"`")
+		assert.Contains(t, src, `case "col_varchar_100", "varchar100":`)
+		assert.Contains(t, src, "func (e *DmlgenTypes) Validate() error {")
+
+		_, err = os.Stat(cfg.OutputProto)
+		assert.True(t, os.IsNotExist(err), "Proto file must not be written without protobuf encoder: %+v", err)
+	})
+
+	t.Run("no tables found", func(t *testing.T) {
+		cfg := &dmlgen.Config{
+			Package:   "testdata",
+			SchemaCSV: "testdata/INFORMATION_SCHEMA.COLUMNS.csv",
+			Tables:    []string{"sales_order_*"},
+			OutputGo:  "x.go",
+		}
+		ts, err := cfg.NewTables(context.Background(), nil)
+		assert.Nil(t, ts)
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+	})
+}
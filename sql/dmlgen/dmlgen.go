@@ -54,10 +54,14 @@ type Tables struct {
 	DisableFileHeader   bool
 	DisableTableSchemas bool
 	GogoProtoOptions    []string
+	// foreignKeys maps table.column of a referencing column to table.column
+	// of the referenced column. Set by WithColumnAliasesFromForeignKeys.
+	foreignKeys map[string]string
 	// goTpl contains a parsed template to render a single table.
-	tpls       *template.Template
-	writeProto bool
-	lastError  error
+	tpls         *template.Template
+	writeProto   bool
+	writeGraphQL bool
+	lastError    error
 }
 
 // Option represents a sortable option for the NewTables function. Each option
@@ -73,8 +77,11 @@ type Option struct {
 type TableOption struct {
 	// Encoders add method receivers for, each struct, compatible with the
 	// interface declarations in the various encoding packages. Supported
-	// encoder names are: text, binary, and protobuf. Text includes JSON. Binary
-	// includes Gob.
+	// encoder names are: text, json, binary, protobuf and graphql. Text
+	// includes JSON via package encoding/json. Json generates reflection free
+	// JSON marshalers and replaces the JSON methods of text. Binary includes
+	// Gob. Graphql writes the schema via Tables.WriteGraphQL and the resolvers
+	// into the Go code; it requires the option Repository.
 	Encoders []string
 	// StructTags enables struct tags proactively for the whole struct. Allowed
 	// values are: bson, db, env, json, protobuf, toml, yaml and xml. For bson,
@@ -101,7 +108,27 @@ type TableOption struct {
 	// but should have a dedicated function to extract their unique primitive
 	// values as a slice.
 	UniquifiedColumns []string
-	lastErr           error
+	// Repository generates the methods Load, Insert, Upsert, Update and Delete
+	// for the entity and LoadAll, Load, Insert, Upsert, Update and Delete for
+	// the collection. The methods use the statements of ddl.Table and require
+	// the table to be registered in a ddl.Tables object.
+	Repository bool
+	// Validation generates the method Validate for the entity and the
+	// collection. Validate checks the fields against the column constraints
+	// like VARCHAR length, unsigned numbers, ENUM/SET members and NOT NULL
+	// columns without a default value. If Repository has been enabled, the
+	// write methods call Validate before executing the statement.
+	Validation bool
+	// JSONOmitEmpty omits empty fields in the JSON output of the encoder json.
+	// Empty are the zero values and invalid Null types.
+	JSONOmitEmpty bool
+	// Fixture generates the factory functions NewFixture<Entity> and
+	// Fixture<Entity> which create entities with random but valid values and
+	// insert them with ddl.SeedFixtures. Columns being an alias of a column of
+	// another table, see WithColumnAliasesFromForeignKeys, get the values of
+	// already inserted parent rows.
+	Fixture bool
+	lastErr error
 }
 
 func (to *TableOption) applyEncoders(ts *Tables, t *table) {
@@ -110,12 +137,17 @@ func (to *TableOption) applyEncoders(ts *Tables, t *table) {
 		switch enc := to.Encoders[i]; enc {
 		case "text":
 			t.TextMarshaler = true
+		case "json":
+			t.JSONMarshaler = true
 		case "binary":
 			t.BinaryMarshaler = true
 		case "protobuf":
 			// github.com/gogo/protobuf/protoc-gen-gogo/generator/generator.go#L1629 Generator.goTag
 			ts.writeProto = true
 			t.Protobuf = true // for now leave it in. maybe later PB gets added to the struct tags.
+		case "graphql":
+			ts.writeGraphQL = true
+			t.GraphQL = true
 		default:
 			to.lastErr = errors.NotSupported.Newf("[dmlgen] WithTableOption: Table %q Encoder %q not supported", t.TableName, enc)
 		}
@@ -266,6 +298,13 @@ func WithTableOption(tableName string, opt *TableOption) (o Option) {
 		opt.applyComments(t)
 		opt.applyColumnAliases(t)
 		opt.applyUniquifiedColumns(t)
+		t.Repository = opt.Repository
+		t.Validation = opt.Validation
+		t.JSONOmitEmpty = opt.JSONOmitEmpty
+		t.Fixture = opt.Fixture
+		if opt.lastErr == nil && t.GraphQL && !t.Repository {
+			return errors.NotAcceptable.Newf("[dmlgen] WithTableOption: Table %q Encoder graphql requires option Repository", tableName)
+		}
 		return opt.lastErr
 	}
 	return
@@ -287,30 +326,47 @@ func WithColumnAliasesFromForeignKeys(ctx context.Context, db dml.Querier) (opt
 		if err != nil {
 			return errors.WithStack(err)
 		}
-		for tblPkCol, kcuc := range tblFks {
-			// tblPkCol == REFERENCED_TABLE_NAME.REFERENCED_COLUMN_NAME
-			// REFERENCED_TABLE_NAME is contained in sortedTableNames()
-			dotPos := strings.IndexByte(tblPkCol, '.')
-			refTable := tblPkCol[:dotPos]
-			refColumn := tblPkCol[dotPos+1:]
-
-			t := ts.Tables[refTable]
-			for _, c := range t.Columns {
-				// TODO: optimize this and rethink method receivers like Each, on the collection.
-				if c.Field == refColumn {
-					unique := map[string]bool{refColumn: true} // refColumn already seen because field name
-					for _, kcu := range kcuc.Data {
-						if kcu.ReferencedColumnName.String == refColumn && !unique[kcu.ColumnName] {
-							c.Aliases = append(c.Aliases, kcu.ColumnName)
-							unique[kcu.ColumnName] = true
-						}
+		ts.applyForeignKeyAliases(tblFks)
+		return nil
+	}
+	return
+}
+
+// applyForeignKeyAliases adds the referencing column names of the foreign keys
+// as aliases to the referenced columns and records the foreign keys for the
+// fixtures. The map key of tblFks has the format
+// REFERENCED_TABLE_NAME.REFERENCED_COLUMN_NAME.
+func (ts *Tables) applyForeignKeyAliases(tblFks map[string]ddl.KeyColumnUsageCollection) {
+	if ts.foreignKeys == nil {
+		ts.foreignKeys = make(map[string]string)
+	}
+	for tblPkCol, kcuc := range tblFks {
+		// tblPkCol == REFERENCED_TABLE_NAME.REFERENCED_COLUMN_NAME
+		// REFERENCED_TABLE_NAME is contained in sortedTableNames()
+		dotPos := strings.IndexByte(tblPkCol, '.')
+		refTable := tblPkCol[:dotPos]
+		refColumn := tblPkCol[dotPos+1:]
+
+		t, ok := ts.Tables[refTable]
+		if !ok {
+			continue
+		}
+		for _, kcu := range kcuc.Data {
+			ts.foreignKeys[kcu.TableName+"."+kcu.ColumnName] = tblPkCol
+		}
+		for _, c := range t.Columns {
+			// TODO: optimize this and rethink method receivers like Each, on the collection.
+			if c.Field == refColumn {
+				unique := map[string]bool{refColumn: true} // refColumn already seen because field name
+				for _, kcu := range kcuc.Data {
+					if kcu.ReferencedColumnName.String == refColumn && !unique[kcu.ColumnName] {
+						c.Aliases = append(c.Aliases, kcu.ColumnName)
+						unique[kcu.ColumnName] = true
 					}
 				}
 			}
 		}
-		return nil
 	}
-	return
 }
 
 // WithTable sets a table and its columns. Allows to overwrite a table fetched
@@ -362,12 +418,17 @@ func NewTables(packageName string, opts ...Option) (*Tables, error) {
 		Tables:  make(map[string]*table),
 		Package: packageName,
 		ImportPaths: []string{
+			"context",
 			"database/sql",
 			"encoding/json",
 			"github.com/corestoreio/pkg/sql/dml",
 			"github.com/corestoreio/pkg/sql/ddl",
 			"github.com/corestoreio/errors",
+			"math/rand",
+			"strconv",
+			"strings",
 			"time",
+			"unicode/utf8",
 		},
 		FuncMap: make(template.FuncMap, 10),
 	}
@@ -379,6 +440,13 @@ func NewTables(packageName string, opts ...Option) (*Tables, error) {
 	ts.FuncMap["GoPrimitive"] = toGoPrimitive
 	ts.FuncMap["ProtoType"] = toProtoType
 	ts.FuncMap["ProtoCustomType"] = toProtoCustomType
+	ts.FuncMap["GoValidation"] = toGoValidation
+	ts.FuncMap["GoJSONMarshal"] = toGoJSONMarshal
+	ts.FuncMap["GoJSONUnmarshal"] = toGoJSONUnmarshal
+	ts.FuncMap["GoFixture"] = ts.toGoFixture
+	ts.FuncMap["GraphQLType"] = toGraphQLType
+	ts.FuncMap["GraphQLInputType"] = toGraphQLInputType
+	ts.FuncMap["LowerFirst"] = toLowerFirst
 
 	if len(ts.GogoProtoOptions) == 0 {
 		ts.GogoProtoOptions = []string{
@@ -508,9 +576,24 @@ func (ts *Tables) WriteGo(w io.Writer) error {
 		if t.TextMarshaler {
 			ts.execTpl(buf, t, "code_text.go.tpl")
 		}
+		if t.JSONMarshaler {
+			ts.execTpl(buf, t, "code_json.go.tpl")
+		}
 		if t.BinaryMarshaler {
 			ts.execTpl(buf, t, "code_binary.go.tpl")
 		}
+		if t.Validation {
+			ts.execTpl(buf, t, "code_validate.go.tpl")
+		}
+		if t.Repository {
+			ts.execTpl(buf, t, "code_repository.go.tpl")
+		}
+		if t.GraphQL {
+			ts.execTpl(buf, t, "code_graphql_resolver.go.tpl")
+		}
+		if t.Fixture {
+			ts.execTpl(buf, t, "code_fixture.go.tpl")
+		}
 		if ts.lastError != nil {
 			return ts.lastError
 		}
@@ -546,9 +629,15 @@ type table struct {
 	Comment                  string      // Comment above the struct type declaration
 	Columns                  ddl.Columns // all columns of a table
 	TextMarshaler            bool
+	JSONMarshaler            bool // writes the reflection free JSON methods if true
+	JSONOmitEmpty            bool
 	BinaryMarshaler          bool
 	Protobuf                 bool // writes the .proto file if true
+	GraphQL                  bool // writes the GraphQL types and resolvers if true
 	DisableCollectionMethods bool
+	Repository               bool // writes the CRUD methods if true
+	Validation               bool // writes the Validate methods if true
+	Fixture                  bool // writes the fixture factory functions if true
 }
 
 // WriteTo implements io.WriterTo and writes the generated source code into w.
@@ -15,6 +15,7 @@
 package dmlgen_test
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"os"
@@ -92,18 +93,25 @@ func TestNewTables(t *testing.T) {
 				ColumnAliases: map[string][]string{
 					"path": {"storage_location", "config_directory"},
 				},
+				Encoders:          []string{"graphql"},
 				UniquifiedColumns: []string{"path"},
+				Repository:        true,
+				Fixture:           true,
+				Validation:        true,
 			}),
 		dmlgen.WithTableOption(
 			"dmlgen_types", &dmlgen.TableOption{
-				Encoders:          []string{"text", "binary", "protobuf"},
+				Encoders:          []string{"text", "json", "binary", "protobuf"},
 				StructTags:        []string{"json", "protobuf"},
 				UniquifiedColumns: []string{"col_longtext_2", "col_int_1", "col_int_2", "has_smallint_5", "col_date_2", "col_blob"},
 				Comment:           "Just another comment.\n//easyjson:json",
+				Validation:        true,
 			}),
 		dmlgen.WithTableOption(
 			"customer_entity", &dmlgen.TableOption{
-				Encoders: []string{"text", "protobuf"},
+				Encoders:      []string{"json", "protobuf"},
+				JSONOmitEmpty: true,
+				Fixture:       true,
 			}),
 
 		dmlgen.WithTable("core_config_data", ddl.Columns{
@@ -122,6 +130,7 @@ func TestNewTables(t *testing.T) {
 
 	writeFile(t, "testdata/output_gen.go", ts.WriteGo)
 	writeFile(t, "testdata/output_gen.proto", ts.WriteProto)
+	writeFile(t, "testdata/output_gen.graphql", ts.WriteGraphQL)
 	// Generates for all proto files the Go source code.
 	require.NoError(t, dmlgen.GenerateProto("./testdata"))
 }
@@ -277,3 +286,92 @@ func TestWithUniquifiedColumns(t *testing.T) {
 		assert.True(t, errors.NotFound.Match(err), "%+v", err)
 	})
 }
+
+func TestWithRepository(t *testing.T) {
+	t.Parallel()
+
+	ts, err := dmlgen.NewTables("testdata",
+		dmlgen.WithTableOption("catalog_product_website", &dmlgen.TableOption{
+			Repository: true,
+		}),
+		dmlgen.WithTable("catalog_product_website", ddl.Columns{
+			&ddl.Column{Field: "product_id", Pos: 1, Null: "NO", DataType: "int", Precision: dml.MakeNullInt64(10), Scale: dml.MakeNullInt64(0), ColumnType: "int(10) unsigned", Key: "PRI", Comment: "Product ID"},
+			&ddl.Column{Field: "website_id", Pos: 2, Null: "NO", DataType: "smallint", Precision: dml.MakeNullInt64(5), Scale: dml.MakeNullInt64(0), ColumnType: "smallint(5) unsigned", Key: "PRI", Comment: "Website ID"},
+		}),
+	)
+	require.NoError(t, err)
+	ts.DisableTableSchemas = true
+
+	var buf bytes.Buffer
+	require.NoError(t, ts.WriteGo(&buf))
+	src := buf.String()
+
+	assert.Contains(t, src, `"context"`)
+	assert.Contains(t, src, "const TableNameCatalogProductWebsite = \"catalog_product_website\"")
+	assert.Contains(t, src, "func (e *CatalogProductWebsite) Load(ctx context.Context, tbls *ddl.Tables, pkProductID uint64, pkWebsiteID uint64) error {")
+	assert.Contains(t, src, "func (e *CatalogProductWebsite) Upsert(ctx context.Context, tbls *ddl.Tables) (sql.Result, error) {")
+	assert.Contains(t, src, `ins.AddColumns("website_id").AddOnDuplicateKeyExclude("website_id")`)
+	assert.Contains(t, src, "func (cc *CatalogProductWebsiteCollection) LoadAll(ctx context.Context, tbls *ddl.Tables, wheres ...*dml.Condition) error {")
+	assert.Contains(t, src, "func (cc *CatalogProductWebsiteCollection) Delete(ctx context.Context, tbls *ddl.Tables) error {")
+	assert.Contains(t, src, "return tbl.RunInTx(ctx, nil, func(db dml.QueryExecPreparer) error {")
+	assert.NotContains(t, src, "func (cc *CatalogProductWebsiteCollection) Load(", "Composite primary keys cannot be loaded as a list")
+}
+
+func TestWithGraphQL(t *testing.T) {
+	t.Parallel()
+
+	columns := ddl.Columns{
+		&ddl.Column{Field: "product_id", Pos: 1, Null: "NO", DataType: "int", Precision: dml.MakeNullInt64(10), Scale: dml.MakeNullInt64(0), ColumnType: "int(10) unsigned", Key: "PRI", Comment: "Product ID"},
+		&ddl.Column{Field: "website_id", Pos: 2, Null: "NO", DataType: "smallint", Precision: dml.MakeNullInt64(5), Scale: dml.MakeNullInt64(0), ColumnType: "smallint(5) unsigned", Key: "PRI", Comment: "Website ID"},
+	}
+
+	t.Run("requires Repository", func(t *testing.T) {
+		ts, err := dmlgen.NewTables("testdata",
+			dmlgen.WithTableOption("catalog_product_website", &dmlgen.TableOption{
+				Encoders: []string{"graphql"},
+			}),
+			dmlgen.WithTable("catalog_product_website", columns),
+		)
+		assert.Nil(t, ts)
+		assert.True(t, errors.NotAcceptable.Match(err), "%+v", err)
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		ts, err := dmlgen.NewTables("testdata",
+			dmlgen.WithTable("catalog_product_website", columns),
+		)
+		require.NoError(t, err)
+		err = ts.WriteGraphQL(new(bytes.Buffer))
+		assert.True(t, errors.NotAcceptable.Match(err), "%+v", err)
+	})
+
+	t.Run("schema and resolvers", func(t *testing.T) {
+		ts, err := dmlgen.NewTables("testdata",
+			dmlgen.WithTableOption("catalog_product_website", &dmlgen.TableOption{
+				Encoders:   []string{"graphql"},
+				Repository: true,
+			}),
+			dmlgen.WithTable("catalog_product_website", columns),
+		)
+		require.NoError(t, err)
+		ts.DisableTableSchemas = true
+
+		var buf bytes.Buffer
+		require.NoError(t, ts.WriteGraphQL(&buf))
+		schema := buf.String()
+		assert.Contains(t, schema, "type CatalogProductWebsite {\n\tproduct_id: UInt64!\n\twebsite_id: Int!\n}")
+		assert.Contains(t, schema, "input CatalogProductWebsiteInput {")
+		assert.Contains(t, schema, "catalogProductWebsite(product_id: UInt64!, website_id: Int!): CatalogProductWebsite\n")
+		assert.Contains(t, schema, "catalogProductWebsiteCollection(offset: Int! = 0, limit: Int! = 100): [CatalogProductWebsite!]!")
+		assert.Contains(t, schema, "deleteCatalogProductWebsite(product_id: UInt64!, website_id: Int!): Boolean!")
+
+		buf.Reset()
+		require.NoError(t, ts.WriteGo(&buf))
+		src := buf.String()
+		assert.Contains(t, src, "type CatalogProductWebsiteResolver struct {")
+		assert.Contains(t, src, "func (r CatalogProductWebsiteResolver) CatalogProductWebsite(ctx context.Context, pkProductID uint64, pkWebsiteID uint64) (*CatalogProductWebsite, error) {")
+		assert.Contains(t, src, "func (r CatalogProductWebsiteResolver) CatalogProductWebsiteCollection(ctx context.Context, offset, limit int32) ([]*CatalogProductWebsite, error) {")
+		assert.Contains(t, src, "tbl.SelectAll().Limit(uint64(offset), uint64(limit))")
+		assert.Contains(t, src, "func (r CatalogProductWebsiteResolver) DeleteCatalogProductWebsite(ctx context.Context, pkProductID uint64, pkWebsiteID uint64) (bool, error) {")
+	})
+}
@@ -15,6 +15,9 @@
 // Package dmlgen provides code generation templates and library code for
 // sql/dml.
 //
+// The command github.com/corestoreio/pkg/codegen/dmlgen generates the code
+// from a YAML or TOML configuration file. See type Config.
+//
 // To generated the protocol buffer file
 // $ protoc --gogo_out=Mgoogle/protobuf/timestamp.proto=github.com/gogo/protobuf/types:. --proto_path=/Users/kiri/GoPro/src/:/Users/kiri/GoPro/src/github.com/gogo/protobuf/protobuf/:. *.proto
 package dmlgen
@@ -0,0 +1,186 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmlgen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/util/strs"
+)
+
+// fixtureIntBounds defines the exclusive upper bound of random integers per
+// data type. Bigger types get the bound of an INT to create realistic values.
+var fixtureIntBounds = map[string]int64{
+	"tinyint":   127,
+	"smallint":  32767,
+	"mediumint": 8388607,
+}
+
+// fixtureReference returns the referenced table and column in the format
+// table.column if the column has a foreign key to another table. The foreign
+// keys get loaded by WithColumnAliasesFromForeignKeys. Returns an empty string
+// if the column does not reference another table.
+func (ts *Tables) fixtureReference(tableName string, c *ddl.Column) string {
+	ref := ts.foreignKeys[tableName+"."+c.Field]
+	if strings.HasPrefix(ref, tableName+".") {
+		return "" // self reference
+	}
+	return ref
+}
+
+// toGoFixtureValue returns the Go expression which creates a random value for
+// the Go type of a column. Null types get wrapped in their Make function.
+func toGoFixtureValue(c *ddl.Column, goType string) string {
+	if vals := parseEnumValues(c.ColumnType); len(vals) > 0 {
+		quoted := make([]string, len(vals))
+		for i, v := range vals {
+			quoted[i] = fmt.Sprintf("%q", v)
+		}
+		value := fmt.Sprintf("[]string{%s}[r.Intn(%d)]", strings.Join(quoted, ", "), len(vals))
+		if goType == "dml.NullString" {
+			value = "dml.MakeNullString(" + value + ")"
+		}
+		return value
+	}
+
+	intBound, ok := fixtureIntBounds[c.DataType]
+	if !ok {
+		intBound = 2147483647
+	}
+	intExpr := fmt.Sprintf("r.Int63n(%d)", intBound)
+	if c.IsPK() {
+		intExpr = fmt.Sprintf("r.Int63n(%d) + 1", intBound-1)
+	}
+	var maxLen int64
+	if c.CharMaxLength.Valid {
+		maxLen = c.CharMaxLength.Int64
+	}
+
+	switch goType {
+	case "int64":
+		return intExpr
+	case "dml.NullInt64":
+		return "dml.MakeNullInt64(" + intExpr + ")"
+	case "uint64":
+		return "uint64(" + intExpr + ")"
+	case "float64":
+		return "r.Float64() * 1000"
+	case "dml.NullFloat64":
+		return "dml.MakeNullFloat64(r.Float64() * 1000)"
+	case "bool":
+		return "r.Intn(2) == 1"
+	case "dml.NullBool":
+		return "dml.MakeNullBool(r.Intn(2) == 1)"
+	case "dml.Decimal":
+		bound, prec := int64(1), c.Precision.Int64
+		for i := int64(0); i < prec && i < 18; i++ {
+			bound *= 10
+		}
+		if bound == 1 {
+			bound = 1000000
+		}
+		return fmt.Sprintf("dml.MakeDecimalInt64(r.Int63n(%d), %d)", bound, c.Scale.Int64)
+	case "string", "dml.NullString":
+		value := fmt.Sprintf("ddl.FixtureString(r, %d)", maxLen)
+		if goType == "dml.NullString" {
+			value = "dml.MakeNullString(" + value + ")"
+		}
+		return value
+	case "[]byte":
+		return fmt.Sprintf("ddl.FixtureBytes(r, %d)", maxLen)
+	case "time.Time", "dml.NullTime":
+		value := "ddl.FixtureTime(r)"
+		if c.DataType == "date" {
+			value += ".Truncate(24 * time.Hour)"
+		}
+		if goType == "dml.NullTime" {
+			value = "dml.MakeNullTime(" + value + ")"
+		}
+		return value
+	default:
+		panic(fmt.Sprintf("[dmlgen] toGoFixtureValue: Go type %q of column %q not supported", goType, c.Field))
+	}
+}
+
+// toGoFixtureEmail generates the Go source code which assigns a random email
+// address to the field of a string column containing email in its name.
+// Returns an empty string for all other columns. ddl.FixtureEmail fails if the
+// column is too short for an email address.
+func toGoFixtureEmail(c *ddl.Column, goType string) string {
+	if !strings.Contains(c.Field, "email") || parseEnumValues(c.ColumnType) != nil {
+		return ""
+	}
+	name := strs.ToGoCamelCase(c.Field)
+	value := "email" + name
+	switch goType {
+	case "string":
+	case "dml.NullString":
+		value = "dml.MakeNullString(" + value + ")"
+	default:
+		return ""
+	}
+	var maxLen int64
+	if c.CharMaxLength.Valid {
+		maxLen = c.CharMaxLength.Int64
+	}
+	return fmt.Sprintf("\nemail%s, err := ddl.FixtureEmail(r, %d)\nif err != nil {\nreturn nil, errors.WithStack(err)\n}\ne.%s = %s",
+		name, maxLen, name, value)
+}
+
+// toGoFixture generates the Go source code which assigns a random but valid
+// value to the field of a column. Auto increment columns stay empty. Columns
+// referencing another table get a value from the variable refs of type
+// ddl.FixtureRefs, if available. Nullable foreign key columns stay NULL when
+// refs contains no value.
+func (ts *Tables) toGoFixture(tableName string, c *ddl.Column) string {
+	if c.IsAutoIncrement() {
+		return ""
+	}
+	field := "e." + strs.ToGoCamelCase(c.Field)
+	goType := mySQLToGoType(c, true)
+	ref := ts.fixtureReference(tableName, c)
+
+	var buf strings.Builder
+	if ref == "" || !c.IsNull() {
+		if email := toGoFixtureEmail(c, goType); email != "" {
+			buf.WriteString(email)
+		} else {
+			fmt.Fprintf(&buf, "\n%s = %s", field, toGoFixtureValue(c, goType))
+		}
+	}
+	if ref == "" {
+		return buf.String()
+	}
+
+	var refFn, assign string
+	switch goType {
+	case "int64":
+		refFn, assign = "Int64", "v"
+	case "uint64":
+		refFn, assign = "Uint64", "v"
+	case "string":
+		refFn, assign = "String", "v"
+	case "dml.NullInt64":
+		refFn, assign = "Int64", "dml.MakeNullInt64(v)"
+	case "dml.NullString":
+		refFn, assign = "String", "dml.MakeNullString(v)"
+	default:
+		return buf.String()
+	}
+	fmt.Fprintf(&buf, "\nif v, ok := refs.%s(r, %q); ok {\n%s = %s\n}", refFn, ref, field, assign)
+	return buf.String()
+}
@@ -0,0 +1,85 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmlgen
+
+import (
+	"testing"
+
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTables_toGoFixture(t *testing.T) {
+	t.Parallel()
+	ts := &Tables{
+		Tables: map[string]*table{
+			"customer_entity": {TableName: "customer_entity", Columns: ddl.Columns{
+				&ddl.Column{Field: `entity_id`, DataType: `int`, ColumnType: `int(10) unsigned`, Key: "PRI", Extra: "auto_increment"},
+			}},
+			"store": {TableName: "store", Columns: ddl.Columns{
+				// Alias quote_store_id has been set via ColumnAliases and is no foreign key.
+				&ddl.Column{Field: `store_id`, DataType: `smallint`, ColumnType: `smallint(5) unsigned`, Key: "PRI", Extra: "auto_increment", Aliases: []string{"quote_store_id"}},
+			}},
+		},
+	}
+	fk := func(table, column, refTable, refColumn string) *ddl.KeyColumnUsage {
+		return &ddl.KeyColumnUsage{TableName: table, ColumnName: column,
+			ReferencedTableName: dml.MakeNullString(refTable), ReferencedColumnName: dml.MakeNullString(refColumn)}
+	}
+	ts.applyForeignKeyAliases(map[string]ddl.KeyColumnUsageCollection{
+		"customer_entity.entity_id": {Data: []*ddl.KeyColumnUsage{
+			fk("sales_order", "customer_id", "customer_entity", "entity_id"),
+			fk("customer_address_entity", "parent_id", "customer_entity", "entity_id"),
+		}},
+		"store.store_id": {Data: []*ddl.KeyColumnUsage{
+			fk("sales_order", "origin_store_id", "store", "store_id"),
+		}},
+	})
+	tests := []struct {
+		c    ddl.Column
+		want string
+	}{
+		{ddl.Column{Field: `entity_id`, DataType: `int`, ColumnType: `int(10) unsigned`, Key: "PRI", Extra: "auto_increment"}, ""},
+		{ddl.Column{Field: `item_id`, DataType: `int`, ColumnType: `int(10) unsigned`, Key: "PRI"},
+			"\ne.ItemID = uint64(r.Int63n(2147483646) + 1)"},
+		{ddl.Column{Field: `customer_id`, DataType: `int`, ColumnType: `int(10) unsigned`},
+			"\ne.CustomerID = uint64(r.Int63n(2147483647))\nif v, ok := refs.Uint64(r, \"customer_entity.entity_id\"); ok {\ne.CustomerID = v\n}"},
+		{ddl.Column{Field: `origin_store_id`, DataType: `smallint`, Null: "YES", ColumnType: `smallint(5) unsigned`},
+			"\nif v, ok := refs.Int64(r, \"store.store_id\"); ok {\ne.OriginStoreID = dml.MakeNullInt64(v)\n}"},
+		{ddl.Column{Field: `parent_id`, DataType: `int`, ColumnType: `int(10) unsigned`},
+			"\ne.ParentID = uint64(r.Int63n(2147483647))"},
+		{ddl.Column{Field: `quote_store_id`, DataType: `smallint`, Null: "YES", ColumnType: `smallint(5) unsigned`},
+			"\ne.QuoteStoreID = dml.MakeNullInt64(r.Int63n(32767))"},
+		{ddl.Column{Field: `customer_email`, DataType: `varchar`, Null: "YES", CharMaxLength: dml.MakeNullInt64(128), ColumnType: `varchar(128)`},
+			"\nemailCustomerEmail, err := ddl.FixtureEmail(r, 128)\nif err != nil {\nreturn nil, errors.WithStack(err)\n}\ne.CustomerEmail = dml.MakeNullString(emailCustomerEmail)"},
+		{ddl.Column{Field: `email`, DataType: `varchar`, Null: "NO", CharMaxLength: dml.MakeNullInt64(255), ColumnType: `varchar(255)`},
+			"\nemailEmail, err := ddl.FixtureEmail(r, 255)\nif err != nil {\nreturn nil, errors.WithStack(err)\n}\ne.Email = emailEmail"},
+		{ddl.Column{Field: `email_type`, DataType: `enum`, Null: "NO", ColumnType: `enum('html','text')`},
+			"\ne.EmailType = []string{\"html\", \"text\"}[r.Intn(2)]"},
+		{ddl.Column{Field: `status`, DataType: `enum`, Null: "NO", ColumnType: `enum('new','done')`},
+			"\ne.Status = []string{\"new\", \"done\"}[r.Intn(2)]"},
+		{ddl.Column{Field: `grand_total`, DataType: `decimal`, Null: "YES", Precision: dml.MakeNullInt64(12), Scale: dml.MakeNullInt64(4), ColumnType: `decimal(12,4)`},
+			"\ne.GrandTotal = dml.MakeDecimalInt64(r.Int63n(1000000000000), 4)"},
+		{ddl.Column{Field: `dob`, DataType: `date`, Null: "YES", ColumnType: `date`},
+			"\ne.Dob = dml.MakeNullTime(ddl.FixtureTime(r).Truncate(24 * time.Hour))"},
+		{ddl.Column{Field: `is_active`, DataType: `smallint`, Null: "NO", ColumnType: `smallint(5)`},
+			"\ne.IsActive = r.Intn(2) == 1"},
+	}
+	for _, test := range tests {
+		c := test.c
+		assert.Exactly(t, test.want, ts.toGoFixture("sales_order", &c), "%s", c.Field)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmlgen
+
+import (
+	"bytes"
+	"io"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/ddl"
+)
+
+// graphQLTypes maps the Go type of a column to the GraphQL type. Int64,
+// UInt64, Decimal, Time and Bytes are custom scalars declared in the schema header.
+var graphQLTypes = map[string]string{
+	"int64":           "Int",
+	"uint64":          "Int",
+	"float64":         "Float",
+	"bool":            "Boolean",
+	"string":          "String",
+	"[]byte":          "Bytes",
+	"time.Time":       "Time",
+	"dml.Decimal":     "Decimal",
+	"dml.NullString":  "String",
+	"dml.NullInt64":   "Int",
+	"dml.NullFloat64": "Float",
+	"dml.NullBool":    "Boolean",
+	"dml.NullTime":    "Time",
+}
+
+// toGraphQLType returns the GraphQL type of a column. The GraphQL Int has only
+// 32 bit, hence BIGINT columns use the scalar Int64 and unsigned INT and
+// BIGINT columns the scalar UInt64. NOT NULL columns are non-null types.
+func toGraphQLType(c *ddl.Column) string {
+	goType := mySQLToGoType(c, true)
+	gt, ok := graphQLTypes[goType]
+	if !ok {
+		panic(errors.NotSupported.Newf("[dmlgen] toGraphQLType: Go type %q of column %q not supported", goType, c.Field))
+	}
+	if gt == "Int" {
+		switch {
+		case c.IsUnsigned() && (c.DataType == "int" || c.DataType == "bigint"):
+			gt = "UInt64"
+		case c.DataType == "bigint":
+			gt = "Int64"
+		}
+	}
+	if !c.IsNull() {
+		gt += "!"
+	}
+	return gt
+}
+
+// toGraphQLInputType returns the GraphQL type of a column used in an input
+// type. Only NOT NULL columns without a default value which are not auto
+// increment columns are required.
+func toGraphQLInputType(c *ddl.Column) string {
+	gt := toGraphQLType(c)
+	if c.Default.Valid || c.IsAutoIncrement() {
+		if l := len(gt) - 1; gt[l] == '!' {
+			gt = gt[:l]
+		}
+	}
+	return gt
+}
+
+// toLowerFirst lower cases the first character, e.g. CustomerEntity becomes
+// customerEntity. Used for GraphQL field names.
+func toLowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
+
+// WriteGraphQL writes the GraphQL schema definition (SDL) into `w`. Each table
+// with the encoder graphql gets an object type, an input type and the fields
+// in the Query and Mutation types. The matching Go resolvers get written by
+// WriteGo.
+func (ts *Tables) WriteGraphQL(w io.Writer) error {
+	if !ts.writeGraphQL {
+		return errors.NotAcceptable.Newf("[dmlgen] GraphQL generation not enabled.")
+	}
+	buf := new(bytes.Buffer)
+	tpls := ts.tpls.Funcs(ts.FuncMap)
+
+	if !ts.DisableFileHeader {
+		if err := tpls.ExecuteTemplate(buf, "code_graphql_header.go.tpl", ts); err != nil {
+			return errors.WriteFailed.New(err, "[dmlgen] For file header")
+		}
+	}
+
+	var tables []*table
+	for _, tblName := range ts.sortedTableNames() {
+		t := ts.Tables[tblName] // must panic if table name not found
+		if !t.GraphQL {
+			continue
+		}
+		if err := t.writeTo(buf, tpls.Lookup("code_graphql.go.tpl")); err != nil {
+			return errors.WriteFailed.New(err, "[dmlgen] For Table %q", t.TableName)
+		}
+		tables = append(tables, t)
+	}
+
+	if err := tpls.ExecuteTemplate(buf, "code_graphql_schema.go.tpl", tables); err != nil {
+		return errors.WriteFailed.New(err, "[dmlgen] For the Query and Mutation types")
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
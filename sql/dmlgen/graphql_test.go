@@ -0,0 +1,56 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmlgen
+
+import (
+	"testing"
+
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToGraphQLType(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		c         ddl.Column
+		want      string
+		wantInput string
+	}{
+		{ddl.Column{Field: `entity_id`, DataType: `int`, Null: "NO", ColumnType: `int(10) unsigned`, Extra: "auto_increment"}, "UInt64!", "UInt64"},
+		{ddl.Column{Field: `store_id`, DataType: `int`, Null: "NO", ColumnType: `int(11)`}, "Int!", "Int!"},
+		{ddl.Column{Field: `website_id`, DataType: `smallint`, Null: "NO", ColumnType: `smallint(5) unsigned`}, "Int!", "Int!"},
+		{ddl.Column{Field: `qty`, DataType: `bigint`, Null: "YES", ColumnType: `bigint(20)`}, "Int64", "Int64"},
+		{ddl.Column{Field: `views`, DataType: `bigint`, Null: "YES", ColumnType: `bigint(20) unsigned`}, "UInt64", "UInt64"},
+		{ddl.Column{Field: `email`, DataType: `varchar`, Null: "NO", ColumnType: `varchar(255)`}, "String!", "String!"},
+		{ddl.Column{Field: `weight`, DataType: `double`, Null: "NO", ColumnType: `double`, Default: dml.MakeNullString(`0`)}, "Float!", "Float"},
+		{ddl.Column{Field: `price`, DataType: `decimal`, Null: "NO", ColumnType: `decimal(12,4)`}, "Decimal!", "Decimal!"},
+		{ddl.Column{Field: `created_at`, DataType: `datetime`, Null: "YES", ColumnType: `datetime`}, "Time", "Time"},
+		{ddl.Column{Field: `is_active`, DataType: `tinyint`, Null: "NO", ColumnType: `tinyint(1)`}, "Boolean!", "Boolean!"},
+		{ddl.Column{Field: `payload`, DataType: `varbinary`, Null: "NO", ColumnType: `varbinary(255)`}, "Bytes!", "Bytes!"},
+	}
+	for _, test := range tests {
+		c := test.c
+		assert.Exactly(t, test.want, toGraphQLType(&c), "%s", c.Field)
+		assert.Exactly(t, test.wantInput, toGraphQLInputType(&c), "%s", c.Field)
+	}
+}
+
+func TestToLowerFirst(t *testing.T) {
+	t.Parallel()
+	assert.Exactly(t, "customerEntity", toLowerFirst("CustomerEntity"))
+	assert.Exactly(t, "äbc", toLowerFirst("Äbc"))
+	assert.Exactly(t, "", toLowerFirst(""))
+}
@@ -0,0 +1,114 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmlgen
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/util/strs"
+)
+
+// jsonTypeFuncs maps a Go type to the function which appends a field value to
+// a byte slice, the function which parses a JSON value and the condition which
+// reports if the field value is not empty. %[1]s gets replaced by the field.
+var jsonTypeFuncs = map[string]struct {
+	append   string
+	parse    string
+	nonEmpty string
+}{
+	"int64":           {"strconv.AppendInt(buf, %[1]s, 10)", "dml.JSONParseInt64", "%[1]s != 0"},
+	"uint64":          {"strconv.AppendUint(buf, %[1]s, 10)", "dml.JSONParseUint64", "%[1]s != 0"},
+	"float64":         {"dml.JSONAppendFloat64(buf, %[1]s)", "dml.JSONParseFloat64", "%[1]s != 0"},
+	"bool":            {"strconv.AppendBool(buf, %[1]s)", "dml.JSONParseBool", "%[1]s"},
+	"string":          {"dml.JSONAppendString(buf, %[1]s)", "dml.JSONParseString", `%[1]s != ""`},
+	"[]byte":          {"dml.JSONAppendBytes(buf, %[1]s)", "dml.JSONParseBytes", "len(%[1]s) > 0"},
+	"time.Time":       {"dml.JSONAppendTime(buf, %[1]s)", "dml.JSONParseTime", "!%[1]s.IsZero()"},
+	"dml.Decimal":     {"dml.JSONAppendDecimal(buf, %[1]s)", "dml.JSONParseDecimal", "%[1]s.Valid"},
+	"dml.NullString":  {"dml.JSONAppendString(buf, %[1]s.String)", "dml.JSONParseNullString", "%[1]s.Valid"},
+	"dml.NullInt64":   {"strconv.AppendInt(buf, %[1]s.Int64, 10)", "dml.JSONParseNullInt64", "%[1]s.Valid"},
+	"dml.NullFloat64": {"dml.JSONAppendFloat64(buf, %[1]s.Float64)", "dml.JSONParseNullFloat64", "%[1]s.Valid"},
+	"dml.NullBool":    {"strconv.AppendBool(buf, %[1]s.Bool)", "dml.JSONParseNullBool", "%[1]s.Valid"},
+	"dml.NullTime":    {"dml.JSONAppendTime(buf, %[1]s.Time)", "dml.JSONParseNullTime", "%[1]s.Valid"},
+}
+
+// jsonKey returns the JSON object key of a column. The name of the json struct
+// tag, for example set via CustomStructTags, takes precedence over the column
+// name. Returns false if the json struct tag is "-".
+func jsonKey(c *ddl.Column) (string, bool) {
+	tag, ok := reflect.StructTag(c.StructTag).Lookup("json")
+	if !ok {
+		return c.Field, true
+	}
+	if tag == "-" {
+		return "", false
+	}
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		tag = tag[:i]
+	}
+	if tag == "" {
+		return c.Field, true
+	}
+	return tag, true
+}
+
+// toGoJSONMarshal generates the Go source code which appends the key and the
+// value of the field of a column to the variable buf, followed by a comma.
+// The key gets returned by jsonKey. A column with the json struct tag "-"
+// returns an empty string. If omitEmpty has been set, the field gets only
+// written when it is not empty.
+func toGoJSONMarshal(omitEmpty bool, c *ddl.Column) string {
+	goType := mySQLToGoType(c, true)
+	funcs, ok := jsonTypeFuncs[goType]
+	if !ok {
+		panic(fmt.Sprintf("[dmlgen] toGoJSONMarshal: Go type %q of column %q not supported", goType, c.Field))
+	}
+	jk, ok := jsonKey(c)
+	if !ok {
+		return ""
+	}
+	field := "e." + strs.ToGoCamelCase(c.Field)
+	key := fmt.Sprintf("buf = append(buf, `%q:`...)\n", jk)
+	value := "buf = " + fmt.Sprintf(funcs.append, field) + "\n"
+	nonEmpty := fmt.Sprintf(funcs.nonEmpty, field)
+
+	isNull := goType != "dml.Decimal" && funcs.nonEmpty == "%[1]s.Valid"
+	switch {
+	case omitEmpty:
+		return "if " + nonEmpty + " {\n" + key + value + "buf = append(buf, ',')\n}"
+	case isNull:
+		value = "if " + nonEmpty + " {\n" + value + "} else {\nbuf = append(buf, \"null\"...)\n}\n"
+	}
+	return key + value + "buf = append(buf, ',')"
+}
+
+// toGoJSONUnmarshal generates the case statement which parses the JSON value
+// of a column into its field. The variables value and err must be declared.
+// The key gets returned by jsonKey. A column with the json struct tag "-"
+// returns an empty string.
+func toGoJSONUnmarshal(c *ddl.Column) string {
+	goType := mySQLToGoType(c, true)
+	funcs, ok := jsonTypeFuncs[goType]
+	if !ok {
+		panic(fmt.Sprintf("[dmlgen] toGoJSONUnmarshal: Go type %q of column %q not supported", goType, c.Field))
+	}
+	jk, ok := jsonKey(c)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("case %q:\ne.%s, err = %s(value)", jk, strs.ToGoCamelCase(c.Field), funcs.parse)
+}
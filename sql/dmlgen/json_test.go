@@ -0,0 +1,76 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmlgen
+
+import (
+	"testing"
+
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToGoJSONMarshal(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		c         ddl.Column
+		omitEmpty bool
+		want      string
+	}{
+		{ddl.Column{Field: `entity_id`, DataType: `int`, ColumnType: `int(10) unsigned`}, false,
+			"buf = append(buf, `\"entity_id\":`...)\nbuf = strconv.AppendUint(buf, e.EntityID, 10)\nbuf = append(buf, ',')"},
+		{ddl.Column{Field: `entity_id`, DataType: `int`, ColumnType: `int(10) unsigned`}, true,
+			"if e.EntityID != 0 {\nbuf = append(buf, `\"entity_id\":`...)\nbuf = strconv.AppendUint(buf, e.EntityID, 10)\nbuf = append(buf, ',')\n}"},
+		{ddl.Column{Field: `email`, DataType: `varchar`, Null: "YES", ColumnType: `varchar(255)`}, false,
+			"buf = append(buf, `\"email\":`...)\nif e.Email.Valid {\nbuf = dml.JSONAppendString(buf, e.Email.String)\n} else {\nbuf = append(buf, \"null\"...)\n}\nbuf = append(buf, ',')"},
+		{ddl.Column{Field: `email`, DataType: `varchar`, Null: "YES", ColumnType: `varchar(255)`}, true,
+			"if e.Email.Valid {\nbuf = append(buf, `\"email\":`...)\nbuf = dml.JSONAppendString(buf, e.Email.String)\nbuf = append(buf, ',')\n}"},
+		{ddl.Column{Field: `price`, DataType: `decimal`, Null: "YES", ColumnType: `decimal(12,4)`}, false,
+			"buf = append(buf, `\"price\":`...)\nbuf = dml.JSONAppendDecimal(buf, e.Price)\nbuf = append(buf, ',')"},
+		{ddl.Column{Field: `created_at`, DataType: `datetime`, Null: "NO", ColumnType: `datetime`}, true,
+			"if !e.CreatedAt.IsZero() {\nbuf = append(buf, `\"created_at\":`...)\nbuf = dml.JSONAppendTime(buf, e.CreatedAt)\nbuf = append(buf, ',')\n}"},
+		{ddl.Column{Field: `path`, DataType: `varchar`, Null: "NO", ColumnType: `varchar(255)`, StructTag: `json:"x_path,omitempty" xml:"y_path"`}, false,
+			"buf = append(buf, `\"x_path\":`...)\nbuf = dml.JSONAppendString(buf, e.Path)\nbuf = append(buf, ',')"},
+		{ddl.Column{Field: `path`, DataType: `varchar`, Null: "NO", ColumnType: `varchar(255)`, StructTag: `json:",omitempty"`}, false,
+			"buf = append(buf, `\"path\":`...)\nbuf = dml.JSONAppendString(buf, e.Path)\nbuf = append(buf, ',')"},
+		{ddl.Column{Field: `password_hash`, DataType: `varchar`, Null: "NO", ColumnType: `varchar(128)`, StructTag: `json:"-"`}, false, ""},
+	}
+	for _, test := range tests {
+		c := test.c
+		assert.Exactly(t, test.want, toGoJSONMarshal(test.omitEmpty, &c), "%s", c.Field)
+	}
+}
+
+func TestToGoJSONUnmarshal(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		c    ddl.Column
+		want string
+	}{
+		{ddl.Column{Field: `qty`, DataType: `int`, Null: "YES", ColumnType: `int(10)`},
+			"case \"qty\":\ne.Qty, err = dml.JSONParseNullInt64(value)"},
+		{ddl.Column{Field: `weight`, DataType: `double`, Null: "NO", ColumnType: `double`, Default: dml.MakeNullString(`0`)},
+			"case \"weight\":\ne.Weight, err = dml.JSONParseFloat64(value)"},
+		{ddl.Column{Field: `is_active`, DataType: `smallint`, Null: "NO", ColumnType: `smallint(5)`},
+			"case \"is_active\":\ne.IsActive, err = dml.JSONParseBool(value)"},
+		{ddl.Column{Field: `path`, DataType: `varchar`, Null: "NO", ColumnType: `varchar(255)`, StructTag: `json:"x_path" xml:"y_path"`},
+			"case \"x_path\":\ne.Path, err = dml.JSONParseString(value)"},
+		{ddl.Column{Field: `password_hash`, DataType: `varchar`, Null: "NO", ColumnType: `varchar(128)`, StructTag: `json:"-"`}, ""},
+	}
+	for _, test := range tests {
+		c := test.c
+		assert.Exactly(t, test.want, toGoJSONUnmarshal(&c), "%s", c.Field)
+	}
+}
@@ -3,14 +3,18 @@
 package testdata
 
 import (
-	"encoding/json"
+	"context"
+	"database/sql"
+	"math/rand"
+	"strconv"
 	"time"
+	"unicode/utf8"
 
 	"github.com/corestoreio/errors"
 	"github.com/corestoreio/pkg/sql/ddl"
 	"github.com/corestoreio/pkg/sql/dml"
-)
 
+)
 // NewTables returns a goified version of the MySQL/MariaDB table schema for the
 // tables: [core_config_data customer_entity dmlgen_types]
 // Auto generated by dmlgen.
@@ -173,20 +177,25 @@ func MakeCoreConfigDataCollection() CoreConfigDataCollection {
 }
 
 func (cc CoreConfigDataCollection) scanColumns(cm *dml.ColumnMap, e *CoreConfigData, idx uint64) error {
-	if err := cc.BeforeMapColumns(idx, e); err != nil {
-		return errors.WithStack(err)
+	if cc.BeforeMapColumns != nil {
+		if err := cc.BeforeMapColumns(idx, e); err != nil {
+			return errors.WithStack(err)
+		}
 	}
 	if err := e.MapColumns(cm); err != nil {
 		return errors.WithStack(err)
 	}
-	if err := cc.AfterMapColumns(idx, e); err != nil {
-		return errors.WithStack(err)
+	if cc.AfterMapColumns != nil {
+		if err := cc.AfterMapColumns(idx, e); err != nil {
+			return errors.WithStack(err)
+		}
 	}
 	return nil
 }
 
-// MapColumns implements dml.ColumnMapper interface. Auto generated.
-func (cc CoreConfigDataCollection) MapColumns(cm *dml.ColumnMap) error {
+// MapColumns implements dml.ColumnMapper interface. A pointer receiver is
+// required to append the scanned entities to the Data slice. Auto generated.
+func (cc *CoreConfigDataCollection) MapColumns(cm *dml.ColumnMap) error {
 	switch m := cm.Mode(); m {
 	case dml.ColumnMapEntityReadAll, dml.ColumnMapEntityReadSet:
 		for i, e := range cc.Data {
@@ -334,6 +343,359 @@ func (s *sliceCoreConfigData) Prepend(n *CoreConfigData) {
 	s.Insert(n, 0)
 }
 
+// Validate checks the fields of the entity against the constraints of the
+// columns in table `core_config_data`: maximum length, unsigned numbers, integer
+// ranges, DECIMAL precision and scale, ENUM and SET members and NOT NULL columns
+// without a default value. Returns the first NotValid error. Auto generated.
+func (e *CoreConfigData) Validate() error {
+	if e.ConfigID > 4294967295 {
+		return errors.NotValid.Newf("[testdata] CoreConfigData.ConfigID: Column `config_id` exceeds the maximum value of 4294967295")
+	}
+	if utf8.RuneCountInString(e.Scope) > 8 {
+		return errors.NotValid.Newf("[testdata] CoreConfigData.Scope: Column `scope` exceeds the maximum length of 8 characters")
+	}
+	if e.ScopeID < -2147483648 || e.ScopeID > 2147483647 {
+		return errors.NotValid.Newf("[testdata] CoreConfigData.ScopeID: Column `scope_id` is out of range [-2147483648, 2147483647]")
+	}
+	if utf8.RuneCountInString(e.Path) > 255 {
+		return errors.NotValid.Newf("[testdata] CoreConfigData.Path: Column `path` exceeds the maximum length of 255 characters")
+	}
+	if e.Value.Valid && len(e.Value.String) > 65535 {
+		return errors.NotValid.Newf("[testdata] CoreConfigData.Value: Column `value` exceeds the maximum length of 65535 bytes")
+	}
+	return nil
+}
+
+// Validate checks each entity of the collection. Auto generated.
+func (cc CoreConfigDataCollection) Validate() error {
+	for _, e := range cc.Data {
+		if err := e.Validate(); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// TableNameCoreConfigData defines the name of the DB table `core_config_data`. Auto
+// generated.
+const TableNameCoreConfigData = "core_config_data"
+
+// Load loads a single row from table `core_config_data` identified by its primary
+// key into the entity. Returns a NotFound error if the row does not exist.
+// Auto generated.
+func (e *CoreConfigData) Load(ctx context.Context, tbls *ddl.Tables, pkConfigID uint64) error {
+	tbl, err := tbls.Table(TableNameCoreConfigData)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	rowCount, err := tbl.SelectByPK().WithArgs().Load(ctx, e, pkConfigID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if rowCount == 0 {
+		return errors.NotFound.Newf("[testdata] CoreConfigData not found")
+	}
+	return nil
+}
+
+// Insert inserts the entity into table `core_config_data`. An auto increment ID
+// gets assigned to the entity. Auto generated.
+func (e *CoreConfigData) Insert(ctx context.Context, tbls *ddl.Tables) (sql.Result, error) {
+	if err := e.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	tbl, err := tbls.Table(TableNameCoreConfigData)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	res, err := repositoryInsertCoreConfigData(tbl).WithArgs().Record("", e).ExecContext(ctx)
+	return res, errors.WithStack(err)
+}
+
+// Upsert inserts the entity into table `core_config_data` or updates all non
+// primary key columns if the primary key already exists. An auto increment ID
+// does not get assigned because an updated row does not generate one. Auto
+// generated.
+func (e *CoreConfigData) Upsert(ctx context.Context, tbls *ddl.Tables) (sql.Result, error) {
+	if err := e.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	tbl, err := tbls.Table(TableNameCoreConfigData)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	res, err := repositoryUpsertCoreConfigData(tbl).WithArgs().Record("", e).ExecContext(ctx)
+	return res, errors.WithStack(err)
+}
+
+// Update updates all non primary key columns of the entity in table
+// `core_config_data`. The primary key identifies the row. Auto generated.
+func (e *CoreConfigData) Update(ctx context.Context, tbls *ddl.Tables) (sql.Result, error) {
+	if err := e.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	tbl, err := tbls.Table(TableNameCoreConfigData)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	res, err := tbl.UpdateByPK().WithArgs().Record("", e).ExecContext(ctx)
+	return res, errors.WithStack(err)
+}
+
+// Delete deletes the entity, identified by its primary key, from table
+// `core_config_data`. Auto generated.
+func (e *CoreConfigData) Delete(ctx context.Context, tbls *ddl.Tables) (sql.Result, error) {
+	tbl, err := tbls.Table(TableNameCoreConfigData)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	res, err := tbl.DeleteByPK().WithArgs().Record("", e).ExecContext(ctx)
+	return res, errors.WithStack(err)
+}
+
+// repositoryInsertCoreConfigData creates the INSERT statement for table
+// `core_config_data`. Primary key columns without auto increment must be part of
+// the statement. Auto generated.
+func repositoryInsertCoreConfigData(tbl *ddl.Table) *dml.Insert {
+	ins := tbl.Insert()
+	return ins.SetRecordPlaceHolderCount(len(ins.Columns))
+}
+
+// repositoryUpsertCoreConfigData creates the INSERT ... ON DUPLICATE KEY UPDATE
+// statement for table `core_config_data`. Auto generated.
+func repositoryUpsertCoreConfigData(tbl *ddl.Table) *dml.Insert {
+	ins := tbl.Insert()
+	ins.AddColumns("config_id").AddOnDuplicateKeyExclude("config_id")
+	return ins.SetRecordPlaceHolderCount(len(ins.Columns)).OnDuplicateKey()
+}
+
+// LoadAll loads all rows from table `core_config_data` into the collection. The
+// optional conditions get added to the WHERE clause. Auto generated.
+func (cc *CoreConfigDataCollection) LoadAll(ctx context.Context, tbls *ddl.Tables, wheres ...*dml.Condition) error {
+	tbl, err := tbls.Table(TableNameCoreConfigData)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = tbl.SelectAll().Where(wheres...).WithArgs().Load(ctx, cc)
+	return errors.WithStack(err)
+}
+
+// Load loads all rows from table `core_config_data` identified by the list of
+// primary keys into the collection. Auto generated.
+func (cc *CoreConfigDataCollection) Load(ctx context.Context, tbls *ddl.Tables, pkConfigIDs ...uint64) error {
+	tbl, err := tbls.Table(TableNameCoreConfigData)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = tbl.SelectByPK().WithArgs().Uint64s(pkConfigIDs...).ExpandPlaceHolders().Load(ctx, cc)
+	return errors.WithStack(err)
+}
+
+// Insert inserts all entities of the collection with one statement into table
+// `core_config_data`. Auto increment IDs get assigned to the entities. Auto
+// generated.
+func (cc *CoreConfigDataCollection) Insert(ctx context.Context, tbls *ddl.Tables) (sql.Result, error) {
+	if len(cc.Data) == 0 {
+		return nil, nil
+	}
+	if err := cc.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	tbl, err := tbls.Table(TableNameCoreConfigData)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	a := repositoryInsertCoreConfigData(tbl).WithArgs()
+	for _, e := range cc.Data {
+		a.Record("", e)
+	}
+	res, err := a.ExecContext(ctx)
+	return res, errors.WithStack(err)
+}
+
+// Upsert inserts or updates all entities of the collection with one statement
+// in table `core_config_data`. Auto increment IDs do not get assigned. Auto
+// generated.
+func (cc *CoreConfigDataCollection) Upsert(ctx context.Context, tbls *ddl.Tables) (sql.Result, error) {
+	if len(cc.Data) == 0 {
+		return nil, nil
+	}
+	if err := cc.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	tbl, err := tbls.Table(TableNameCoreConfigData)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	a := repositoryUpsertCoreConfigData(tbl).WithArgs()
+	for _, e := range cc.Data {
+		a.Record("", e)
+	}
+	res, err := a.ExecContext(ctx)
+	return res, errors.WithStack(err)
+}
+
+// Update updates each entity of the collection in table `core_config_data`. The
+// statement gets built once and executed for each entity within one
+// transaction, see ddl.Table.RunInTx. Auto generated.
+func (cc *CoreConfigDataCollection) Update(ctx context.Context, tbls *ddl.Tables) error {
+	if err := cc.Validate(); err != nil {
+		return errors.WithStack(err)
+	}
+	tbl, err := tbls.Table(TableNameCoreConfigData)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return tbl.RunInTx(ctx, nil, func(db dml.QueryExecPreparer) error {
+		a := tbl.UpdateByPK().WithArgs().WithDB(db)
+		for _, e := range cc.Data {
+			if _, err := a.Record("", e).ExecContext(ctx); err != nil {
+				return errors.WithStack(err)
+			}
+			a.Reset()
+		}
+		return nil
+	})
+}
+
+// Delete deletes each entity of the collection from table `core_config_data`
+// within one transaction, see ddl.Table.RunInTx. Auto generated.
+func (cc *CoreConfigDataCollection) Delete(ctx context.Context, tbls *ddl.Tables) error {
+	tbl, err := tbls.Table(TableNameCoreConfigData)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return tbl.RunInTx(ctx, nil, func(db dml.QueryExecPreparer) error {
+		a := tbl.DeleteByPK().WithArgs().WithDB(db)
+		for _, e := range cc.Data {
+			if _, err := a.Record("", e).ExecContext(ctx); err != nil {
+				return errors.WithStack(err)
+			}
+			a.Reset()
+		}
+		return nil
+	})
+}
+
+// CoreConfigDataResolver resolves the GraphQL Query and Mutation fields of DB
+// table `core_config_data` with the repository methods. Embed it into the root
+// resolver of the GraphQL server. The schema gets written by
+// Tables.WriteGraphQL. Auto generated.
+type CoreConfigDataResolver struct {
+	Tables *ddl.Tables
+	// MaxLimit caps the argument limit of the query field
+	// `coreConfigDataCollection`. Defaults to 100 if zero.
+	MaxLimit int32
+}
+
+// CoreConfigData resolves the query field `coreConfigData`. Returns nil if
+// the row does not exist. Auto generated.
+func (r CoreConfigDataResolver) CoreConfigData(ctx context.Context, pkConfigID uint64) (*CoreConfigData, error) {
+	e := new(CoreConfigData)
+	err := e.Load(ctx, r.Tables, pkConfigID)
+	switch {
+	case errors.NotFound.Match(err):
+		return nil, nil
+	case err != nil:
+		return nil, errors.WithStack(err)
+	}
+	return e, nil
+}
+
+// CoreConfigDataCollection resolves the query field `coreConfigDataCollection`
+// and returns at most `limit` rows starting at `offset`. A limit smaller than
+// one or greater than MaxLimit gets set to MaxLimit. Auto generated.
+func (r CoreConfigDataResolver) CoreConfigDataCollection(ctx context.Context, offset, limit int32) ([]*CoreConfigData, error) {
+	maxLimit := r.MaxLimit
+	if maxLimit < 1 {
+		maxLimit = 100
+	}
+	if limit < 1 || limit > maxLimit {
+		limit = maxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	tbl, err := r.Tables.Table(TableNameCoreConfigData)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cc := MakeCoreConfigDataCollection()
+	if _, err := tbl.SelectAll().Limit(uint64(offset), uint64(limit)).WithArgs().Load(ctx, &cc); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return cc.Data, nil
+}
+
+// CreateCoreConfigData resolves the mutation field `createCoreConfigData`. Auto
+// generated.
+func (r CoreConfigDataResolver) CreateCoreConfigData(ctx context.Context, input *CoreConfigData) (*CoreConfigData, error) {
+	if _, err := input.Insert(ctx, r.Tables); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return input, nil
+}
+
+// UpdateCoreConfigData resolves the mutation field `updateCoreConfigData`. The
+// primary key of the input identifies the row. Auto generated.
+func (r CoreConfigDataResolver) UpdateCoreConfigData(ctx context.Context, input *CoreConfigData) (*CoreConfigData, error) {
+	if _, err := input.Update(ctx, r.Tables); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return input, nil
+}
+
+// DeleteCoreConfigData resolves the mutation field `deleteCoreConfigData`. Returns
+// false if the row does not exist. Auto generated.
+func (r CoreConfigDataResolver) DeleteCoreConfigData(ctx context.Context, pkConfigID uint64) (bool, error) {
+	e := &CoreConfigData{
+		ConfigID: pkConfigID,
+	}
+	res, err := e.Delete(ctx, r.Tables)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, errors.WithStack(err)
+}
+
+// NewFixtureCoreConfigData creates a new entity for table `core_config_data`
+// filled with random but valid values. Columns referencing other tables get
+// their values from refs, if available. Auto increment columns stay empty.
+// Returns an error if a column is too short for its random value. Auto
+// generated.
+func NewFixtureCoreConfigData(r *rand.Rand, refs ddl.FixtureRefs) (*CoreConfigData, error) {
+	e := new(CoreConfigData)
+	e.Scope = ddl.FixtureString(r, 8)
+	e.ScopeID = r.Int63n(2147483647)
+	e.Path = ddl.FixtureString(r, 255)
+	e.Value = dml.MakeNullString(ddl.FixtureString(r, 65535))
+	return e, nil
+}
+
+// FixtureCoreConfigData returns the fixture which inserts n random rows into
+// table `core_config_data`. Use it with function ddl.SeedFixtures. Auto
+// generated.
+func FixtureCoreConfigData(n int) ddl.Fixture {
+	return ddl.Fixture{
+		TableName: "core_config_data",
+		Count:     n,
+		Insert: func(ctx context.Context, db *dml.ConnPool, r *rand.Rand, refs ddl.FixtureRefs) error {
+			e, err := NewFixtureCoreConfigData(r, refs)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			ins := dml.NewInsert("core_config_data").AddColumns("scope", "scope_id", "path", "value")
+			if _, err := ins.SetRecordPlaceHolderCount(len(ins.Columns)).WithDB(db.DB).WithArgs().Record("", e).ExecContext(ctx); err != nil {
+				return errors.WithStack(err)
+			}
+			refs.Add("core_config_data.config_id", e.ConfigID)
+			refs.Add("core_config_data.path", e.Path)
+			return nil
+		},
+	}
+}
+
 // CustomerEntity represents a single row for DB table `customer_entity`.
 // Auto generated.
 type CustomerEntity struct {
@@ -469,20 +831,25 @@ func MakeCustomerEntityCollection() CustomerEntityCollection {
 }
 
 func (cc CustomerEntityCollection) scanColumns(cm *dml.ColumnMap, e *CustomerEntity, idx uint64) error {
-	if err := cc.BeforeMapColumns(idx, e); err != nil {
-		return errors.WithStack(err)
+	if cc.BeforeMapColumns != nil {
+		if err := cc.BeforeMapColumns(idx, e); err != nil {
+			return errors.WithStack(err)
+		}
 	}
 	if err := e.MapColumns(cm); err != nil {
 		return errors.WithStack(err)
 	}
-	if err := cc.AfterMapColumns(idx, e); err != nil {
-		return errors.WithStack(err)
+	if cc.AfterMapColumns != nil {
+		if err := cc.AfterMapColumns(idx, e); err != nil {
+			return errors.WithStack(err)
+		}
 	}
 	return nil
 }
 
-// MapColumns implements dml.ColumnMapper interface. Auto generated.
-func (cc CustomerEntityCollection) MapColumns(cm *dml.ColumnMap) error {
+// MapColumns implements dml.ColumnMapper interface. A pointer receiver is
+// required to append the scanned entities to the Data slice. Auto generated.
+func (cc *CustomerEntityCollection) MapColumns(cm *dml.ColumnMap) error {
 	switch m := cm.Mode(); m {
 	case dml.ColumnMapEntityReadAll, dml.ColumnMapEntityReadSet:
 		for i, e := range cc.Data {
@@ -609,17 +976,329 @@ func (s *sliceCustomerEntity) Prepend(n *CustomerEntity) {
 	s.Insert(n, 0)
 }
 
-// UnmarshalJSON implements interface json.Unmarshaler.
-func (cc *CustomerEntityCollection) UnmarshalJSON(b []byte) (err error) {
-	return json.Unmarshal(b, cc.Data)
+// AppendJSON appends the JSON encoding of the entity to buf without using
+// reflection. The object keys are the names of the json struct tags or the
+// column names of table `customer_entity`. Empty fields are omitted. Auto
+// generated.
+func (e *CustomerEntity) AppendJSON(buf []byte) ([]byte, error) {
+	buf = append(buf, '{')
+	if e.EntityID != 0 {
+		buf = append(buf, `"entity_id":`...)
+		buf = strconv.AppendUint(buf, e.EntityID, 10)
+		buf = append(buf, ',')
+	}
+	if e.WebsiteID.Valid {
+		buf = append(buf, `"website_id":`...)
+		buf = strconv.AppendInt(buf, e.WebsiteID.Int64, 10)
+		buf = append(buf, ',')
+	}
+	if e.Email.Valid {
+		buf = append(buf, `"email":`...)
+		buf = dml.JSONAppendString(buf, e.Email.String)
+		buf = append(buf, ',')
+	}
+	if e.GroupID != 0 {
+		buf = append(buf, `"group_id":`...)
+		buf = strconv.AppendUint(buf, e.GroupID, 10)
+		buf = append(buf, ',')
+	}
+	if e.IncrementID.Valid {
+		buf = append(buf, `"increment_id":`...)
+		buf = dml.JSONAppendString(buf, e.IncrementID.String)
+		buf = append(buf, ',')
+	}
+	if e.StoreID.Valid {
+		buf = append(buf, `"store_id":`...)
+		buf = strconv.AppendInt(buf, e.StoreID.Int64, 10)
+		buf = append(buf, ',')
+	}
+	if !e.CreatedAt.IsZero() {
+		buf = append(buf, `"created_at":`...)
+		buf = dml.JSONAppendTime(buf, e.CreatedAt)
+		buf = append(buf, ',')
+	}
+	if !e.UpdatedAt.IsZero() {
+		buf = append(buf, `"updated_at":`...)
+		buf = dml.JSONAppendTime(buf, e.UpdatedAt)
+		buf = append(buf, ',')
+	}
+	if e.IsActive {
+		buf = append(buf, `"is_active":`...)
+		buf = strconv.AppendBool(buf, e.IsActive)
+		buf = append(buf, ',')
+	}
+	if e.DisableAutoGroupChange != 0 {
+		buf = append(buf, `"disable_auto_group_change":`...)
+		buf = strconv.AppendUint(buf, e.DisableAutoGroupChange, 10)
+		buf = append(buf, ',')
+	}
+	if e.CreatedIn.Valid {
+		buf = append(buf, `"created_in":`...)
+		buf = dml.JSONAppendString(buf, e.CreatedIn.String)
+		buf = append(buf, ',')
+	}
+	if e.Prefix.Valid {
+		buf = append(buf, `"prefix":`...)
+		buf = dml.JSONAppendString(buf, e.Prefix.String)
+		buf = append(buf, ',')
+	}
+	if e.Firstname.Valid {
+		buf = append(buf, `"firstname":`...)
+		buf = dml.JSONAppendString(buf, e.Firstname.String)
+		buf = append(buf, ',')
+	}
+	if e.Middlename.Valid {
+		buf = append(buf, `"middlename":`...)
+		buf = dml.JSONAppendString(buf, e.Middlename.String)
+		buf = append(buf, ',')
+	}
+	if e.Lastname.Valid {
+		buf = append(buf, `"lastname":`...)
+		buf = dml.JSONAppendString(buf, e.Lastname.String)
+		buf = append(buf, ',')
+	}
+	if e.Suffix.Valid {
+		buf = append(buf, `"suffix":`...)
+		buf = dml.JSONAppendString(buf, e.Suffix.String)
+		buf = append(buf, ',')
+	}
+	if e.Dob.Valid {
+		buf = append(buf, `"dob":`...)
+		buf = dml.JSONAppendTime(buf, e.Dob.Time)
+		buf = append(buf, ',')
+	}
+	if e.PasswordHash.Valid {
+		buf = append(buf, `"password_hash":`...)
+		buf = dml.JSONAppendString(buf, e.PasswordHash.String)
+		buf = append(buf, ',')
+	}
+	if e.RpToken.Valid {
+		buf = append(buf, `"rp_token":`...)
+		buf = dml.JSONAppendString(buf, e.RpToken.String)
+		buf = append(buf, ',')
+	}
+	if e.RpTokenCreatedAt.Valid {
+		buf = append(buf, `"rp_token_created_at":`...)
+		buf = dml.JSONAppendTime(buf, e.RpTokenCreatedAt.Time)
+		buf = append(buf, ',')
+	}
+	if e.DefaultBilling.Valid {
+		buf = append(buf, `"default_billing":`...)
+		buf = strconv.AppendInt(buf, e.DefaultBilling.Int64, 10)
+		buf = append(buf, ',')
+	}
+	if e.DefaultShipping.Valid {
+		buf = append(buf, `"default_shipping":`...)
+		buf = strconv.AppendInt(buf, e.DefaultShipping.Int64, 10)
+		buf = append(buf, ',')
+	}
+	if e.Taxvat.Valid {
+		buf = append(buf, `"taxvat":`...)
+		buf = dml.JSONAppendString(buf, e.Taxvat.String)
+		buf = append(buf, ',')
+	}
+	if e.Confirmation.Valid {
+		buf = append(buf, `"confirmation":`...)
+		buf = dml.JSONAppendString(buf, e.Confirmation.String)
+		buf = append(buf, ',')
+	}
+	if e.Gender.Valid {
+		buf = append(buf, `"gender":`...)
+		buf = strconv.AppendInt(buf, e.Gender.Int64, 10)
+		buf = append(buf, ',')
+	}
+	if e.FailuresNum.Valid {
+		buf = append(buf, `"failures_num":`...)
+		buf = strconv.AppendInt(buf, e.FailuresNum.Int64, 10)
+		buf = append(buf, ',')
+	}
+	if e.FirstFailure.Valid {
+		buf = append(buf, `"first_failure":`...)
+		buf = dml.JSONAppendTime(buf, e.FirstFailure.Time)
+		buf = append(buf, ',')
+	}
+	if e.LockExpires.Valid {
+		buf = append(buf, `"lock_expires":`...)
+		buf = dml.JSONAppendTime(buf, e.LockExpires.Time)
+		buf = append(buf, ',')
+	}
+	if buf[len(buf)-1] == ',' {
+		buf = buf[:len(buf)-1]
+	}
+	return append(buf, '}'), nil
+}
+
+// MarshalJSON implements interface json.Marshaler. Auto generated.
+func (e *CustomerEntity) MarshalJSON() ([]byte, error) {
+	return e.AppendJSON(make([]byte, 0, 28*16))
 }
 
-// MarshalJSON implements interface json.Marshaler.
+// UnmarshalJSON implements interface json.Unmarshaler without using
+// reflection. Unknown keys are ignored. Auto generated.
+func (e *CustomerEntity) UnmarshalJSON(data []byte) error {
+	return dml.JSONObjectEach(data, func(key string, value []byte) (err error) {
+		switch key {
+		case "entity_id":
+			e.EntityID, err = dml.JSONParseUint64(value)
+		case "website_id":
+			e.WebsiteID, err = dml.JSONParseNullInt64(value)
+		case "email":
+			e.Email, err = dml.JSONParseNullString(value)
+		case "group_id":
+			e.GroupID, err = dml.JSONParseUint64(value)
+		case "increment_id":
+			e.IncrementID, err = dml.JSONParseNullString(value)
+		case "store_id":
+			e.StoreID, err = dml.JSONParseNullInt64(value)
+		case "created_at":
+			e.CreatedAt, err = dml.JSONParseTime(value)
+		case "updated_at":
+			e.UpdatedAt, err = dml.JSONParseTime(value)
+		case "is_active":
+			e.IsActive, err = dml.JSONParseBool(value)
+		case "disable_auto_group_change":
+			e.DisableAutoGroupChange, err = dml.JSONParseUint64(value)
+		case "created_in":
+			e.CreatedIn, err = dml.JSONParseNullString(value)
+		case "prefix":
+			e.Prefix, err = dml.JSONParseNullString(value)
+		case "firstname":
+			e.Firstname, err = dml.JSONParseNullString(value)
+		case "middlename":
+			e.Middlename, err = dml.JSONParseNullString(value)
+		case "lastname":
+			e.Lastname, err = dml.JSONParseNullString(value)
+		case "suffix":
+			e.Suffix, err = dml.JSONParseNullString(value)
+		case "dob":
+			e.Dob, err = dml.JSONParseNullTime(value)
+		case "password_hash":
+			e.PasswordHash, err = dml.JSONParseNullString(value)
+		case "rp_token":
+			e.RpToken, err = dml.JSONParseNullString(value)
+		case "rp_token_created_at":
+			e.RpTokenCreatedAt, err = dml.JSONParseNullTime(value)
+		case "default_billing":
+			e.DefaultBilling, err = dml.JSONParseNullInt64(value)
+		case "default_shipping":
+			e.DefaultShipping, err = dml.JSONParseNullInt64(value)
+		case "taxvat":
+			e.Taxvat, err = dml.JSONParseNullString(value)
+		case "confirmation":
+			e.Confirmation, err = dml.JSONParseNullString(value)
+		case "gender":
+			e.Gender, err = dml.JSONParseNullInt64(value)
+		case "failures_num":
+			e.FailuresNum, err = dml.JSONParseNullInt64(value)
+		case "first_failure":
+			e.FirstFailure, err = dml.JSONParseNullTime(value)
+		case "lock_expires":
+			e.LockExpires, err = dml.JSONParseNullTime(value)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "[testdata] CustomerEntity.UnmarshalJSON key %q", key)
+		}
+		return nil
+	})
+}
+
+// AppendJSON appends the JSON array of all entities to buf. Auto generated.
+func (cc *CustomerEntityCollection) AppendJSON(buf []byte) (_ []byte, err error) {
+	buf = append(buf, '[')
+	for i, e := range cc.Data {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if buf, err = e.AppendJSON(buf); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return append(buf, ']'), nil
+}
+
+// MarshalJSON implements interface json.Marshaler. Auto generated.
 func (cc *CustomerEntityCollection) MarshalJSON() ([]byte, error) {
-	return json.Marshal(cc.Data)
+	return cc.AppendJSON(make([]byte, 0, len(cc.Data)*28*16+2))
+}
+
+// UnmarshalJSON implements interface json.Unmarshaler and replaces the
+// entities of the collection with the entities of the JSON array. Auto
+// generated.
+func (cc *CustomerEntityCollection) UnmarshalJSON(data []byte) error {
+	cc.Data = cc.Data[:0]
+	return dml.JSONArrayEach(data, func(value []byte) error {
+		e := new(CustomerEntity)
+		if err := e.UnmarshalJSON(value); err != nil {
+			return errors.WithStack(err)
+		}
+		cc.Data = append(cc.Data, e)
+		return nil
+	})
+}
+
+// NewFixtureCustomerEntity creates a new entity for table `customer_entity`
+// filled with random but valid values. Columns referencing other tables get
+// their values from refs, if available. Auto increment columns stay empty.
+// Returns an error if a column is too short for its random value. Auto
+// generated.
+func NewFixtureCustomerEntity(r *rand.Rand, refs ddl.FixtureRefs) (*CustomerEntity, error) {
+	e := new(CustomerEntity)
+	e.WebsiteID = dml.MakeNullInt64(r.Int63n(32767))
+	emailEmail, err := ddl.FixtureEmail(r, 255)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	e.Email = dml.MakeNullString(emailEmail)
+	e.GroupID = uint64(r.Int63n(32767))
+	e.IncrementID = dml.MakeNullString(ddl.FixtureString(r, 50))
+	e.StoreID = dml.MakeNullInt64(r.Int63n(32767))
+	e.CreatedAt = ddl.FixtureTime(r)
+	e.UpdatedAt = ddl.FixtureTime(r)
+	e.IsActive = r.Intn(2) == 1
+	e.DisableAutoGroupChange = uint64(r.Int63n(32767))
+	e.CreatedIn = dml.MakeNullString(ddl.FixtureString(r, 255))
+	e.Prefix = dml.MakeNullString(ddl.FixtureString(r, 40))
+	e.Firstname = dml.MakeNullString(ddl.FixtureString(r, 255))
+	e.Middlename = dml.MakeNullString(ddl.FixtureString(r, 255))
+	e.Lastname = dml.MakeNullString(ddl.FixtureString(r, 255))
+	e.Suffix = dml.MakeNullString(ddl.FixtureString(r, 40))
+	e.Dob = dml.MakeNullTime(ddl.FixtureTime(r).Truncate(24 * time.Hour))
+	e.PasswordHash = dml.MakeNullString(ddl.FixtureString(r, 128))
+	e.RpToken = dml.MakeNullString(ddl.FixtureString(r, 128))
+	e.RpTokenCreatedAt = dml.MakeNullTime(ddl.FixtureTime(r))
+	e.DefaultBilling = dml.MakeNullInt64(r.Int63n(2147483647))
+	e.DefaultShipping = dml.MakeNullInt64(r.Int63n(2147483647))
+	e.Taxvat = dml.MakeNullString(ddl.FixtureString(r, 50))
+	e.Confirmation = dml.MakeNullString(ddl.FixtureString(r, 64))
+	e.Gender = dml.MakeNullInt64(r.Int63n(32767))
+	e.FailuresNum = dml.MakeNullInt64(r.Int63n(32767))
+	e.FirstFailure = dml.MakeNullTime(ddl.FixtureTime(r))
+	e.LockExpires = dml.MakeNullTime(ddl.FixtureTime(r))
+	return e, nil
+}
+
+// FixtureCustomerEntity returns the fixture which inserts n random rows into
+// table `customer_entity`. Use it with function ddl.SeedFixtures. Auto
+// generated.
+func FixtureCustomerEntity(n int) ddl.Fixture {
+	return ddl.Fixture{
+		TableName: "customer_entity",
+		Count:     n,
+		Insert: func(ctx context.Context, db *dml.ConnPool, r *rand.Rand, refs ddl.FixtureRefs) error {
+			e, err := NewFixtureCustomerEntity(r, refs)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			ins := dml.NewInsert("customer_entity").AddColumns("website_id", "email", "group_id", "increment_id", "store_id", "created_at", "updated_at", "is_active", "disable_auto_group_change", "created_in", "prefix", "firstname", "middlename", "lastname", "suffix", "dob", "password_hash", "rp_token", "rp_token_created_at", "default_billing", "default_shipping", "taxvat", "confirmation", "gender", "failures_num", "first_failure", "lock_expires")
+			if _, err := ins.SetRecordPlaceHolderCount(len(ins.Columns)).WithDB(db.DB).WithArgs().Record("", e).ExecContext(ctx); err != nil {
+				return errors.WithStack(err)
+			}
+			refs.Add("customer_entity.entity_id", e.EntityID)
+			return nil
+		},
+	}
 }
 
-// TODO add MarshalText and UnmarshalText.
 // DmlgenTypes represents a single row for DB table `dmlgen_types`.
 // Auto generated.
 // Just another comment.
@@ -801,20 +1480,25 @@ func MakeDmlgenTypesCollection() DmlgenTypesCollection {
 }
 
 func (cc DmlgenTypesCollection) scanColumns(cm *dml.ColumnMap, e *DmlgenTypes, idx uint64) error {
-	if err := cc.BeforeMapColumns(idx, e); err != nil {
-		return errors.WithStack(err)
+	if cc.BeforeMapColumns != nil {
+		if err := cc.BeforeMapColumns(idx, e); err != nil {
+			return errors.WithStack(err)
+		}
 	}
 	if err := e.MapColumns(cm); err != nil {
 		return errors.WithStack(err)
 	}
-	if err := cc.AfterMapColumns(idx, e); err != nil {
-		return errors.WithStack(err)
+	if cc.AfterMapColumns != nil {
+		if err := cc.AfterMapColumns(idx, e); err != nil {
+			return errors.WithStack(err)
+		}
 	}
 	return nil
 }
 
-// MapColumns implements dml.ColumnMapper interface. Auto generated.
-func (cc DmlgenTypesCollection) MapColumns(cm *dml.ColumnMap) error {
+// MapColumns implements dml.ColumnMapper interface. A pointer receiver is
+// required to append the scanned entities to the Data slice. Auto generated.
+func (cc *DmlgenTypesCollection) MapColumns(cm *dml.ColumnMap) error {
 	switch m := cm.Mode(); m {
 	case dml.ColumnMapEntityReadAll, dml.ColumnMapEntityReadSet:
 		for i, e := range cc.Data {
@@ -1067,17 +1751,350 @@ func (s *sliceDmlgenTypes) Prepend(n *DmlgenTypes) {
 	s.Insert(n, 0)
 }
 
-// UnmarshalJSON implements interface json.Unmarshaler.
-func (cc *DmlgenTypesCollection) UnmarshalJSON(b []byte) (err error) {
-	return json.Unmarshal(b, cc.Data)
+// TODO add MarshalText and UnmarshalText.
+
+// AppendJSON appends the JSON encoding of the entity to buf without using
+// reflection. The object keys are the column names of table
+// `dmlgen_types`. Auto
+// generated.
+func (e *DmlgenTypes) AppendJSON(buf []byte) ([]byte, error) {
+	buf = append(buf, '{')
+	buf = append(buf, `"id":`...)
+	buf = strconv.AppendInt(buf, e.ID, 10)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_bigint_1":`...)
+	if e.ColBigint1.Valid {
+		buf = strconv.AppendInt(buf, e.ColBigint1.Int64, 10)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_bigint_2":`...)
+	buf = strconv.AppendInt(buf, e.ColBigint2, 10)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_bigint_3":`...)
+	if e.ColBigint3.Valid {
+		buf = strconv.AppendInt(buf, e.ColBigint3.Int64, 10)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_bigint_4":`...)
+	buf = strconv.AppendUint(buf, e.ColBigint4, 10)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_blob":`...)
+	if e.ColBlob.Valid {
+		buf = dml.JSONAppendString(buf, e.ColBlob.String)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_date_1":`...)
+	if e.ColDate1.Valid {
+		buf = dml.JSONAppendTime(buf, e.ColDate1.Time)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_date_2":`...)
+	buf = dml.JSONAppendTime(buf, e.ColDate2)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_datetime_1":`...)
+	if e.ColDatetime1.Valid {
+		buf = dml.JSONAppendTime(buf, e.ColDatetime1.Time)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_datetime_2":`...)
+	buf = dml.JSONAppendTime(buf, e.ColDatetime2)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_decimal_10_0":`...)
+	buf = dml.JSONAppendDecimal(buf, e.ColDecimal100)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_decimal_12_4":`...)
+	buf = dml.JSONAppendDecimal(buf, e.ColDecimal124)
+	buf = append(buf, ',')
+	buf = append(buf, `"price_12_4a":`...)
+	buf = dml.JSONAppendDecimal(buf, e.Price124a)
+	buf = append(buf, ',')
+	buf = append(buf, `"price_12_4b":`...)
+	buf = dml.JSONAppendDecimal(buf, e.Price124b)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_decimal_12_3":`...)
+	buf = dml.JSONAppendDecimal(buf, e.ColDecimal123)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_decimal_20_6":`...)
+	buf = dml.JSONAppendDecimal(buf, e.ColDecimal206)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_decimal_24_12":`...)
+	buf = dml.JSONAppendDecimal(buf, e.ColDecimal2412)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_float":`...)
+	buf = dml.JSONAppendFloat64(buf, e.ColFloat)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_int_1":`...)
+	if e.ColInt1.Valid {
+		buf = strconv.AppendInt(buf, e.ColInt1.Int64, 10)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_int_2":`...)
+	buf = strconv.AppendInt(buf, e.ColInt2, 10)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_int_3":`...)
+	if e.ColInt3.Valid {
+		buf = strconv.AppendInt(buf, e.ColInt3.Int64, 10)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_int_4":`...)
+	buf = strconv.AppendUint(buf, e.ColInt4, 10)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_longtext_1":`...)
+	if e.ColLongtext1.Valid {
+		buf = dml.JSONAppendString(buf, e.ColLongtext1.String)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_longtext_2":`...)
+	buf = dml.JSONAppendString(buf, e.ColLongtext2)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_mediumblob":`...)
+	if e.ColMediumblob.Valid {
+		buf = dml.JSONAppendString(buf, e.ColMediumblob.String)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_mediumtext_1":`...)
+	if e.ColMediumtext1.Valid {
+		buf = dml.JSONAppendString(buf, e.ColMediumtext1.String)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_mediumtext_2":`...)
+	buf = dml.JSONAppendString(buf, e.ColMediumtext2)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_smallint_1":`...)
+	if e.ColSmallint1.Valid {
+		buf = strconv.AppendInt(buf, e.ColSmallint1.Int64, 10)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_smallint_2":`...)
+	buf = strconv.AppendInt(buf, e.ColSmallint2, 10)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_smallint_3":`...)
+	if e.ColSmallint3.Valid {
+		buf = strconv.AppendInt(buf, e.ColSmallint3.Int64, 10)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_smallint_4":`...)
+	buf = strconv.AppendUint(buf, e.ColSmallint4, 10)
+	buf = append(buf, ',')
+	buf = append(buf, `"has_smallint_5":`...)
+	buf = strconv.AppendBool(buf, e.HasSmallint5)
+	buf = append(buf, ',')
+	buf = append(buf, `"is_smallint_5":`...)
+	if e.IsSmallint5.Valid {
+		buf = strconv.AppendBool(buf, e.IsSmallint5.Bool)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_text":`...)
+	if e.ColText.Valid {
+		buf = dml.JSONAppendString(buf, e.ColText.String)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_timestamp_1":`...)
+	buf = dml.JSONAppendTime(buf, e.ColTimestamp1)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_timestamp_2":`...)
+	if e.ColTimestamp2.Valid {
+		buf = dml.JSONAppendTime(buf, e.ColTimestamp2.Time)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_tinyint_1":`...)
+	buf = strconv.AppendInt(buf, e.ColTinyint1, 10)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_varchar_1":`...)
+	buf = dml.JSONAppendString(buf, e.ColVarchar1)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_varchar_100":`...)
+	if e.ColVarchar100.Valid {
+		buf = dml.JSONAppendString(buf, e.ColVarchar100.String)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_varchar_16":`...)
+	buf = dml.JSONAppendString(buf, e.ColVarchar16)
+	buf = append(buf, ',')
+	buf = append(buf, `"col_char_1":`...)
+	if e.ColChar1.Valid {
+		buf = dml.JSONAppendString(buf, e.ColChar1.String)
+	} else {
+		buf = append(buf, "null"...)
+	}
+	buf = append(buf, ',')
+	buf = append(buf, `"col_char_2":`...)
+	buf = dml.JSONAppendString(buf, e.ColChar2)
+	buf = append(buf, ',')
+	if buf[len(buf)-1] == ',' {
+		buf = buf[:len(buf)-1]
+	}
+	return append(buf, '}'), nil
+}
+
+// MarshalJSON implements interface json.Marshaler. Auto generated.
+func (e *DmlgenTypes) MarshalJSON() ([]byte, error) {
+	return e.AppendJSON(make([]byte, 0, 42*16))
+}
+
+// UnmarshalJSON implements interface json.Unmarshaler without using
+// reflection. Unknown keys are ignored. Auto generated.
+func (e *DmlgenTypes) UnmarshalJSON(data []byte) error {
+	return dml.JSONObjectEach(data, func(key string, value []byte) (err error) {
+		switch key {
+		case "id":
+			e.ID, err = dml.JSONParseInt64(value)
+		case "col_bigint_1":
+			e.ColBigint1, err = dml.JSONParseNullInt64(value)
+		case "col_bigint_2":
+			e.ColBigint2, err = dml.JSONParseInt64(value)
+		case "col_bigint_3":
+			e.ColBigint3, err = dml.JSONParseNullInt64(value)
+		case "col_bigint_4":
+			e.ColBigint4, err = dml.JSONParseUint64(value)
+		case "col_blob":
+			e.ColBlob, err = dml.JSONParseNullString(value)
+		case "col_date_1":
+			e.ColDate1, err = dml.JSONParseNullTime(value)
+		case "col_date_2":
+			e.ColDate2, err = dml.JSONParseTime(value)
+		case "col_datetime_1":
+			e.ColDatetime1, err = dml.JSONParseNullTime(value)
+		case "col_datetime_2":
+			e.ColDatetime2, err = dml.JSONParseTime(value)
+		case "col_decimal_10_0":
+			e.ColDecimal100, err = dml.JSONParseDecimal(value)
+		case "col_decimal_12_4":
+			e.ColDecimal124, err = dml.JSONParseDecimal(value)
+		case "price_12_4a":
+			e.Price124a, err = dml.JSONParseDecimal(value)
+		case "price_12_4b":
+			e.Price124b, err = dml.JSONParseDecimal(value)
+		case "col_decimal_12_3":
+			e.ColDecimal123, err = dml.JSONParseDecimal(value)
+		case "col_decimal_20_6":
+			e.ColDecimal206, err = dml.JSONParseDecimal(value)
+		case "col_decimal_24_12":
+			e.ColDecimal2412, err = dml.JSONParseDecimal(value)
+		case "col_float":
+			e.ColFloat, err = dml.JSONParseFloat64(value)
+		case "col_int_1":
+			e.ColInt1, err = dml.JSONParseNullInt64(value)
+		case "col_int_2":
+			e.ColInt2, err = dml.JSONParseInt64(value)
+		case "col_int_3":
+			e.ColInt3, err = dml.JSONParseNullInt64(value)
+		case "col_int_4":
+			e.ColInt4, err = dml.JSONParseUint64(value)
+		case "col_longtext_1":
+			e.ColLongtext1, err = dml.JSONParseNullString(value)
+		case "col_longtext_2":
+			e.ColLongtext2, err = dml.JSONParseString(value)
+		case "col_mediumblob":
+			e.ColMediumblob, err = dml.JSONParseNullString(value)
+		case "col_mediumtext_1":
+			e.ColMediumtext1, err = dml.JSONParseNullString(value)
+		case "col_mediumtext_2":
+			e.ColMediumtext2, err = dml.JSONParseString(value)
+		case "col_smallint_1":
+			e.ColSmallint1, err = dml.JSONParseNullInt64(value)
+		case "col_smallint_2":
+			e.ColSmallint2, err = dml.JSONParseInt64(value)
+		case "col_smallint_3":
+			e.ColSmallint3, err = dml.JSONParseNullInt64(value)
+		case "col_smallint_4":
+			e.ColSmallint4, err = dml.JSONParseUint64(value)
+		case "has_smallint_5":
+			e.HasSmallint5, err = dml.JSONParseBool(value)
+		case "is_smallint_5":
+			e.IsSmallint5, err = dml.JSONParseNullBool(value)
+		case "col_text":
+			e.ColText, err = dml.JSONParseNullString(value)
+		case "col_timestamp_1":
+			e.ColTimestamp1, err = dml.JSONParseTime(value)
+		case "col_timestamp_2":
+			e.ColTimestamp2, err = dml.JSONParseNullTime(value)
+		case "col_tinyint_1":
+			e.ColTinyint1, err = dml.JSONParseInt64(value)
+		case "col_varchar_1":
+			e.ColVarchar1, err = dml.JSONParseString(value)
+		case "col_varchar_100":
+			e.ColVarchar100, err = dml.JSONParseNullString(value)
+		case "col_varchar_16":
+			e.ColVarchar16, err = dml.JSONParseString(value)
+		case "col_char_1":
+			e.ColChar1, err = dml.JSONParseNullString(value)
+		case "col_char_2":
+			e.ColChar2, err = dml.JSONParseString(value)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "[testdata] DmlgenTypes.UnmarshalJSON key %q", key)
+		}
+		return nil
+	})
+}
+
+// AppendJSON appends the JSON array of all entities to buf. Auto generated.
+func (cc *DmlgenTypesCollection) AppendJSON(buf []byte) (_ []byte, err error) {
+	buf = append(buf, '[')
+	for i, e := range cc.Data {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if buf, err = e.AppendJSON(buf); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return append(buf, ']'), nil
 }
 
-// MarshalJSON implements interface json.Marshaler.
+// MarshalJSON implements interface json.Marshaler. Auto generated.
 func (cc *DmlgenTypesCollection) MarshalJSON() ([]byte, error) {
-	return json.Marshal(cc.Data)
+	return cc.AppendJSON(make([]byte, 0, len(cc.Data)*42*16+2))
+}
+
+// UnmarshalJSON implements interface json.Unmarshaler and replaces the
+// entities of the collection with the entities of the JSON array. Auto
+// generated.
+func (cc *DmlgenTypesCollection) UnmarshalJSON(data []byte) error {
+	cc.Data = cc.Data[:0]
+	return dml.JSONArrayEach(data, func(value []byte) error {
+		e := new(DmlgenTypes)
+		if err := e.UnmarshalJSON(value); err != nil {
+			return errors.WithStack(err)
+		}
+		cc.Data = append(cc.Data, e)
+		return nil
+	})
 }
 
-// TODO add MarshalText and UnmarshalText.
 // UnmarshalBinary implements encoding.BinaryUnmarshaler.
 func (cc *DmlgenTypesCollection) UnmarshalBinary(data []byte) error {
 	return cc.Unmarshal(data) // Implemented via github.com/gogo/protobuf
@@ -1099,3 +2116,115 @@ func (cc *DmlgenTypesCollection) GobDecode(data []byte) error {
 func (cc *DmlgenTypesCollection) GobEncode() ([]byte, error) {
 	return cc.Marshal() // Implemented via github.com/gogo/protobuf
 }
+
+// Validate checks the fields of the entity against the constraints of the
+// columns in table `dmlgen_types`: maximum length, unsigned numbers, integer
+// ranges, DECIMAL precision and scale, ENUM and SET members and NOT NULL columns
+// without a default value. Returns the first NotValid error. Auto generated.
+func (e *DmlgenTypes) Validate() error {
+
+	if e.ID < -2147483648 || e.ID > 2147483647 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ID: Column `id` is out of range [-2147483648, 2147483647]")
+	}
+	if e.ColBigint3.Valid && e.ColBigint3.Int64 < 0 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColBigint3: Column `col_bigint_3` cannot be negative")
+	}
+	if e.ColBlob.Valid && len(e.ColBlob.String) > 65535 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColBlob: Column `col_blob` exceeds the maximum length of 65535 bytes")
+	}
+	if e.ColDecimal100.Valid && e.ColDecimal100.Negative {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColDecimal100: Column `col_decimal_10_0` cannot be negative")
+	}
+	if !e.ColDecimal100.FitsColumn(10, 0) {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColDecimal100: Column `col_decimal_10_0` exceeds the precision of DECIMAL(10,0)")
+	}
+	if !e.ColDecimal124.FitsColumn(12, 4) {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColDecimal124: Column `col_decimal_12_4` exceeds the precision of DECIMAL(12,4)")
+	}
+	if !e.Price124a.FitsColumn(12, 4) {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.Price124a: Column `price_12_4a` exceeds the precision of DECIMAL(12,4)")
+	}
+	if !e.Price124b.FitsColumn(12, 4) {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.Price124b: Column `price_12_4b` exceeds the precision of DECIMAL(12,4)")
+	}
+	if !e.ColDecimal123.FitsColumn(12, 3) {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColDecimal123: Column `col_decimal_12_3` exceeds the precision of DECIMAL(12,3)")
+	}
+	if !e.ColDecimal206.FitsColumn(20, 6) {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColDecimal206: Column `col_decimal_20_6` exceeds the precision of DECIMAL(20,6)")
+	}
+	if !e.ColDecimal2412.FitsColumn(24, 12) {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColDecimal2412: Column `col_decimal_24_12` exceeds the precision of DECIMAL(24,12)")
+	}
+	if e.ColInt1.Valid && (e.ColInt1.Int64 < -2147483648 || e.ColInt1.Int64 > 2147483647) {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColInt1: Column `col_int_1` is out of range [-2147483648, 2147483647]")
+	}
+	if e.ColInt2 < -2147483648 || e.ColInt2 > 2147483647 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColInt2: Column `col_int_2` is out of range [-2147483648, 2147483647]")
+	}
+	if e.ColInt3.Valid && e.ColInt3.Int64 < 0 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColInt3: Column `col_int_3` cannot be negative")
+	}
+	if e.ColInt3.Valid && e.ColInt3.Int64 > 4294967295 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColInt3: Column `col_int_3` exceeds the maximum value of 4294967295")
+	}
+	if e.ColInt4 > 4294967295 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColInt4: Column `col_int_4` exceeds the maximum value of 4294967295")
+	}
+	if e.ColMediumblob.Valid && len(e.ColMediumblob.String) > 16777215 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColMediumblob: Column `col_mediumblob` exceeds the maximum length of 16777215 bytes")
+	}
+	if e.ColMediumtext1.Valid && len(e.ColMediumtext1.String) > 16777215 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColMediumtext1: Column `col_mediumtext_1` exceeds the maximum length of 16777215 bytes")
+	}
+	if len(e.ColMediumtext2) > 16777215 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColMediumtext2: Column `col_mediumtext_2` exceeds the maximum length of 16777215 bytes")
+	}
+	if e.ColSmallint1.Valid && (e.ColSmallint1.Int64 < -32768 || e.ColSmallint1.Int64 > 32767) {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColSmallint1: Column `col_smallint_1` is out of range [-32768, 32767]")
+	}
+	if e.ColSmallint2 < -32768 || e.ColSmallint2 > 32767 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColSmallint2: Column `col_smallint_2` is out of range [-32768, 32767]")
+	}
+	if e.ColSmallint3.Valid && e.ColSmallint3.Int64 < 0 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColSmallint3: Column `col_smallint_3` cannot be negative")
+	}
+	if e.ColSmallint3.Valid && e.ColSmallint3.Int64 > 65535 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColSmallint3: Column `col_smallint_3` exceeds the maximum value of 65535")
+	}
+	if e.ColSmallint4 > 65535 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColSmallint4: Column `col_smallint_4` exceeds the maximum value of 65535")
+	}
+	if e.ColText.Valid && len(e.ColText.String) > 65535 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColText: Column `col_text` exceeds the maximum length of 65535 bytes")
+	}
+	if e.ColTinyint1 < -128 || e.ColTinyint1 > 127 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColTinyint1: Column `col_tinyint_1` is out of range [-128, 127]")
+	}
+	if utf8.RuneCountInString(e.ColVarchar1) > 1 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColVarchar1: Column `col_varchar_1` exceeds the maximum length of 1 characters")
+	}
+	if e.ColVarchar100.Valid && utf8.RuneCountInString(e.ColVarchar100.String) > 100 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColVarchar100: Column `col_varchar_100` exceeds the maximum length of 100 characters")
+	}
+	if utf8.RuneCountInString(e.ColVarchar16) > 16 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColVarchar16: Column `col_varchar_16` exceeds the maximum length of 16 characters")
+	}
+	if e.ColChar1.Valid && utf8.RuneCountInString(e.ColChar1.String) > 21 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColChar1: Column `col_char_1` exceeds the maximum length of 21 characters")
+	}
+	if utf8.RuneCountInString(e.ColChar2) > 17 {
+		return errors.NotValid.Newf("[testdata] DmlgenTypes.ColChar2: Column `col_char_2` exceeds the maximum length of 17 characters")
+	}
+	return nil
+}
+
+// Validate checks each entity of the collection. Auto generated.
+func (cc DmlgenTypesCollection) Validate() error {
+	for _, e := range cc.Data {
+		if err := e.Validate(); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmlgen
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/util/strs"
+)
+
+// parseEnumValues extracts the allowed members of an ENUM or SET column type.
+// For example: enum('a','b') returns []string{"a","b"}. Escaped quotes get
+// unescaped. Returns nil if the column type is neither an ENUM nor a SET.
+func parseEnumValues(columnType string) []string {
+	ct := strings.ToLower(columnType)
+	var start int
+	switch {
+	case strings.HasPrefix(ct, "enum("):
+		start = len("enum(")
+	case strings.HasPrefix(ct, "set("):
+		start = len("set(")
+	default:
+		return nil
+	}
+	end := strings.LastIndexByte(columnType, ')')
+	if end < start {
+		return nil
+	}
+
+	var ret []string
+	var buf strings.Builder
+	inQuote := false
+	body := columnType[start:end]
+	for i := 0; i < len(body); i++ {
+		switch b := body[i]; {
+		case b == '\'' && inQuote && i+1 < len(body) && body[i+1] == '\'':
+			buf.WriteByte('\'') // escaped quote
+			i++
+		case b == '\\' && inQuote && i+1 < len(body):
+			buf.WriteByte(body[i+1])
+			i++
+		case b == '\'':
+			if inQuote {
+				ret = append(ret, buf.String())
+				buf.Reset()
+			}
+			inQuote = !inQuote
+		case inQuote:
+			buf.WriteByte(b)
+		}
+	}
+	return ret
+}
+
+// integerRanges contains the minimum and maximum value of the signed and the
+// maximum value of the unsigned integer types. BIGINT gets checked by the Go
+// type.
+var integerRanges = map[string]struct {
+	min, max int64
+	maxU     uint64
+}{
+	"tinyint":   {math.MinInt8, math.MaxInt8, math.MaxUint8},
+	"smallint":  {math.MinInt16, math.MaxInt16, math.MaxUint16},
+	"mediumint": {-1 << 23, 1<<23 - 1, 1<<24 - 1},
+	"int":       {math.MinInt32, math.MaxInt32, math.MaxUint32},
+}
+
+// parseDecimalType extracts the precision and the scale of a DECIMAL column
+// type. For example: decimal(12,4) unsigned returns 12 and 4. A missing scale
+// defaults to zero. Returns false if the column type is not a DECIMAL with a
+// precision.
+func parseDecimalType(columnType string) (precision, scale int64, ok bool) {
+	ct := strings.ToLower(columnType)
+	if !strings.HasPrefix(ct, "decimal(") {
+		return 0, 0, false
+	}
+	end := strings.IndexByte(ct, ')')
+	if end < 0 {
+		return 0, 0, false
+	}
+	args := strings.Split(ct[len("decimal("):end], ",")
+	if len(args) > 2 {
+		return 0, 0, false
+	}
+	precision, err := strconv.ParseInt(strings.TrimSpace(args[0]), 10, 32)
+	if err != nil {
+		return 0, 0, false
+	}
+	if len(args) == 2 {
+		if scale, err = strconv.ParseInt(strings.TrimSpace(args[1]), 10, 32); err != nil {
+			return 0, 0, false
+		}
+	}
+	return precision, scale, true
+}
+
+// toGoValidation generates the Go source code which checks the field of a
+// column against the constraints of the column. errPrefix gets prepended to
+// each error message. Returns an empty string if the column has no
+// constraints which can be checked.
+func toGoValidation(errPrefix string, c *ddl.Column) string {
+	var buf strings.Builder
+	goType := mySQLToGoType(c, true)
+	field := "e." + strs.ToGoCamelCase(c.Field)
+	errMsg := func(cond, format string, args ...interface{}) {
+		fmt.Fprintf(&buf, "\nif %s {\nreturn errors.NotValid.Newf(%q)\n}", cond,
+			fmt.Sprintf("%s.%s: Column `%s` ", errPrefix, strs.ToGoCamelCase(c.Field), c.Field)+fmt.Sprintf(format, args...))
+	}
+
+	// NOT NULL columns without a default value must be set. Numbers and bools
+	// are excluded because their zero value is a valid value.
+	if !c.IsNull() && !c.Default.Valid && !c.IsAutoIncrement() {
+		switch goType {
+		case "string":
+			errMsg(field+` == ""`, "cannot be empty")
+		case "[]byte":
+			errMsg(field+` == nil`, "cannot be nil")
+		case "time.Time":
+			errMsg(field+`.IsZero()`, "cannot be zero")
+		}
+	}
+
+	if vals := parseEnumValues(c.ColumnType); vals != nil {
+		quoted := make([]string, len(vals))
+		for i, v := range vals {
+			quoted[i] = fmt.Sprintf("%q", v)
+		}
+		value, isNull := field, goType == "dml.NullString"
+		if isNull {
+			value = field + ".String"
+		}
+		if strings.HasPrefix(strings.ToLower(c.ColumnType), "set(") {
+			cond := value + ` != ""`
+			if isNull {
+				cond = field + ".Valid && " + cond
+			}
+			fmt.Fprintf(&buf, "\nif %s {\nfor _, v := range strings.Split(%s, \",\") {\nswitch v {\ncase %s:\ndefault:\nreturn errors.NotValid.Newf(%q, v)\n}\n}\n}",
+				cond, value, strings.Join(quoted, ", "),
+				fmt.Sprintf("%s.%s: Column `%s` contains the invalid set member %%q. Allowed: %s", errPrefix, strs.ToGoCamelCase(c.Field), c.Field, strings.Join(vals, ",")))
+			return buf.String()
+		}
+		// An empty string is only a valid ENUM value if it is a member.
+		sw := fmt.Sprintf("switch %s {\ncase %s:\ndefault:\nreturn errors.NotValid.Newf(%q, %s)\n}",
+			value, strings.Join(quoted, ", "),
+			fmt.Sprintf("%s.%s: Column `%s` contains the invalid enum value %%q. Allowed: %s", errPrefix, strs.ToGoCamelCase(c.Field), c.Field, strings.Join(vals, ",")),
+			value)
+		if isNull {
+			sw = "if " + field + ".Valid {\n" + sw + "\n}"
+		}
+		buf.WriteString("\n" + sw)
+		return buf.String()
+	}
+
+	// The length of LONGTEXT or LONGBLOB cannot be exceeded and the constant
+	// would overflow an int on 32-bit platforms.
+	if maxLen := c.CharMaxLength.Int64; c.CharMaxLength.Valid && maxLen > 0 && maxLen < math.MaxInt32 {
+		switch dt := c.DataType; {
+		case goType == "string" && (dt == "varchar" || dt == "char"):
+			errMsg(fmt.Sprintf("utf8.RuneCountInString(%s) > %d", field, maxLen), "exceeds the maximum length of %d characters", maxLen)
+		case goType == "dml.NullString" && (dt == "varchar" || dt == "char"):
+			errMsg(fmt.Sprintf("%s.Valid && utf8.RuneCountInString(%s.String) > %d", field, field, maxLen), "exceeds the maximum length of %d characters", maxLen)
+		case goType == "string":
+			errMsg(fmt.Sprintf("len(%s) > %d", field, maxLen), "exceeds the maximum length of %d bytes", maxLen)
+		case goType == "dml.NullString":
+			errMsg(fmt.Sprintf("%s.Valid && len(%s.String) > %d", field, field, maxLen), "exceeds the maximum length of %d bytes", maxLen)
+		case goType == "[]byte":
+			errMsg(fmt.Sprintf("len(%s) > %d", field, maxLen), "exceeds the maximum length of %d bytes", maxLen)
+		}
+	}
+
+	// Unsigned not null integers are already mapped to an uint64.
+	if c.IsUnsigned() {
+		switch goType {
+		case "dml.NullInt64":
+			errMsg(fmt.Sprintf("%s.Valid && %s.Int64 < 0", field, field), "cannot be negative")
+		case "float64":
+			errMsg(fmt.Sprintf("%s < 0", field), "cannot be negative")
+		case "dml.NullFloat64":
+			errMsg(fmt.Sprintf("%s.Valid && %s.Float64 < 0", field, field), "cannot be negative")
+		case "dml.Decimal":
+			errMsg(fmt.Sprintf("%s.Valid && %s.Negative", field, field), "cannot be negative")
+		}
+	}
+
+	if r, ok := integerRanges[c.DataType]; ok {
+		switch {
+		case goType == "uint64":
+			errMsg(fmt.Sprintf("%s > %d", field, r.maxU), "exceeds the maximum value of %d", r.maxU)
+		case goType == "dml.NullInt64" && c.IsUnsigned():
+			errMsg(fmt.Sprintf("%s.Valid && %s.Int64 > %d", field, field, r.maxU), "exceeds the maximum value of %d", r.maxU)
+		case goType == "int64":
+			errMsg(fmt.Sprintf("%s < %d || %s > %d", field, r.min, field, r.max), "is out of range [%d, %d]", r.min, r.max)
+		case goType == "dml.NullInt64":
+			errMsg(fmt.Sprintf("%s.Valid && (%s.Int64 < %d || %s.Int64 > %d)", field, field, r.min, field, r.max), "is out of range [%d, %d]", r.min, r.max)
+		}
+	}
+
+	if precision, scale, ok := parseDecimalType(c.ColumnType); ok && goType == "dml.Decimal" {
+		errMsg(fmt.Sprintf("!%s.FitsColumn(%d, %d)", field, precision, scale), "exceeds the precision of DECIMAL(%d,%d)", precision, scale)
+	}
+	return buf.String()
+}
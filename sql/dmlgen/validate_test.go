@@ -0,0 +1,113 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmlgen
+
+import (
+	"testing"
+
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEnumValues(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		columnType string
+		want       []string
+	}{
+		{"enum('a','b')", []string{"a", "b"}},
+		{"ENUM('cash on delivery','it''s','')", []string{"cash on delivery", "it's", ""}},
+		{"set('a,b','c\\'d')", []string{"a,b", "c'd"}},
+		{"varchar(255)", nil},
+		{"enum(", nil},
+	}
+	for _, test := range tests {
+		assert.Exactly(t, test.want, parseEnumValues(test.columnType), "%q", test.columnType)
+	}
+}
+
+func TestParseDecimalType(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		columnType       string
+		precision, scale int64
+		ok               bool
+	}{
+		{"decimal(12,4)", 12, 4, true},
+		{"DECIMAL(10, 0) unsigned", 10, 0, true},
+		{"decimal(8)", 8, 0, true},
+		{"decimal", 0, 0, false},
+		{"decimal(a,4)", 0, 0, false},
+		{"double", 0, 0, false},
+	}
+	for _, test := range tests {
+		p, s, ok := parseDecimalType(test.columnType)
+		assert.Exactly(t, test.ok, ok, "%q", test.columnType)
+		assert.Exactly(t, test.precision, p, "%q", test.columnType)
+		assert.Exactly(t, test.scale, s, "%q", test.columnType)
+	}
+}
+
+func TestToGoValidation(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		c    ddl.Column
+		want string
+	}{
+		{ddl.Column{Field: `entity_id`, DataType: `int`, ColumnType: `int(10) unsigned`, Extra: "auto_increment"},
+			"\nif e.EntityID > 4294967295 {\nreturn errors.NotValid.Newf(\"[p] E.EntityID: Column `entity_id` exceeds the maximum value of 4294967295\")\n}"},
+		{ddl.Column{Field: `qty`, DataType: `int`, Null: "YES", ColumnType: `int(10) unsigned`},
+			"\nif e.Qty.Valid && e.Qty.Int64 < 0 {\nreturn errors.NotValid.Newf(\"[p] E.Qty: Column `qty` cannot be negative\")\n}" +
+				"\nif e.Qty.Valid && e.Qty.Int64 > 4294967295 {\nreturn errors.NotValid.Newf(\"[p] E.Qty: Column `qty` exceeds the maximum value of 4294967295\")\n}"},
+		{ddl.Column{Field: `level`, DataType: `tinyint`, Null: "NO", ColumnType: `tinyint(4)`},
+			"\nif e.Level < -128 || e.Level > 127 {\nreturn errors.NotValid.Newf(\"[p] E.Level: Column `level` is out of range [-128, 127]\")\n}"},
+		{ddl.Column{Field: `sort_order`, DataType: `smallint`, Null: "YES", ColumnType: `smallint(6)`},
+			"\nif e.SortOrder.Valid && (e.SortOrder.Int64 < -32768 || e.SortOrder.Int64 > 32767) {\nreturn errors.NotValid.Newf(\"[p] E.SortOrder: Column `sort_order` is out of range [-32768, 32767]\")\n}"},
+		{ddl.Column{Field: `store_id`, DataType: `smallint`, Null: "NO", ColumnType: `smallint(5) unsigned`},
+			"\nif e.StoreID > 65535 {\nreturn errors.NotValid.Newf(\"[p] E.StoreID: Column `store_id` exceeds the maximum value of 65535\")\n}"},
+		{ddl.Column{Field: `views`, DataType: `mediumint`, Null: "NO", ColumnType: `mediumint(9)`},
+			"\nif e.Views < -8388608 || e.Views > 8388607 {\nreturn errors.NotValid.Newf(\"[p] E.Views: Column `views` is out of range [-8388608, 8388607]\")\n}"},
+		{ddl.Column{Field: `customer_id`, DataType: `int`, Null: "NO", ColumnType: `int(11)`},
+			"\nif e.CustomerID < -2147483648 || e.CustomerID > 2147483647 {\nreturn errors.NotValid.Newf(\"[p] E.CustomerID: Column `customer_id` is out of range [-2147483648, 2147483647]\")\n}"},
+		{ddl.Column{Field: `is_active`, DataType: `tinyint`, Null: "NO", ColumnType: `tinyint(1)`}, ""},
+		{ddl.Column{Field: `qty_big`, DataType: `bigint`, Null: "NO", ColumnType: `bigint(20)`}, ""},
+		{ddl.Column{Field: `price`, DataType: `decimal`, Null: "YES", ColumnType: `decimal(12,4)`},
+			"\nif !e.Price.FitsColumn(12, 4) {\nreturn errors.NotValid.Newf(\"[p] E.Price: Column `price` exceeds the precision of DECIMAL(12,4)\")\n}"},
+		{ddl.Column{Field: `total`, DataType: `decimal`, Null: "YES", ColumnType: `decimal(10,0) unsigned`},
+			"\nif e.Total.Valid && e.Total.Negative {\nreturn errors.NotValid.Newf(\"[p] E.Total: Column `total` cannot be negative\")\n}" +
+				"\nif !e.Total.FitsColumn(10, 0) {\nreturn errors.NotValid.Newf(\"[p] E.Total: Column `total` exceeds the precision of DECIMAL(10,0)\")\n}"},
+		{ddl.Column{Field: `weight`, DataType: `double`, Null: "NO", ColumnType: `double unsigned`, Default: dml.MakeNullString(`0`)},
+			"\nif e.Weight < 0 {\nreturn errors.NotValid.Newf(\"[p] E.Weight: Column `weight` cannot be negative\")\n}"},
+		{ddl.Column{Field: `sku`, DataType: `varchar`, Null: "NO", CharMaxLength: dml.MakeNullInt64(64), ColumnType: `varchar(64)`},
+			"\nif e.Sku == \"\" {\nreturn errors.NotValid.Newf(\"[p] E.Sku: Column `sku` cannot be empty\")\n}" +
+				"\nif utf8.RuneCountInString(e.Sku) > 64 {\nreturn errors.NotValid.Newf(\"[p] E.Sku: Column `sku` exceeds the maximum length of 64 characters\")\n}"},
+		{ddl.Column{Field: `note`, DataType: `text`, Null: "YES", CharMaxLength: dml.MakeNullInt64(65535), ColumnType: `text`},
+			"\nif e.Note.Valid && len(e.Note.String) > 65535 {\nreturn errors.NotValid.Newf(\"[p] E.Note: Column `note` exceeds the maximum length of 65535 bytes\")\n}"},
+		{ddl.Column{Field: `body`, DataType: `longtext`, Null: "YES", CharMaxLength: dml.MakeNullInt64(4294967295), ColumnType: `longtext`}, ""},
+		{ddl.Column{Field: `created_at`, DataType: `datetime`, Null: "NO", ColumnType: `datetime`},
+			"\nif e.CreatedAt.IsZero() {\nreturn errors.NotValid.Newf(\"[p] E.CreatedAt: Column `created_at` cannot be zero\")\n}"},
+		{ddl.Column{Field: `state`, DataType: `enum`, Null: "NO", Default: dml.MakeNullString(`'new'`), ColumnType: `enum('new','done')`},
+			"\nswitch e.State {\ncase \"new\", \"done\":\ndefault:\nreturn errors.NotValid.Newf(\"[p] E.State: Column `state` contains the invalid enum value %q. Allowed: new,done\", e.State)\n}"},
+		{ddl.Column{Field: `state`, DataType: `enum`, Null: "YES", ColumnType: `enum('new','done')`},
+			"\nif e.State.Valid {\nswitch e.State.String {\ncase \"new\", \"done\":\ndefault:\nreturn errors.NotValid.Newf(\"[p] E.State: Column `state` contains the invalid enum value %q. Allowed: new,done\", e.State.String)\n}\n}"},
+		{ddl.Column{Field: `flags`, DataType: `set`, Null: "NO", Default: dml.MakeNullString(`''`), ColumnType: `set('a','b')`},
+			"\nif e.Flags != \"\" {\nfor _, v := range strings.Split(e.Flags, \",\") {\nswitch v {\ncase \"a\", \"b\":\ndefault:\nreturn errors.NotValid.Newf(\"[p] E.Flags: Column `flags` contains the invalid set member %q. Allowed: a,b\", v)\n}\n}\n}"},
+	}
+	for _, test := range tests {
+		c := test.c
+		assert.Exactly(t, test.want, toGoValidation("[p] E", &c), "%s", c.Field)
+	}
+}
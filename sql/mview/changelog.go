@@ -0,0 +1,216 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mview
+
+import (
+	"context"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/util/conv"
+)
+
+// Changelog column names. The layout equals the Magento 2 changelog tables.
+const (
+	ChangelogColumnVersionID = "version_id"
+	ChangelogColumnEntityID  = "entity_id"
+)
+
+// triggerEvents lists all events which gets a trigger per source table.
+var triggerEvents = [...]string{"insert", "update", "delete"}
+
+// ChangelogDDL returns the CREATE TABLE statement of the changelog table.
+func (v *View) ChangelogDDL() string {
+	var buf strings.Builder
+	buf.WriteString("CREATE TABLE IF NOT EXISTS ")
+	buf.WriteString(dml.Quoter.Name(v.ChangelogTable()))
+	buf.WriteString(" (\n\t")
+	buf.WriteString(dml.Quoter.Name(ChangelogColumnVersionID))
+	buf.WriteString(" BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT 'Version ID',\n\t")
+	buf.WriteString(dml.Quoter.Name(ChangelogColumnEntityID))
+	buf.WriteString(" BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'Entity ID',\n\tPRIMARY KEY (")
+	buf.WriteString(dml.Quoter.Name(ChangelogColumnVersionID))
+	buf.WriteString(")\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='")
+	buf.WriteString(v.ID)
+	buf.WriteString(" changelog'")
+	return buf.String()
+}
+
+// TriggerNames returns the names of all triggers for a source table.
+func (v *View) TriggerNames(sourceTable string) []string {
+	ret := make([]string, 0, len(triggerEvents))
+	for _, ev := range triggerEvents {
+		ret = append(ret, ddl.TriggerName(sourceTable+"_"+v.ID, "after", ev))
+	}
+	return ret
+}
+
+// TriggersDDL returns the CREATE TRIGGER statements for all sources. Each
+// trigger writes the entity ID of the modified row into the changelog table.
+// The DELETE trigger uses the OLD row, the INSERT and UPDATE triggers the NEW
+// row. An UPDATE which changes the entity column writes both IDs.
+func (v *View) TriggersDDL() []string {
+	ret := make([]string, 0, len(v.Sources)*len(triggerEvents))
+	cl := dml.Quoter.Name(v.ChangelogTable())
+	ec := dml.Quoter.Name(ChangelogColumnEntityID)
+	for _, s := range v.Sources {
+		names := v.TriggerNames(s.Table)
+		col := dml.Quoter.Name(s.Column)
+		for i, ev := range triggerEvents {
+			var buf strings.Builder
+			buf.WriteString("CREATE TRIGGER ")
+			buf.WriteString(dml.Quoter.Name(names[i]))
+			buf.WriteString(" AFTER ")
+			buf.WriteString(strings.ToUpper(ev))
+			buf.WriteString(" ON ")
+			buf.WriteString(dml.Quoter.Name(s.Table))
+			buf.WriteString(" FOR EACH ROW BEGIN ")
+			switch ev {
+			case "insert":
+				buf.WriteString("INSERT INTO " + cl + " (" + ec + ") VALUES (NEW." + col + ");")
+			case "update":
+				buf.WriteString("INSERT INTO " + cl + " (" + ec + ") VALUES (NEW." + col + ");")
+				buf.WriteString(" IF (NEW." + col + " <> OLD." + col + ") THEN INSERT INTO " + cl + " (" + ec + ") VALUES (OLD." + col + "); END IF;")
+			case "delete":
+				buf.WriteString("INSERT INTO " + cl + " (" + ec + ") VALUES (OLD." + col + ");")
+			}
+			buf.WriteString(" END")
+			ret = append(ret, buf.String())
+		}
+	}
+	return ret
+}
+
+// CreateChangelog creates the changelog table of the view, if it does not yet
+// exists.
+func (v *View) CreateChangelog(ctx context.Context, db dml.Execer) error {
+	if _, err := db.ExecContext(ctx, v.ChangelogDDL()); err != nil {
+		return errors.Wrapf(err, "[mview] Failed to create changelog table for view %q", v.ID)
+	}
+	return nil
+}
+
+// CreateTriggers drops and recreates all triggers on the source tables. Use
+// triggers only if the changes do not get captured via the binary log and
+// the ChangelogHandler.
+func (v *View) CreateTriggers(ctx context.Context, db dml.Execer) error {
+	if err := v.DropTriggers(ctx, db); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, sqlStr := range v.TriggersDDL() {
+		if _, err := db.ExecContext(ctx, sqlStr); err != nil {
+			return errors.Wrapf(err, "[mview] Failed to create trigger for view %q: %q", v.ID, sqlStr)
+		}
+	}
+	return nil
+}
+
+// DropTriggers removes all triggers of the view from the source tables.
+func (v *View) DropTriggers(ctx context.Context, db dml.Execer) error {
+	for _, s := range v.Sources {
+		for _, tn := range v.TriggerNames(s.Table) {
+			if _, err := db.ExecContext(ctx, "DROP TRIGGER IF EXISTS "+dml.Quoter.Name(tn)); err != nil {
+				return errors.Wrapf(err, "[mview] Failed to drop trigger %q of view %q", tn, v.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// WriteChangelog writes the entity IDs into the changelog table of the view.
+func (v *View) WriteChangelog(ctx context.Context, db dml.Execer, entityIDs ...uint64) error {
+	if len(entityIDs) == 0 {
+		return nil
+	}
+	var buf strings.Builder
+	buf.WriteString("INSERT INTO ")
+	buf.WriteString(dml.Quoter.Name(v.ChangelogTable()))
+	buf.WriteString(" (")
+	buf.WriteString(dml.Quoter.Name(ChangelogColumnEntityID))
+	buf.WriteString(") VALUES ")
+	args := make([]interface{}, 0, len(entityIDs))
+	for i, id := range entityIDs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString("(?)")
+		args = append(args, id)
+	}
+	if _, err := db.ExecContext(ctx, buf.String(), args...); err != nil {
+		return errors.Wrapf(err, "[mview] Failed to write changelog of view %q", v.ID)
+	}
+	return nil
+}
+
+// ChangelogHandler writes the entity IDs of all row events of the source
+// tables into the changelog tables of the views. It implements interface
+// binlogsync.RowsEventHandler and gets used instead of triggers.
+type ChangelogHandler struct {
+	DB    dml.Execer
+	Views []*View
+}
+
+// Do extracts the entity IDs from the rows of a source table.
+func (h ChangelogHandler) Do(ctx context.Context, action string, t ddl.Table, rows [][]interface{}) error {
+	for _, v := range h.Views {
+		for _, s := range v.Sources {
+			if s.Table != t.Name {
+				continue
+			}
+			idx := -1
+			for i, c := range t.Columns {
+				if c.Field == s.Column {
+					idx = i
+					break
+				}
+			}
+			if idx < 0 {
+				return errors.NotFound.Newf("[mview] Column %q not found in table %q for view %q", s.Column, t.Name, v.ID)
+			}
+			ids := make([]uint64, 0, len(rows))
+			for _, row := range rows {
+				if idx < len(row) && row[idx] != nil {
+					ids = append(ids, uint64(conv.ToUint(row[idx])))
+				}
+			}
+			if err := v.WriteChangelog(ctx, h.DB, uniqueUint64s(ids)...); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+	return nil
+}
+
+// Complete does nothing.
+func (h ChangelogHandler) Complete(_ context.Context) error { return nil }
+
+// String returns the name of the handler.
+func (h ChangelogHandler) String() string { return "mview.ChangelogHandler" }
+
+func uniqueUint64s(ids []uint64) []uint64 {
+	seen := make(map[uint64]struct{}, len(ids))
+	j := 0
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids[j] = id
+		j++
+	}
+	return ids[:j]
+}
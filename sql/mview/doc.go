@@ -14,6 +14,14 @@
 
 // Package mview adds materialized views via events on the MySQL binary log.
 //
+// A View gets defined by a dml.Select statement, its source tables and a
+// target table. Changes to the source tables get recorded in a changelog table
+// per view, either via triggers or via the ChangelogHandler registered with
+// binlogsync.Canal. The Service refreshes a view incrementally from its
+// changelog or fully by swapping the target table. The version and the status
+// of each view get stored in the table `mview_state`, like in Magento 2.
+// Refreshes can run on demand or scheduled. A view can be suspended, for
+// example during a bulk import, and resumed afterwards.
 //
 // https://de.slideshare.net/MySQLGeek/flexviews-materialized-views-for-my-sql
 // https://github.com/greenlion/swanhart-tools
@@ -0,0 +1,317 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mview_test
+
+import (
+	"context"
+	"testing"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/corestoreio/pkg/sql/mview"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ binlogsync.RowsEventHandler = (*mview.ChangelogHandler)(nil)
+var _ dml.ColumnMapper = (*mview.State)(nil)
+
+func newStockView() *mview.View {
+	return &mview.View{
+		ID: "cataloginventory_stock",
+		Select: dml.NewSelect("csi.product_id").AddColumnsConditions(dml.Expr("SUM(csi.qty)").Alias("qty")).
+			FromAlias("cataloginventory_stock_item", "csi").GroupBy("csi.product_id"),
+		Sources: []mview.Source{
+			{Table: "cataloginventory_stock_item", Column: "product_id"},
+		},
+		Target:       "cataloginventory_stock_idx",
+		EntityColumn: "csi.product_id",
+	}
+}
+
+func TestView_Validate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("valid", func(t *testing.T) {
+		assert.NoError(t, newStockView().Validate())
+	})
+	t.Run("missing select", func(t *testing.T) {
+		v := newStockView()
+		v.Select = nil
+		err := v.Validate()
+		assert.True(t, errors.Empty.Match(err), "%+v", err)
+	})
+	t.Run("invalid source", func(t *testing.T) {
+		v := newStockView()
+		v.Sources[0].Table = "cataloginventory stock"
+		err := v.Validate()
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+}
+
+func TestView_ChangelogDDL(t *testing.T) {
+	t.Parallel()
+	v := newStockView()
+	assert.Exactly(t, "cataloginventory_stock_cl", v.ChangelogTable())
+	assert.Exactly(t, "CREATE TABLE IF NOT EXISTS `cataloginventory_stock_cl` (\n\t`version_id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT 'Version ID',\n\t`entity_id` BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'Entity ID',\n\tPRIMARY KEY (`version_id`)\n) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='cataloginventory_stock changelog'",
+		v.ChangelogDDL())
+}
+
+func TestView_TriggersDDL(t *testing.T) {
+	t.Parallel()
+	v := newStockView()
+	trgs := v.TriggersDDL()
+	require.Len(t, trgs, 3)
+	names := v.TriggerNames("cataloginventory_stock_item")
+	assert.Exactly(t, "CREATE TRIGGER `"+names[0]+"` AFTER INSERT ON `cataloginventory_stock_item` FOR EACH ROW BEGIN INSERT INTO `cataloginventory_stock_cl` (`entity_id`) VALUES (NEW.`product_id`); END",
+		trgs[0])
+	assert.Contains(t, trgs[1], "IF (NEW.`product_id` <> OLD.`product_id`) THEN")
+	assert.Contains(t, trgs[2], "VALUES (OLD.`product_id`); END")
+}
+
+func TestNewService_DuplicateView(t *testing.T) {
+	t.Parallel()
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	srv, err := mview.NewService(dbc, mview.WithViews(newStockView(), newStockView()))
+	assert.Nil(t, srv)
+	assert.True(t, errors.AlreadyExists.Match(err), "%+v", err)
+}
+
+func TestService_Refresh_NotFound(t *testing.T) {
+	t.Parallel()
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	srv, err := mview.NewService(dbc)
+	require.NoError(t, err)
+	err = srv.Refresh(context.TODO(), "xyz")
+	assert.True(t, errors.NotFound.Match(err), "%+v", err)
+}
+
+func TestService_Refresh_NothingToDo(t *testing.T) {
+	t.Parallel()
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	srv, err := mview.NewService(dbc, mview.WithViews(newStockView()))
+	require.NoError(t, err)
+
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT `view_id`, `mode`, `status`, `updated`, `version_id` FROM `mview_state` WHERE (`view_id` = 'cataloginventory_stock')")).
+		WillReturnRows(sqlmock.NewRows([]string{"view_id", "mode", "status", "updated", "version_id"}).
+			AddRow("cataloginventory_stock", "enabled", "idle", nil, 33))
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT MAX(`version_id`) FROM `cataloginventory_stock_cl`")).
+		WillReturnRows(sqlmock.NewRows([]string{"m"}).AddRow(33))
+
+	assert.NoError(t, srv.Refresh(context.TODO(), "cataloginventory_stock"))
+}
+
+const (
+	selStateSQL = "SELECT `view_id`, `mode`, `status`, `updated`, `version_id` FROM `mview_state` WHERE (`view_id` = 'cataloginventory_stock')"
+	selMaxSQL   = "SELECT MAX(`version_id`) FROM `cataloginventory_stock_cl`"
+)
+
+var stateColumns = []string{"view_id", "mode", "status", "updated", "version_id"}
+
+func expectRefreshStart(dbMock sqlmock.Sqlmock, versionID, maxVersion uint64, ids ...uint64) {
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selStateSQL)).
+		WillReturnRows(sqlmock.NewRows(stateColumns).AddRow("cataloginventory_stock", "enabled", "idle", nil, versionID))
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selMaxSQL)).
+		WillReturnRows(sqlmock.NewRows([]string{"m"}).AddRow(maxVersion))
+	rows := sqlmock.NewRows([]string{"entity_id"})
+	for _, id := range ids {
+		rows.AddRow(id)
+	}
+	dbMock.ExpectQuery("SELECT DISTINCT `entity_id` FROM `cataloginventory_stock_cl`").WillReturnRows(rows)
+	dbMock.ExpectExec("INSERT INTO `mview_state`").
+		WithArgs("cataloginventory_stock", "enabled", "working", sqlmock.AnyArg(), versionID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func expectRefreshBatch(dbMock sqlmock.Sqlmock, idList string) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("DELETE FROM `cataloginventory_stock_idx` WHERE (`product_id` IN (" + idList + "))")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `cataloginventory_stock_idx` SELECT `csi`.`product_id`, SUM(csi.qty) AS `qty` FROM `cataloginventory_stock_item` AS `csi` WHERE (`csi`.`product_id` IN (" + idList + ")) GROUP BY `csi`.`product_id`")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+}
+
+func TestService_Refresh_Batched(t *testing.T) {
+	t.Parallel()
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	v := newStockView()
+	v.BatchSize = 2
+	srv, err := mview.NewService(dbc, mview.WithViews(v))
+	require.NoError(t, err)
+
+	expectRefreshStart(dbMock, 33, 36, 3, 5, 7)
+	expectRefreshBatch(dbMock, "3,5")
+	expectRefreshBatch(dbMock, "7")
+	dbMock.ExpectExec("INSERT INTO `mview_state`").
+		WithArgs("cataloginventory_stock", "enabled", "idle", sqlmock.AnyArg(), 36).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("DELETE FROM `cataloginventory_stock_cl` WHERE (`version_id` < 36)")).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	assert.NoError(t, srv.Refresh(context.TODO(), "cataloginventory_stock"))
+}
+
+func TestService_Refresh_Rollback(t *testing.T) {
+	t.Parallel()
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	v := newStockView()
+	v.BatchSize = 2
+	srv, err := mview.NewService(dbc, mview.WithViews(v))
+	require.NoError(t, err)
+
+	expectRefreshStart(dbMock, 33, 36, 3, 5, 7)
+	expectRefreshBatch(dbMock, "3,5")
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("DELETE FROM `cataloginventory_stock_idx` WHERE (`product_id` IN (7))")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec("INSERT INTO `cataloginventory_stock_idx`").
+		WillReturnError(errors.AlreadyClosed.Newf("DB closed"))
+	dbMock.ExpectRollback()
+	// the version stays, so the next Refresh applies all entities again
+	dbMock.ExpectExec("INSERT INTO `mview_state`").
+		WithArgs("cataloginventory_stock", "enabled", "idle", sqlmock.AnyArg(), 33).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = srv.Refresh(context.TODO(), "cataloginventory_stock")
+	assert.True(t, errors.AlreadyClosed.Match(err), "%+v", err)
+}
+
+func TestService_Refresh_ChangelogReset(t *testing.T) {
+	t.Parallel()
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	srv, err := mview.NewService(dbc, mview.WithViews(newStockView()))
+	require.NoError(t, err)
+
+	// MAX(version_id) below the stored version: the changelog has been
+	// truncated and all rows are new.
+	expectRefreshStart(dbMock, 33, 2, 9)
+	expectRefreshBatch(dbMock, "9")
+	dbMock.ExpectExec("INSERT INTO `mview_state`").
+		WithArgs("cataloginventory_stock", "enabled", "idle", sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("DELETE FROM `cataloginventory_stock_cl` WHERE (`version_id` < 2)")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	assert.NoError(t, srv.Refresh(context.TODO(), "cataloginventory_stock"))
+}
+
+func TestService_Suspend(t *testing.T) {
+	t.Parallel()
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	srv, err := mview.NewService(dbc, mview.WithViews(newStockView()))
+	require.NoError(t, err)
+
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selStateSQL)).
+		WillReturnRows(sqlmock.NewRows(stateColumns).AddRow("cataloginventory_stock", "enabled", "idle", nil, 33))
+	dbMock.ExpectExec("INSERT INTO `mview_state`").
+		WithArgs("cataloginventory_stock", "enabled", "suspended", sqlmock.AnyArg(), 33).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, srv.Suspend(context.TODO(), "cataloginventory_stock"))
+
+	// a suspended view does not get refreshed
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selStateSQL)).
+		WillReturnRows(sqlmock.NewRows(stateColumns).AddRow("cataloginventory_stock", "enabled", "suspended", nil, 33))
+	require.NoError(t, srv.Refresh(context.TODO(), "cataloginventory_stock"))
+
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(selStateSQL)).
+		WillReturnRows(sqlmock.NewRows(stateColumns).AddRow("cataloginventory_stock", "enabled", "suspended", nil, 33))
+	dbMock.ExpectExec("INSERT INTO `mview_state`").
+		WithArgs("cataloginventory_stock", "enabled", "idle", sqlmock.AnyArg(), 33).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, srv.Resume(context.TODO(), "cataloginventory_stock"))
+
+	err = srv.Suspend(context.TODO(), "xyz")
+	assert.True(t, errors.NotFound.Match(err), "%+v", err)
+}
+
+func TestService_RefreshFull_Error(t *testing.T) {
+	t.Parallel()
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	srv, err := mview.NewService(dbc, mview.WithViews(newStockView()))
+	require.NoError(t, err)
+
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT `view_id`, `mode`, `status`, `updated`, `version_id` FROM `mview_state` WHERE (`view_id` = 'cataloginventory_stock')")).
+		WillReturnRows(sqlmock.NewRows([]string{"view_id", "mode", "status", "updated", "version_id"}).
+			AddRow("cataloginventory_stock", "enabled", "suspended", nil, 33))
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT MAX(`version_id`) FROM `cataloginventory_stock_cl`")).
+		WillReturnRows(sqlmock.NewRows([]string{"m"}).AddRow(35))
+	dbMock.ExpectExec("INSERT INTO `mview_state`").
+		WithArgs("cataloginventory_stock", "enabled", "working", sqlmock.AnyArg(), 33).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("DROP TABLE IF EXISTS `cataloginventory_stock_idx_tmp`")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("CREATE TABLE `cataloginventory_stock_idx_tmp` LIKE `cataloginventory_stock_idx`")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbMock.ExpectExec("INSERT INTO `cataloginventory_stock_idx_tmp`").
+		WillReturnError(errors.AlreadyClosed.Newf("DB closed"))
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("DROP TABLE IF EXISTS `cataloginventory_stock_idx_tmp`")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	dbMock.ExpectExec("INSERT INTO `mview_state`").
+		WithArgs("cataloginventory_stock", "enabled", "suspended", sqlmock.AnyArg(), 33).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = srv.RefreshFull(context.TODO(), "cataloginventory_stock")
+	assert.True(t, errors.AlreadyClosed.Match(err), "%+v", err)
+}
+
+func TestChangelogHandler_Do(t *testing.T) {
+	t.Parallel()
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	srv, err := mview.NewService(dbc, mview.WithViews(newStockView()))
+	require.NoError(t, err)
+
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `cataloginventory_stock_cl` (`entity_id`) VALUES (?),(?)")).
+		WithArgs(uint64(5), uint64(6)).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	tbl := ddl.NewTable("cataloginventory_stock_item",
+		&ddl.Column{Field: "item_id"},
+		&ddl.Column{Field: "product_id"},
+		&ddl.Column{Field: "qty"},
+	)
+	h := srv.ChangelogHandler()
+	err = h.Do(context.TODO(), binlogsync.UpdateAction, *tbl, [][]interface{}{
+		{1, 5, 3.4},
+		{1, 6, 3.4},
+		{2, 5, 1.1},
+	})
+	assert.NoError(t, err)
+
+	err = h.Do(context.TODO(), binlogsync.InsertAction, *ddl.NewTable("sales_order"), [][]interface{}{{1}})
+	assert.NoError(t, err, "Unrelated tables must be skipped")
+}
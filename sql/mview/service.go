@@ -0,0 +1,528 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mview
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+)
+
+// Service manages all registered materialized views. It maintains the state
+// of each view, refreshes the views on demand or scheduled and provides the
+// changelog handler for the binary log. Thread safe.
+type Service struct {
+	db         *dml.ConnPool
+	stateTable string
+	// Log defaults to a black hole.
+	Log log.Logger
+
+	mu    sync.RWMutex
+	views map[string]*viewLock
+
+	wg      sync.WaitGroup
+	cancels []context.CancelFunc
+}
+
+// viewLock protects a view from parallel refreshes.
+type viewLock struct {
+	sync.Mutex
+	*View
+}
+
+// Option applies options to the Service type.
+type Option func(*Service) error
+
+// WithLogger sets a custom logger.
+func WithLogger(l log.Logger) Option {
+	return func(s *Service) error {
+		s.Log = l
+		return nil
+	}
+}
+
+// WithStateTable sets a custom name for the state table. Default name is
+// `mview_state`.
+func WithStateTable(tableName string) Option {
+	return func(s *Service) error {
+		if err := dml.IsValidIdentifier(tableName); err != nil {
+			return errors.WithStack(err)
+		}
+		s.stateTable = tableName
+		return nil
+	}
+}
+
+// WithViews registers views. Registering an already existing view ID returns
+// an error.
+func WithViews(views ...*View) Option {
+	return func(s *Service) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		for _, v := range views {
+			if err := v.Validate(); err != nil {
+				return errors.WithStack(err)
+			}
+			if _, ok := s.views[v.ID]; ok {
+				return errors.AlreadyExists.Newf("[mview] View %q already registered", v.ID)
+			}
+			s.views[v.ID] = &viewLock{View: v}
+		}
+		return nil
+	}
+}
+
+// NewService creates a new materialized view service.
+func NewService(db *dml.ConnPool, opts ...Option) (*Service, error) {
+	s := &Service{
+		db:         db,
+		stateTable: DefaultStateTable,
+		Log:        log.BlackHole{},
+		views:      make(map[string]*viewLock),
+	}
+	for _, o := range opts {
+		if err := o(s); err != nil {
+			return nil, errors.Wrap(err, "[mview] NewService applied option error")
+		}
+	}
+	return s, nil
+}
+
+// ViewIDs returns the sorted IDs of all registered views.
+func (s *Service) ViewIDs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.views))
+	for id := range s.views {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s *Service) view(id string) (*viewLock, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.views[id]
+	if !ok {
+		return nil, errors.NotFound.Newf("[mview] View %q not found", id)
+	}
+	return v, nil
+}
+
+// ChangelogHandler returns a handler which can be registered with
+// binlogsync.Canal.RegisterRowsEventHandler to write the changelog of all views
+// from the binary log events.
+func (s *Service) ChangelogHandler() ChangelogHandler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	h := ChangelogHandler{DB: s.db.DB, Views: make([]*View, 0, len(s.views))}
+	for _, id := range s.viewIDsLocked() {
+		h.Views = append(h.Views, s.views[id].View)
+	}
+	return h
+}
+
+func (s *Service) viewIDsLocked() []string {
+	ids := make([]string, 0, len(s.views))
+	for id := range s.views {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Setup creates the state table, all changelog tables, all missing target
+// tables and the initial state of each view. The state of an already existing
+// view does not get touched. Setting up triggers is optional, see
+// SetupTriggers. A target table created by Setup does not have any indexes.
+func (s *Service) Setup(ctx context.Context) error {
+	if _, err := s.db.DB.ExecContext(ctx, StateTableDDL(s.stateTable)); err != nil {
+		return errors.Wrapf(err, "[mview] Failed to create state table %q", s.stateTable)
+	}
+	for _, id := range s.ViewIDs() {
+		v, err := s.view(id)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := v.CreateChangelog(ctx, s.db.DB); err != nil {
+			return errors.WithStack(err)
+		}
+		selSQL, args, err := v.Select.WithArgs().Interpolate().ToSQL()
+		if err != nil {
+			return errors.Wrapf(err, "[mview] Failed to build SELECT of view %q", id)
+		}
+		if _, err := s.db.DB.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+dml.Quoter.Name(v.Target)+
+			" SELECT * FROM ("+selSQL+") AS `mv` WHERE 1=0", args...); err != nil {
+			return errors.Wrapf(err, "[mview] Failed to create target table %q of view %q", v.Target, id)
+		}
+		if _, err := s.db.DB.ExecContext(ctx, "INSERT IGNORE INTO "+dml.Quoter.Name(s.stateTable)+
+			" (`view_id`,`mode`,`status`,`version_id`) VALUES (?,?,?,0)", id, ModeEnabled, StatusIdle); err != nil {
+			return errors.Wrapf(err, "[mview] Failed to create state of view %q", id)
+		}
+	}
+	return nil
+}
+
+// SetupTriggers creates the triggers on the source tables for the provided
+// view IDs. If no ID has been provided, the triggers of all views get created.
+func (s *Service) SetupTriggers(ctx context.Context, viewIDs ...string) error {
+	if len(viewIDs) == 0 {
+		viewIDs = s.ViewIDs()
+	}
+	for _, id := range viewIDs {
+		v, err := s.view(id)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if err := v.CreateTriggers(ctx, s.db.DB); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// State loads the current state of a view from the state table.
+func (s *Service) State(ctx context.Context, viewID string) (State, error) {
+	var st State
+	n, err := dml.NewSelect(stateColumns...).From(s.stateTable).
+		Where(dml.Column("view_id").Str(viewID)).
+		WithDB(s.db.DB).WithArgs().Load(ctx, &st)
+	if err != nil {
+		return State{}, errors.Wrapf(err, "[mview] Failed to load state of view %q", viewID)
+	}
+	if n == 0 {
+		return State{}, errors.NotFound.Newf("[mview] State of view %q not found. Did you run Setup?", viewID)
+	}
+	return st, nil
+}
+
+func (s *Service) saveState(ctx context.Context, db dml.QueryExecPreparer, st *State) error {
+	_, err := dml.NewInsert(s.stateTable).AddColumns(stateColumns...).
+		AddOnDuplicateKeyExclude("view_id").OnDuplicateKey().
+		WithDB(db).WithArgs().Record("", st).ExecContext(ctx)
+	return errors.Wrapf(err, "[mview] Failed to save state of view %q", st.ViewID)
+}
+
+// SetMode sets the mode of a view. Only ModeEnabled views gets refreshed by
+// RefreshAll and the scheduler.
+func (s *Service) SetMode(ctx context.Context, viewID string, mode string) error {
+	switch mode {
+	case ModeEnabled, ModeDisabled:
+	default:
+		return errors.NotSupported.Newf("[mview] Mode %q not supported", mode)
+	}
+	st, err := s.State(ctx, viewID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	st.Mode = mode
+	return s.saveState(ctx, s.db.DB, &st)
+}
+
+func (s *Service) maxVersion(ctx context.Context, v *View) (uint64, error) {
+	nv, _, err := dml.NewSelect().AddColumnsConditions(dml.Expr("MAX(" + dml.Quoter.Name(ChangelogColumnVersionID) + ")")).
+		From(v.ChangelogTable()).WithDB(s.db.DB).WithArgs().LoadNullUint64(ctx)
+	if err != nil {
+		return 0, errors.Wrapf(err, "[mview] Failed to load max version of view %q", v.ID)
+	}
+	return nv.Uint64, nil
+}
+
+// clearChangelog deletes the applied rows of the changelog but keeps the row
+// with the version upToVersion. InnoDB resets the AUTO_INCREMENT counter of an
+// empty table to the maximum plus one after a server restart, so without this
+// row new changes would get versions already marked as applied.
+func (s *Service) clearChangelog(ctx context.Context, v *View, upToVersion uint64) error {
+	_, err := dml.NewDelete(v.ChangelogTable()).
+		Where(dml.Column(ChangelogColumnVersionID).Less().Uint64(upToVersion)).
+		WithDB(s.db.DB).WithArgs().ExecContext(ctx)
+	return errors.Wrapf(err, "[mview] Failed to clear changelog of view %q", v.ID)
+}
+
+// Refresh applies all changes from the changelog to the target table of the
+// view. Only the rows of the changed entities get recalculated, each batch
+// within its own transaction. A suspended view gets skipped. Refresh blocks
+// when another refresh of the same view is running. If the changelog has been
+// truncated and its versions start again below the stored version, all rows
+// of the changelog get applied.
+func (s *Service) Refresh(ctx context.Context, viewID string) error {
+	v, err := s.view(viewID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	v.Lock()
+	defer v.Unlock()
+
+	st, err := s.State(ctx, viewID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if st.Status == StatusSuspended {
+		return nil
+	}
+
+	maxVersion, err := s.maxVersion(ctx, v.View)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fromVersion := st.VersionID
+	if maxVersion < fromVersion {
+		// clearChangelog always keeps the row with the stored version, so
+		// the changelog has been truncated by someone else.
+		if s.Log.IsInfo() {
+			s.Log.Info("mview.Service.Refresh.ChangelogReset", log.String("view_id", viewID),
+				log.Uint64("version_id", st.VersionID), log.Uint64("max_version", maxVersion))
+		}
+		fromVersion = 0
+	}
+	if maxVersion == fromVersion {
+		return nil
+	}
+
+	ids, err := dml.NewSelect(ChangelogColumnEntityID).Distinct().From(v.ChangelogTable()).
+		Where(
+			dml.Column(ChangelogColumnVersionID).Greater().Uint64(fromVersion),
+			dml.Column(ChangelogColumnVersionID).LessOrEqual().Uint64(maxVersion),
+		).OrderBy(ChangelogColumnEntityID).
+		WithDB(s.db.DB).WithArgs().LoadUint64s(ctx, nil)
+	if err != nil {
+		return errors.Wrapf(err, "[mview] Failed to load changelog of view %q", viewID)
+	}
+
+	if s.Log.IsDebug() {
+		s.Log.Debug("mview.Service.Refresh", log.String("view_id", viewID), log.Uint64("version_from", fromVersion),
+			log.Uint64("version_to", maxVersion), log.Int("entities", len(ids)))
+	}
+
+	st.Status = StatusWorking
+	if err := s.saveState(ctx, s.db.DB, &st); err != nil {
+		return errors.WithStack(err)
+	}
+
+	bs := v.batchSize()
+	for len(ids) > 0 {
+		n := bs
+		if n > len(ids) {
+			n = len(ids)
+		}
+		if err := s.refreshEntities(ctx, v.View, ids[:n]); err != nil {
+			st.Status = StatusIdle
+			if err2 := s.saveState(ctx, s.db.DB, &st); err2 != nil {
+				s.Log.Info("mview.Service.Refresh.saveState", log.Err(err2), log.String("view_id", viewID))
+			}
+			return errors.WithStack(err)
+		}
+		ids = ids[n:]
+	}
+
+	st.Status = StatusIdle
+	st.VersionID = maxVersion
+	st.Updated = dml.MakeNullTime(time.Now())
+	if err := s.saveState(ctx, s.db.DB, &st); err != nil {
+		return errors.WithStack(err)
+	}
+	return s.clearChangelog(ctx, v.View, maxVersion)
+}
+
+// refreshEntities deletes and recalculates the rows of the entities within a
+// transaction.
+func (s *Service) refreshEntities(ctx context.Context, v *View, ids []uint64) error {
+	return s.db.Transaction(ctx, nil, func(tx *dml.Tx) error {
+		if _, err := tx.DeleteFrom(v.Target).
+			Where(dml.Column(v.targetEntityColumn()).In().Uint64s(ids...)).
+			WithArgs().ExecContext(ctx); err != nil {
+			return errors.Wrapf(err, "[mview] Failed to delete entities from target %q of view %q", v.Target, v.ID)
+		}
+
+		sel := v.Select.Clone()
+		sel.Wheres = append(sel.Wheres, dml.Column(v.EntityColumn).In().Uint64s(ids...))
+		if _, err := tx.InsertInto(v.Target).FromSelect(sel).WithArgs().ExecContext(ctx); err != nil {
+			return errors.Wrapf(err, "[mview] Failed to insert entities into target %q of view %q", v.Target, v.ID)
+		}
+		return nil
+	})
+}
+
+// RefreshFull recalculates the whole view. The data gets written into a
+// temporary table which then gets swapped atomically with the target table.
+// All changelog entries up to the start of the full refresh are considered
+// as applied. RefreshFull refreshes a suspended view too and the view stays
+// suspended until Resume gets called. On error the temporary table gets
+// dropped and the previous status gets restored.
+func (s *Service) RefreshFull(ctx context.Context, viewID string) (err error) {
+	v, err := s.view(viewID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	v.Lock()
+	defer v.Unlock()
+
+	st, err := s.State(ctx, viewID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	maxVersion, err := s.maxVersion(ctx, v.View)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	prevStatus := st.Status
+	st.Status = StatusWorking
+	if err := s.saveState(ctx, s.db.DB, &st); err != nil {
+		return errors.WithStack(err)
+	}
+
+	tmp := v.tmpTarget()
+	tmpTbl := ddl.NewTable(tmp)
+	var done bool
+	defer func() {
+		if done {
+			return
+		}
+		// The context might have been cancelled, so clean up with a new one.
+		if err2 := tmpTbl.Drop(context.Background(), s.db.DB); err2 != nil {
+			s.Log.Info("mview.Service.RefreshFull.Drop", log.Err(err2), log.String("view_id", viewID))
+		}
+		st.Status = prevStatus
+		if err2 := s.saveState(context.Background(), s.db.DB, &st); err2 != nil {
+			s.Log.Info("mview.Service.RefreshFull.saveState", log.Err(err2), log.String("view_id", viewID))
+		}
+	}()
+
+	if err := tmpTbl.Drop(ctx, s.db.DB); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := s.db.DB.ExecContext(ctx, "CREATE TABLE "+dml.Quoter.Name(tmp)+" LIKE "+dml.Quoter.Name(v.Target)); err != nil {
+		return errors.Wrapf(err, "[mview] Failed to create temporary table %q of view %q", tmp, viewID)
+	}
+	if _, err := dml.NewInsert(tmp).FromSelect(v.Select.Clone()).WithDB(s.db.DB).WithArgs().ExecContext(ctx); err != nil {
+		return errors.Wrapf(err, "[mview] Failed to fill temporary table %q of view %q", tmp, viewID)
+	}
+	if err := ddl.NewTable(v.Target).Swap(ctx, s.db.DB, tmp); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := tmpTbl.Drop(ctx, s.db.DB); err != nil {
+		return errors.WithStack(err)
+	}
+
+	st.Status = StatusIdle
+	if prevStatus == StatusSuspended {
+		st.Status = StatusSuspended
+	}
+	st.VersionID = maxVersion
+	st.Updated = dml.MakeNullTime(time.Now())
+	if err := s.saveState(ctx, s.db.DB, &st); err != nil {
+		return errors.WithStack(err)
+	}
+	done = true
+	return s.clearChangelog(ctx, v.View, maxVersion)
+}
+
+// Suspend stops the incremental refreshes of a view, for example during a
+// bulk import followed by RefreshFull. Changes still get recorded in the
+// changelog and get applied by the first Refresh after Resume. Suspend waits
+// until a running refresh of the view has finished.
+func (s *Service) Suspend(ctx context.Context, viewID string) error {
+	return s.setStatus(ctx, viewID, StatusSuspended)
+}
+
+// Resume enables the incremental refreshes of a suspended view.
+func (s *Service) Resume(ctx context.Context, viewID string) error {
+	return s.setStatus(ctx, viewID, StatusIdle)
+}
+
+func (s *Service) setStatus(ctx context.Context, viewID string, status string) error {
+	v, err := s.view(viewID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	v.Lock()
+	defer v.Unlock()
+
+	st, err := s.State(ctx, viewID)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if st.Status == status {
+		return nil
+	}
+	st.Status = status
+	return s.saveState(ctx, s.db.DB, &st)
+}
+
+// RefreshAll refreshes incrementally all views which have the mode
+// ModeEnabled. It stops at the first error.
+func (s *Service) RefreshAll(ctx context.Context) error {
+	for _, id := range s.ViewIDs() {
+		st, err := s.State(ctx, id)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if st.Mode != ModeEnabled {
+			continue
+		}
+		if err := s.Refresh(ctx, id); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// Schedule starts a goroutine which calls RefreshAll in the provided interval.
+// Errors get logged as info. The goroutine terminates when the context gets
+// cancelled or Close gets called.
+func (s *Service) Schedule(ctx context.Context, interval time.Duration) {
+	ctx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.cancels = append(s.cancels, cancel)
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				if err := s.RefreshAll(ctx); err != nil && ctx.Err() == nil {
+					s.Log.Info("mview.Service.Schedule.RefreshAll", log.Err(err))
+				}
+			}
+		}
+	}()
+}
+
+// Close stops all scheduled refreshes and waits until they have been
+// terminated. It does not close the database connection.
+func (s *Service) Close() error {
+	s.mu.Lock()
+	for _, c := range s.cancels {
+		c()
+	}
+	s.cancels = nil
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mview
+
+import (
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+)
+
+// DefaultStateTable defines the name of the table which stores the State of
+// each view. Same name as in Magento 2.
+const DefaultStateTable = "mview_state"
+
+// Mode constants of a view. A disabled view gets not refreshed by the
+// scheduler.
+const (
+	ModeDisabled = "disabled"
+	ModeEnabled  = "enabled"
+)
+
+// Status constants of a view.
+const (
+	StatusIdle      = "idle"
+	StatusWorking   = "working"
+	StatusSuspended = "suspended"
+)
+
+// State represents a single row for DB table `mview_state`. VersionID contains
+// the last changelog version which has been applied to the target table.
+type State struct {
+	ViewID    string       // view_id varchar(255) NOT NULL PRI
+	Mode      string       // mode varchar(16) NOT NULL DEFAULT 'disabled'
+	Status    string       // status varchar(16) NOT NULL DEFAULT 'idle'
+	Updated   dml.NullTime // updated datetime NULL
+	VersionID uint64       // version_id bigint(20) unsigned NOT NULL DEFAULT '0'
+}
+
+// MapColumns implements interface dml.ColumnMapper.
+func (s *State) MapColumns(cm *dml.ColumnMap) error {
+	if cm.Mode() == dml.ColumnMapEntityReadAll {
+		return cm.String(&s.ViewID).String(&s.Mode).String(&s.Status).NullTime(&s.Updated).Uint64(&s.VersionID).Err()
+	}
+	for cm.Next() {
+		switch c := cm.Column(); c {
+		case "view_id":
+			cm.String(&s.ViewID)
+		case "mode":
+			cm.String(&s.Mode)
+		case "status":
+			cm.String(&s.Status)
+		case "updated":
+			cm.NullTime(&s.Updated)
+		case "version_id":
+			cm.Uint64(&s.VersionID)
+		default:
+			return errors.NotFound.Newf("[mview] State Column %q not found", c)
+		}
+	}
+	return errors.WithStack(cm.Err())
+}
+
+// stateColumns lists the columns of the state table in the same order as
+// State.MapColumns reads them.
+var stateColumns = []string{"view_id", "mode", "status", "updated", "version_id"}
+
+// StateTableDDL returns the CREATE TABLE statement for the state table.
+func StateTableDDL(tableName string) string {
+	return "CREATE TABLE IF NOT EXISTS " + dml.Quoter.Name(tableName) + ` (
+	` + "`view_id`" + ` VARCHAR(255) NOT NULL COMMENT 'View ID',
+	` + "`mode`" + ` VARCHAR(16) NOT NULL DEFAULT 'disabled' COMMENT 'View Mode',
+	` + "`status`" + ` VARCHAR(16) NOT NULL DEFAULT 'idle' COMMENT 'View Status',
+	` + "`updated`" + ` DATETIME NULL COMMENT 'View updated time',
+	` + "`version_id`" + ` BIGINT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'View Version ID',
+	PRIMARY KEY (` + "`view_id`" + `)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='View State'`
+}
@@ -0,0 +1,122 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mview
+
+import (
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+)
+
+// Source defines a table which contributes rows to a materialized view. Column
+// contains the name of the column in the source table whose value identifies
+// the affected entity in the view. For example the source table
+// `cataloginventory_stock_item` would have the Column `product_id` when the
+// view gets keyed by the product entity ID.
+type Source struct {
+	Table  string
+	Column string
+}
+
+// View defines a materialized view. The Select statement calculates the data of
+// the view and its result gets written into the Target table. Each change to
+// one of the Sources gets recorded in the changelog table of the view. The
+// EntityColumn must be part of the Select result and of the Target table. It
+// gets used to refresh the view incrementally.
+type View struct {
+	// ID unique identifier of the view. Gets used as a prefix for the
+	// changelog table and as the primary key in the state table.
+	ID string
+	// Select defines the query to calculate the data of the view. The query
+	// must not have a LIMIT clause.
+	Select *dml.Select
+	// Sources lists all tables whose changes affect the view.
+	Sources []Source
+	// Target the name of the table where the result of the Select query gets
+	// stored.
+	Target string
+	// EntityColumn the qualified or unqualified name of the column in the Select
+	// query and the Target table which identifies an entity. Changelog entries
+	// reference this column.
+	EntityColumn string
+	// TargetEntityColumn optional name of the entity column in the target
+	// table. Defaults to EntityColumn without its qualifier.
+	TargetEntityColumn string
+	// BatchSize defines how many entity IDs of the changelog gets refreshed
+	// within one transaction. Defaults to 1000.
+	BatchSize int
+}
+
+const defaultBatchSize = 1000
+
+// Validate checks if all required fields have been set and if all identifiers
+// are valid.
+func (v *View) Validate() error {
+	if v.Select == nil {
+		return errors.Empty.Newf("[mview] View %q: Select cannot be nil", v.ID)
+	}
+	if len(v.Sources) == 0 {
+		return errors.Empty.Newf("[mview] View %q: Sources cannot be empty", v.ID)
+	}
+	if v.EntityColumn == "" {
+		return errors.Empty.Newf("[mview] View %q: EntityColumn cannot be empty", v.ID)
+	}
+	if err := dml.IsValidIdentifier(v.ID); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := dml.IsValidIdentifier(v.Target); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, s := range v.Sources {
+		if err := dml.IsValidIdentifier(s.Table); err != nil {
+			return errors.Wrapf(err, "[mview] View %q source table", v.ID)
+		}
+		if err := dml.IsValidIdentifier(s.Column); err != nil {
+			return errors.Wrapf(err, "[mview] View %q source column of table %q", v.ID, s.Table)
+		}
+	}
+	return nil
+}
+
+// ChangelogTable returns the name of the changelog table of the view. It
+// follows the Magento naming convention: `<view_id>_cl`.
+func (v *View) ChangelogTable() string {
+	return ddl.TableName("", v.ID, "cl")
+}
+
+func (v *View) targetEntityColumn() string {
+	if v.TargetEntityColumn != "" {
+		return v.TargetEntityColumn
+	}
+	c := v.EntityColumn
+	for i := len(c) - 1; i >= 0; i-- {
+		if c[i] == '.' {
+			return c[i+1:]
+		}
+	}
+	return c
+}
+
+func (v *View) batchSize() int {
+	if v.BatchSize > 0 {
+		return v.BatchSize
+	}
+	return defaultBatchSize
+}
+
+// tmpTarget returns the name of the table used during a full refresh.
+func (v *View) tmpTarget() string {
+	return ddl.TableName("", v.Target, "tmp")
+}
@@ -17,6 +17,9 @@ type BinlogStreamer struct {
 	bleChan chan *BinlogEvent
 	errChan chan error
 	err     error
+	// errPending contains an error received while events were still
+	// buffered. It gets returned once all events have been consumed.
+	errPending error
 }
 
 // GetEvent gets the binlog event one by one, it will block until Syncer receives any events from MySQL
@@ -26,11 +29,28 @@ func (s *BinlogStreamer) GetEvent(ctx context.Context) (*BinlogEvent, error) {
 	if s.err != nil {
 		return nil, errors.Temporary.Newf("[myreplicator] Last sync error or closed, try sync and get event again")
 	}
+	if s.errPending != nil {
+		select {
+		case ble := <-s.bleChan:
+			return ble, nil
+		default:
+			s.err, s.errPending = s.errPending, nil
+			return nil, errors.Wrap(s.err, "[myreplicator] GetEvent error")
+		}
+	}
 
 	select {
 	case ble := <-s.bleChan:
 		return ble, nil
-	case s.err = <-s.errChan:
+	case err := <-s.errChan:
+		// Events sent before the error must be delivered first.
+		select {
+		case ble := <-s.bleChan:
+			s.errPending = err
+			return ble, nil
+		default:
+		}
+		s.err = err
 		return nil, errors.Wrap(s.err, "[myreplicator] GetEvent error")
 	case <-ctx.Done():
 		return nil, errors.Wrap(ctx.Err(), "[myreplicator] GetEvent context error")
@@ -409,13 +409,19 @@ func (e *MariadbBinlogCheckPointEvent) Dump(w io.Writer) {
 	fmt.Fprintln(w)
 }
 
+// MariadbGTIDFlagStandalone marks a MariaDB GTID event group which is not
+// wrapped in a transaction, e.g. a DDL statement.
+const MariadbGTIDFlagStandalone uint8 = 1
+
 type MariadbGTIDEvent struct {
-	GTID mysql.MariadbGTID
+	GTID  mysql.MariadbGTID
+	Flags uint8
 }
 
 func (e *MariadbGTIDEvent) Decode(data []byte) error {
 	e.GTID.SequenceNumber = binary.LittleEndian.Uint64(data)
 	e.GTID.DomainID = binary.LittleEndian.Uint32(data[8:])
+	e.Flags = data[12]
 
 	// we don't care commit id now, maybe later
 
@@ -0,0 +1,653 @@
+package fakemaster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/myreplicator"
+	"github.com/siddontang/go-mysql/mysql"
+)
+
+// defaultFile is the name of the first binlog file if no file has been
+// loaded.
+const defaultFile = "mysql-bin.000001"
+
+// postHeaderLengths contains the post header lengths of all MySQL 5.7 event
+// types. The parser uses it to detect the size of the table ID.
+var postHeaderLengths = []byte{
+	56, 13, 0, 8, 0, 18, 0, 4, 4, 4, 4, 18, 0, 0, 95, 0, 4, 26, 8, 0, 0, 0,
+	8, 8, 8, 2, 0, 0, 0, 10, 10, 10, 42, 42, 0, 18, 52, 0,
+}
+
+type binlogFile struct {
+	name string
+	data []byte
+}
+
+// Column describes a column of a table map event. Type must be one of the
+// mysql.MYSQL_TYPE_* constants. Meta contains the type specific meta data:
+// the maximum length in bytes for VARCHAR, (MYSQL_TYPE_STRING<<8)|length for
+// CHAR, the number of length bytes for BLOB and 4 or 8 for FLOAT and DOUBLE.
+//
+// Rows can be encoded for the types TINY, SHORT, INT24, LONG, LONGLONG,
+// FLOAT, DOUBLE, VARCHAR, VAR_STRING, STRING and BLOB.
+type Column struct {
+	Type     byte
+	Meta     uint16
+	Nullable bool
+}
+
+// current returns the last binlog file and creates the default file if none
+// exists.
+func (m *Master) current() *binlogFile {
+	if len(m.files) == 0 {
+		m.newFile(defaultFile)
+	}
+	return m.files[len(m.files)-1]
+}
+
+func (m *Master) newFile(name string) {
+	f := &binlogFile{name: name}
+	f.data = append(f.data, myreplicator.BinLogFileHeader...)
+	m.files = append(m.files, f)
+	m.appendEvent(f, myreplicator.FORMAT_DESCRIPTION_EVENT, m.formatDescription())
+	m.cond.Broadcast()
+}
+
+func (m *Master) formatDescription() []byte {
+	body := make([]byte, 2+50+4+1, 2+50+4+1+len(postHeaderLengths)+5)
+	binary.LittleEndian.PutUint16(body, myreplicator.MinBinlogVersion)
+	copy(body[2:52], m.ServerVersion)
+	binary.LittleEndian.PutUint32(body[52:], uint32(time.Now().Unix()))
+	body[56] = myreplicator.EventHeaderSize
+	body = append(body, postHeaderLengths...)
+	// checksum algorithm OFF followed by the unused checksum
+	return append(body, myreplicator.BINLOG_CHECKSUM_ALG_OFF, 0, 0, 0, 0)
+}
+
+func (m *Master) appendEvent(f *binlogFile, et myreplicator.EventType, body []byte) ddl.MasterStatus {
+	start := len(f.data)
+	size := myreplicator.EventHeaderSize + len(body)
+	var h [myreplicator.EventHeaderSize]byte
+	binary.LittleEndian.PutUint32(h[0:], uint32(time.Now().Unix()))
+	h[4] = byte(et)
+	binary.LittleEndian.PutUint32(h[5:], m.ServerID)
+	binary.LittleEndian.PutUint32(h[9:], uint32(size))
+	binary.LittleEndian.PutUint32(h[13:], uint32(start+size))
+	f.data = append(f.data, h[:]...)
+	f.data = append(f.data, body...)
+	return ddl.MasterStatus{File: f.name, Position: uint(start + size)}
+}
+
+// Event appends a raw event to the current binlog file. The body must not
+// contain a checksum. Returns the position after the event.
+func (m *Master) Event(et myreplicator.EventType, body []byte) ddl.MasterStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ms := m.appendEvent(m.current(), et, body)
+	m.cond.Broadcast()
+	return ms
+}
+
+// Query appends a query event, for example BEGIN, COMMIT or a DDL statement.
+func (m *Master) Query(schema, query string) ddl.MasterStatus {
+	body := make([]byte, 13, 13+len(schema)+1+len(query))
+	body[8] = byte(len(schema))
+	body = append(body, schema...)
+	body = append(body, 0)
+	body = append(body, query...)
+	return m.Event(myreplicator.QUERY_EVENT, body)
+}
+
+// Begin appends the query event which starts a transaction.
+func (m *Master) Begin(schema string) ddl.MasterStatus {
+	return m.Query(schema, "BEGIN")
+}
+
+// XID appends the event which commits a transaction.
+func (m *Master) XID(xid uint64) ddl.MasterStatus {
+	var body [8]byte
+	binary.LittleEndian.PutUint64(body[:], xid)
+	return m.Event(myreplicator.XID_EVENT, body[:])
+}
+
+// GTID appends a MySQL GTID event and adds the GTID to the executed GTID set.
+// The source ID must be a UUID. Panics if the UUID cannot be parsed.
+func (m *Master) GTID(sid string, gno int64) ddl.MasterStatus {
+	u, err := parseUUID(sid)
+	if err != nil {
+		panic(err)
+	}
+	body := make([]byte, 42)
+	body[0] = 1 // commit flag
+	copy(body[1:], u[:])
+	binary.LittleEndian.PutUint64(body[17:], uint64(gno))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.executed.add(formatUUID(u), gno)
+	ms := m.appendEvent(m.current(), myreplicator.GTID_EVENT, body)
+	m.cond.Broadcast()
+	return ms
+}
+
+// MariadbGTID appends a MariaDB GTID event. The server ID of the GTID is the
+// ServerID of the Master.
+func (m *Master) MariadbGTID(domainID uint32, seq uint64) ddl.MasterStatus {
+	body := make([]byte, 19)
+	binary.LittleEndian.PutUint64(body, seq)
+	binary.LittleEndian.PutUint32(body[8:], domainID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mariadb[domainID] = mysql.MariadbGTID{DomainID: domainID, ServerID: m.ServerID, SequenceNumber: seq}
+	ms := m.appendEvent(m.current(), myreplicator.MARIADB_GTID_EVENT, body)
+	m.cond.Broadcast()
+	return ms
+}
+
+// Rotate appends a rotate event and starts a new binlog file with the next
+// sequence number. Returns the position after the format description event
+// of the new file.
+func (m *Master) Rotate() ddl.MasterStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	next := nextFileName(m.current().name)
+	body := make([]byte, 8, 8+len(next))
+	binary.LittleEndian.PutUint64(body, 4)
+	m.appendEvent(m.current(), myreplicator.ROTATE_EVENT, append(body, next...))
+	m.newFile(next)
+	return m.status()
+}
+
+func nextFileName(name string) string {
+	i := strings.LastIndexByte(name, '.')
+	seq, err := strconv.ParseUint(name[i+1:], 10, 64)
+	if i < 0 || err != nil {
+		return name + ".000001"
+	}
+	return fmt.Sprintf("%s.%0*d", name[:i], len(name)-i-1, seq+1)
+}
+
+// TableMap appends a table map event. The columns get used to encode the
+// rows of the following rows events with the same table ID.
+func (m *Master) TableMap(tableID uint64, schema, table string, cols ...Column) ddl.MasterStatus {
+	body := make([]byte, 8, 64)
+	putTableID(body, tableID)
+	body = append(body, byte(len(schema)))
+	body = append(body, schema...)
+	body = append(body, 0, byte(len(table)))
+	body = append(body, table...)
+	body = append(body, 0)
+	body = appendLengthEncodedInt(body, uint64(len(cols)))
+	var meta []byte
+	nulls := make([]byte, (len(cols)+7)/8)
+	for i, c := range cols {
+		body = append(body, c.Type)
+		meta = appendColumnMeta(meta, c)
+		if c.Nullable {
+			nulls[i/8] |= 1 << uint(i%8)
+		}
+	}
+	body = appendLengthEncodedInt(body, uint64(len(meta)))
+	body = append(body, meta...)
+	body = append(body, nulls...)
+
+	m.mu.Lock()
+	m.tables[tableID] = cols
+	m.mu.Unlock()
+	return m.Event(myreplicator.TABLE_MAP_EVENT, body)
+}
+
+func appendColumnMeta(meta []byte, c Column) []byte {
+	switch c.Type {
+	case mysql.MYSQL_TYPE_STRING, mysql.MYSQL_TYPE_NEWDECIMAL:
+		return append(meta, byte(c.Meta>>8), byte(c.Meta))
+	case mysql.MYSQL_TYPE_VAR_STRING, mysql.MYSQL_TYPE_VARCHAR, mysql.MYSQL_TYPE_BIT:
+		return append(meta, byte(c.Meta), byte(c.Meta>>8))
+	case mysql.MYSQL_TYPE_BLOB, mysql.MYSQL_TYPE_DOUBLE, mysql.MYSQL_TYPE_FLOAT,
+		mysql.MYSQL_TYPE_GEOMETRY, mysql.MYSQL_TYPE_JSON, mysql.MYSQL_TYPE_TIME2,
+		mysql.MYSQL_TYPE_DATETIME2, mysql.MYSQL_TYPE_TIMESTAMP2:
+		return append(meta, byte(c.Meta))
+	}
+	return meta
+}
+
+// WriteRows appends a WRITE_ROWS_EVENTv2 for a table announced previously
+// with TableMap. Panics if a value cannot be encoded.
+func (m *Master) WriteRows(tableID uint64, rows ...[]interface{}) ddl.MasterStatus {
+	return m.rowsEvent(myreplicator.WRITE_ROWS_EVENTv2, tableID, rows)
+}
+
+// UpdateRows appends an UPDATE_ROWS_EVENTv2. The rows must be pairs of before
+// and after images. Panics if a value cannot be encoded.
+func (m *Master) UpdateRows(tableID uint64, rows ...[]interface{}) ddl.MasterStatus {
+	if len(rows)%2 != 0 {
+		panic(errors.NotValid.Newf("[fakemaster] UpdateRows requires pairs of rows, got %d rows", len(rows)))
+	}
+	return m.rowsEvent(myreplicator.UPDATE_ROWS_EVENTv2, tableID, rows)
+}
+
+// DeleteRows appends a DELETE_ROWS_EVENTv2. Panics if a value cannot be
+// encoded.
+func (m *Master) DeleteRows(tableID uint64, rows ...[]interface{}) ddl.MasterStatus {
+	return m.rowsEvent(myreplicator.DELETE_ROWS_EVENTv2, tableID, rows)
+}
+
+func (m *Master) rowsEvent(et myreplicator.EventType, tableID uint64, rows [][]interface{}) ddl.MasterStatus {
+	m.mu.Lock()
+	cols, ok := m.tables[tableID]
+	m.mu.Unlock()
+	if !ok {
+		panic(errors.NotFound.Newf("[fakemaster] Table ID %d not found, TableMap must be called first", tableID))
+	}
+
+	body := make([]byte, 8, 128)
+	putTableID(body, tableID)
+	binary.LittleEndian.PutUint16(body[6:], 1) // STMT_END_F
+	body = append(body, 2, 0)                  // extra data length includes itself
+	body = appendLengthEncodedInt(body, uint64(len(cols)))
+	present := make([]byte, (len(cols)+7)/8)
+	for i := range cols {
+		present[i/8] |= 1 << uint(i%8)
+	}
+	body = append(body, present...)
+	if et == myreplicator.UPDATE_ROWS_EVENTv2 {
+		body = append(body, present...)
+	}
+
+	for _, row := range rows {
+		if len(row) != len(cols) {
+			panic(errors.NotValid.Newf("[fakemaster] Table ID %d has %d columns but row has %d values", tableID, len(cols), len(row)))
+		}
+		nulls := make([]byte, len(present))
+		for i, v := range row {
+			if v == nil {
+				nulls[i/8] |= 1 << uint(i%8)
+			}
+		}
+		body = append(body, nulls...)
+		for i, v := range row {
+			if v == nil {
+				continue
+			}
+			var err error
+			if body, err = appendValue(body, cols[i], v); err != nil {
+				panic(errors.Wrapf(err, "[fakemaster] Table ID %d column %d", tableID, i))
+			}
+		}
+	}
+	return m.Event(et, body)
+}
+
+func putTableID(b []byte, id uint64) {
+	for i := 0; i < 6; i++ {
+		b[i] = byte(id >> (8 * uint(i)))
+	}
+}
+
+func appendValue(b []byte, c Column, v interface{}) ([]byte, error) {
+	switch c.Type {
+	case mysql.MYSQL_TYPE_TINY, mysql.MYSQL_TYPE_SHORT, mysql.MYSQL_TYPE_INT24,
+		mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_LONGLONG:
+		i, err := toInt64(v)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		size := map[byte]int{
+			mysql.MYSQL_TYPE_TINY: 1, mysql.MYSQL_TYPE_SHORT: 2, mysql.MYSQL_TYPE_INT24: 3,
+			mysql.MYSQL_TYPE_LONG: 4, mysql.MYSQL_TYPE_LONGLONG: 8,
+		}[c.Type]
+		for j := 0; j < size; j++ {
+			b = append(b, byte(uint64(i)>>(8*uint(j))))
+		}
+		return b, nil
+	case mysql.MYSQL_TYPE_FLOAT, mysql.MYSQL_TYPE_DOUBLE:
+		f, err := toFloat64(v)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if c.Type == mysql.MYSQL_TYPE_FLOAT {
+			var buf [4]byte
+			binary.LittleEndian.PutUint32(buf[:], math.Float32bits(float32(f)))
+			return append(b, buf[:]...), nil
+		}
+		var buf [8]byte
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(f))
+		return append(b, buf[:]...), nil
+	case mysql.MYSQL_TYPE_VARCHAR, mysql.MYSQL_TYPE_VAR_STRING, mysql.MYSQL_TYPE_STRING:
+		s, err := toBytes(v)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		maxLen := int(c.Meta)
+		if c.Type == mysql.MYSQL_TYPE_STRING {
+			if c.Meta>>8 != uint16(mysql.MYSQL_TYPE_STRING) {
+				return nil, errors.NotSupported.Newf("[fakemaster] CHAR meta data %#x not supported", c.Meta)
+			}
+			maxLen = int(c.Meta & 0xff)
+		}
+		if len(s) > maxLen {
+			return nil, errors.NotValid.Newf("[fakemaster] Value %q longer than %d bytes", s, maxLen)
+		}
+		if maxLen < 256 {
+			b = append(b, byte(len(s)))
+		} else {
+			b = append(b, byte(len(s)), byte(len(s)>>8))
+		}
+		return append(b, s...), nil
+	case mysql.MYSQL_TYPE_BLOB:
+		s, err := toBytes(v)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if c.Meta < 1 || c.Meta > 4 {
+			return nil, errors.NotValid.Newf("[fakemaster] Invalid BLOB length bytes %d", c.Meta)
+		}
+		for j := 0; j < int(c.Meta); j++ {
+			b = append(b, byte(len(s)>>(8*uint(j))))
+		}
+		return append(b, s...), nil
+	}
+	return nil, errors.NotSupported.Newf("[fakemaster] Column type %d not supported", c.Type)
+}
+
+func toInt64(v interface{}) (int64, error) {
+	switch i := v.(type) {
+	case int:
+		return int64(i), nil
+	case int8:
+		return int64(i), nil
+	case int16:
+		return int64(i), nil
+	case int32:
+		return int64(i), nil
+	case int64:
+		return i, nil
+	case uint:
+		return int64(i), nil
+	case uint8:
+		return int64(i), nil
+	case uint16:
+		return int64(i), nil
+	case uint32:
+		return int64(i), nil
+	case uint64:
+		return int64(i), nil
+	case bool:
+		if i {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, errors.NotSupported.Newf("[fakemaster] Cannot convert %T to an integer", v)
+}
+
+func toFloat64(v interface{}) (float64, error) {
+	switch f := v.(type) {
+	case float32:
+		return float64(f), nil
+	case float64:
+		return f, nil
+	}
+	i, err := toInt64(v)
+	return float64(i), err
+}
+
+func toBytes(v interface{}) ([]byte, error) {
+	switch s := v.(type) {
+	case string:
+		return []byte(s), nil
+	case []byte:
+		return s, nil
+	case fmt.Stringer:
+		return []byte(s.String()), nil
+	}
+	return nil, errors.NotSupported.Newf("[fakemaster] Cannot convert %T to a string", v)
+}
+
+// LoadFile appends a binlog file written by a MySQL or MariaDB server. The
+// base name of the path becomes the file name and must sort after all
+// existing files. GTID events of the file get added to the executed GTID set.
+// The events get served as they are, including their checksums.
+func (m *Master) LoadFile(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(data) < len(myreplicator.BinLogFileHeader) || string(data[:4]) != string(myreplicator.BinLogFileHeader) {
+		return errors.NotValid.Newf("[fakemaster] File %q is not a binlog file", path)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name := filepath.Base(path)
+	if n := len(m.files); n > 0 && m.files[n-1].name >= name {
+		return errors.NotValid.Newf("[fakemaster] File %q must sort after %q", name, m.files[n-1].name)
+	}
+
+	for pos := 4; pos+myreplicator.EventHeaderSize <= len(data); {
+		size := int(binary.LittleEndian.Uint32(data[pos+9:]))
+		if size < myreplicator.EventHeaderSize || pos+size > len(data) {
+			return errors.NotValid.Newf("[fakemaster] File %q contains an invalid event at position %d", path, pos)
+		}
+		body := data[pos+myreplicator.EventHeaderSize : pos+size]
+		switch myreplicator.EventType(data[pos+4]) {
+		case myreplicator.GTID_EVENT:
+			if len(body) >= 25 {
+				var u [16]byte
+				copy(u[:], body[1:17])
+				m.executed.add(formatUUID(u), int64(binary.LittleEndian.Uint64(body[17:])))
+			}
+		case myreplicator.MARIADB_GTID_EVENT:
+			if len(body) >= 12 {
+				d := binary.LittleEndian.Uint32(body[8:])
+				m.mariadb[d] = mysql.MariadbGTID{
+					DomainID:       d,
+					ServerID:       binary.LittleEndian.Uint32(data[pos+5:]),
+					SequenceNumber: binary.LittleEndian.Uint64(body),
+				}
+			}
+		}
+		pos += size
+	}
+
+	m.files = append(m.files, &binlogFile{name: name, data: data})
+	m.cond.Broadcast()
+	return nil
+}
+
+// WriteFiles writes all binlog files and the index file into dir. The files
+// can be read with the myreplicator.BinlogFileReader.
+func (m *Master) WriteFiles(dir string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.current()
+	var index []byte
+	for _, f := range m.files {
+		if err := ioutil.WriteFile(filepath.Join(dir, f.name), f.data, 0644); err != nil {
+			return errors.WithStack(err)
+		}
+		index = append(index, "./"+f.name+"\n"...)
+	}
+	i := strings.LastIndexByte(m.files[0].name, '.')
+	if i < 0 {
+		return nil
+	}
+	idx := filepath.Join(dir, m.files[0].name[:i]+".index")
+	return errors.WithStack(ioutil.WriteFile(idx, index, os.FileMode(0644)))
+}
+
+func (m *Master) mariadbPos() string {
+	var domains []int
+	for d := range m.mariadb {
+		domains = append(domains, int(d))
+	}
+	sort.Ints(domains)
+	var buf bytes.Buffer
+	for i, d := range domains {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(m.mariadb[uint32(d)].String())
+	}
+	return buf.String()
+}
+
+// gtidSet maps a formatted UUID to sorted and merged intervals. The stop of an
+// interval is exclusive like in the MySQL binary encoding.
+type gtidSet map[string][]interval
+
+type interval struct {
+	start, stop int64
+}
+
+func (s gtidSet) add(sid string, gno int64) {
+	in := append(s[sid], interval{gno, gno + 1})
+	sort.Slice(in, func(i, j int) bool { return in[i].start < in[j].start })
+	merged := in[:1]
+	for _, iv := range in[1:] {
+		last := &merged[len(merged)-1]
+		if iv.start <= last.stop {
+			if iv.stop > last.stop {
+				last.stop = iv.stop
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	s[sid] = merged
+}
+
+func (s gtidSet) contains(sid string, gno int64) bool {
+	for _, iv := range s[sid] {
+		if gno >= iv.start && gno < iv.stop {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the set like the MySQL server, for example
+// `3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7`.
+func (s gtidSet) String() string {
+	sids := make([]string, 0, len(s))
+	for sid := range s {
+		sids = append(sids, sid)
+	}
+	sort.Strings(sids)
+	var buf bytes.Buffer
+	for i, sid := range sids {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(sid)
+		for _, iv := range s[sid] {
+			if iv.stop-iv.start == 1 {
+				fmt.Fprintf(&buf, ":%d", iv.start)
+			} else {
+				fmt.Fprintf(&buf, ":%d-%d", iv.start, iv.stop-1)
+			}
+		}
+	}
+	return buf.String()
+}
+
+// decodeGTIDSet decodes the binary GTID set of a COM_BINLOG_DUMP_GTID command.
+func decodeGTIDSet(data []byte) (gtidSet, error) {
+	s := make(gtidSet)
+	if len(data) == 0 {
+		return s, nil
+	}
+	if len(data) < 8 {
+		return nil, errors.NotValid.Newf("[fakemaster] Invalid GTID set length %d", len(data))
+	}
+	n := binary.LittleEndian.Uint64(data)
+	pos := 8
+	for i := uint64(0); i < n; i++ {
+		if len(data) < pos+24 {
+			return nil, errors.NotValid.Newf("[fakemaster] Invalid GTID set at position %d", pos)
+		}
+		var u [16]byte
+		copy(u[:], data[pos:])
+		sid := formatUUID(u)
+		nIntervals := binary.LittleEndian.Uint64(data[pos+16:])
+		pos += 24
+		for j := uint64(0); j < nIntervals; j++ {
+			if len(data) < pos+16 {
+				return nil, errors.NotValid.Newf("[fakemaster] Invalid GTID interval at position %d", pos)
+			}
+			s[sid] = append(s[sid], interval{
+				start: int64(binary.LittleEndian.Uint64(data[pos:])),
+				stop:  int64(binary.LittleEndian.Uint64(data[pos+8:])),
+			})
+			pos += 16
+		}
+	}
+	return s, nil
+}
+
+// parseMariadbState parses the value of @slave_connect_state, for example
+// `0-1-5,1-2-9`, into the sequence number per domain.
+func parseMariadbState(state string) (map[uint32]uint64, error) {
+	seqs := make(map[uint32]uint64)
+	for _, g := range strings.Split(state, ",") {
+		if g = strings.TrimSpace(g); g == "" {
+			continue
+		}
+		parts := strings.Split(g, "-")
+		if len(parts) != 3 {
+			return nil, errors.NotValid.Newf("[fakemaster] Invalid MariaDB GTID %q", g)
+		}
+		d, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, errors.NotValid.Newf("[fakemaster] Invalid MariaDB GTID %q: %s", g, err)
+		}
+		seq, err := strconv.ParseUint(parts[2], 10, 64)
+		if err != nil {
+			return nil, errors.NotValid.Newf("[fakemaster] Invalid MariaDB GTID %q: %s", g, err)
+		}
+		seqs[uint32(d)] = seq
+	}
+	return seqs, nil
+}
+
+func parseUUID(s string) ([16]byte, error) {
+	var u [16]byte
+	b, err := hex.DecodeString(strings.Replace(s, "-", "", -1))
+	if err != nil || len(b) != 16 {
+		return u, errors.NotValid.Newf("[fakemaster] Invalid UUID %q", s)
+	}
+	copy(u[:], b)
+	return u, nil
+}
+
+func formatUUID(u [16]byte) string {
+	h := hex.EncodeToString(u[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func appendLengthEncodedInt(b []byte, n uint64) []byte {
+	switch {
+	case n < 251:
+		return append(b, byte(n))
+	case n < 1<<16:
+		return append(b, 0xfc, byte(n), byte(n>>8))
+	case n < 1<<24:
+		return append(b, 0xfd, byte(n), byte(n>>8), byte(n>>16))
+	}
+	return append(b, 0xfe, byte(n), byte(n>>8), byte(n>>16), byte(n>>24),
+		byte(n>>32), byte(n>>40), byte(n>>48), byte(n>>56))
+}
@@ -0,0 +1,753 @@
+package fakemaster
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/myreplicator"
+	"github.com/siddontang/go-mysql/mysql"
+)
+
+const (
+	maxPayloadLen  = 1<<24 - 1
+	nativePassword = "mysql_native_password"
+	capabilities   = mysql.CLIENT_LONG_PASSWORD | mysql.CLIENT_FOUND_ROWS | mysql.CLIENT_LONG_FLAG |
+		mysql.CLIENT_CONNECT_WITH_DB | mysql.CLIENT_PROTOCOL_41 | mysql.CLIENT_TRANSACTIONS |
+		mysql.CLIENT_SECURE_CONNECTION | mysql.CLIENT_MULTI_RESULTS | mysql.CLIENT_PLUGIN_AUTH
+)
+
+// MySQL error codes sent to the client.
+const (
+	errAccessDenied    = 1045
+	errUnknownCom      = 1047
+	errUnknown         = 1105
+	errParse           = 1064
+	errMasterFatalRead = 1236
+)
+
+var (
+	regexpLike   = regexp.MustCompile(`(?i)\bLIKE\s+'([^']*)'`)
+	regexpQuoted = regexp.MustCompile(`'([^']*)'`)
+	regexpSet    = regexp.MustCompile(`^(?i)@?@?(?:SESSION\.|GLOBAL\.)?([a-z0-9_]+)\s*:?=\s*(.*)$`)
+)
+
+// conn handles one client connection. The dump stream and the semi sync ACKs
+// share the connection, so during a dump a separate goroutine reads packets.
+type conn struct {
+	m   *Master
+	nc  net.Conn
+	br  *bufio.Reader
+	id  uint32
+	seq uint8
+
+	// session variables set via SET @name = value
+	vars map[string]string
+	// pending contains a command received during a binlog dump.
+	pending    []byte
+	pendingSeq uint8
+}
+
+func newConn(m *Master, nc net.Conn, id uint32) *conn {
+	return &conn{
+		m:    m,
+		nc:   nc,
+		br:   bufio.NewReader(nc),
+		id:   id,
+		vars: make(map[string]string),
+	}
+}
+
+func (c *conn) serve() {
+	defer c.nc.Close()
+	if err := c.handshake(); err != nil {
+		if c.m.Log.IsDebug() {
+			c.m.Log.Debug("fakemaster.conn.handshake.error", log.Err(err), log.Uint("conn_id", uint(c.id)))
+		}
+		return
+	}
+	for {
+		data, seq, err := c.readPacket()
+		if err != nil {
+			return
+		}
+		for data != nil {
+			c.seq = seq + 1
+			if err := c.dispatch(data); err != nil {
+				if c.m.Log.IsDebug() {
+					c.m.Log.Debug("fakemaster.conn.dispatch.error", log.Err(err), log.Uint("conn_id", uint(c.id)))
+				}
+				return
+			}
+			data, seq, c.pending = c.pending, c.pendingSeq, nil
+		}
+	}
+}
+
+func (c *conn) readPacket() ([]byte, uint8, error) {
+	var h [4]byte
+	if _, err := io.ReadFull(c.br, h[:]); err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	data := make([]byte, int(uint32(h[0])|uint32(h[1])<<8|uint32(h[2])<<16))
+	if _, err := io.ReadFull(c.br, data); err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	return data, h[3], nil
+}
+
+func (c *conn) writePacket(data []byte) error {
+	for {
+		n := len(data)
+		if n > maxPayloadLen {
+			n = maxPayloadLen
+		}
+		buf := make([]byte, 4, 4+n)
+		buf[0], buf[1], buf[2], buf[3] = byte(n), byte(n>>8), byte(n>>16), c.seq
+		c.seq++
+		if _, err := c.nc.Write(append(buf, data[:n]...)); err != nil {
+			return errors.WithStack(err)
+		}
+		data = data[n:]
+		if n < maxPayloadLen {
+			return nil
+		}
+	}
+}
+
+func (c *conn) writeOK() error {
+	return c.writePacket([]byte{mysql.OK_HEADER, 0, 0, byte(mysql.SERVER_STATUS_AUTOCOMMIT), 0, 0, 0})
+}
+
+func (c *conn) writeEOF() error {
+	return c.writePacket([]byte{mysql.EOF_HEADER, 0, 0, byte(mysql.SERVER_STATUS_AUTOCOMMIT), 0})
+}
+
+func (c *conn) writeError(code uint16, state, format string, args ...interface{}) error {
+	data := []byte{mysql.ERR_HEADER, byte(code), byte(code >> 8), '#'}
+	data = append(data, state...)
+	data = append(data, fmt.Sprintf(format, args...)...)
+	return c.writePacket(data)
+}
+
+func (c *conn) handshake() error {
+	salt := make([]byte, 20)
+	if _, err := rand.Read(salt); err != nil {
+		return errors.WithStack(err)
+	}
+	for i, b := range salt {
+		salt[i] = b%94 + 33 // printable and never zero
+	}
+
+	data := []byte{10}
+	data = append(data, c.m.ServerVersion...)
+	data = append(data, 0, byte(c.id), byte(c.id>>8), byte(c.id>>16), byte(c.id>>24))
+	data = append(data, salt[:8]...)
+	data = append(data, 0, byte(capabilities&0xff), byte(capabilities>>8&0xff), mysql.DEFAULT_COLLATION_ID)
+	data = append(data, byte(mysql.SERVER_STATUS_AUTOCOMMIT), 0, byte(capabilities>>16&0xff), byte(capabilities>>24))
+	data = append(data, byte(len(salt)+1), 0, 0, 0, 0, 0, 0, 0, 0, 0, 0)
+	data = append(data, salt[8:]...)
+	data = append(data, 0)
+	data = append(data, nativePassword...)
+	data = append(data, 0)
+
+	c.seq = 0
+	if err := c.writePacket(data); err != nil {
+		return errors.WithStack(err)
+	}
+
+	data, seq, err := c.readPacket()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.seq = seq + 1
+	if len(data) < 32 {
+		return errors.NotValid.Newf("[fakemaster] Handshake response too short: %d bytes", len(data))
+	}
+	caps := binary.LittleEndian.Uint32(data)
+	rest := data[32:]
+	user, rest := readNullString(rest)
+	var auth []byte
+	switch {
+	case caps&mysql.CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA != 0:
+		n, size := readLengthEncodedInt(rest)
+		if len(rest) < size+int(n) {
+			return errors.NotValid.Newf("[fakemaster] Invalid auth response")
+		}
+		auth, rest = rest[size:size+int(n)], rest[size+int(n):]
+	case caps&mysql.CLIENT_SECURE_CONNECTION != 0 && len(rest) > 0:
+		n := int(rest[0])
+		if len(rest) < 1+n {
+			return errors.NotValid.Newf("[fakemaster] Invalid auth response")
+		}
+		auth, rest = rest[1:1+n], rest[1+n:]
+	default:
+		var s string
+		s, rest = readNullString(rest)
+		auth = []byte(s)
+	}
+	if caps&mysql.CLIENT_CONNECT_WITH_DB != 0 {
+		_, rest = readNullString(rest)
+	}
+	if plugin, _ := readNullString(rest); caps&mysql.CLIENT_PLUGIN_AUTH != 0 && plugin != "" && plugin != nativePassword {
+		// auth switch request
+		sw := append([]byte{mysql.EOF_HEADER}, nativePassword...)
+		sw = append(append(append(sw, 0), salt...), 0)
+		if err := c.writePacket(sw); err != nil {
+			return errors.WithStack(err)
+		}
+		if auth, seq, err = c.readPacket(); err != nil {
+			return errors.WithStack(err)
+		}
+		c.seq = seq + 1
+	}
+
+	if c.m.User != "" && (user != c.m.User || !bytes.Equal(auth, scramblePassword(salt, c.m.Password))) {
+		c.writeError(errAccessDenied, "28000", "Access denied for user '%s'", user)
+		return errors.Unauthorized.Newf("[fakemaster] Access denied for user %q", user)
+	}
+	return c.writeOK()
+}
+
+// scramblePassword computes the mysql_native_password response
+// SHA1(password) XOR SHA1(salt + SHA1(SHA1(password))).
+func scramblePassword(salt []byte, password string) []byte {
+	if password == "" {
+		return nil
+	}
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+	h := sha1.New()
+	h.Write(salt)
+	h.Write(stage2[:])
+	s := h.Sum(nil)
+	for i := range s {
+		s[i] ^= stage1[i]
+	}
+	return s
+}
+
+func readNullString(b []byte) (string, []byte) {
+	i := bytes.IndexByte(b, 0)
+	if i < 0 {
+		return string(b), nil
+	}
+	return string(b[:i]), b[i+1:]
+}
+
+func readLengthEncodedInt(b []byte) (uint64, int) {
+	if len(b) == 0 {
+		return 0, 0
+	}
+	switch b[0] {
+	case 0xfc:
+		if len(b) >= 3 {
+			return uint64(b[1]) | uint64(b[2])<<8, 3
+		}
+	case 0xfd:
+		if len(b) >= 4 {
+			return uint64(b[1]) | uint64(b[2])<<8 | uint64(b[3])<<16, 4
+		}
+	case 0xfe:
+		if len(b) >= 9 {
+			return binary.LittleEndian.Uint64(b[1:]), 9
+		}
+	default:
+		return uint64(b[0]), 1
+	}
+	return 0, len(b)
+}
+
+func (c *conn) dispatch(data []byte) error {
+	if len(data) == 0 {
+		return errors.NotValid.Newf("[fakemaster] Empty command packet")
+	}
+	switch cmd, data := data[0], data[1:]; cmd {
+	case mysql.COM_QUIT:
+		return io.EOF
+	case mysql.COM_PING, mysql.COM_INIT_DB:
+		return c.writeOK()
+	case mysql.COM_QUERY:
+		return c.handleQuery(string(data))
+	case mysql.COM_REGISTER_SLAVE:
+		return c.handleRegisterSlave(data)
+	case mysql.COM_BINLOG_DUMP:
+		return c.handleBinlogDump(data)
+	case mysql.COM_BINLOG_DUMP_GTID:
+		return c.handleBinlogDumpGTID(data)
+	default:
+		return c.writeError(errUnknownCom, "08S01", "Unknown command %d", cmd)
+	}
+}
+
+func (c *conn) handleRegisterSlave(data []byte) error {
+	if len(data) < 4 {
+		return c.writeError(errUnknownCom, "08S01", "Malformed COM_REGISTER_SLAVE packet")
+	}
+	s := Slave{ServerID: binary.LittleEndian.Uint32(data)}
+	rest := data[4:]
+	for _, f := range []*string{&s.Host, &s.User, &s.Password} {
+		if len(rest) == 0 || len(rest) < 1+int(rest[0]) {
+			return c.writeError(errUnknownCom, "08S01", "Malformed COM_REGISTER_SLAVE packet")
+		}
+		*f, rest = string(rest[1:1+int(rest[0])]), rest[1+int(rest[0]):]
+	}
+	if len(rest) >= 2 {
+		s.Port = binary.LittleEndian.Uint16(rest)
+	}
+	c.m.addSlave(s)
+	return c.writeOK()
+}
+
+func (c *conn) handleQuery(query string) error {
+	r, err := c.query(query)
+	if err != nil {
+		code := uint16(errUnknown)
+		if errors.NotSupported.Match(err) {
+			code = errParse
+		}
+		return c.writeError(code, "HY000", "%s", err)
+	}
+	if r == nil || len(r.Columns) == 0 {
+		return c.writeOK()
+	}
+	return c.writeResult(r)
+}
+
+func (c *conn) query(query string) (*Result, error) {
+	q := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(query), ";"))
+	u := strings.ToUpper(q)
+	switch {
+	case strings.HasPrefix(u, "SET "):
+		c.set(q[4:])
+		return nil, nil
+	case u == "SHOW MASTER STATUS":
+		c.m.mu.Lock()
+		ms := c.m.status()
+		c.m.mu.Unlock()
+		return &Result{
+			Columns: []string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"},
+			Rows:    [][]interface{}{{ms.File, ms.Position, "", "", ms.ExecutedGTIDSet}},
+		}, nil
+	case u == "SHOW BINARY LOGS" || u == "SHOW MASTER LOGS":
+		c.m.mu.Lock()
+		defer c.m.mu.Unlock()
+		c.m.current()
+		r := &Result{Columns: []string{"Log_name", "File_size"}}
+		for _, f := range c.m.files {
+			r.Rows = append(r.Rows, []interface{}{f.name, len(f.data)})
+		}
+		return r, nil
+	case strings.HasPrefix(u, "SHOW ") && strings.Contains(u, " VARIABLES"):
+		return c.showVariables(q), nil
+	case c.m.QueryHandler != nil:
+		return c.m.QueryHandler(q)
+	}
+	return nil, errors.NotSupported.Newf("[fakemaster] Query not supported: %q", q)
+}
+
+// set stores session variables. Supports one assignment per statement and
+// ignores statements like SET NAMES.
+func (c *conn) set(assignment string) {
+	sm := regexpSet.FindStringSubmatch(strings.TrimSpace(assignment))
+	if sm == nil {
+		return
+	}
+	c.vars[strings.ToLower(sm[1])] = strings.Trim(strings.TrimSpace(sm[2]), `'"`)
+}
+
+func (c *conn) variables() map[string]string {
+	m := c.m
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	semiSync := "OFF"
+	if m.SemiSync {
+		semiSync = "ON"
+	}
+	vars := map[string]string{
+		"binlog_checksum":              "NONE",
+		"binlog_format":                "ROW",
+		"binlog_row_image":             "FULL",
+		"log_bin":                      "ON",
+		"rpl_semi_sync_master_enabled": semiSync,
+		"server_id":                    strconv.FormatUint(uint64(m.ServerID), 10),
+		"version":                      m.ServerVersion,
+	}
+	if m.Flavor == mysql.MariaDBFlavor {
+		vars["gtid_binlog_pos"] = m.mariadbPos()
+		vars["gtid_current_pos"] = vars["gtid_binlog_pos"]
+	} else {
+		vars["gtid_mode"] = "ON"
+		vars["gtid_executed"] = m.executed.String()
+	}
+	for k, v := range m.Variables {
+		vars[k] = v
+	}
+	return vars
+}
+
+// showVariables supports SHOW [GLOBAL|SESSION] VARIABLES with an optional
+// LIKE pattern or a WHERE clause. For WHERE only the quoted names get
+// compared.
+func (c *conn) showVariables(q string) *Result {
+	var match func(name string) bool
+	if sm := regexpLike.FindStringSubmatch(q); sm != nil {
+		pattern := regexp.QuoteMeta(strings.ToLower(sm[1]))
+		pattern = strings.Replace(pattern, "%", ".*", -1)
+		pattern = strings.Replace(pattern, "_", ".", -1)
+		re := regexp.MustCompile("^" + pattern + "$")
+		match = re.MatchString
+	} else if i := strings.Index(strings.ToUpper(q), " WHERE "); i > 0 {
+		names := map[string]bool{}
+		for _, sm := range regexpQuoted.FindAllStringSubmatch(q[i:], -1) {
+			names[strings.ToLower(sm[1])] = true
+		}
+		match = func(name string) bool { return names[name] }
+	} else {
+		match = func(string) bool { return true }
+	}
+
+	vars := c.variables()
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		if match(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	r := &Result{Columns: []string{"Variable_name", "Value"}}
+	for _, name := range names {
+		r.Rows = append(r.Rows, []interface{}{name, vars[name]})
+	}
+	return r
+}
+
+func appendLengthEncodedString(b []byte, s []byte) []byte {
+	return append(appendLengthEncodedInt(b, uint64(len(s))), s...)
+}
+
+func (c *conn) writeResult(r *Result) error {
+	if err := c.writePacket(appendLengthEncodedInt(nil, uint64(len(r.Columns)))); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, col := range r.Columns {
+		var def []byte
+		for _, s := range []string{"def", "", "", "", col, col} {
+			def = appendLengthEncodedString(def, []byte(s))
+		}
+		def = append(def, 0x0c, mysql.DEFAULT_COLLATION_ID, 0, 0, 1, 0, 0, mysql.MYSQL_TYPE_VAR_STRING, 0, 0, 0, 0, 0)
+		if err := c.writePacket(def); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if err := c.writeEOF(); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, row := range r.Rows {
+		var data []byte
+		for _, v := range row {
+			switch v := v.(type) {
+			case nil:
+				data = append(data, 0xfb)
+			case []byte:
+				data = appendLengthEncodedString(data, v)
+			default:
+				data = appendLengthEncodedString(data, []byte(fmt.Sprint(v)))
+			}
+		}
+		if err := c.writePacket(data); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return c.writeEOF()
+}
+
+func (c *conn) handleBinlogDump(data []byte) error {
+	if len(data) < 10 {
+		return c.writeError(errUnknownCom, "08S01", "Malformed COM_BINLOG_DUMP packet")
+	}
+	d := Dump{
+		Flags:    binary.LittleEndian.Uint16(data[4:]),
+		ServerID: binary.LittleEndian.Uint32(data[6:]),
+		Position: ddl.MasterStatus{
+			File:     string(data[10:]),
+			Position: uint(binary.LittleEndian.Uint32(data)),
+		},
+	}
+	var skip func(ev []byte) (bool, bool)
+	if state, ok := c.vars["slave_connect_state"]; ok && c.m.Flavor == mysql.MariaDBFlavor {
+		d.GTIDSet = state
+		seqs, err := parseMariadbState(state)
+		if err != nil {
+			return c.writeError(errMasterFatalRead, "HY000", "%s", err)
+		}
+		skip = func(ev []byte) (bool, bool) {
+			if myreplicator.EventType(ev[4]) != myreplicator.MARIADB_GTID_EVENT || len(ev) < myreplicator.EventHeaderSize+12 {
+				return false, false
+			}
+			body := ev[myreplicator.EventHeaderSize:]
+			seq, ok := seqs[binary.LittleEndian.Uint32(body[8:])]
+			return true, ok && binary.LittleEndian.Uint64(body) <= seq
+		}
+	}
+	c.m.addDump(d)
+	return c.dump(d, skip)
+}
+
+func (c *conn) handleBinlogDumpGTID(data []byte) error {
+	if len(data) < 10 {
+		return c.writeError(errUnknownCom, "08S01", "Malformed COM_BINLOG_DUMP_GTID packet")
+	}
+	d := Dump{
+		Flags:    binary.LittleEndian.Uint16(data),
+		ServerID: binary.LittleEndian.Uint32(data[2:]),
+	}
+	nameLen := int(binary.LittleEndian.Uint32(data[6:]))
+	rest := data[10:]
+	if len(rest) < nameLen+12 {
+		return c.writeError(errUnknownCom, "08S01", "Malformed COM_BINLOG_DUMP_GTID packet")
+	}
+	d.Position.File = string(rest[:nameLen])
+	d.Position.Position = uint(binary.LittleEndian.Uint64(rest[nameLen:]))
+	rest = rest[nameLen+8:]
+	gtidLen := int(binary.LittleEndian.Uint32(rest))
+	if len(rest) < 4+gtidLen {
+		return c.writeError(errUnknownCom, "08S01", "Malformed COM_BINLOG_DUMP_GTID packet")
+	}
+	set, err := decodeGTIDSet(rest[4 : 4+gtidLen])
+	if err != nil {
+		return c.writeError(errMasterFatalRead, "HY000", "%s", err)
+	}
+	d.GTIDSet = set.String()
+	c.m.addDump(d)
+
+	// The GTID set decides where to start, so file and position get ignored.
+	d.Position = ddl.MasterStatus{}
+	return c.dump(d, func(ev []byte) (bool, bool) {
+		if myreplicator.EventType(ev[4]) != myreplicator.GTID_EVENT || len(ev) < myreplicator.EventHeaderSize+25 {
+			return false, false
+		}
+		body := ev[myreplicator.EventHeaderSize:]
+		var u [16]byte
+		copy(u[:], body[1:17])
+		return true, set.contains(formatUUID(u), int64(binary.LittleEndian.Uint64(body[17:])))
+	})
+}
+
+// dump streams the events starting at the position of the Dump. The optional
+// skip function reports whether an event starts a new transaction and whether
+// that transaction has already been executed by the slave.
+func (c *conn) dump(d Dump, skip func(ev []byte) (isGTID, executed bool)) error {
+	m := c.m
+	m.mu.Lock()
+	m.current()
+	fi := 0
+	if d.Position.File != "" {
+		fi = -1
+		for i, f := range m.files {
+			if f.name == d.Position.File {
+				fi = i
+			}
+		}
+	}
+	if fi < 0 || (d.Position.Position > 4 && int(d.Position.Position) > len(m.files[fi].data)) {
+		m.mu.Unlock()
+		return c.writeError(errMasterFatalRead, "HY000",
+			"Could not find first log file name in binary log index file: %s", d.Position)
+	}
+	file := m.files[fi]
+	data := file.data
+	m.mu.Unlock()
+
+	pos := int(d.Position.Position)
+	if pos < 4 {
+		pos = 4
+	}
+
+	// During the dump the slave can only send ACKs. Any other packet stops
+	// the dump and gets dispatched afterwards.
+	acks := make(chan []byte)
+	done := make(chan struct{})
+	stop := make(chan struct{})
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			close(done)
+			m.cond.Broadcast()
+			m.mu.Unlock()
+		}()
+		for {
+			data, seq, err := c.readPacket()
+			if err != nil {
+				return
+			}
+			if len(data) == 0 || data[0] != myreplicator.SemiSyncIndicator {
+				c.pending, c.pendingSeq = data, seq
+				return
+			}
+			// the slave resets the sequence for each ACK
+			select {
+			case acks <- append(data, seq):
+			case <-stop:
+				return
+			}
+		}
+	}()
+	defer func() {
+		close(stop)
+		c.nc.SetReadDeadline(time.Now())
+		<-done
+		c.nc.SetReadDeadline(time.Time{})
+	}()
+	isDone := func() bool {
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}
+
+	semiSync := m.SemiSync && c.vars["rpl_semi_sync_slave"] == "1"
+	send := func(ev []byte, needACK bool) error {
+		data := make([]byte, 0, len(ev)+3)
+		data = append(data, mysql.OK_HEADER)
+		if semiSync {
+			ack := byte(0)
+			if needACK {
+				ack = 1
+			}
+			data = append(data, myreplicator.SemiSyncIndicator, ack)
+		}
+		if err := c.writePacket(append(data, ev...)); err != nil {
+			return errors.WithStack(err)
+		}
+		if !semiSync || !needACK {
+			return nil
+		}
+		select {
+		case ack := <-acks:
+			seq := ack[len(ack)-1]
+			ack = ack[1 : len(ack)-1]
+			if len(ack) < 8 {
+				return errors.NotValid.Newf("[fakemaster] Malformed semi sync ACK")
+			}
+			m.addAck(ddl.MasterStatus{
+				File:     string(ack[8:]),
+				Position: uint(binary.LittleEndian.Uint64(ack)),
+			})
+			c.seq = seq + 1
+			return c.writeOK()
+		case <-done:
+			return io.EOF
+		}
+	}
+
+	// A dump always starts with an artificial rotate event. If the position
+	// is after the format description event, it gets sent with a zero
+	// position.
+	if err := send(rotateEvent(m.ServerID, file.name, uint64(pos)), false); err != nil {
+		return errors.WithStack(err)
+	}
+	if pos > 4 {
+		size := int(binary.LittleEndian.Uint32(data[4+9:]))
+		fde := append([]byte(nil), data[4:4+size]...)
+		binary.LittleEndian.PutUint32(fde[13:], 0)
+		if err := send(fde, false); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	skipping := false
+	for {
+		m.mu.Lock()
+		for !m.closed && !isDone() && pos >= len(file.data) && fi == len(m.files)-1 {
+			if d.Flags&myreplicator.BINLOG_DUMP_NON_BLOCK != 0 {
+				m.mu.Unlock()
+				return c.writeEOF()
+			}
+			m.cond.Wait()
+		}
+		if m.closed || isDone() {
+			m.mu.Unlock()
+			return io.EOF
+		}
+		if pos >= len(file.data) {
+			fi++
+			file = m.files[fi]
+			pos = 4
+			m.mu.Unlock()
+			continue
+		}
+		data = file.data
+		m.mu.Unlock()
+
+		if len(data) < pos+myreplicator.EventHeaderSize {
+			return c.writeError(errMasterFatalRead, "HY000", "Truncated event in %s at %d", file.name, pos)
+		}
+		size := int(binary.LittleEndian.Uint32(data[pos+9:]))
+		if size < myreplicator.EventHeaderSize || len(data) < pos+size {
+			return c.writeError(errMasterFatalRead, "HY000", "Invalid event in %s at %d", file.name, pos)
+		}
+		ev := data[pos : pos+size]
+		pos += size
+
+		if skip != nil {
+			if isGTID, executed := skip(ev); isGTID {
+				skipping = executed
+			}
+		}
+		et := myreplicator.EventType(ev[4])
+		switch et {
+		case myreplicator.FORMAT_DESCRIPTION_EVENT, myreplicator.ROTATE_EVENT,
+			myreplicator.PREVIOUS_GTIDS_EVENT, myreplicator.STOP_EVENT,
+			myreplicator.MARIADB_GTID_LIST_EVENT, myreplicator.MARIADB_BINLOG_CHECKPOINT_EVENT:
+		default:
+			if skipping {
+				continue
+			}
+		}
+		if err := send(ev, et == myreplicator.XID_EVENT || isCommitQuery(ev)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+}
+
+func rotateEvent(serverID uint32, file string, pos uint64) []byte {
+	ev := make([]byte, myreplicator.EventHeaderSize+8, myreplicator.EventHeaderSize+8+len(file))
+	ev[4] = byte(myreplicator.ROTATE_EVENT)
+	binary.LittleEndian.PutUint32(ev[5:], serverID)
+	binary.LittleEndian.PutUint32(ev[9:], uint32(len(ev)+len(file)))
+	binary.LittleEndian.PutUint16(ev[17:], myreplicator.LOG_EVENT_ARTIFICIAL_F)
+	binary.LittleEndian.PutUint64(ev[myreplicator.EventHeaderSize:], pos)
+	return append(ev, file...)
+}
+
+// isCommitQuery reports whether a query event ends a transaction, which is
+// the case for COMMIT and for DDL statements.
+func isCommitQuery(ev []byte) bool {
+	if myreplicator.EventType(ev[4]) != myreplicator.QUERY_EVENT || len(ev) < myreplicator.EventHeaderSize+13 {
+		return false
+	}
+	body := ev[myreplicator.EventHeaderSize:]
+	schemaLen := int(body[8])
+	start := 13 + int(binary.LittleEndian.Uint16(body[11:])) + schemaLen + 1
+	if start > len(body) {
+		return false
+	}
+	q := strings.ToUpper(strings.TrimSpace(string(body[start:])))
+	return q != "BEGIN" && !strings.HasPrefix(q, "XA ")
+}
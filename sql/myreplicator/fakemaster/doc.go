@@ -0,0 +1,17 @@
+// Package fakemaster provides an in-process MySQL replication master for
+// hermetic tests.
+//
+// The Master speaks enough of the MySQL client/server protocol to accept a
+// connection from the myreplicator.BinlogSyncer or from the go-sql-driver:
+// handshake with mysql_native_password authentication, text protocol queries
+// like SHOW MASTER STATUS, SHOW VARIABLES and SET, COM_REGISTER_SLAVE,
+// COM_BINLOG_DUMP and COM_BINLOG_DUMP_GTID. Semi synchronous replication gets
+// emulated by requesting an ACK for each transaction commit.
+//
+// Binlog events can be scripted with the event builder functions like Query,
+// GTID, TableMap, WriteRows or XID or loaded from binlog files written by a
+// real server. Scripted events do not contain checksums.
+//
+// Prepared statements, TLS and compression are not supported. Queries
+// unknown to the Master can be answered via the QueryHandler field.
+package fakemaster
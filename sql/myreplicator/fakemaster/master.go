@@ -0,0 +1,314 @@
+package fakemaster
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/siddontang/go-mysql/mysql"
+)
+
+// Result defines a text protocol result set returned to the client. A nil
+// Result or a Result without columns sends an OK packet. Row values can be nil
+// for SQL NULL, []byte, string or any other type which gets formatted with
+// fmt.Sprint.
+type Result struct {
+	Columns []string
+	Rows    [][]interface{}
+}
+
+// Slave contains the data of a COM_REGISTER_SLAVE command.
+type Slave struct {
+	ServerID uint32
+	Host     string
+	User     string
+	Password string
+	Port     uint16
+}
+
+// Dump contains the data of a COM_BINLOG_DUMP or COM_BINLOG_DUMP_GTID
+// command.
+type Dump struct {
+	ServerID uint32
+	Flags    uint16
+	Position ddl.MasterStatus
+	// GTIDSet contains for MySQL the decoded GTID set of a COM_BINLOG_DUMP_GTID
+	// command and for MariaDB the value of the variable @slave_connect_state.
+	GTIDSet string
+}
+
+// Master is an in-process MySQL replication master. Events can be added
+// before and after Start has been called. Running dump streams receive new
+// events immediately. All methods are safe for concurrent use.
+type Master struct {
+	// Flavor is either mysql.MySQLFlavor or mysql.MariaDBFlavor and defines
+	// the GTID format. Default mysql.
+	Flavor string
+	// ServerID gets written into the event headers. Default 1.
+	ServerID uint32
+	// ServerVersion gets announced in the handshake and written into the
+	// format description event.
+	ServerVersion string
+	// User and Password, if User is not empty, get checked during the
+	// handshake. Otherwise all clients are allowed to connect.
+	User     string
+	Password string
+	// SemiSync enables the rpl_semi_sync_master_enabled variable. Slaves
+	// requesting semi sync must acknowledge each commit event.
+	SemiSync bool
+	// Variables get returned by SHOW VARIABLES and overwrite the default
+	// variables. The names must be lower case.
+	Variables map[string]string
+	// QueryHandler, if set, gets called for all queries which the Master
+	// cannot answer itself.
+	QueryHandler func(query string) (*Result, error)
+	Log          log.Logger
+
+	mu         sync.Mutex
+	cond       *sync.Cond
+	ln         net.Listener
+	closed     bool
+	conns      map[*conn]struct{}
+	lastConnID uint32
+	wg         sync.WaitGroup
+
+	files    []*binlogFile
+	tables   map[uint64][]Column
+	executed gtidSet
+	mariadb  map[uint32]mysql.MariadbGTID
+
+	slaves []Slave
+	dumps  []Dump
+	acks   []ddl.MasterStatus
+	ackC   chan struct{}
+}
+
+// NewMaster creates a new MySQL flavored master. Call Start to accept
+// connections.
+func NewMaster() *Master {
+	m := &Master{
+		Flavor:        mysql.MySQLFlavor,
+		ServerID:      1,
+		ServerVersion: "5.7.22-fakemaster",
+		Log:           log.BlackHole{},
+		conns:         make(map[*conn]struct{}),
+		tables:        make(map[uint64][]Column),
+		executed:      make(gtidSet),
+		mariadb:       make(map[uint32]mysql.MariadbGTID),
+		ackC:          make(chan struct{}),
+	}
+	m.cond = sync.NewCond(&m.mu)
+	return m
+}
+
+// NewMariaDBMaster creates a new MariaDB flavored master. Call Start to
+// accept connections.
+func NewMariaDBMaster() *Master {
+	m := NewMaster()
+	m.Flavor = mysql.MariaDBFlavor
+	m.ServerVersion = "10.2.14-MariaDB-fakemaster"
+	return m
+}
+
+// Start listens on a random port of 127.0.0.1 and serves connections in the
+// background.
+func (m *Master) Start() error {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ln != nil {
+		ln.Close()
+		return errors.AlreadyExists.Newf("[fakemaster] Master already started on %q", m.ln.Addr())
+	}
+	if m.closed {
+		ln.Close()
+		return errors.AlreadyClosed.Newf("[fakemaster] Master already closed")
+	}
+	if m.Log == nil {
+		m.Log = log.BlackHole{}
+	}
+	m.ln = ln
+	m.wg.Add(1)
+	go m.accept(ln)
+	return nil
+}
+
+func (m *Master) accept(ln net.Listener) {
+	defer m.wg.Done()
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		m.mu.Lock()
+		if m.closed {
+			m.mu.Unlock()
+			nc.Close()
+			return
+		}
+		m.lastConnID++
+		c := newConn(m, nc, m.lastConnID)
+		m.conns[c] = struct{}{}
+		m.wg.Add(1)
+		m.mu.Unlock()
+
+		go func() {
+			defer m.wg.Done()
+			c.serve()
+			m.mu.Lock()
+			delete(m.conns, c)
+			m.mu.Unlock()
+		}()
+	}
+}
+
+// Addr returns the listening address or an empty string if the Master has
+// not been started.
+func (m *Master) Addr() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ln == nil {
+		return ""
+	}
+	return m.ln.Addr().String()
+}
+
+// HostPort returns host and port of the listening address, suitable for the
+// myreplicator.BinlogSyncerConfig.
+func (m *Master) HostPort() (string, uint16) {
+	host, port, _ := net.SplitHostPort(m.Addr())
+	p, _ := strconv.ParseUint(port, 10, 16)
+	return host, uint16(p)
+}
+
+// DropConnections closes all client connections but keeps the listener
+// running. Slaves can test their reconnect logic with it.
+func (m *Master) DropConnections() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for c := range m.conns {
+		c.nc.Close()
+	}
+	m.cond.Broadcast()
+}
+
+// Close stops the listener, closes all connections and waits until all
+// goroutines have been terminated.
+func (m *Master) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	var err error
+	if m.ln != nil {
+		err = m.ln.Close()
+	}
+	for c := range m.conns {
+		c.nc.Close()
+	}
+	m.cond.Broadcast()
+	m.mu.Unlock()
+
+	m.wg.Wait()
+	return errors.WithStack(err)
+}
+
+// Status returns the current master status like SHOW MASTER STATUS.
+func (m *Master) Status() ddl.MasterStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status()
+}
+
+func (m *Master) status() ddl.MasterStatus {
+	f := m.current()
+	return ddl.MasterStatus{
+		File:            f.name,
+		Position:        uint(len(f.data)),
+		ExecutedGTIDSet: m.executed.String(),
+	}
+}
+
+// ExecutedGTIDSet returns for MySQL the set of all written GTIDs, for example
+// `3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5`, and for MariaDB the binlog
+// position of all domains, for example `0-1-5`.
+func (m *Master) ExecutedGTIDSet() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Flavor == mysql.MariaDBFlavor {
+		return m.mariadbPos()
+	}
+	return m.executed.String()
+}
+
+// Slaves returns all received COM_REGISTER_SLAVE commands.
+func (m *Master) Slaves() []Slave {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Slave(nil), m.slaves...)
+}
+
+// Dumps returns all received binlog dump commands.
+func (m *Master) Dumps() []Dump {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Dump(nil), m.dumps...)
+}
+
+// Acks returns all received semi sync acknowledgements.
+func (m *Master) Acks() []ddl.MasterStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]ddl.MasterStatus(nil), m.acks...)
+}
+
+// WaitAcks blocks until at least n semi sync acknowledgements have been
+// received or the context gets cancelled.
+func (m *Master) WaitAcks(ctx context.Context, n int) ([]ddl.MasterStatus, error) {
+	for {
+		m.mu.Lock()
+		if len(m.acks) >= n {
+			acks := append([]ddl.MasterStatus(nil), m.acks...)
+			m.mu.Unlock()
+			return acks, nil
+		}
+		ackC := m.ackC
+		m.mu.Unlock()
+
+		select {
+		case <-ackC:
+		case <-ctx.Done():
+			return nil, errors.WithStack(ctx.Err())
+		}
+	}
+}
+
+func (m *Master) addAck(ms ddl.MasterStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acks = append(m.acks, ms)
+	close(m.ackC)
+	m.ackC = make(chan struct{})
+}
+
+func (m *Master) addSlave(s Slave) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.slaves = append(m.slaves, s)
+}
+
+func (m *Master) addDump(d Dump) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dumps = append(m.dumps, d)
+}
@@ -0,0 +1,332 @@
+package fakemaster_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/myreplicator"
+	"github.com/corestoreio/pkg/sql/myreplicator/fakemaster"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSID = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+func startMaster(t *testing.T, m *fakemaster.Master) *myreplicator.BinlogSyncer {
+	require.NoError(t, m.Start())
+	host, port := m.HostPort()
+	flavor := mysql.MySQLFlavor
+	if m.Flavor == mysql.MariaDBFlavor {
+		flavor = mysql.MariaDBFlavor
+	}
+	return myreplicator.NewBinlogSyncer(&myreplicator.BinlogSyncerConfig{
+		ServerID:        100,
+		Flavor:          flavor,
+		Host:            host,
+		Port:            port,
+		User:            m.User,
+		Password:        m.Password,
+		Localhost:       "fakeslave",
+		SemiSyncEnabled: m.SemiSync,
+	})
+}
+
+// readEvents reads n events and returns their string representation.
+func readEvents(t *testing.T, s *myreplicator.BinlogStreamer, n int) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var evs []string
+	for i := 0; i < n; i++ {
+		e, err := s.GetEvent(ctx)
+		require.NoError(t, err, "Events so far: %q", evs)
+		switch ev := e.Event.(type) {
+		case *myreplicator.RotateEvent:
+			evs = append(evs, fmt.Sprintf("rotate %s:%d", ev.NextLogName, ev.Position))
+		case *myreplicator.QueryEvent:
+			evs = append(evs, "query "+string(ev.Query))
+		case *myreplicator.GTIDEvent:
+			evs = append(evs, fmt.Sprintf("gtid %d", ev.GNO))
+		case *myreplicator.MariadbGTIDEvent:
+			evs = append(evs, "gtid "+ev.GTID.String())
+		case *myreplicator.TableMapEvent:
+			evs = append(evs, fmt.Sprintf("table %s.%s", ev.Schema, ev.Table))
+		case *myreplicator.RowsEvent:
+			evs = append(evs, fmt.Sprintf("%s %v", e.Header.EventType, ev.Rows))
+		case *myreplicator.XIDEvent:
+			evs = append(evs, fmt.Sprintf("xid %d", ev.XID))
+		default:
+			evs = append(evs, e.Header.EventType.String())
+		}
+	}
+	return evs
+}
+
+func writeTransaction(m *fakemaster.Master, xid uint64, rows ...[]interface{}) ddl.MasterStatus {
+	m.Begin("shop")
+	m.TableMap(7, "shop", "customer",
+		fakemaster.Column{Type: mysql.MYSQL_TYPE_LONG},
+		fakemaster.Column{Type: mysql.MYSQL_TYPE_VARCHAR, Meta: 255, Nullable: true},
+	)
+	m.WriteRows(7, rows...)
+	return m.XID(xid)
+}
+
+func TestMaster_StartSync(t *testing.T) {
+	m := fakemaster.NewMaster()
+	defer m.Close()
+	writeTransaction(m, 11, []interface{}{1, "Gopher"}, []interface{}{2, nil})
+	priceTable := func() {
+		m.TableMap(8, "shop", "price",
+			fakemaster.Column{Type: mysql.MYSQL_TYPE_LONGLONG},
+			fakemaster.Column{Type: mysql.MYSQL_TYPE_DOUBLE, Meta: 8},
+			fakemaster.Column{Type: mysql.MYSQL_TYPE_STRING, Meta: uint16(mysql.MYSQL_TYPE_STRING)<<8 | 3},
+			fakemaster.Column{Type: mysql.MYSQL_TYPE_BLOB, Meta: 2},
+		)
+	}
+	// each rows event ends the statement, so the table map gets repeated
+	priceTable()
+	m.UpdateRows(8, []interface{}{int64(1), 1.5, "EUR", []byte("a")}, []interface{}{int64(1), 2.25, "CHF", []byte("b")})
+	priceTable()
+	m.DeleteRows(8, []interface{}{int64(1), 2.25, "CHF", []byte("b")})
+
+	bs := startMaster(t, m)
+	defer bs.Close()
+
+	s, err := bs.StartSync(ddl.MasterStatus{File: "mysql-bin.000001", Position: 4})
+	require.NoError(t, err)
+	assert.Exactly(t, []string{
+		"rotate mysql-bin.000001:4",
+		"FormatDescriptionEvent",
+		"query BEGIN",
+		"table shop.customer",
+		"WriteRowsEventV2 [[1 Gopher] [2 <nil>]]",
+		"xid 11",
+		"table shop.price",
+		"UpdateRowsEventV2 [[1 1.5 EUR [97]] [1 2.25 CHF [98]]]",
+		"table shop.price",
+		"DeleteRowsEventV2 [[1 2.25 CHF [98]]]",
+	}, readEvents(t, s, 10))
+
+	// events written after the start of the dump get streamed immediately
+	m.Query("shop", "TRUNCATE TABLE customer")
+	assert.Exactly(t, []string{"query TRUNCATE TABLE customer"}, readEvents(t, s, 1))
+
+	// the syncer sends the port of the master
+	_, port := m.HostPort()
+	assert.Exactly(t, []fakemaster.Slave{{ServerID: 100, Host: "fakeslave", Port: port}}, m.Slaves())
+	assert.Exactly(t, []fakemaster.Dump{{
+		ServerID: 100,
+		Position: ddl.MasterStatus{File: "mysql-bin.000001", Position: 4},
+	}}, m.Dumps())
+}
+
+func TestMaster_StartSync_Position(t *testing.T) {
+	m := fakemaster.NewMaster()
+	defer m.Close()
+	writeTransaction(m, 11, []interface{}{1, "Gopher"})
+	m.Rotate()
+	start := m.Query("shop", "CREATE TABLE a (id INT)")
+	m.Query("shop", "CREATE TABLE b (id INT)")
+
+	bs := startMaster(t, m)
+	defer bs.Close()
+
+	s, err := bs.StartSync(start)
+	require.NoError(t, err)
+	assert.Exactly(t, []string{
+		fmt.Sprintf("rotate mysql-bin.000002:%d", start.Position),
+		"FormatDescriptionEvent",
+		"query CREATE TABLE b (id INT)",
+	}, readEvents(t, s, 3))
+}
+
+func TestMaster_StartSync_Reconnect(t *testing.T) {
+	m := fakemaster.NewMaster()
+	defer m.Close()
+	m.Query("shop", "CREATE TABLE a (id INT)")
+
+	bs := startMaster(t, m)
+	defer bs.Close()
+
+	s, err := bs.StartSync(ddl.MasterStatus{File: "mysql-bin.000001"})
+	require.NoError(t, err)
+	assert.Exactly(t, []string{
+		"rotate mysql-bin.000001:4", "FormatDescriptionEvent", "query CREATE TABLE a (id INT)",
+	}, readEvents(t, s, 3))
+
+	m.DropConnections()
+	m.Rotate()
+	m.Query("shop", "CREATE TABLE b (id INT)")
+
+	// the syncer resumes after the last received event
+	evs := readEvents(t, s, 5)
+	assert.Exactly(t, "rotate mysql-bin.000002:4", evs[2])
+	assert.Exactly(t, "query CREATE TABLE b (id INT)", evs[4])
+	assert.Len(t, m.Dumps(), 2)
+}
+
+func TestMaster_StartSyncGTID(t *testing.T) {
+	m := fakemaster.NewMaster()
+	defer m.Close()
+	for i := int64(1); i <= 3; i++ {
+		m.GTID(testSID, i)
+		writeTransaction(m, uint64(i+10), []interface{}{i, "Gopher"})
+	}
+	assert.Exactly(t, testSID+":1-3", m.ExecutedGTIDSet())
+
+	bs := startMaster(t, m)
+	defer bs.Close()
+
+	gset, err := mysql.ParseMysqlGTIDSet(testSID + ":1-2")
+	require.NoError(t, err)
+	s, err := bs.StartSyncGTID(gset)
+	require.NoError(t, err)
+	assert.Exactly(t, []string{
+		"rotate mysql-bin.000001:4",
+		"FormatDescriptionEvent",
+		"gtid 3",
+		"query BEGIN",
+		"table shop.customer",
+		"WriteRowsEventV2 [[3 Gopher]]",
+		"xid 13",
+	}, readEvents(t, s, 7))
+	assert.Exactly(t, testSID+":1-2", m.Dumps()[0].GTIDSet)
+}
+
+func TestMaster_StartSyncGTID_MariaDB(t *testing.T) {
+	m := fakemaster.NewMariaDBMaster()
+	defer m.Close()
+	m.MariadbGTID(0, 1)
+	m.Query("shop", "CREATE TABLE a (id INT)")
+	m.MariadbGTID(0, 2)
+	m.Query("shop", "CREATE TABLE b (id INT)")
+	assert.Exactly(t, "0-1-2", m.ExecutedGTIDSet())
+
+	bs := startMaster(t, m)
+	defer bs.Close()
+
+	gset, err := mysql.ParseMariadbGTIDSet("0-1-1")
+	require.NoError(t, err)
+	s, err := bs.StartSyncGTID(gset)
+	require.NoError(t, err)
+	assert.Exactly(t, []string{
+		"rotate mysql-bin.000001:4",
+		"FormatDescriptionEvent",
+		"gtid 0-1-2",
+		"query CREATE TABLE b (id INT)",
+	}, readEvents(t, s, 4))
+	assert.Exactly(t, "0-1-1", m.Dumps()[0].GTIDSet)
+}
+
+func TestMaster_SemiSync(t *testing.T) {
+	m := fakemaster.NewMaster()
+	defer m.Close()
+	m.SemiSync = true
+	commit1 := writeTransaction(m, 11, []interface{}{1, "Gopher"})
+	ddlPos := m.Query("shop", "CREATE TABLE a (id INT)")
+
+	bs := startMaster(t, m)
+	defer bs.Close()
+
+	s, err := bs.StartSync(ddl.MasterStatus{File: "mysql-bin.000001", Position: 4})
+	require.NoError(t, err)
+	readEvents(t, s, 7)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	acks, err := m.WaitAcks(ctx, 2)
+	require.NoError(t, err)
+	assert.Exactly(t, []ddl.MasterStatus{commit1, ddlPos}, acks)
+
+	// streaming continues after the ACKs
+	commit2 := writeTransaction(m, 12, []interface{}{2, "Gopher"})
+	readEvents(t, s, 4)
+	acks, err = m.WaitAcks(ctx, 3)
+	require.NoError(t, err)
+	assert.Exactly(t, commit2, acks[2])
+}
+
+func TestMaster_SQLDriver(t *testing.T) {
+	m := fakemaster.NewMaster()
+	defer m.Close()
+	m.User = "replicator"
+	m.Password = "s3cr3t"
+	m.Variables = map[string]string{"binlog_row_image": "MINIMAL"}
+	m.QueryHandler = func(query string) (*fakemaster.Result, error) {
+		if query == "SELECT 1" {
+			return &fakemaster.Result{Columns: []string{"1"}, Rows: [][]interface{}{{1}}}, nil
+		}
+		return nil, fmt.Errorf("unknown query %q", query)
+	}
+	m.GTID(testSID, 1)
+	status := m.Query("shop", "CREATE TABLE a (id INT)")
+	require.NoError(t, m.Start())
+
+	db, err := sql.Open("mysql", fmt.Sprintf("replicator:s3cr3t@tcp(%s)/", m.Addr()))
+	require.NoError(t, err)
+	defer db.Close()
+
+	var ms ddl.MasterStatus
+	var doDB, ignoreDB string
+	require.NoError(t, db.QueryRow("SHOW MASTER STATUS").Scan(&ms.File, &ms.Position, &doDB, &ignoreDB, &ms.ExecutedGTIDSet))
+	status.ExecutedGTIDSet = testSID + ":1"
+	assert.Exactly(t, status, ms)
+
+	var name, value string
+	require.NoError(t, db.QueryRow("SHOW GLOBAL VARIABLES LIKE 'binlog_row%'").Scan(&name, &value))
+	assert.Exactly(t, "binlog_row_image", name)
+	assert.Exactly(t, "MINIMAL", value)
+
+	var one int
+	require.NoError(t, db.QueryRow("SELECT 1").Scan(&one))
+	assert.Exactly(t, 1, one)
+	_, err = db.Exec("SELECT 2")
+	assert.Contains(t, fmt.Sprint(err), `unknown query "SELECT 2"`)
+
+	db2, err := sql.Open("mysql", fmt.Sprintf("replicator:wrong@tcp(%s)/", m.Addr()))
+	require.NoError(t, err)
+	defer db2.Close()
+	assert.Contains(t, fmt.Sprint(db2.Ping()), "Access denied")
+}
+
+func TestMaster_WriteFiles_LoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fakemaster")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	m := fakemaster.NewMaster()
+	m.GTID(testSID, 5)
+	m.Query("shop", "CREATE TABLE a (id INT)")
+	m.Rotate()
+	m.GTID(testSID, 6)
+	m.Query("shop", "CREATE TABLE b (id INT)")
+	require.NoError(t, m.WriteFiles(dir))
+
+	var qs []string
+	err = myreplicator.NewBinlogFileReader(dir).Read(context.Background(),
+		ddl.MasterStatus{File: "mysql-bin.000001"}, ddl.MasterStatus{},
+		func(e *myreplicator.BinlogEvent) error {
+			if q, ok := e.Event.(*myreplicator.QueryEvent); ok {
+				qs = append(qs, string(q.Query))
+			}
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Exactly(t, []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}, qs)
+
+	m2 := fakemaster.NewMaster()
+	require.NoError(t, m2.LoadFile(filepath.Join(dir, "mysql-bin.000001")))
+	require.NoError(t, m2.LoadFile(filepath.Join(dir, "mysql-bin.000002")))
+	assert.Error(t, m2.LoadFile(filepath.Join(dir, "mysql-bin.000001")), "files must be sorted")
+	assert.Exactly(t, m.Status(), m2.Status())
+	assert.Exactly(t, testSID+":5-6", m2.ExecutedGTIDSet())
+}
@@ -0,0 +1,214 @@
+package myreplicator
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/ddl"
+)
+
+// BinlogFileReader reads binlog files from a local directory, for example
+// written by BinlogSyncer.StartBackup or copied from the server, and emits the
+// same events as a live sync. It allows to replay archived binlogs without a
+// running server.
+type BinlogFileReader struct {
+	// Dir contains the binlog files.
+	Dir string
+	// RawMode parses only the FormatDescriptionEvent and the RotateEvent.
+	RawMode bool
+	Log     log.Logger
+}
+
+// NewBinlogFileReader creates a new reader for the binlog files in dir.
+func NewBinlogFileReader(dir string) *BinlogFileReader {
+	return &BinlogFileReader{
+		Dir: dir,
+		Log: log.BlackHole{},
+	}
+}
+
+// Read parses the binlog files starting at position start and calls onEvent
+// for each event. The position of start must point to the beginning of an event
+// and should point to the beginning of a transaction, otherwise rows events
+// cannot be decoded due to the missing table map events. A start position
+// lower than 4 starts at the beginning of the file. After the end of a file
+// the reader continues with the file of the RotateEvent or with the next file
+// of the same sequence in the directory. The reading stops before the first
+// event starting at or after the stop position. An empty stop file reads all
+// files. Read returns nil once the stop position or the end of the last file
+// has been reached.
+func (r *BinlogFileReader) Read(ctx context.Context, start, stop ddl.MasterStatus, onEvent OnEventFunc) error {
+	file, offset := start.File, int64(start.Position)
+	for file != "" {
+		if stop.File != "" && file > stop.File {
+			return nil
+		}
+		if file != start.File {
+			// The last file might point to a file which has not been archived.
+			if _, err := os.Stat(filepath.Join(r.Dir, file)); os.IsNotExist(err) {
+				return nil
+			}
+		}
+		next, stopped, err := r.readFile(ctx, file, offset, stop, onEvent)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if stopped || (stop.File != "" && file == stop.File) {
+			return nil
+		}
+		if next == "" {
+			if next, err = r.nextFile(file); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		if r.Log.IsDebug() {
+			r.Log.Debug("myreplicator.BinlogFileReader.Read.nextFile", log.String("file", file), log.String("next_file", next))
+		}
+		file, offset = next, 0
+	}
+	return nil
+}
+
+// Stream same as Read but runs in a goroutine and emits the events via the
+// BinlogStreamer. After the last event, GetEvent returns an error whose cause
+// is io.EOF. Canceling the context stops the reader.
+func (r *BinlogFileReader) Stream(ctx context.Context, start, stop ddl.MasterStatus) *BinlogStreamer {
+	s := newBinlogStreamer(r.Log)
+	go func() {
+		err := r.Read(ctx, start, stop, func(e *BinlogEvent) error {
+			select {
+			case s.bleChan <- e:
+				return nil
+			case <-ctx.Done():
+				return errors.WithStack(ctx.Err())
+			}
+		})
+		if err == nil {
+			err = io.EOF
+		}
+		s.closeWithError(errors.Wrap(err, "[myreplicator] BinlogFileReader.Stream"))
+	}()
+	return s
+}
+
+// readFile reads a single binlog file. It returns the name of the next file if
+// the file ends with a RotateEvent and whether the stop position has been
+// reached.
+func (r *BinlogFileReader) readFile(ctx context.Context, name string, offset int64, stop ddl.MasterStatus, onEvent OnEventFunc) (next string, stopped bool, _ error) {
+	f, err := os.Open(filepath.Join(r.Dir, name))
+	if err != nil {
+		return "", false, errors.Wrapf(err, "[myreplicator] BinlogFileReader open %q", name)
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(BinLogFileHeader))
+	if _, err := io.ReadFull(f, magic); err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", false, errors.Wrapf(err, "[myreplicator] BinlogFileReader read %q", name)
+	}
+	if !bytes.Equal(magic, BinLogFileHeader) {
+		return "", false, errors.NotValid.Newf("[myreplicator] %q is not a valid binlog file, the first 4 bytes must be fe'bin'", name)
+	}
+
+	p := NewBinlogParser()
+	p.SetRawMode(r.RawMode)
+
+	pos := int64(len(BinLogFileHeader))
+	header := make([]byte, EventHeaderSize)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", false, errors.WithStack(err)
+		}
+		if stop.File == name && stop.Position > 0 && pos >= int64(stop.Position) {
+			return "", true, nil
+		}
+
+		if _, err := io.ReadFull(f, header); err == io.EOF {
+			return "", false, nil
+		} else if err != nil {
+			return "", false, errors.Wrapf(err, "[myreplicator] BinlogFileReader %q read header at %d", name, pos)
+		}
+		var h EventHeader
+		if err := h.Decode(header); err != nil {
+			return "", false, errors.Wrapf(err, "[myreplicator] BinlogFileReader %q at %d", name, pos)
+		}
+
+		data := make([]byte, h.EventSize)
+		copy(data, header)
+		if _, err := io.ReadFull(f, data[EventHeaderSize:]); err != nil {
+			return "", false, errors.Wrapf(err, "[myreplicator] BinlogFileReader %q read event body at %d", name, pos)
+		}
+		eventPos := pos
+		pos += int64(h.EventSize)
+
+		// The format description at the beginning of the file must always be
+		// parsed, even if the reading starts later.
+		if eventPos < offset && h.EventType != FORMAT_DESCRIPTION_EVENT {
+			if _, err := f.Seek(offset, io.SeekStart); err != nil {
+				return "", false, errors.Wrapf(err, "[myreplicator] BinlogFileReader %q seek to %d", name, offset)
+			}
+			pos = offset
+			continue
+		}
+
+		e, err := p.parse(data)
+		if err != nil {
+			return "", false, errors.Wrapf(err, "[myreplicator] BinlogFileReader %q parse event %s at %d", name, h.EventType, eventPos)
+		}
+		if eventPos >= offset {
+			if err := onEvent(e); err != nil {
+				return "", false, errors.WithStack(err)
+			}
+		}
+		if re, ok := e.Event.(*RotateEvent); ok && h.Timestamp != 0 {
+			// A rotate event ends the file, except the fake ones.
+			return string(re.NextLogName), false, nil
+		}
+	}
+}
+
+// nextFile returns the file following name with the same base name and a
+// higher sequence number, e.g. mysql-bin.000002 after mysql-bin.000001. Returns
+// an empty string if there is no further file.
+func (r *BinlogFileReader) nextFile(name string) (string, error) {
+	base, seq, ok := splitBinlogName(name)
+	if !ok {
+		return "", nil
+	}
+	fis, err := ioutil.ReadDir(r.Dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "[myreplicator] BinlogFileReader read dir %q", r.Dir)
+	}
+	var next string
+	var nextSeq uint64
+	for _, fi := range fis {
+		b, s, ok := splitBinlogName(fi.Name())
+		if !ok || fi.IsDir() || b != base || s <= seq {
+			continue
+		}
+		if next == "" || s < nextSeq {
+			next, nextSeq = fi.Name(), s
+		}
+	}
+	return next, nil
+}
+
+// splitBinlogName splits mysql-bin.000042 into mysql-bin and 42.
+func splitBinlogName(name string) (base string, seq uint64, ok bool) {
+	dot := strings.LastIndexByte(name, '.')
+	if dot < 1 {
+		return "", 0, false
+	}
+	seq, err := strconv.ParseUint(name[dot+1:], 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return name[:dot], seq, true
+}
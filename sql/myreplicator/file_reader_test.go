@@ -0,0 +1,159 @@
+package myreplicator
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBinlogWriter creates binlog files without checksums.
+type testBinlogWriter struct {
+	buf bytes.Buffer
+}
+
+func newTestBinlogWriter() *testBinlogWriter {
+	w := new(testBinlogWriter)
+	w.buf.Write(BinLogFileHeader)
+	fde := make([]byte, 2+50+4+1+40)
+	binary.LittleEndian.PutUint16(fde, 4)
+	copy(fde[2:], "5.5.0-test") // before checksums have been introduced
+	fde[56] = EventHeaderSize
+	w.event(FORMAT_DESCRIPTION_EVENT, fde)
+	return w
+}
+
+// event appends an event and returns its start position.
+func (w *testBinlogWriter) event(et EventType, body []byte) uint32 {
+	start := uint32(w.buf.Len())
+	size := uint32(EventHeaderSize + len(body))
+	h := make([]byte, EventHeaderSize)
+	binary.LittleEndian.PutUint32(h, 1500000000)
+	h[4] = byte(et)
+	binary.LittleEndian.PutUint32(h[5:], 1)
+	binary.LittleEndian.PutUint32(h[9:], size)
+	binary.LittleEndian.PutUint32(h[13:], start+size)
+	w.buf.Write(h)
+	w.buf.Write(body)
+	return start
+}
+
+func (w *testBinlogWriter) query(q string) uint32 {
+	body := make([]byte, 13)
+	body[8] = 2 // schema length
+	body = append(body, "db"...)
+	body = append(body, 0)
+	return w.event(QUERY_EVENT, append(body, q...))
+}
+
+func (w *testBinlogWriter) rotate(next string) uint32 {
+	body := make([]byte, 8)
+	binary.LittleEndian.PutUint64(body, 4)
+	return w.event(ROTATE_EVENT, append(body, next...))
+}
+
+func (w *testBinlogWriter) writeFile(t *testing.T, dir, name string) {
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), w.buf.Bytes(), 0644))
+}
+
+func eventQueries(evs []*BinlogEvent) []string {
+	var qs []string
+	for _, ev := range evs {
+		switch e := ev.Event.(type) {
+		case *QueryEvent:
+			qs = append(qs, string(e.Query))
+		case *RotateEvent:
+			qs = append(qs, "rotate "+string(e.NextLogName))
+		case *FormatDescriptionEvent:
+			qs = append(qs, "format")
+		}
+	}
+	return qs
+}
+
+func TestBinlogFileReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "myreplicator_file_reader")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w1 := newTestBinlogWriter()
+	w1.query("BEGIN")
+	posQ1 := w1.query("INSERT 1")
+	w1.query("COMMIT")
+	w1.rotate("mysql-bin.000002")
+	w1.writeFile(t, dir, "mysql-bin.000001")
+
+	w2 := newTestBinlogWriter()
+	w2.query("INSERT 2")
+	posQ3 := w2.query("INSERT 3")
+	w2.writeFile(t, dir, "mysql-bin.000002")
+
+	// no rotate event, next file gets found via the sequence number
+	w3 := newTestBinlogWriter()
+	w3.query("INSERT 4")
+	w3.writeFile(t, dir, "mysql-bin.000003")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "mysql-bin.index"), []byte("x"), 0644))
+
+	ctx := context.Background()
+	read := func(start, stop ddl.MasterStatus) ([]string, error) {
+		var evs []*BinlogEvent
+		err := NewBinlogFileReader(dir).Read(ctx, start, stop, func(e *BinlogEvent) error {
+			evs = append(evs, e)
+			return nil
+		})
+		return eventQueries(evs), err
+	}
+
+	t.Run("all files", func(t *testing.T) {
+		qs, err := read(ddl.MasterStatus{File: "mysql-bin.000001"}, ddl.MasterStatus{})
+		require.NoError(t, err)
+		assert.Exactly(t, []string{
+			"format", "BEGIN", "INSERT 1", "COMMIT", "rotate mysql-bin.000002",
+			"format", "INSERT 2", "INSERT 3",
+			"format", "INSERT 4",
+		}, qs)
+	})
+
+	t.Run("start and stop position", func(t *testing.T) {
+		qs, err := read(
+			ddl.MasterStatus{File: "mysql-bin.000001", Position: uint(posQ1)},
+			ddl.MasterStatus{File: "mysql-bin.000002", Position: uint(posQ3)},
+		)
+		require.NoError(t, err)
+		assert.Exactly(t, []string{"INSERT 1", "COMMIT", "rotate mysql-bin.000002", "format", "INSERT 2"}, qs)
+	})
+
+	t.Run("stop file", func(t *testing.T) {
+		qs, err := read(ddl.MasterStatus{File: "mysql-bin.000002"}, ddl.MasterStatus{File: "mysql-bin.000002"})
+		require.NoError(t, err)
+		assert.Exactly(t, []string{"format", "INSERT 2", "INSERT 3"}, qs)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		_, err := read(ddl.MasterStatus{File: "mysql-bin.index"}, ddl.MasterStatus{})
+		assert.True(t, errors.NotValid.Match(errors.Cause(err)), "%+v", err)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		s := NewBinlogFileReader(dir).Stream(ctx, ddl.MasterStatus{File: "mysql-bin.000002"}, ddl.MasterStatus{})
+		var evs []*BinlogEvent
+		for {
+			ev, err := s.GetEvent(ctx)
+			if err != nil {
+				assert.Exactly(t, io.EOF, errors.Cause(err), "%+v", err)
+				break
+			}
+			evs = append(evs, ev)
+		}
+		assert.Exactly(t, []string{"format", "INSERT 2", "INSERT 3", "format", "INSERT 4"}, eventQueries(evs))
+	})
+}